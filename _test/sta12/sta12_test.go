@@ -37,7 +37,11 @@ func TestStation12(t *testing.T) {
 		}
 	})
 
-	stmt, err := d.Prepare(`INSERT INTO todos(subject) VALUES(?)`)
+	// TODOService はユーザーの TODO だけを扱う
+	user := &model.User{ID: 1, Username: "station"}
+	ctx := service.ContextWithUser(context.Background(), user)
+
+	stmt, err := d.Prepare(`INSERT INTO todos(subject, owner_id) VALUES(?, ?)`)
 	if err != nil {
 		t.Errorf("ステートメントの作成に失敗しました: %v", err)
 		return
@@ -49,7 +53,7 @@ func TestStation12(t *testing.T) {
 		}
 	})
 
-	_, err = stmt.Exec("todo subject")
+	_, err = stmt.Exec("todo subject", user.ID)
 	if err != nil {
 		t.Errorf("todoの追加に失敗しました: %v", err)
 		return
@@ -84,7 +88,7 @@ func TestStation12(t *testing.T) {
 		tc := tc
 		t.Run(name, func(t *testing.T) {
 			svc := service.NewTODOService(d)
-			got, err := svc.UpdateTODO(context.Background(), tc.ID, tc.Subject, tc.Description)
+			got, err := svc.UpdateTODO(ctx, tc.ID, tc.Subject, tc.Description)
 			switch tc.WantError {
 			case nil:
 				if err != nil {
@@ -113,6 +117,7 @@ func TestStation12(t *testing.T) {
 				ID:          tc.ID,
 				Subject:     tc.Subject,
 				Description: tc.Description,
+				OwnerID:     user.ID,
 				CreatedAt:   time.Now(),
 				UpdatedAt:   time.Now(),
 			}
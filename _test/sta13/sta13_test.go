@@ -2,6 +2,7 @@ package sta13_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/TechBowl-japan/go-stations/db"
 	"github.com/TechBowl-japan/go-stations/handler/router"
+	"github.com/TechBowl-japan/go-stations/service"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
@@ -41,7 +43,20 @@ func TestStation13(t *testing.T) {
 		}
 	})
 
-	stmt, err := todoDB.Prepare(`INSERT INTO todos(subject) VALUES(?)`)
+	// TODO のエンドポイントはログインしたユーザーの TODO だけを扱う
+	userService := service.NewUserService(todoDB)
+	user, err := userService.Register(context.Background(), "station", "password")
+	if err != nil {
+		t.Errorf("ユーザーの作成に失敗しました: %v", err)
+		return
+	}
+	session, err := userService.Login(context.Background(), "station", "password")
+	if err != nil {
+		t.Errorf("ログインに失敗しました: %v", err)
+		return
+	}
+
+	stmt, err := todoDB.Prepare(`INSERT INTO todos(subject, owner_id) VALUES(?, ?)`)
 	if err != nil {
 		t.Errorf("ステートメントの作成に失敗しました: %v", err)
 		return
@@ -54,7 +69,7 @@ func TestStation13(t *testing.T) {
 		}
 	})
 
-	_, err = stmt.Exec("todo subject")
+	_, err = stmt.Exec("todo subject", user.ID)
 	if err != nil {
 		t.Errorf("todoの追加に失敗しました: %v", err)
 		return
@@ -71,11 +86,11 @@ func TestStation13(t *testing.T) {
 		WantHTTPStatusCode int
 	}{
 		"ID is empty": {
-			WantHTTPStatusCode: http.StatusBadRequest,
+			WantHTTPStatusCode: http.StatusUnprocessableEntity,
 		},
 		"Subject is empty": {
 			ID:                 1,
-			WantHTTPStatusCode: http.StatusBadRequest,
+			WantHTTPStatusCode: http.StatusUnprocessableEntity,
 		},
 		"Description is empty": {
 			ID:                 1,
@@ -101,6 +116,7 @@ func TestStation13(t *testing.T) {
 				return
 			}
 			req.Header.Add("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+session.Token)
 			resp, err := http.DefaultClient.Do(req)
 			if err != nil {
 				t.Errorf("リクエストの送信に失敗しました: %v", err)
@@ -143,6 +159,7 @@ func TestStation13(t *testing.T) {
 				"id":          tc.ID,
 				"subject":     tc.Subject,
 				"description": tc.Description,
+				"status":      "open",
 			}
 
 			now := time.Now().UTC()
@@ -49,7 +49,11 @@ func TestStation15(t *testing.T) {
 		}
 	})
 
-	stmt, err := d.Prepare(`INSERT INTO todos(subject, description) VALUES(?, ?)`)
+	// TODOService はユーザーの TODO だけを扱う
+	user := &model.User{ID: 1, Username: "station"}
+	ctx := service.ContextWithUser(context.Background(), user)
+
+	stmt, err := d.Prepare(`INSERT INTO todos(subject, description, owner_id) VALUES(?, ?, ?)`)
 	if err != nil {
 		t.Errorf("データベースのステートメントの作成に失敗しました: %v", err)
 		return
@@ -63,7 +67,8 @@ func TestStation15(t *testing.T) {
 	})
 
 	for _, todo := range []*model.TODO{todos[2], todos[1], todos[0]} {
-		if _, err := stmt.Exec(todo.Subject, todo.Description); err != nil {
+		todo.OwnerID = user.ID
+		if _, err := stmt.Exec(todo.Subject, todo.Description, user.ID); err != nil {
 			t.Errorf("データベースのステートメントの実行に失敗しました: %v", err)
 			return
 		}
@@ -115,7 +120,7 @@ func TestStation15(t *testing.T) {
 		tc := tc
 		t.Run(name, func(t *testing.T) {
 			svc := service.NewTODOService(d)
-			ret, err := svc.ReadTODO(context.Background(), tc.PrevID, tc.Size)
+			ret, err := svc.ReadTODO(ctx, tc.PrevID, tc.Size)
 			if err != nil {
 				t.Errorf("ReadTODOに失敗しました: %v", err)
 				return
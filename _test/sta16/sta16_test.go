@@ -1,6 +1,7 @@
 package sta16_test
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/TechBowl-japan/go-stations/db"
 	"github.com/TechBowl-japan/go-stations/handler/router"
+	"github.com/TechBowl-japan/go-stations/service"
 )
 
 func TestStation16(t *testing.T) {
@@ -36,7 +38,20 @@ func TestStation16(t *testing.T) {
 		}
 	})
 
-	stmt, err := todoDB.Prepare(`INSERT INTO todos(subject) VALUES(?)`)
+	// TODO のエンドポイントはログインしたユーザーの TODO だけを扱う
+	userService := service.NewUserService(todoDB)
+	user, err := userService.Register(context.Background(), "station", "password")
+	if err != nil {
+		t.Errorf("ユーザーの作成に失敗しました: %v", err)
+		return
+	}
+	session, err := userService.Login(context.Background(), "station", "password")
+	if err != nil {
+		t.Errorf("ログインに失敗しました: %v", err)
+		return
+	}
+
+	stmt, err := todoDB.Prepare(`INSERT INTO todos(subject, owner_id) VALUES(?, ?)`)
 	if err != nil {
 		t.Errorf("ステートメントの作成に失敗しました: %v", err)
 		return
@@ -50,7 +65,7 @@ func TestStation16(t *testing.T) {
 	})
 
 	for i := 0; i < 3; i++ {
-		if _, err := stmt.Exec(fmt.Sprintf("todo subject %d", i+1)); err != nil {
+		if _, err := stmt.Exec(fmt.Sprintf("todo subject %d", i+1), user.ID); err != nil {
 			t.Errorf("todoの追加に失敗しました: %v", err)
 			return
 		}
@@ -113,7 +128,13 @@ func TestStation16(t *testing.T) {
 				q = fmt.Sprintf("?size=%d", tc.Size)
 			}
 
-			resp, err := http.Get(srv.URL + "/todos" + q)
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/todos"+q, nil)
+			if err != nil {
+				t.Errorf("リクエストの作成に失敗しました: %v", err)
+				return
+			}
+			req.Header.Set("Authorization", "Bearer "+session.Token)
+			resp, err := http.DefaultClient.Do(req)
 			if err != nil {
 				t.Errorf("リクエストに失敗しました: %v", err)
 				return
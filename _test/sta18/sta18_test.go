@@ -47,7 +47,11 @@ func TestStation18(t *testing.T) {
 		}
 	})
 
-	stmt, err := todoDB.Prepare(`INSERT INTO todos(subject, description) VALUES(?, ?)`)
+	// TODOService はユーザーの TODO だけを扱う
+	user := &model.User{ID: 1, Username: "station"}
+	ctx := service.ContextWithUser(context.Background(), user)
+
+	stmt, err := todoDB.Prepare(`INSERT INTO todos(subject, description, owner_id) VALUES(?, ?, ?)`)
 	if err != nil {
 		t.Errorf("データベースのステートメントの作成に失敗しました: %v", err)
 		return
@@ -61,7 +65,7 @@ func TestStation18(t *testing.T) {
 	})
 
 	for _, todo := range []*model.TODO{todos[2], todos[1], todos[0]} {
-		if _, err = stmt.Exec(todo.Subject, todo.Description); err != nil {
+		if _, err = stmt.Exec(todo.Subject, todo.Description, user.ID); err != nil {
 			t.Errorf("データベースのステートメントの実行に失敗しました: %v", err)
 			return
 		}
@@ -91,7 +95,7 @@ func TestStation18(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			err := service.NewTODOService(todoDB).DeleteTODO(context.Background(), tc.IDs)
+			err := service.NewTODOService(todoDB).DeleteTODO(ctx, tc.IDs)
 
 			switch tc.WantError {
 			case nil:
@@ -2,6 +2,7 @@ package sta19_test
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/TechBowl-japan/go-stations/db"
 	"github.com/TechBowl-japan/go-stations/handler/router"
+	"github.com/TechBowl-japan/go-stations/service"
 )
 
 func TestStation19(t *testing.T) {
@@ -37,7 +39,20 @@ func TestStation19(t *testing.T) {
 		}
 	})
 
-	stmt, err := todoDB.Prepare(`INSERT INTO todos(subject) VALUES(?)`)
+	// TODO のエンドポイントはログインしたユーザーの TODO だけを扱う
+	userService := service.NewUserService(todoDB)
+	user, err := userService.Register(context.Background(), "station", "password")
+	if err != nil {
+		t.Errorf("ユーザーの作成に失敗しました: %v", err)
+		return
+	}
+	session, err := userService.Login(context.Background(), "station", "password")
+	if err != nil {
+		t.Errorf("ログインに失敗しました: %v", err)
+		return
+	}
+
+	stmt, err := todoDB.Prepare(`INSERT INTO todos(subject, owner_id) VALUES(?, ?)`)
 	if err != nil {
 		t.Errorf("ステートメントの作成に失敗しました: %v", err)
 		return
@@ -51,7 +66,7 @@ func TestStation19(t *testing.T) {
 	})
 
 	for i := 0; i < 3; i++ {
-		if _, err := stmt.Exec("sbuject"); err != nil {
+		if _, err := stmt.Exec("sbuject", user.ID); err != nil {
 			t.Errorf("todoの追加に失敗しました: %v", err)
 			return
 		}
@@ -67,7 +82,7 @@ func TestStation19(t *testing.T) {
 	}{
 		"Empty Ids": {
 			IDs:                []string{},
-			WantHTTPStatusCode: http.StatusBadRequest,
+			WantHTTPStatusCode: http.StatusUnprocessableEntity,
 		},
 		"Not found ID": {
 			IDs:                []string{"4"},
@@ -92,6 +107,7 @@ func TestStation19(t *testing.T) {
 				return
 			}
 			req.Header.Add("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+session.Token)
 			resp, err := http.DefaultClient.Do(req)
 			if err != nil {
 				t.Errorf("リクエストの送信に失敗しました: %v", err)
@@ -51,6 +51,10 @@ func TestStation8(t *testing.T) {
 		}
 	})
 
+	// TODOService はユーザーの TODO だけを扱う
+	user := &model.User{ID: 1, Username: "station"}
+	ctx := service.ContextWithUser(context.Background(), user)
+
 	var sqlite3Err sqlite3.Error
 
 	for name, tc := range testcases {
@@ -60,7 +64,7 @@ func TestStation8(t *testing.T) {
 			t.Parallel()
 
 			svc := service.NewTODOService(d)
-			got, err := svc.CreateTODO(context.Background(), tc.Subject, tc.Description)
+			got, err := svc.CreateTODO(ctx, tc.Subject, tc.Description)
 			if err != nil {
 				if !errors.As(err, &sqlite3Err) {
 					t.Errorf("期待していないエラーの Type です, got = %t, want = %+v", err, sqlite3Err)
@@ -75,6 +79,7 @@ func TestStation8(t *testing.T) {
 			want := &model.TODO{
 				Subject:     tc.Subject,
 				Description: tc.Description,
+				OwnerID:     user.ID,
 				CreatedAt:   time.Now(),
 				UpdatedAt:   time.Now(),
 			}
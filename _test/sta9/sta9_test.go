@@ -2,6 +2,7 @@ package sta9_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -15,6 +16,7 @@ import (
 
 	"github.com/TechBowl-japan/go-stations/db"
 	"github.com/TechBowl-japan/go-stations/handler/router"
+	"github.com/TechBowl-japan/go-stations/service"
 )
 
 func TestStation9(t *testing.T) {
@@ -41,6 +43,18 @@ func TestStation9(t *testing.T) {
 		}
 	})
 
+	// TODO のエンドポイントはログインしたユーザーの TODO だけを扱う
+	userService := service.NewUserService(todoDB)
+	if _, err := userService.Register(context.Background(), "station", "password"); err != nil {
+		t.Error("ユーザーの作成に失敗しました。", err)
+		return
+	}
+	session, err := userService.Login(context.Background(), "station", "password")
+	if err != nil {
+		t.Error("ログインに失敗しました。", err)
+		return
+	}
+
 	r := router.NewRouter(todoDB)
 	srv := httptest.NewServer(r)
 	defer srv.Close()
@@ -51,7 +65,7 @@ func TestStation9(t *testing.T) {
 		WantHTTPStatusCode int
 	}{
 		"Subject is empty": {
-			WantHTTPStatusCode: http.StatusBadRequest,
+			WantHTTPStatusCode: http.StatusUnprocessableEntity,
 		},
 		"Description is empty": {
 			Subject:            "todo subject",
@@ -68,8 +82,15 @@ func TestStation9(t *testing.T) {
 		name := name
 		tc := tc
 		t.Run(name, func(t *testing.T) {
-			resp, err := http.Post(srv.URL+"/todos", "application/json",
+			req, err := http.NewRequest(http.MethodPost, srv.URL+"/todos",
 				bytes.NewBufferString(fmt.Sprintf(`{"subject":"%s","description":"%s"}`, tc.Subject, tc.Description)))
+			if err != nil {
+				t.Error("リクエストの作成に失敗しました。", err)
+				return
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+session.Token)
+			resp, err := http.DefaultClient.Do(req)
 			if err != nil {
 				t.Error("リクエストの送信に失敗しました。", err)
 				return
@@ -110,6 +131,7 @@ func TestStation9(t *testing.T) {
 			want := map[string]interface{}{
 				"subject":     tc.Subject,
 				"description": tc.Description,
+				"status":      "open",
 			}
 
 			now := time.Now().UTC()
@@ -1,25 +1,95 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	_ "embed"
+	"errors"
+	"strings"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/TechBowl-japan/go-stations/tracing"
+	"github.com/mattn/go-sqlite3"
 )
 
-//go:embed schema.sql
-var schema string
+// ftsSchema sets up the full-text index of TODOs. FTS5 is only compiled into
+// go-sqlite3 with the sqlite_fts5 build tag, e.g. go build -tags sqlite_fts5.
+// A database indexed this way must always be opened by binaries built with the
+// tag, since the triggers keeping the index in sync need the module, and
+// NewDB refuses to open it otherwise.
+// It is not a migration for the same reason, and is set up after migrating.
+//
+//go:embed fts.sql
+var ftsSchema string
 
-// NewDB returns go-sqlite3 driver based *sql.DB.
+// dsnParams are appended to every database path.
+// Foreign keys are enforced so that deleting a TODO detaches its tags.
+// BEGIN IMMEDIATE makes read-modify-write transactions take the write lock
+// up front, so concurrent writers wait on the busy timeout instead of failing.
+const dsnParams = "_foreign_keys=on&_txlock=immediate&_busy_timeout=5000"
+
+// NewDB returns go-sqlite3 driver based *sql.DB, migrated up to the latest schema.
 func NewDB(path string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", path)
+	db, err := Open(path)
 	if err != nil {
 		return nil, err
 	}
 
-	if _, err := db.Exec(schema); err != nil {
+	m, err := NewMigrator(db, Migrations())
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := setupFTS(db); err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return db, nil
 }
+
+// Open returns go-sqlite3 driver based *sql.DB as it is, without migrating it.
+// Statements run in a traced context are traced, see tracing.OpenDB.
+func Open(path string) (*sql.DB, error) {
+	return tracing.OpenDB(&sqlite3.SQLiteDriver{}, dsn(path)), nil
+}
+
+func dsn(path string) string {
+	if strings.Contains(path, "?") {
+		return path + "&" + dsnParams
+	}
+	return path + "?" + dsnParams
+}
+
+// setupFTS creates the full-text index when FTS5 is available,
+// indexing the TODOs written before the index existed.
+// It fails when the database has the index but FTS5 is not available, since
+// every write to TODOs would fail in the triggers.
+func setupFTS(db *sql.DB) error {
+	var enabled, exists bool
+	if err := db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&enabled); err != nil {
+		return err
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'todos_fts'`).Scan(&exists); err != nil {
+		return err
+	}
+	if !enabled {
+		if exists {
+			return errors.New("the database has the full-text index todos_fts, which needs FTS5: build with -tags sqlite_fts5")
+		}
+		return nil
+	}
+
+	if _, err := db.Exec(ftsSchema); err != nil {
+		return err
+	}
+	if !exists {
+		if _, err := db.Exec(`INSERT INTO todos_fts(todos_fts) VALUES('rebuild')`); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,6 +3,8 @@ package db_test
 import (
 	"errors"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/TechBowl-japan/go-stations/db"
@@ -44,3 +46,29 @@ func TestNewDB(t *testing.T) {
 		})
 	}
 }
+
+func TestNewDBWithoutFTS5(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "todo.db")
+	conn, err := db.Open(path)
+	if err != nil {
+		t.Fatal("failed to open database, err =", err)
+	}
+	defer conn.Close()
+	var enabled bool
+	if err := conn.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&enabled); err != nil {
+		t.Fatal("failed to read compile options, err =", err)
+	}
+	if enabled {
+		t.Skip("FTS5 is compiled in")
+	}
+
+	// FTS5 付きでビルドしたバイナリが作った索引の代わり
+	if _, err := conn.Exec(`CREATE TABLE todos_fts (subject TEXT, description TEXT)`); err != nil {
+		t.Fatal("failed to create table, err =", err)
+	}
+	if _, err := db.NewDB(path); err == nil || !strings.Contains(err.Error(), "sqlite_fts5") {
+		t.Errorf("unexpected error, given = %v", err)
+	}
+}
@@ -0,0 +1,388 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrations returns the migrations of the TODO schema.
+//
+// A migration is a pair of files NNNN_name.up.sql and NNNN_name.down.sql,
+// where NNNN is its version. Migrations are applied in the order of versions
+// and must never be edited once released; add a new one instead.
+func Migrations() fs.FS {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
+
+// A Migration expresses a versioned change of the schema.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Checksum identifies the content of the up migration.
+// Applied migrations are verified with it to detect edits.
+func (m *Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+// A MigrationStatus expresses whether a migration is applied.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	// Modified is true when the applied migration differs from the known one.
+	Modified bool
+	// Unknown is true when the applied migration is not known to this binary,
+	// e.g. the database was migrated by a newer version.
+	Unknown bool
+}
+
+// An ErrChecksumMismatch expresses an applied migration which was edited afterwards.
+type ErrChecksumMismatch struct {
+	Version int64
+	Name    string
+}
+
+func (e *ErrChecksumMismatch) Error() string {
+	return fmt.Sprintf("migration %d %s was modified after it was applied", e.Version, e.Name)
+}
+
+// An ErrUnknownMigration expresses an applied migration this binary does not know.
+type ErrUnknownMigration struct {
+	Version int64
+}
+
+func (e *ErrUnknownMigration) Error() string {
+	return fmt.Sprintf("migration %d is applied but unknown", e.Version)
+}
+
+// An ErrSchemaVersion expresses a database migrated to another version than
+// the latest migration this binary knows.
+type ErrSchemaVersion struct {
+	Version int64
+	Want    int64
+}
+
+func (e *ErrSchemaVersion) Error() string {
+	return fmt.Sprintf("schema version is %d, want %d", e.Version, e.Want)
+}
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// LoadMigrations reads the migrations in the root of fsys, ordered by version.
+func LoadMigrations(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		match := migrationFileName.FindStringSubmatch(e.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", e.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %q: %w", e.Name(), err)
+		}
+		b, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names, %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(b)
+		} else {
+			m.Down = string(b)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d %s needs both up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// A Migrator applies and reverts migrations, recording them in the schema_migrations table.
+// Each migration is applied in its own transaction together with its record.
+type Migrator struct {
+	db         *sql.DB
+	migrations []*Migration
+}
+
+// NewMigrator returns new Migrator applying the migrations in fsys to db.
+func NewMigrator(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}, nil
+}
+
+// appliedMigration is a row of schema_migrations.
+type appliedMigration struct {
+	version   int64
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+func (m *Migrator) init(ctx context.Context) error {
+	const create = `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version    INTEGER  NOT NULL PRIMARY KEY,
+  name       TEXT     NOT NULL,
+  checksum   TEXT     NOT NULL,
+  applied_at DATETIME NOT NULL DEFAULT (DATETIME('now'))
+)`
+	_, err := m.db.ExecContext(ctx, create)
+	return err
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]*appliedMigration, error) {
+	const read = `SELECT version, name, checksum, applied_at FROM schema_migrations`
+	rows, err := m.db.QueryContext(ctx, read)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]*appliedMigration{}
+	for rows.Next() {
+		a := appliedMigration{}
+		if err := rows.Scan(&a.version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[a.version] = &a
+	}
+	return applied, rows.Err()
+}
+
+// verify checks that every applied migration is known and unmodified.
+func (m *Migrator) verify(applied map[int64]*appliedMigration) error {
+	known := map[int64]*Migration{}
+	for _, mig := range m.migrations {
+		known[mig.Version] = mig
+	}
+	versions := make([]int64, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	for _, v := range versions {
+		mig, ok := known[v]
+		if !ok {
+			return &ErrUnknownMigration{Version: v}
+		}
+		if applied[v].checksum != mig.Checksum() {
+			return &ErrChecksumMismatch{Version: v, Name: mig.Name}
+		}
+	}
+	return nil
+}
+
+// Up applies every pending migration and returns those applied.
+// It fails without applying anything when an applied migration is unknown or modified.
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	if err := m.init(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.verify(applied); err != nil {
+		return nil, err
+	}
+
+	var done []*Migration
+	for _, mig := range m.migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		ok, err := m.apply(ctx, mig)
+		if err != nil {
+			return done, fmt.Errorf("failed to apply migration %d %s: %w", mig.Version, mig.Name, err)
+		}
+		if ok {
+			done = append(done, mig)
+		}
+	}
+	return done, nil
+}
+
+// apply applies mig unless another process applied it first.
+func (m *Migrator) apply(ctx context.Context, mig *Migration) (bool, error) {
+	const (
+		exists = `SELECT COUNT(*) > 0 FROM schema_migrations WHERE version = ?`
+		insert = `INSERT INTO schema_migrations(version, name, checksum) VALUES(?, ?, ?)`
+	)
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var ok bool
+	if err := tx.QueryRowContext(ctx, exists, mig.Version).Scan(&ok); err != nil {
+		return false, err
+	}
+	if ok {
+		return false, nil
+	}
+	if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, insert, mig.Version, mig.Name, mig.Checksum()); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns those reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	if err := m.init(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.verify(applied); err != nil {
+		return nil, err
+	}
+
+	var done []*Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		mig := m.migrations[i]
+		if _, ok := applied[mig.Version]; !ok {
+			continue
+		}
+		if err := m.revert(ctx, mig); err != nil {
+			return done, fmt.Errorf("failed to revert migration %d %s: %w", mig.Version, mig.Name, err)
+		}
+		done = append(done, mig)
+	}
+	return done, nil
+}
+
+func (m *Migrator) revert(ctx context.Context, mig *Migration) error {
+	const remove = `DELETE FROM schema_migrations WHERE version = ?`
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, remove, mig.Version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Status returns the status of every known migration, followed by applied but unknown ones.
+func (m *Migrator) Status(ctx context.Context) ([]*MigrationStatus, error) {
+	if err := m.init(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]*MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		s := &MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if a, ok := applied[mig.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = &a.appliedAt
+			s.Modified = a.checksum != mig.Checksum()
+			delete(applied, mig.Version)
+		}
+		statuses = append(statuses, s)
+	}
+	for _, a := range applied {
+		a := a
+		statuses = append(statuses, &MigrationStatus{
+			Version:   a.version,
+			Name:      a.name,
+			Applied:   true,
+			AppliedAt: &a.appliedAt,
+			Unknown:   true,
+		})
+	}
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// Version returns the version of the last applied migration, or 0 when there is none.
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	var version int64
+	err := m.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+// CheckVersion checks that the database is migrated up to the latest known
+// migration, and not past it, failing with ErrSchemaVersion otherwise.
+func (m *Migrator) CheckVersion(ctx context.Context) error {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	var want int64
+	if len(m.migrations) > 0 {
+		want = m.migrations[len(m.migrations)-1].Version
+	}
+	if version != want {
+		return &ErrSchemaVersion{Version: version, Want: want}
+	}
+	return nil
+}
@@ -0,0 +1,377 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/google/go-cmp/cmp"
+)
+
+// testMigrations is a minimal set of migrations; the second one fails halfway
+// through when the table t already has a row with id 1.
+var testMigrations = fstest.MapFS{
+	"0001_create_t.up.sql":   {Data: []byte(`CREATE TABLE t (id INTEGER PRIMARY KEY);`)},
+	"0001_create_t.down.sql": {Data: []byte(`DROP TABLE t;`)},
+	"0002_insert_t.up.sql":   {Data: []byte(`CREATE TABLE u (id INTEGER); INSERT INTO t(id) VALUES(1);`)},
+	"0002_insert_t.down.sql": {Data: []byte(`DROP TABLE u; DELETE FROM t WHERE id = 1;`)},
+	"0010_create_v.up.sql":   {Data: []byte(`CREATE TABLE v (id INTEGER);`)},
+	"0010_create_v.down.sql": {Data: []byte(`DROP TABLE v;`)},
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	d, err := db.Open(filepath.Join(t.TempDir(), "migrate_test.db"))
+	if err != nil {
+		t.Fatal("failed to open database, err =", err)
+	}
+	t.Cleanup(func() {
+		if err := d.Close(); err != nil {
+			t.Error("failed to close database, err =", err)
+		}
+	})
+	return d
+}
+
+func newTestMigrator(t *testing.T, d *sql.DB) *db.Migrator {
+	t.Helper()
+	m, err := db.NewMigrator(d, testMigrations)
+	if err != nil {
+		t.Fatal("failed to create migrator, err =", err)
+	}
+	return m
+}
+
+func versions(migrations []*db.Migration) []int64 {
+	ret := []int64{}
+	for _, m := range migrations {
+		ret = append(ret, m.Version)
+	}
+	return ret
+}
+
+// embeddedVersions returns the versions of the migrations on disk, which must
+// be numbered from 1 without gaps, so that migrations are added without
+// editing tests.
+func embeddedVersions(t *testing.T) []int64 {
+	t.Helper()
+	ups, err := fs.Glob(os.DirFS("migrations"), "*.up.sql")
+	if err != nil {
+		t.Fatal("failed to list migrations, err =", err)
+	}
+	ret := []int64{}
+	for i := range ups {
+		ret = append(ret, int64(i+1))
+	}
+	return ret
+}
+
+func userExists(t *testing.T, d *sql.DB, username string) bool {
+	t.Helper()
+	var ok bool
+	if err := d.QueryRow(`SELECT COUNT(*) > 0 FROM users WHERE username = ?`, username).Scan(&ok); err != nil {
+		t.Fatal("failed to read users, err =", err)
+	}
+	return ok
+}
+
+func tableExists(t *testing.T, d *sql.DB, name string) bool {
+	t.Helper()
+	var ok bool
+	err := d.QueryRow(`SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&ok)
+	if err != nil {
+		t.Fatal("failed to read schema, err =", err)
+	}
+	return ok
+}
+
+func TestLoadMigrations(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		fsys     fstest.MapFS
+		versions []int64
+		wantErr  bool
+	}{
+		"Embedded": {versions: embeddedVersions(t)},
+		"Ordered by version": {fsys: fstest.MapFS{
+			"10_b.up.sql": {Data: []byte("b")}, "10_b.down.sql": {Data: []byte("b")},
+			"9_a.up.sql": {Data: []byte("a")}, "9_a.down.sql": {Data: []byte("a")},
+		}, versions: []int64{9, 10}},
+		"Missing down": {fsys: fstest.MapFS{"1_a.up.sql": {Data: []byte("a")}}, wantErr: true},
+		"Two names": {fsys: fstest.MapFS{
+			"1_a.up.sql": {Data: []byte("a")}, "1_b.down.sql": {Data: []byte("b")},
+		}, wantErr: true},
+		"Invalid name": {fsys: fstest.MapFS{"a.sql": {Data: []byte("a")}}, wantErr: true},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			fsys := db.Migrations()
+			if c.fsys != nil {
+				fsys = c.fsys
+			}
+			migrations, err := db.LoadMigrations(fsys)
+			if c.wantErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("failed to load migrations, err =", err)
+			}
+			if diff := cmp.Diff(c.versions, versions(migrations)); diff != "" {
+				t.Error("unexpected versions, diff =", diff)
+			}
+		})
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("Up and down", func(t *testing.T) {
+		t.Parallel()
+		d := openTestDB(t)
+		m := newTestMigrator(t, d)
+
+		applied, err := m.Up(ctx)
+		if err != nil {
+			t.Fatal("failed to migrate up, err =", err)
+		}
+		if diff := cmp.Diff([]int64{1, 2, 10}, versions(applied)); diff != "" {
+			t.Error("unexpected applied versions, diff =", diff)
+		}
+		if applied, err := m.Up(ctx); err != nil || len(applied) != 0 {
+			t.Errorf("expected nothing to apply, given = %v, err = %v", versions(applied), err)
+		}
+		if v, err := m.Version(ctx); err != nil || v != 10 {
+			t.Errorf("unexpected version, given = %d, err = %v", v, err)
+		}
+		if err := m.CheckVersion(ctx); err != nil {
+			t.Error("unexpected error checking the latest version, err =", err)
+		}
+
+		reverted, err := m.Down(ctx, 2)
+		if err != nil {
+			t.Fatal("failed to migrate down, err =", err)
+		}
+		if diff := cmp.Diff([]int64{10, 2}, versions(reverted)); diff != "" {
+			t.Error("unexpected reverted versions, diff =", diff)
+		}
+		if tableExists(t, d, "u") || tableExists(t, d, "v") || !tableExists(t, d, "t") {
+			t.Error("unexpected tables after migrating down")
+		}
+		var errVersion *db.ErrSchemaVersion
+		if err := m.CheckVersion(ctx); !errors.As(err, &errVersion) || errVersion.Version != 1 || errVersion.Want != 10 {
+			t.Errorf("unexpected error checking an old version, err = %v", err)
+		}
+
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			t.Fatal("failed to read status, err =", err)
+		}
+		var applieds []bool
+		for _, s := range statuses {
+			applieds = append(applieds, s.Applied)
+		}
+		if diff := cmp.Diff([]bool{true, false, false}, applieds); diff != "" {
+			t.Error("unexpected status, diff =", diff)
+		}
+	})
+
+	t.Run("Failed migration is rolled back", func(t *testing.T) {
+		t.Parallel()
+		d := openTestDB(t)
+		m := newTestMigrator(t, d)
+
+		if _, err := m.Up(ctx); err != nil {
+			t.Fatal("failed to migrate up, err =", err)
+		}
+		if _, err := m.Down(ctx, 2); err != nil {
+			t.Fatal("failed to migrate down, err =", err)
+		}
+		// 0002 の INSERT が主キーの重複で失敗するようにする
+		if _, err := d.Exec(`INSERT INTO t(id) VALUES(1)`); err != nil {
+			t.Fatal("failed to insert row, err =", err)
+		}
+
+		if _, err := m.Up(ctx); err == nil {
+			t.Fatal("expected an error")
+		}
+		if tableExists(t, d, "u") {
+			t.Error("statements of the failed migration were not rolled back")
+		}
+		if v, err := m.Version(ctx); err != nil || v != 1 {
+			t.Errorf("unexpected version, given = %d, err = %v", v, err)
+		}
+	})
+
+	t.Run("Modified migration", func(t *testing.T) {
+		t.Parallel()
+		d := openTestDB(t)
+		m := newTestMigrator(t, d)
+
+		if _, err := m.Up(ctx); err != nil {
+			t.Fatal("failed to migrate up, err =", err)
+		}
+		if _, err := d.Exec(`UPDATE schema_migrations SET checksum = 'edited' WHERE version = 2`); err != nil {
+			t.Fatal("failed to edit checksum, err =", err)
+		}
+
+		var errMismatch *db.ErrChecksumMismatch
+		if _, err := m.Up(ctx); !errors.As(err, &errMismatch) || errMismatch.Version != 2 {
+			t.Errorf("unexpected up error, given = %v", err)
+		}
+		if _, err := m.Down(ctx, 1); !errors.As(err, &errMismatch) {
+			t.Errorf("unexpected down error, given = %v", err)
+		}
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			t.Fatal("failed to read status, err =", err)
+		}
+		if !statuses[1].Modified || statuses[0].Modified {
+			t.Error("unexpected modified flags")
+		}
+	})
+
+	t.Run("Unknown migration", func(t *testing.T) {
+		t.Parallel()
+		d := openTestDB(t)
+		m := newTestMigrator(t, d)
+
+		if _, err := m.Up(ctx); err != nil {
+			t.Fatal("failed to migrate up, err =", err)
+		}
+		if _, err := d.Exec(`INSERT INTO schema_migrations(version, name, checksum) VALUES(11, 'newer', '')`); err != nil {
+			t.Fatal("failed to record migration, err =", err)
+		}
+
+		var errUnknown *db.ErrUnknownMigration
+		if _, err := m.Up(ctx); !errors.As(err, &errUnknown) || errUnknown.Version != 11 {
+			t.Errorf("unexpected up error, given = %v", err)
+		}
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			t.Fatal("failed to read status, err =", err)
+		}
+		if last := statuses[len(statuses)-1]; last.Version != 11 || !last.Unknown {
+			t.Errorf("unexpected last status, given = %+v", last)
+		}
+	})
+
+	t.Run("Database created before migrations", func(t *testing.T) {
+		t.Parallel()
+		d := openTestDB(t)
+
+		// schema.sql of the first release
+		const schema = `CREATE TABLE IF NOT EXISTS todos (
+  id          INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  subject     TEXT     NOT NULL,
+  description TEXT     NOT NULL DEFAULT '',
+  created_at  DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at  DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(subject <> '')
+);
+INSERT INTO todos(subject) VALUES('existing');`
+		if _, err := d.Exec(schema); err != nil {
+			t.Fatal("failed to create legacy schema, err =", err)
+		}
+
+		m, err := db.NewMigrator(d, db.Migrations())
+		if err != nil {
+			t.Fatal("failed to create migrator, err =", err)
+		}
+		if _, err := m.Up(ctx); err != nil {
+			t.Fatal("failed to migrate up, err =", err)
+		}
+		var subject, status, owner string
+		if err := d.QueryRow(`SELECT subject, status, users.username FROM todos JOIN users ON users.id = todos.owner_id`).Scan(&subject, &status, &owner); err != nil {
+			t.Fatal("failed to read todo, err =", err)
+		}
+		// 所有者のいない TODO は匿名で共有されず、legacy に引き取られる
+		if subject != "existing" || status != "open" || owner != "legacy" {
+			t.Errorf("unexpected todo, given = %s, %s, %s", subject, status, owner)
+		}
+
+		if _, err := m.Down(ctx, len(embeddedVersions(t))); err != nil {
+			t.Fatal("failed to migrate every migration down, err =", err)
+		}
+		if tableExists(t, d, "todos") || tableExists(t, d, "tags") {
+			t.Error("tables remain after migrating every migration down")
+		}
+	})
+
+	t.Run("Ownerless TODOs with users", func(t *testing.T) {
+		t.Parallel()
+		d := openTestDB(t)
+
+		// ユーザーを導入したマイグレーションまでを先に当てる
+		const usersVersion = 6
+		before := fstest.MapFS{}
+		names, err := fs.Glob(db.Migrations(), "000[1-6]_*.sql")
+		if err != nil {
+			t.Fatal("failed to list migrations, err =", err)
+		}
+		for _, name := range names {
+			data, err := fs.ReadFile(db.Migrations(), name)
+			if err != nil {
+				t.Fatal("failed to read migration, err =", err)
+			}
+			before[name] = &fstest.MapFile{Data: data}
+		}
+		m, err := db.NewMigrator(d, before)
+		if err != nil {
+			t.Fatal("failed to create migrator, err =", err)
+		}
+		if _, err := m.Up(ctx); err != nil {
+			t.Fatal("failed to migrate up, err =", err)
+		}
+		if _, err := d.Exec(`INSERT INTO users(username, password_hash) VALUES('alice', 'hash');
+INSERT INTO todos(subject) VALUES('ownerless');`); err != nil {
+			t.Fatal("failed to insert rows, err =", err)
+		}
+
+		// 既存のユーザーがいても、所有者のいない TODO は legacy に引き取られる
+		m, err = db.NewMigrator(d, db.Migrations())
+		if err != nil {
+			t.Fatal("failed to create migrator, err =", err)
+		}
+		if _, err := m.Up(ctx); err != nil {
+			t.Fatal("failed to migrate up, err =", err)
+		}
+		var owner string
+		if err := d.QueryRow(`SELECT users.username FROM todos JOIN users ON users.id = todos.owner_id`).Scan(&owner); err != nil {
+			t.Fatal("failed to read todo, err =", err)
+		}
+		if owner != "legacy" {
+			t.Errorf("unexpected owner, given = %s", owner)
+		}
+
+		if _, err := m.Down(ctx, len(embeddedVersions(t))-usersVersion); err != nil {
+			t.Fatal("failed to migrate down, err =", err)
+		}
+		var ownerless, users int
+		if err := d.QueryRow(`SELECT COUNT(*) FROM todos WHERE owner_id IS NULL`).Scan(&ownerless); err != nil {
+			t.Fatal("failed to read todos, err =", err)
+		}
+		if err := d.QueryRow(`SELECT COUNT(*) FROM users WHERE username = 'alice'`).Scan(&users); err != nil {
+			t.Fatal("failed to read users, err =", err)
+		}
+		if ownerless != 1 || users != 1 || userExists(t, d, "legacy") {
+			t.Errorf("unexpected rows after migrating down, ownerless todos = %d, users = %d", ownerless, users)
+		}
+	})
+}
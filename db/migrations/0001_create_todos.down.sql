@@ -0,0 +1,2 @@
+DROP TRIGGER trigger_todos_updated_at;
+DROP TABLE todos;
@@ -1,3 +1,4 @@
+-- IF NOT EXISTS adopts databases created before migrations were introduced.
 CREATE TABLE IF NOT EXISTS todos (
   id          INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
   subject     TEXT     NOT NULL,
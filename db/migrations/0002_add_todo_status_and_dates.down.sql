@@ -0,0 +1,7 @@
+DROP INDEX index_todos_due_at;
+DROP INDEX index_todos_status;
+
+ALTER TABLE todos DROP COLUMN remind_at;
+ALTER TABLE todos DROP COLUMN due_at;
+ALTER TABLE todos DROP COLUMN completed_at;
+ALTER TABLE todos DROP COLUMN status;
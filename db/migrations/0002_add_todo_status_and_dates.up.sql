@@ -0,0 +1,8 @@
+ALTER TABLE todos ADD COLUMN status TEXT NOT NULL DEFAULT 'open'
+  CHECK(status IN ('open', 'in_progress', 'done', 'cancelled'));
+ALTER TABLE todos ADD COLUMN completed_at DATETIME;
+ALTER TABLE todos ADD COLUMN due_at DATETIME;
+ALTER TABLE todos ADD COLUMN remind_at DATETIME;
+
+CREATE INDEX index_todos_status ON todos(status);
+CREATE INDEX index_todos_due_at ON todos(due_at);
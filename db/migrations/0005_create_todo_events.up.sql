@@ -0,0 +1,15 @@
+-- before and after are JSON snapshots of the TODO; NULL where there is none,
+-- e.g. before of a create and after of a delete.
+CREATE TABLE todo_events (
+  id          INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  todo_id     INTEGER  NOT NULL REFERENCES todos(id) ON DELETE CASCADE,
+  revision    INTEGER  NOT NULL,
+  action      TEXT     NOT NULL,
+  actor       TEXT     NOT NULL DEFAULT '',
+  reverted_to INTEGER,
+  before      TEXT,
+  after       TEXT,
+  created_at  DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  UNIQUE(todo_id, revision),
+  CHECK(action IN ('create', 'update', 'delete', 'restore', 'revert'))
+);
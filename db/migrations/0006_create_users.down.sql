@@ -0,0 +1,7 @@
+DROP INDEX index_todos_owner_id;
+
+ALTER TABLE todos DROP COLUMN owner_id;
+
+DROP TABLE sessions;
+
+DROP TABLE users;
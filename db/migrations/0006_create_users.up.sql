@@ -0,0 +1,24 @@
+CREATE TABLE users (
+  id            INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  username      TEXT     NOT NULL UNIQUE COLLATE NOCASE,
+  password_hash TEXT     NOT NULL,
+  created_at    DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(username <> '')
+);
+
+-- token_hash is the SHA-256 of the session token, which is never stored.
+CREATE TABLE sessions (
+  token_hash TEXT     NOT NULL PRIMARY KEY,
+  user_id    INTEGER  NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  expires_at DATETIME NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now'))
+);
+
+CREATE INDEX index_sessions_user_id ON sessions(user_id);
+
+-- TODOs created before users existed have no owner_id until the next
+-- migration. The column has no foreign key since SQLite cannot drop a column
+-- referring to another table.
+ALTER TABLE todos ADD COLUMN owner_id INTEGER;
+
+CREATE INDEX index_todos_owner_id ON todos(owner_id);
@@ -0,0 +1,7 @@
+-- The account "legacy" is deleted and its TODOs are left without owner
+-- again. Once an operator set its password, it is kept as a user.
+UPDATE todos
+SET owner_id = NULL
+WHERE owner_id = (SELECT id FROM users WHERE username = 'legacy' AND password_hash = '!');
+
+DELETE FROM users WHERE username = 'legacy' AND password_hash = '!';
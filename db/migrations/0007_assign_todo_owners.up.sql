@@ -0,0 +1,12 @@
+-- TODOs without owner were shared by every anonymous request. Requests must
+-- log in now, so the TODOs left without owner are given to the account
+-- "legacy" made for them, whatever other users there are. The account cannot
+-- log in until an operator sets its password_hash, since "!" is no bcrypt
+-- hash. The migration fails when a user already took the name "legacy".
+INSERT INTO users (username, password_hash)
+SELECT 'legacy', '!'
+WHERE EXISTS (SELECT 1 FROM todos WHERE owner_id IS NULL);
+
+UPDATE todos
+SET owner_id = (SELECT id FROM users WHERE username = 'legacy' AND password_hash = '!')
+WHERE owner_id IS NULL;
@@ -0,0 +1,15 @@
+-- prefix is the public part of a token looked up on authentication, and
+-- token_hash is the SHA-256 of the whole token, which is never stored.
+-- scopes are separated by spaces, as in OAuth 2.0.
+CREATE TABLE api_tokens (
+  id           INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  user_id      INTEGER  NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  name         TEXT     NOT NULL,
+  prefix       TEXT     NOT NULL UNIQUE,
+  token_hash   TEXT     NOT NULL,
+  scopes       TEXT     NOT NULL,
+  last_used_at DATETIME,
+  created_at   DATETIME NOT NULL DEFAULT (DATETIME('now'))
+);
+
+CREATE INDEX index_api_tokens_user_id ON api_tokens(user_id);
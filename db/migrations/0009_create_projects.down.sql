@@ -0,0 +1,7 @@
+DROP INDEX index_todos_project_id;
+
+ALTER TABLE todos DROP COLUMN project_id;
+
+DROP TABLE project_members;
+
+DROP TABLE projects;
@@ -0,0 +1,29 @@
+CREATE TABLE projects (
+  id          INTEGER  NOT NULL PRIMARY KEY AUTOINCREMENT,
+  name        TEXT     NOT NULL,
+  description TEXT     NOT NULL DEFAULT '',
+  created_at  DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  updated_at  DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  CHECK(name <> '')
+);
+
+CREATE TRIGGER trigger_projects_updated_at AFTER UPDATE ON projects
+BEGIN
+  UPDATE projects SET updated_at = DATETIME('now') WHERE id == NEW.id;
+END;
+
+CREATE TABLE project_members (
+  project_id INTEGER  NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+  user_id    INTEGER  NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  role       TEXT     NOT NULL CHECK(role IN ('owner', 'editor', 'viewer')),
+  created_at DATETIME NOT NULL DEFAULT (DATETIME('now')),
+  PRIMARY KEY(project_id, user_id)
+);
+
+CREATE INDEX index_project_members_user_id ON project_members(user_id);
+
+-- TODOs with project_id belong to the project instead of their owner. Like
+-- owner_id, the column has no foreign key so that it can be dropped.
+ALTER TABLE todos ADD COLUMN project_id INTEGER;
+
+CREATE INDEX index_todos_project_id ON todos(project_id);
@@ -3,8 +3,9 @@ module github.com/TechBowl-japan/go-stations
 go 1.16
 
 require (
-	github.com/google/go-cmp v0.5.9 // indirect
+	github.com/google/go-cmp v0.5.9
 	github.com/jstemmer/go-junit-report v0.9.1
 	github.com/mattn/go-sqlite3 v1.14.7
 	github.com/mileusna/useragent v1.3.3
+	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
 )
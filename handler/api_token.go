@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/handler/middleware"
+	"github.com/TechBowl-japan/go-stations/handler/response"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// An APITokenHandler implements the endpoints of the API tokens of the
+// logged in user under /auth/tokens. It must be used inside
+// middleware.Authenticate.
+type APITokenHandler struct {
+	svc *service.UserService
+}
+
+// NewAPITokenHandler returns APITokenHandler based http.Handler.
+func NewAPITokenHandler(svc *service.UserService) *APITokenHandler {
+	return &APITokenHandler{
+		svc: svc,
+	}
+}
+
+// Create handles the endpoint that creates an API token.
+func (h *APITokenHandler) Create(ctx context.Context, req *model.CreateAPITokenRequest) (*model.CreateAPITokenResponse, error) {
+	apiToken, token, err := h.svc.CreateAPIToken(ctx, req.Name, req.Scopes)
+	return &model.CreateAPITokenResponse{APIToken: *apiToken, Token: token}, err
+}
+
+// Read handles the endpoint that reads the API tokens.
+func (h *APITokenHandler) Read(ctx context.Context, req *model.ReadAPITokensRequest) (*model.ReadAPITokensResponse, error) {
+	tokens, err := h.svc.ReadAPITokens(ctx)
+	return &model.ReadAPITokensResponse{APITokens: tokens}, err
+}
+
+// Revoke handles the endpoint that revokes the API token.
+func (h *APITokenHandler) Revoke(ctx context.Context, req *model.RevokeAPITokenRequest) (*model.RevokeAPITokenResponse, error) {
+	return &model.RevokeAPITokenResponse{}, h.svc.RevokeAPIToken(ctx, req.ID)
+}
+
+// ServeHTTP implements http.Handler interface.
+// It serves the collection /auth/tokens and the items /auth/tokens/{id}.
+//
+// API tokens are managed with a login session only, so that a leaked
+// API token cannot be used to make more of them.
+func (h *APITokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	principal, err := middleware.GetPrincipal(r.Context())
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		response.Error(w, r, &model.ErrUnauthorized{Message: "login is required"})
+		return
+	}
+	if principal.APITokenID != 0 {
+		response.Error(w, r, &model.ErrForbidden{Message: "API tokens cannot manage API tokens"})
+		return
+	}
+
+	var res interface{}
+	if r.URL.Path == "/auth/tokens" {
+		switch r.Method {
+		case http.MethodGet:
+			res, err = h.Read(r.Context(), &model.ReadAPITokensRequest{})
+		case http.MethodPost:
+			req := &model.CreateAPITokenRequest{}
+			if err := decodeJSON(r, req); err != nil {
+				response.Error(w, r, err)
+				return
+			}
+			// JWTs may be narrowed to some scopes, which must not be widened
+			for _, scope := range req.Scopes {
+				if !principal.HasScope(scope) {
+					response.Error(w, r, &model.ErrForbidden{Message: "token lacks scope " + scope})
+					return
+				}
+			}
+			res, err = h.Create(r.Context(), req)
+		default:
+			methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+			return
+		}
+	} else if params, ok := matchPath("/auth/tokens/{id}", r.URL.Path); ok {
+		if r.Method != http.MethodDelete {
+			methodNotAllowed(w, r, http.MethodDelete)
+			return
+		}
+		req := &model.RevokeAPITokenRequest{ID: params[0]}
+		if err := req.Validate(); err != nil {
+			response.Error(w, r, err)
+			return
+		}
+		res, err = h.Revoke(r.Context(), req)
+	} else {
+		response.Error(w, r, &model.ErrNotFound{})
+		return
+	}
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, res)
+}
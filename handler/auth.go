@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/handler/middleware"
+	"github.com/TechBowl-japan/go-stations/handler/response"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// An AuthHandler implements the endpoints of accounts and login sessions
+// under /auth/.
+type AuthHandler struct {
+	svc *service.UserService
+}
+
+// NewAuthHandler returns AuthHandler based http.Handler.
+func NewAuthHandler(svc *service.UserService) *AuthHandler {
+	return &AuthHandler{
+		svc: svc,
+	}
+}
+
+// Register handles the endpoint that creates a user.
+func (h *AuthHandler) Register(ctx context.Context, req *model.RegisterRequest) (*model.RegisterResponse, error) {
+	user, err := h.svc.Register(ctx, req.Username, req.Password)
+	return &model.RegisterResponse{User: *user}, err
+}
+
+// Login handles the endpoint that starts a session.
+func (h *AuthHandler) Login(ctx context.Context, req *model.LoginRequest) (*model.LoginResponse, error) {
+	session, err := h.svc.Login(ctx, req.Username, req.Password)
+	return &model.LoginResponse{Session: *session}, err
+}
+
+// Logout handles the endpoint that ends the session.
+func (h *AuthHandler) Logout(ctx context.Context, req *model.LogoutRequest) (*model.LogoutResponse, error) {
+	return &model.LogoutResponse{}, h.svc.Logout(ctx, req.Token)
+}
+
+// ServeHTTP implements http.Handler interface.
+func (h *AuthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var (
+		res interface{}
+		err error
+	)
+	switch r.URL.Path {
+	case "/auth/register":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r, http.MethodPost)
+			return
+		}
+		req := &model.RegisterRequest{}
+		if err := decodeJSON(r, req); err != nil {
+			response.Error(w, r, err)
+			return
+		}
+		res, err = h.Register(r.Context(), req)
+	case "/auth/login":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r, http.MethodPost)
+			return
+		}
+		req := &model.LoginRequest{}
+		if err := decodeJSON(r, req); err != nil {
+			response.Error(w, r, err)
+			return
+		}
+		res, err = h.Login(r.Context(), req)
+	case "/auth/logout":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r, http.MethodPost)
+			return
+		}
+		token, ok := middleware.BearerToken(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			response.Error(w, r, &model.ErrUnauthorized{Message: "a Bearer token is required"})
+			return
+		}
+		res, err = h.Logout(r.Context(), &model.LogoutRequest{Token: token})
+	default:
+		response.Error(w, r, &model.ErrNotFound{})
+		return
+	}
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// setETag sets the ETag header of the response representing todo, if any.
+func setETag(w http.ResponseWriter, todo *model.TODO) {
+	if todo == nil {
+		return
+	}
+	if etag := todo.ETag(); etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+}
+
+// ifMatch returns the ETags of the If-Match headers of r.
+func ifMatch(r *http.Request) []string {
+	return parseETags(r.Header.Values("If-Match"))
+}
+
+// noneMatch reports whether the If-None-Match headers of r match etag, in
+// which case the client already has the representation. If-None-Match uses
+// the weak comparison, so W/ prefixes are ignored.
+func noneMatch(r *http.Request, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, v := range parseETags(r.Header.Values("If-None-Match")) {
+		if v == "*" || strings.TrimPrefix(v, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseETags splits header values listing ETags separated by commas.
+// ETags are opaque quoted strings which never contain commas.
+func parseETags(values []string) []string {
+	var etags []string
+	for _, v := range values {
+		for _, etag := range strings.Split(v, ",") {
+			if etag = strings.TrimSpace(etag); etag != "" {
+				etags = append(etags, etag)
+			}
+		}
+	}
+	return etags
+}
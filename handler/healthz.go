@@ -1,11 +1,11 @@
 package handler
 
 import (
-	"encoding/json"
-	"log"
 	"net/http"
 
+	"github.com/TechBowl-japan/go-stations/handler/response"
 	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
 )
 
 // A HealthzHandler implements health check endpoint.
@@ -19,9 +19,45 @@ func NewHealthzHandler() *HealthzHandler {
 // ServeHTTP implements http.Handler interface.
 func (h *HealthzHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	res := &model.HealthzResponse{Message: "OK"}
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(res); err != nil {
-		log.Println(err)
+	response.JSON(w, http.StatusOK, res)
+}
+
+// A LivezHandler implements the liveness probe, which succeeds as long as
+// the server answers, so that it is only restarted when it hangs.
+type LivezHandler struct{}
+
+// NewLivezHandler returns LivezHandler based http.Handler.
+func NewLivezHandler() *LivezHandler {
+	return &LivezHandler{}
+}
+
+// ServeHTTP implements http.Handler interface.
+func (h *LivezHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, http.StatusOK, &model.ProbeResponse{Status: model.ProbeStatusOK})
+}
+
+// A ReadyzHandler implements the readiness probe, which fails with 503 while
+// a check of the server fails or it is shutting down, so that load balancers
+// send it no requests meanwhile.
+type ReadyzHandler struct {
+	svc *service.HealthService
+}
+
+// NewReadyzHandler returns ReadyzHandler based http.Handler.
+func NewReadyzHandler(svc *service.HealthService) *ReadyzHandler {
+	return &ReadyzHandler{
+		svc: svc,
+	}
+}
+
+// ServeHTTP implements http.Handler interface.
+func (h *ReadyzHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	res := h.svc.Ready(r.Context())
+	status := http.StatusOK
+	if res.Status != model.ProbeStatusOK {
+		status = http.StatusServiceUnavailable
 	}
+	// 失敗したチェックも含めて、チェックごとの結果を返す
+	w.Header().Set("Cache-Control", "no-store")
+	response.JSON(w, status, res)
 }
@@ -1,37 +1,156 @@
 package middleware
 
 import (
+	"bytes"
 	"encoding/json"
-	"log"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
+
+	"github.com/TechBowl-japan/go-stations/logger"
+	"github.com/TechBowl-japan/go-stations/tracing"
+	"github.com/mileusna/useragent"
 )
 
-type accessLog struct {
-	Timestamp time.Time
-	Latency   int64
-	Path      string
-	OS        string
+// An AccessLog expresses a request served, as recorded by AccessLogger.
+type AccessLog struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Latency is how long serving the request took, in milliseconds.
+	Latency    int64  `json:"latency"`
+	Method     string `json:"method"`
+	URI        string `json:"uri"`
+	Path       string `json:"path"`
+	Proto      string `json:"proto"`
+	Status     int    `json:"status"`
+	Bytes      int64  `json:"bytes"`
+	RemoteAddr string `json:"remote_addr"`
+	Referer    string `json:"referer,omitempty"`
+	UserAgent  string `json:"user_agent,omitempty"`
+	OS         string `json:"os,omitempty"`
+	RequestID  string `json:"request_id,omitempty"`
+	// TraceID is the trace of the request, when it is traced.
+	TraceID string `json:"trace_id,omitempty"`
+}
+
+// An AccessLogSink writes access logs somewhere, e.g. to stdout or a file.
+// Write is called from the goroutines of concurrent requests.
+type AccessLogSink interface {
+	Write(l *AccessLog) error
 }
 
-func AccessLogger(h http.Handler) http.Handler {
-	fn := func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
+// AccessLogger returns a middleware writing an access log of every request
+// to each of sinks once the request has been served, or aborted by a panic,
+// e.g. of http.ErrAbortHandler. Failures of sinks are logged, and do not
+// affect the response. It must be used inside SetRequestID for the logs to
+// have the request ID, and inside Trace for them to have the trace ID.
+func AccessLogger(sinks ...AccessLogSink) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := Record(w)
+			defer func() {
+				requestID, _ := GetRequestID(r.Context())
+				al := &AccessLog{
+					Timestamp:  start,
+					Latency:    time.Since(start).Milliseconds(),
+					Method:     r.Method,
+					URI:        r.RequestURI,
+					Path:       r.URL.Path,
+					Proto:      r.Proto,
+					Status:     rw.Status(),
+					Bytes:      rw.Bytes(),
+					RemoteAddr: r.RemoteAddr,
+					Referer:    r.Referer(),
+					UserAgent:  r.UserAgent(),
+					OS:         useragent.Parse(r.UserAgent()).OS,
+					RequestID:  requestID,
+				}
+				if sc := tracing.SpanFromContext(r.Context()).SpanContext(); sc.Sampled {
+					al.TraceID = sc.TraceID.String()
+				}
+				for _, sink := range sinks {
+					if err := sink.Write(al); err != nil {
+						logger.Println(r.Context(), "failed to write access log, err =", err)
+					}
+				}
+			}()
 
-		h.ServeHTTP(w, r)
-		os, _ := GetUserOS(r.Context())
-		al := accessLog{
-			Timestamp: start,
-			Latency:   int64(time.Since(start).Milliseconds()),
-			Path:      r.URL.Path,
-			OS:        os,
+			h.ServeHTTP(rw, r)
 		}
-		bytes, err := json.Marshal(al)
-		if err != nil {
-			log.Println(err)
-			return
+		return http.HandlerFunc(fn)
+	}
+}
+
+// A lineSink writes every access log as a line formatted by format to w.
+// Each line is written with a single call, so that lines of concurrent
+// requests are not interleaved.
+type lineSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format func(buf *bytes.Buffer, l *AccessLog) error
+}
+
+func (s *lineSink) Write(l *AccessLog) error {
+	var buf bytes.Buffer
+	if err := s.format(&buf, l); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.w.Write(buf.Bytes())
+	return err
+}
+
+// NewJSONSink returns an AccessLogSink writing every access log to w as a
+// line of JSON, e.g. to os.Stdout for log collectors.
+func NewJSONSink(w io.Writer) AccessLogSink {
+	return &lineSink{w: w, format: func(buf *bytes.Buffer, l *AccessLog) error {
+		return json.NewEncoder(buf).Encode(l)
+	}}
+}
+
+// NewCombinedSink returns an AccessLogSink writing every access log to w in
+// the Combined Log Format of the Apache HTTP Server, for the tools reading it:
+//
+//	127.0.0.1 - - [10/Oct/2000:13:55:36 +0900] "GET /todos HTTP/1.1" 200 2326 "-" "curl/8.0"
+func NewCombinedSink(w io.Writer) AccessLogSink {
+	return &lineSink{w: w, format: func(buf *bytes.Buffer, l *AccessLog) error {
+		size := "-"
+		if l.Bytes > 0 {
+			size = strconv.FormatInt(l.Bytes, 10)
+		}
+		_, err := fmt.Fprintf(buf, "%s - - [%s] %s %d %s %s %s\n",
+			clientHost(&http.Request{RemoteAddr: l.RemoteAddr}),
+			l.Timestamp.Format("02/Jan/2006:15:04:05 -0700"),
+			combinedQuote(l.Method+" "+l.URI+" "+l.Proto),
+			l.Status, size,
+			combinedQuote(l.Referer), combinedQuote(l.UserAgent))
+		return err
+	}}
+}
+
+// combinedQuote quotes s as the Apache HTTP Server does, with "-" for empty
+// strings, escaping quotes, backslashes and control characters.
+func combinedQuote(s string) string {
+	if s == "" {
+		return `"-"`
+	}
+	var b bytes.Buffer
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
 		}
-		log.Println(string(bytes))
 	}
-	return http.HandlerFunc(fn)
+	b.WriteByte('"')
+	return b.String()
 }
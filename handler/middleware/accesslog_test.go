@@ -0,0 +1,166 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/handler/middleware"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// accessLogRecorder is an AccessLogSink keeping the logs it is given, and
+// failing with err if any.
+type accessLogRecorder struct {
+	err  error
+	mu   sync.Mutex
+	logs []*middleware.AccessLog
+}
+
+func (s *accessLogRecorder) Write(l *middleware.AccessLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs = append(s.logs, l)
+	return s.err
+}
+
+func TestAccessLogger(t *testing.T) {
+	t.Parallel()
+
+	const windows = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"
+	cases := map[string]struct {
+		serve http.HandlerFunc
+		want  middleware.AccessLog
+	}{
+		"Body": {
+			serve: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("hello"))
+			},
+			want: middleware.AccessLog{Status: http.StatusCreated, Bytes: 5},
+		},
+		"Nothing written": {
+			serve: func(w http.ResponseWriter, r *http.Request) {},
+			want:  middleware.AccessLog{Status: http.StatusOK},
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// 書けなかったシンクがあっても、残りのシンクには書く
+			failing := &accessLogRecorder{err: errors.New("disk full")}
+			sink := &accessLogRecorder{}
+			h := middleware.SetRequestID(middleware.AccessLogger(failing, sink)(c.serve))
+
+			req := httptest.NewRequest(http.MethodGet, "/todos?size=1", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.Header.Set("Referer", "https://example.com/")
+			req.Header.Set("User-Agent", windows)
+			req.Header.Set(middleware.RequestIDHeader, "abc")
+			start := time.Now()
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			want := c.want
+			want.Method, want.URI, want.Path, want.Proto = http.MethodGet, "/todos?size=1", "/todos", "HTTP/1.1"
+			want.RemoteAddr, want.Referer, want.UserAgent, want.OS = "192.0.2.1:1234", "https://example.com/", windows, "Windows"
+			want.RequestID = "abc"
+			for _, s := range []*accessLogRecorder{failing, sink} {
+				if len(s.logs) != 1 {
+					t.Fatalf("unexpected number of logs, given = %d", len(s.logs))
+				}
+				got := s.logs[0]
+				if got.Timestamp.Before(start) || got.Latency < 0 {
+					t.Errorf("unexpected time, timestamp = %s, latency = %d", got.Timestamp, got.Latency)
+				}
+				if diff := cmp.Diff(&want, got, cmpopts.IgnoreFields(middleware.AccessLog{}, "Timestamp", "Latency")); diff != "" {
+					t.Error("unexpected log, diff =", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestJSONSink(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	l := &middleware.AccessLog{
+		Timestamp:  time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC),
+		Latency:    12,
+		Method:     http.MethodGet,
+		URI:        "/todos",
+		Path:       "/todos",
+		Proto:      "HTTP/1.1",
+		Status:     http.StatusOK,
+		Bytes:      100,
+		RemoteAddr: "192.0.2.1:1234",
+	}
+	if err := middleware.NewJSONSink(&buf).Write(l); err != nil {
+		t.Fatal("failed to write log, err =", err)
+	}
+	// 空の項目は省く
+	want := `{"timestamp":"2021-01-02T03:04:05Z","latency":12,"method":"GET","uri":"/todos","path":"/todos","proto":"HTTP/1.1","status":200,"bytes":100,"remote_addr":"192.0.2.1:1234"}` + "\n"
+	if buf.String() != want {
+		t.Errorf("unexpected line, want = %s, given = %s", want, buf.String())
+	}
+	var decoded middleware.AccessLog
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || !decoded.Timestamp.Equal(l.Timestamp) {
+		t.Errorf("unexpected decoded log, given = %+v, err = %v", decoded, err)
+	}
+}
+
+func TestCombinedSink(t *testing.T) {
+	t.Parallel()
+
+	timestamp := time.Date(2000, 10, 10, 13, 55, 36, 0, time.FixedZone("JST", 9*60*60))
+	cases := map[string]struct {
+		log  middleware.AccessLog
+		want string
+	}{
+		"Full": {
+			log: middleware.AccessLog{
+				Method: http.MethodGet, URI: "/todos", Proto: "HTTP/1.1", Status: http.StatusOK, Bytes: 2326,
+				RemoteAddr: "127.0.0.1:1234", Referer: "https://example.com/", UserAgent: "curl/8.0",
+			},
+			want: `127.0.0.1 - - [10/Oct/2000:13:55:36 +0900] "GET /todos HTTP/1.1" 200 2326 "https://example.com/" "curl/8.0"` + "\n",
+		},
+		"Empty values": {
+			log: middleware.AccessLog{
+				Method: http.MethodDelete, URI: "/todos", Proto: "HTTP/1.1", Status: http.StatusNoContent,
+				RemoteAddr: "[::1]:1234",
+			},
+			want: `::1 - - [10/Oct/2000:13:55:36 +0900] "DELETE /todos HTTP/1.1" 204 - "-" "-"` + "\n",
+		},
+		// 引用符や制御文字で行を偽造させない
+		"Escaped": {
+			log: middleware.AccessLog{
+				Method: http.MethodGet, URI: "/todos", Proto: "HTTP/1.1", Status: http.StatusOK, Bytes: 1,
+				RemoteAddr: "127.0.0.1:1234", UserAgent: "a\"b\\c\nd\x7f",
+			},
+			want: `127.0.0.1 - - [10/Oct/2000:13:55:36 +0900] "GET /todos HTTP/1.1" 200 1 "-" "a\"b\\c\x0ad\x7f"` + "\n",
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			c.log.Timestamp = timestamp
+			if err := middleware.NewCombinedSink(&buf).Write(&c.log); err != nil {
+				t.Fatal("failed to write log, err =", err)
+			}
+			if buf.String() != c.want {
+				t.Errorf("unexpected line, want = %q, given = %q", c.want, buf.String())
+			}
+		})
+	}
+}
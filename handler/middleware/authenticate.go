@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/handler/response"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+const principalKey contexKey = "Principal"
+
+// BearerToken returns the token of the Bearer scheme in the Authorization header.
+func BearerToken(r *http.Request) (string, bool) {
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") || parts[1] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// Authenticate returns a middleware resolving the session token or API token
+// in the Authorization header into a principal, which is put into the
+// request context, see GetPrincipal. The user of the principal is put there
+// too, see service.ContextWithUser.
+//
+// Requests without Authorization header, and requests with an invalid or
+// expired token, are rejected with 401. Requests already authenticated, e.g.
+// by VerifyJWT, pass through as they are.
+func Authenticate(svc *service.UserService) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if _, err := GetPrincipal(r.Context()); err == nil {
+				h.ServeHTTP(w, r)
+				return
+			}
+			if r.Header.Get("Authorization") == "" {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				response.Error(w, r, &model.ErrUnauthorized{Message: "login is required"})
+				return
+			}
+			token, ok := BearerToken(r)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_request"`)
+				response.Error(w, r, &model.ErrUnauthorized{Message: "Authorization must be a Bearer token"})
+				return
+			}
+			principal, err := svc.Principal(r.Context(), token)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				response.Error(w, r, err)
+				return
+			}
+			h.ServeHTTP(w, withPrincipal(r, principal))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// withPrincipal returns a shallow copy of r whose context has principal and
+// its user.
+func withPrincipal(r *http.Request, principal *model.Principal) *http.Request {
+	ctx := context.WithValue(r.Context(), principalKey, principal)
+	ctx = service.ContextWithUser(ctx, principal.User)
+	return r.WithContext(ctx)
+}
+
+// GetPrincipal returns the principal put into ctx by Authenticate.
+// It fails for requests which did not go through Authenticate.
+func GetPrincipal(ctx context.Context) (*model.Principal, error) {
+	principal, ok := ctx.Value(principalKey).(*model.Principal)
+	if !ok {
+		return nil, fmt.Errorf("principal not found")
+	}
+	return principal, nil
+}
+
+// RequireScope returns a middleware rejecting requests of principals without
+// the scope for their method with 403: read for GET and HEAD, and write for
+// the others. It must be used inside Authenticate, and rejects requests
+// without principal with 401 in case it is not.
+func RequireScope(read, write string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			principal, err := GetPrincipal(r.Context())
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				response.Error(w, r, &model.ErrUnauthorized{Message: "login is required"})
+				return
+			}
+			scope := write
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				scope = read
+			}
+			if !principal.HasScope(scope) {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope="%s"`, scope))
+				response.Error(w, r, &model.ErrForbidden{Message: "token lacks scope " + scope})
+				return
+			}
+			h.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -1,24 +1,330 @@
 package middleware
 
 import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/handler/response"
+	"github.com/TechBowl-japan/go-stations/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Defaults of BasicAuth.
+const (
+	DefaultRealm = "go-stations"
+	// A client is locked out of a username after DefaultLockoutThreshold
+	// failures in a row, first for DefaultLockoutBase and twice as long
+	// after every further failure, up to DefaultLockoutMax.
+	DefaultLockoutThreshold = 5
+	DefaultLockoutBase      = time.Second
+	DefaultLockoutMax       = 15 * time.Minute
 )
 
-func BasicAuth(h http.Handler) http.Handler {
-	uid := os.Getenv("BASIC_AUTH_USER_ID")
-	pw := os.Getenv("BASIC_AUTH_PASSWORD")
-	fn := func(w http.ResponseWriter, r *http.Request) {
-		clientID, clientSecret, ok := r.BasicAuth()
-		if !ok {
-			w.WriteHeader(http.StatusUnauthorized)
+// Credentials are the users BasicAuth lets in, with the bcrypt hashes of
+// their passwords. They are read from a file in the format of htpasswd -B,
+// one user per line:
+//
+//	# comments and blank lines are ignored
+//	alice:$2y$10$...
+//
+// Other hash formats of htpasswd are rejected, since they are too fast to
+// resist guessing. Credentials are safe for concurrent use.
+type Credentials struct {
+	// path is the file the users are read from, or empty for static users.
+	path  string
+	mu    sync.RWMutex
+	users map[string][]byte
+}
+
+// LoadCredentials returns the Credentials read from the file at path.
+func LoadCredentials(path string) (*Credentials, error) {
+	c := &Credentials{path: path}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// StaticCredentials returns the Credentials of a single user with password,
+// which is only kept as its bcrypt hash. An empty username lets nobody in.
+func StaticCredentials(username, password string) (*Credentials, error) {
+	c := &Credentials{users: map[string][]byte{}}
+	if username == "" {
+		return c, nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	c.users[username] = hash
+	return c, nil
+}
+
+// Reload reads the file of c again. The users are only replaced when the
+// whole file is valid, so that a broken edit does not lock everyone out.
+// Static credentials have nothing to reload.
+func (c *Credentials) Reload() error {
+	if c.path == "" {
+		return nil
+	}
+	f, err := os.Open(c.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	users, err := parseHtpasswd(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.path, err)
+	}
+	c.mu.Lock()
+	c.users = users
+	c.mu.Unlock()
+	return nil
+}
+
+// ReloadOn reloads c whenever one of sigs is received until ctx is done,
+// e.g. on SIGHUP. Failures are logged and the previous users are kept.
+func (c *Credentials) ReloadOn(ctx context.Context, sigs ...os.Signal) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	defer signal.Stop(ch)
+
+	for {
+		select {
+		case <-ctx.Done():
 			return
+		case <-ch:
+			if err := c.Reload(); err != nil {
+				log.Println("failed to reload basic auth credentials, err =", err)
+				continue
+			}
+			log.Println("reloaded basic auth credentials from", c.path)
 		}
-		if clientID != uid || clientSecret != pw {
-			w.WriteHeader(http.StatusUnauthorized)
-			return
+	}
+}
+
+// parseHtpasswd parses the lines of an htpasswd file with bcrypt hashes.
+func parseHtpasswd(r io.Reader) (map[string][]byte, error) {
+	users := map[string][]byte{}
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i <= 0 {
+			return nil, fmt.Errorf("line %d: want username:hash", n)
+		}
+		username, hash := line[:i], line[i+1:]
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("line %d: hash of %s is not bcrypt: %w", n, username, err)
+		}
+		if _, ok := users[username]; ok {
+			return nil, fmt.Errorf("line %d: duplicate user %s", n, username)
+		}
+		users[username] = []byte(hash)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// basicAuthDummyHash is compared against for unknown users, so that the
+// response time does not tell whether a username exists.
+var basicAuthDummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// Verify reports whether password is the password of the user with username.
+// It takes as long for unknown users as for wrong passwords, and passwords
+// are compared by bcrypt in constant time.
+func (c *Credentials) Verify(username, password string) bool {
+	c.mu.RLock()
+	hash, ok := c.users[username]
+	c.mu.RUnlock()
+	if !ok {
+		hash = basicAuthDummyHash
+	}
+	err := bcrypt.CompareHashAndPassword(hash, []byte(password))
+	return ok && err == nil
+}
+
+// A BasicAuthOption configures BasicAuth.
+type BasicAuthOption func(*basicAuth)
+
+// WithRealm sets the realm of the WWW-Authenticate challenge, which browsers
+// show when they prompt for credentials.
+func WithRealm(realm string) BasicAuthOption {
+	return func(a *basicAuth) {
+		a.realm = realm
+	}
+}
+
+// WithLockout locks a client out of a username after threshold failures in
+// a row, first for base and twice as long after every further failure, up
+// to max. A threshold of 0 disables the lockout.
+func WithLockout(threshold int, base, max time.Duration) BasicAuthOption {
+	return func(a *basicAuth) {
+		a.lockout.threshold = threshold
+		a.lockout.base = base
+		a.lockout.max = max
+	}
+}
+
+// WithLockoutProxies makes the lockout tell apart the clients behind one of
+// proxies by X-Forwarded-For, the same way as RateLimiter.ClientIP, instead
+// of locking out everyone behind a proxy at once.
+func WithLockoutProxies(proxies ...*net.IPNet) BasicAuthOption {
+	return func(a *basicAuth) {
+		a.trusted = proxies
+	}
+}
+
+type basicAuth struct {
+	creds   *Credentials
+	realm   string
+	lockout *lockout
+	trusted []*net.IPNet
+}
+
+// BasicAuth returns a middleware letting in the requests with the Basic
+// credentials of a user of creds.
+//
+// Requests without valid credentials are answered with 401 and a
+// WWW-Authenticate challenge, so that browsers prompt for them. Clients
+// failing too often are locked out of the username with 429 and
+// Retry-After, without their password being checked.
+func BasicAuth(creds *Credentials, opts ...BasicAuthOption) func(http.Handler) http.Handler {
+	a := &basicAuth{
+		creds: creds,
+		realm: DefaultRealm,
+		lockout: &lockout{
+			threshold: DefaultLockoutThreshold,
+			base:      DefaultLockoutBase,
+			max:       DefaultLockoutMax,
+			failures:  map[string]*failure{},
+			now:       time.Now,
+		},
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	challenge := fmt.Sprintf(`Basic realm=%q, charset="UTF-8"`, a.realm)
+
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			username, password, ok := r.BasicAuth()
+			if !ok {
+				w.Header().Set("WWW-Authenticate", challenge)
+				response.Error(w, r, &model.ErrUnauthorized{Message: "basic auth credentials are required"})
+				return
+			}
+			key := clientIP(r, a.trusted) + "\x00" + username
+			if wait := a.lockout.wait(key); wait > 0 {
+				response.Error(w, r, &model.ErrTooManyRequests{Message: "too many failed attempts", RetryAfter: wait})
+				return
+			}
+			if !a.creds.Verify(username, password) {
+				a.lockout.fail(key)
+				w.Header().Set("WWW-Authenticate", challenge)
+				response.Error(w, r, &model.ErrUnauthorized{Message: "invalid credentials"})
+				return
+			}
+			a.lockout.succeed(key)
+			h.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// A lockout counts the failures in a row per key, a client and a username,
+// so that guessing is slowed down without letting others lock a user out
+// from everywhere.
+type lockout struct {
+	threshold int
+	base, max time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	failures map[string]*failure
+	swept    time.Time
+}
+
+type failure struct {
+	count int
+	last  time.Time
+	until time.Time
+}
+
+// wait returns how long key is still locked out.
+func (l *lockout) wait(key string) time.Duration {
+	if l.threshold <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if f, ok := l.failures[key]; ok {
+		if d := f.until.Sub(l.now()); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// fail records a failure of key, locking it out once there are too many.
+func (l *lockout) fail(key string) {
+	if l.threshold <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	f, ok := l.failures[key]
+	if !ok {
+		f = &failure{}
+		l.failures[key] = f
+	}
+	f.count++
+	f.last = now
+	if n := f.count - l.threshold; n >= 0 {
+		d := l.max
+		if n < 32 && l.base<<uint(n) < l.max {
+			d = l.base << uint(n)
+		}
+		f.until = now.Add(d)
+	}
+}
+
+// succeed forgets the failures of key.
+func (l *lockout) succeed(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.failures, key)
+}
+
+// sweep forgets the failures which ended longer than max ago, at most once
+// every max, so that the failures of clients gone away do not pile up.
+func (l *lockout) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.max {
+		return
+	}
+	l.swept = now
+	for key, f := range l.failures {
+		if now.Sub(f.last) > l.max && now.After(f.until) {
+			delete(l.failures, key)
 		}
-		h.ServeHTTP(w, r)
 	}
-	return http.HandlerFunc(fn)
 }
@@ -0,0 +1,205 @@
+package middleware_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/handler/middleware"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newBasicAuth returns a handler behind BasicAuth letting in alice with the
+// password "secret".
+func newBasicAuth(t *testing.T, opts ...middleware.BasicAuthOption) http.Handler {
+	t.Helper()
+	creds, err := middleware.StaticCredentials("alice", "secret")
+	if err != nil {
+		t.Fatal("failed to create credentials, err =", err)
+	}
+	return middleware.BasicAuth(creds, opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+}
+
+// serveBasicAuth serves a request with the Basic credentials of username and
+// password to h, or without any when username is empty.
+func serveBasicAuth(h http.Handler, username, password string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestBasicAuth(t *testing.T) {
+	t.Parallel()
+
+	h := newBasicAuth(t, middleware.WithRealm("metrics"))
+	cases := map[string]struct {
+		username, password string
+		status             int
+	}{
+		"No credentials": {status: http.StatusUnauthorized},
+		"Wrong password": {username: "alice", password: "wrong", status: http.StatusUnauthorized},
+		"Unknown user":   {username: "bob", password: "secret", status: http.StatusUnauthorized},
+		"Valid":          {username: "alice", password: "secret", status: http.StatusOK},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := serveBasicAuth(h, c.username, c.password)
+			if rec.Code != c.status {
+				t.Errorf("unexpected status, want = %d, given = %d", c.status, rec.Code)
+			}
+			want := ""
+			if c.status == http.StatusUnauthorized {
+				want = `Basic realm="metrics", charset="UTF-8"`
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); got != want {
+				t.Errorf("unexpected challenge, want = %q, given = %q", want, got)
+			}
+		})
+	}
+}
+
+func TestBasicAuthLockout(t *testing.T) {
+	t.Parallel()
+
+	// 締め出しがテスト中に解けないよう、待ち時間を長くする
+	h := newBasicAuth(t, middleware.WithLockout(2, time.Hour, 2*time.Hour))
+
+	// 成功すると失敗の回数は数え直しになる
+	for _, password := range []string{"wrong", "secret", "wrong"} {
+		serveBasicAuth(h, "alice", password)
+	}
+	if rec := serveBasicAuth(h, "alice", "secret"); rec.Code != http.StatusOK {
+		t.Fatalf("locked out before the threshold, status = %d", rec.Code)
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := serveBasicAuth(h, "alice", "wrong"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("unexpected status of failure %d, given = %d", i+1, rec.Code)
+		}
+	}
+	// 締め出し中は正しいパスワードも確かめない
+	rec := serveBasicAuth(h, "alice", "secret")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("unexpected status after the threshold, given = %d", rec.Code)
+	}
+	retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+	if err != nil || retryAfter <= 0 || retryAfter > int(time.Hour/time.Second) {
+		t.Errorf("unexpected Retry-After, given = %q", rec.Header().Get("Retry-After"))
+	}
+
+	// 締め出しはユーザー名ごと
+	if rec := serveBasicAuth(h, "bob", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("unexpected status of another user, given = %d", rec.Code)
+	}
+}
+
+func TestBasicAuthLockoutBehindProxy(t *testing.T) {
+	t.Parallel()
+
+	_, proxy, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatal("failed to parse CIDR, err =", err)
+	}
+	h := newBasicAuth(t, middleware.WithLockout(2, time.Hour, 2*time.Hour), middleware.WithLockoutProxies(proxy))
+	serve := func(client, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", client)
+		req.SetBasicAuth("alice", password)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		serve("192.0.2.1", "wrong")
+	}
+	if rec := serve("192.0.2.1", "secret"); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("unexpected status of the locked out client, given = %d", rec.Code)
+	}
+	// 同じプロキシの向こうでも、別のクライアントは締め出されない
+	if rec := serve("192.0.2.2", "secret"); rec.Code != http.StatusOK {
+		t.Errorf("unexpected status of another client behind the proxy, given = %d", rec.Code)
+	}
+}
+
+func TestBasicAuthWithoutLockout(t *testing.T) {
+	t.Parallel()
+
+	h := newBasicAuth(t, middleware.WithLockout(0, time.Hour, time.Hour))
+	for i := 0; i < middleware.DefaultLockoutThreshold+1; i++ {
+		serveBasicAuth(h, "alice", "wrong")
+	}
+	if rec := serveBasicAuth(h, "alice", "secret"); rec.Code != http.StatusOK {
+		t.Errorf("unexpected status, given = %d", rec.Code)
+	}
+}
+
+func TestCredentialsReload(t *testing.T) {
+	t.Parallel()
+
+	hash := func(password string) string {
+		b, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal("failed to hash password, err =", err)
+		}
+		return string(b)
+	}
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal("failed to write htpasswd, err =", err)
+		}
+	}
+
+	write("# users\n\nalice:" + hash("secret") + "\n")
+	creds, err := middleware.LoadCredentials(path)
+	if err != nil {
+		t.Fatal("failed to load credentials, err =", err)
+	}
+	if !creds.Verify("alice", "secret") || creds.Verify("alice", "wrong") {
+		t.Fatal("unexpected verification of loaded user")
+	}
+
+	// 壊れたファイルは読み込まず、元のユーザーを残す
+	invalid := map[string]string{
+		"No separator":    "alice\n",
+		"No username":     ":" + hash("secret") + "\n",
+		"Not bcrypt":      "alice:$apr1$salt$hash\n",
+		"Plain password":  "alice:secret\n",
+		"Duplicate user":  "bob:" + hash("a") + "\nbob:" + hash("b") + "\n",
+		"One broken line": "bob:" + hash("b") + "\ncarol:{SHA}hash\n",
+	}
+	for name, content := range invalid {
+		write(content)
+		if err := creds.Reload(); err == nil {
+			t.Errorf("%s: expected an error reloading", name)
+		}
+		if !creds.Verify("alice", "secret") || creds.Verify("bob", "b") {
+			t.Errorf("%s: users changed by a failed reload", name)
+		}
+	}
+	if _, err := middleware.LoadCredentials(path); err == nil {
+		t.Error("expected an error loading invalid credentials")
+	}
+
+	write("bob:" + hash("b") + "\n")
+	if err := creds.Reload(); err != nil {
+		t.Fatal("failed to reload credentials, err =", err)
+	}
+	if creds.Verify("alice", "secret") || !creds.Verify("bob", "b") {
+		t.Error("users not replaced by a reload")
+	}
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A CrashReportDir is a PanicReporter writing every panic to a file of its
+// own in a directory, named after the time of the panic, e.g.
+// crash-20240102T150405.000000000Z-123456.txt. Only the newest reports are
+// kept, so that a handler panicking on every request cannot fill the disk.
+type CrashReportDir struct {
+	dir        string
+	maxReports int
+
+	// mu serializes writing reports with pruning old ones.
+	mu sync.Mutex
+}
+
+// Names of crash reports.
+const (
+	crashReportPrefix = "crash-"
+	crashReportSuffix = ".txt"
+)
+
+// NewCrashReportDir returns a new CrashReportDir writing reports into dir,
+// creating it if needed, and keeping maxReports of them. A maxReports of 0
+// keeps every report.
+func NewCrashReportDir(dir string, maxReports int) (*CrashReportDir, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &CrashReportDir{dir: dir, maxReports: maxReports}, nil
+}
+
+// Report implements PanicReporter interface.
+func (d *CrashReportDir) Report(p *Panic) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	pattern := crashReportPrefix + p.Time.UTC().Format("20060102T150405.000000000Z") + "-*" + crashReportSuffix
+	f, err := os.CreateTemp(d.dir, pattern)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "time: %s\nrequest_id: %s\nrequest: %s %s\nwrote_header: %t\n%v\n\n%s",
+		p.Time.Format(time.RFC3339Nano), p.RequestID, p.Method, p.URI, p.WroteHeader, p, p.Stack)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return d.prune()
+}
+
+// prune removes the oldest reports beyond maxReports.
+func (d *CrashReportDir) prune() error {
+	if d.maxReports <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return err
+	}
+	var reports []string
+	for _, e := range entries {
+		if name := e.Name(); !e.IsDir() && strings.HasPrefix(name, crashReportPrefix) && strings.HasSuffix(name, crashReportSuffix) {
+			reports = append(reports, name)
+		}
+	}
+	if len(reports) <= d.maxReports {
+		return nil
+	}
+	// the names start with the time, so that they sort oldest first
+	sort.Strings(reports)
+	for _, name := range reports[:len(reports)-d.maxReports] {
+		if err := os.Remove(filepath.Join(d.dir, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
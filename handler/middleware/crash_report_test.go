@@ -0,0 +1,99 @@
+package middleware_test
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/handler/middleware"
+)
+
+// reportPanics reports n panics a second apart to d, and returns their times.
+func reportPanics(t *testing.T, d *middleware.CrashReportDir, n int) []time.Time {
+	t.Helper()
+	var times []time.Time
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		p := &middleware.Panic{
+			Value:     "boom",
+			Stack:     []byte("goroutine 1 [running]:"),
+			Time:      start.Add(time.Duration(i) * time.Second),
+			RequestID: "abc",
+			Method:    "GET",
+			URI:       "/do-panic",
+		}
+		if err := d.Report(p); err != nil {
+			t.Fatal("failed to report panic, err =", err)
+		}
+		times = append(times, p.Time)
+	}
+	return times
+}
+
+// readDir returns the names of the files in dir in order.
+func readDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal("failed to read dir, err =", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestCrashReportDir(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "crashes")
+	d, err := middleware.NewCrashReportDir(dir, 3)
+	if err != nil {
+		t.Fatal("failed to create crash report dir, err =", err)
+	}
+	// 報告以外のファイルは消さない
+	if err := os.WriteFile(filepath.Join(dir, "README"), nil, 0o644); err != nil {
+		t.Fatal("failed to write file, err =", err)
+	}
+
+	times := reportPanics(t, d, 5)
+	names := readDir(t, dir)
+	if len(names) != 4 || names[0] != "README" {
+		t.Fatalf("unexpected files, given = %v", names)
+	}
+	// 新しい報告だけが残る
+	for i, name := range names[1:] {
+		if want := "crash-" + times[i+2].Format("20060102T150405.000000000Z"); !strings.HasPrefix(name, want) || !strings.HasSuffix(name, ".txt") {
+			t.Errorf("unexpected report, want = %s..., given = %s", want, name)
+		}
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, names[3]))
+	if err != nil {
+		t.Fatal("failed to read report, err =", err)
+	}
+	for _, want := range []string{"request_id: abc\n", "request: GET /do-panic\n", "wrote_header: false\n", "panic: boom\n", "goroutine 1 [running]:"} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("report lacks %q, given = %s", want, b)
+		}
+	}
+}
+
+func TestCrashReportDirWithoutLimit(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	d, err := middleware.NewCrashReportDir(dir, 0)
+	if err != nil {
+		t.Fatal("failed to create crash report dir, err =", err)
+	}
+	reportPanics(t, d, 5)
+	if names := readDir(t, dir); len(names) != 5 {
+		t.Errorf("unexpected number of reports, given = %d", len(names))
+	}
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import "time"
+
+// WithNow makes the RateLimiter read the time from now.
+func WithNow(now func() time.Time) RateLimiterOption {
+	return func(l *RateLimiter) {
+		l.now = now
+	}
+}
+
+// WithMaxBuckets sets how many buckets the RateLimiter keeps at most.
+func WithMaxBuckets(n int) RateLimiterOption {
+	return func(l *RateLimiter) {
+		l.maxBuckets = n
+	}
+}
+
+// Buckets returns how many buckets l keeps.
+func (l *RateLimiter) Buckets() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
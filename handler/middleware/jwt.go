@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/handler/response"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// VerifyJWT returns a middleware resolving the JWT access token in the
+// Authorization header into a principal, the same way as Authenticate.
+// The signature, exp, nbf and aud of the token are checked, and requests
+// failing any of them are rejected with 401.
+//
+// Requests without a JWT pass through untouched, so that Authenticate
+// handles session tokens and API tokens after it.
+func VerifyJWT(svc *service.TokenService) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			token, ok := BearerToken(r)
+			if !ok || !service.IsJWT(token) {
+				h.ServeHTTP(w, r)
+				return
+			}
+			principal, err := svc.Principal(r.Context(), token)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				response.Error(w, r, err)
+				return
+			}
+			h.ServeHTTP(w, withPrincipal(r, principal))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/metrics"
+)
+
+// Metrics returns a middleware counting every request into reg by method,
+// route and status, even when it is aborted by a panic, as http_requests_total, with its latency by method and
+// route, as http_request_duration_seconds, and the requests being served,
+// as http_requests_in_flight.
+//
+// route returns the route of a request, e.g. the pattern of the ServeMux
+// serving it, so that the series do not grow with the paths clients send.
+func Metrics(reg *metrics.Registry, route func(r *http.Request) string) func(http.Handler) http.Handler {
+	requests := reg.Counter("http_requests_total", "HTTP requests served by method, route and status.", "method", "route", "status")
+	latency := reg.Histogram("http_request_duration_seconds", "Latency of HTTP requests by method and route.", metrics.DefaultBuckets, "method", "route")
+	inFlight := reg.Gauge("http_requests_in_flight", "HTTP requests being served.")
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := Record(w)
+			inFlight.Add(1)
+			defer func() {
+				inFlight.Add(-1)
+				method, path := metricsMethod(r.Method), route(r)
+				requests.Inc(method, path, strconv.Itoa(rw.Status()))
+				latency.Observe(time.Since(start).Seconds(), method, path)
+			}()
+
+			h.ServeHTTP(rw, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// metricsMethod returns method when it is a standard one, and "OTHER"
+// otherwise, since clients may send any method.
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
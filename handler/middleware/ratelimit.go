@@ -0,0 +1,313 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/handler/response"
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// A Limit lets Burst requests through at once, refilled evenly over Per,
+// e.g. 60 requests a minute with bursts of 60. A zero Burst means no limit.
+type Limit struct {
+	Burst int
+	Per   time.Duration
+}
+
+// ParseLimit parses a Limit written as burst/period, e.g. "60/1m" or
+// "10/s". "0" or "off" means no limit.
+func ParseLimit(s string) (Limit, error) {
+	if s == "0" || s == "off" {
+		return Limit{}, nil
+	}
+	i := strings.Index(s, "/")
+	if i < 0 {
+		return Limit{}, fmt.Errorf("limit %q must be burst/period", s)
+	}
+	burst, err := strconv.Atoi(s[:i])
+	if err != nil || burst < 0 {
+		return Limit{}, fmt.Errorf("burst of limit %q must be a non-negative integer", s)
+	}
+	period := s[i+1:]
+	if period != "" && strings.IndexAny(period[:1], "0123456789") < 0 {
+		// "10/s" means "10/1s"
+		period = "1" + period
+	}
+	per, err := time.ParseDuration(period)
+	if err != nil || per <= 0 {
+		return Limit{}, fmt.Errorf("period of limit %q must be a positive duration", s)
+	}
+	return Limit{Burst: burst, Per: per}, nil
+}
+
+// ParseTrustedProxies parses a comma separated list of IP addresses and
+// CIDRs, e.g. "10.0.0.0/8,192.0.2.1", into the networks of proxies whose
+// X-Forwarded-For is trusted.
+func ParseTrustedProxies(s string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid proxy address %q", v)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy network %q", v)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// DefaultMaxBuckets is how many buckets a RateLimiter keeps at most.
+const DefaultMaxBuckets = 100000
+
+// A RateLimiterOption configures a RateLimiter.
+type RateLimiterOption func(*RateLimiter)
+
+// WithTrustedProxies makes the RateLimiter take the client address from
+// X-Forwarded-For when the request comes from one of proxies.
+func WithTrustedProxies(proxies ...*net.IPNet) RateLimiterOption {
+	return func(l *RateLimiter) {
+		l.trusted = proxies
+	}
+}
+
+// A RateLimiter limits the requests of every client with token buckets.
+// Clients are told apart by their principal when authenticated, and by their
+// address otherwise. Every route group made by Limit has buckets of its own.
+//
+// At most maxBuckets buckets are kept: once there are more, the least
+// recently used ones are evicted, so that clients spraying addresses cannot
+// exhaust memory. Buckets left idle until they are full again are evicted
+// by SweepEvery as well, since a new bucket is the same as a full one.
+type RateLimiter struct {
+	trusted    []*net.IPNet
+	maxBuckets int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	buckets map[bucketKey]*list.Element
+	// lru holds the buckets, the most recently used first.
+	lru *list.List
+}
+
+type bucketKey struct {
+	group, client string
+}
+
+// A bucket holds the tokens of a client, which were last refilled at last.
+type bucket struct {
+	key    bucketKey
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a new RateLimiter configured by opts.
+func NewRateLimiter(opts ...RateLimiterOption) *RateLimiter {
+	l := &RateLimiter{
+		maxBuckets: DefaultMaxBuckets,
+		now:        time.Now,
+		buckets:    map[bucketKey]*list.Element{},
+		lru:        list.New(),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Limit returns a middleware limiting the requests of the route group to
+// limit per client. It must be used inside Authenticate for authenticated
+// clients to be told apart by their principal.
+//
+// Every response of the group has the RateLimit-Limit, RateLimit-Remaining
+// and RateLimit-Reset headers, the last of which is the seconds until the
+// bucket is full again. Requests over the limit are answered with 429 and
+// Retry-After.
+func (l *RateLimiter) Limit(group string, limit Limit) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		if limit.Burst <= 0 {
+			return h
+		}
+		policy := fmt.Sprintf("%d;w=%d", limit.Burst, ceilSeconds(limit.Per))
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ok, remaining, reset, retry := l.take(bucketKey{group: group, client: l.client(r)}, limit)
+			header := w.Header()
+			header.Set("RateLimit-Policy", policy)
+			header.Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+			header.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+			header.Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(reset), 10))
+			if !ok {
+				response.Error(w, r, &model.ErrTooManyRequests{Message: "rate limit exceeded", RetryAfter: retry})
+				return
+			}
+			h.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// take takes a token from the bucket of key, reporting whether there was
+// one, how many are left, how long until the bucket is full and how long
+// until the next token.
+func (l *RateLimiter) take(key bucketKey, limit Limit) (ok bool, remaining int, reset, retry time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+
+	var b *bucket
+	if e, found := l.buckets[key]; found && e.Value.(*bucket).limit == limit {
+		b = e.Value.(*bucket)
+		l.lru.MoveToFront(e)
+	} else {
+		if found {
+			l.evict(e)
+		}
+		b = &bucket{key: key, limit: limit, tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = l.lru.PushFront(b)
+		for l.lru.Len() > l.maxBuckets {
+			l.evict(l.lru.Back())
+		}
+	}
+	// tokens per nanosecond
+	rate := float64(limit.Burst) / float64(limit.Per)
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+float64(now.Sub(b.last))*rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		ok = true
+	} else {
+		retry = time.Duration((1 - b.tokens) / rate)
+	}
+	reset = time.Duration((float64(limit.Burst) - b.tokens) / rate)
+	return ok, int(b.tokens), reset, retry
+}
+
+// evict removes the bucket of e.
+func (l *RateLimiter) evict(e *list.Element) {
+	delete(l.buckets, e.Value.(*bucket).key)
+	l.lru.Remove(e)
+}
+
+// sweep evicts the buckets which have become full again.
+func (l *RateLimiter) sweep() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	for e := l.lru.Back(); e != nil; {
+		prev := e.Prev()
+		if b := e.Value.(*bucket); now.Sub(b.last) >= b.limit.Per {
+			l.evict(e)
+		}
+		e = prev
+	}
+}
+
+// SweepEvery evicts the buckets which have become full again every
+// interval, starting right away, until ctx is done. Without it, buckets are
+// only evicted once there are more than maxBuckets of them.
+func (l *RateLimiter) SweepEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		l.sweep()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// client returns the key of the client of r: its user when authenticated,
+// or its address otherwise.
+func (l *RateLimiter) client(r *http.Request) string {
+	if p, err := GetPrincipal(r.Context()); err == nil && p.User != nil {
+		return "user:" + strconv.FormatInt(p.User.ID, 10)
+	}
+	return "ip:" + l.ClientIP(r)
+}
+
+// ClientIP returns the address of the client of r, see clientIP.
+func (l *RateLimiter) ClientIP(r *http.Request) string {
+	return clientIP(r, l.trusted)
+}
+
+// clientIP returns the address of the client of r. When r comes from one of
+// the trusted proxies, X-Forwarded-For is read from the right, skipping the
+// trusted proxies appending to it, so that clients cannot choose their
+// address by sending X-Forwarded-For themselves.
+func clientIP(r *http.Request, trusted []*net.IPNet) string {
+	ip := clientHost(r)
+	if !isTrusted(ip, trusted) {
+		return ip
+	}
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			// a malformed hop cannot be told apart from a forged one
+			break
+		}
+		ip = hop
+		if !isTrusted(hop, trusted) {
+			break
+		}
+	}
+	return ip
+}
+
+// clientHost returns the host of the remote address of r.
+func clientHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// isTrusted reports whether ip is the address of one of the trusted proxies.
+func isTrusted(ip string, trusted []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// ceilSeconds returns d in whole seconds, rounded up.
+func ceilSeconds(d time.Duration) int64 {
+	return int64((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,267 @@
+package middleware_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/handler/middleware"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseLimit(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		limit middleware.Limit
+		fails bool
+	}{
+		"60/1m":  {limit: middleware.Limit{Burst: 60, Per: time.Minute}},
+		"10/s":   {limit: middleware.Limit{Burst: 10, Per: time.Second}},
+		"5/1.5h": {limit: middleware.Limit{Burst: 5, Per: 90 * time.Minute}},
+		"0":      {},
+		"off":    {},
+		"60":     {fails: true},
+		"-1/s":   {fails: true},
+		"x/s":    {fails: true},
+		"10/":    {fails: true},
+		"10/0s":  {fails: true},
+		"10/-1s": {fails: true},
+		"10/day": {fails: true},
+	}
+	for s, c := range cases {
+		s, c := s, c
+		t.Run(s, func(t *testing.T) {
+			t.Parallel()
+
+			limit, err := middleware.ParseLimit(s)
+			if c.fails {
+				if err == nil {
+					t.Errorf("expected an error, given = %+v", limit)
+				}
+				return
+			}
+			if err != nil || limit != c.limit {
+				t.Errorf("unexpected limit, want = %+v, given = %+v, err = %v", c.limit, limit, err)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		proxies string
+		want    []string
+		fails   bool
+	}{
+		"Empty":        {},
+		"Addresses":    {proxies: "192.0.2.1, 2001:db8::1", want: []string{"192.0.2.1/32", "2001:db8::1/128"}},
+		"Networks":     {proxies: "10.0.0.0/8,2001:db8::/32", want: []string{"10.0.0.0/8", "2001:db8::/32"}},
+		"Blank items":  {proxies: ",10.0.0.1,,", want: []string{"10.0.0.1/32"}},
+		"Host name":    {proxies: "proxy.example.com", fails: true},
+		"Invalid CIDR": {proxies: "10.0.0.0/33", fails: true},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			nets, err := middleware.ParseTrustedProxies(c.proxies)
+			if c.fails {
+				if err == nil {
+					t.Errorf("expected an error, given = %v", nets)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("failed to parse proxies, err =", err)
+			}
+			var got []string
+			for _, n := range nets {
+				got = append(got, n.String())
+			}
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Error("unexpected networks, diff =", diff)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	t.Parallel()
+
+	proxies, err := middleware.ParseTrustedProxies("10.0.0.0/8,192.0.2.1")
+	if err != nil {
+		t.Fatal("failed to parse proxies, err =", err)
+	}
+	l := middleware.NewRateLimiter(middleware.WithTrustedProxies(proxies...))
+
+	cases := map[string]struct {
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		"Direct":                {remoteAddr: "203.0.113.1:1234", want: "203.0.113.1"},
+		"Untrusted forwarding":  {remoteAddr: "203.0.113.1:1234", forwarded: []string{"198.51.100.1"}, want: "203.0.113.1"},
+		"Trusted proxy":         {remoteAddr: "10.0.0.1:1234", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		"Trusted proxy only":    {remoteAddr: "10.0.0.1:1234", want: "10.0.0.1"},
+		"Chained proxies":       {remoteAddr: "10.0.0.1:1234", forwarded: []string{"198.51.100.1, 192.0.2.1, 10.0.0.2"}, want: "198.51.100.1"},
+		"Headers of each proxy": {remoteAddr: "10.0.0.1:1234", forwarded: []string{"198.51.100.1", "10.0.0.2"}, want: "198.51.100.1"},
+		// クライアントが付けた左端の値は信用しない
+		"Forged hop":           {remoteAddr: "10.0.0.1:1234", forwarded: []string{"1.2.3.4, 198.51.100.1"}, want: "198.51.100.1"},
+		"Malformed hop":        {remoteAddr: "10.0.0.1:1234", forwarded: []string{"198.51.100.1, unknown, 10.0.0.2"}, want: "10.0.0.2"},
+		"Only proxies":         {remoteAddr: "10.0.0.1:1234", forwarded: []string{"10.0.0.3, 10.0.0.2"}, want: "10.0.0.3"},
+		"IPv6":                 {remoteAddr: "[2001:db8::1]:1234", want: "2001:db8::1"},
+		"Address without port": {remoteAddr: "203.0.113.1", want: "203.0.113.1"},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/todos", nil)
+			r.RemoteAddr = c.remoteAddr
+			for _, v := range c.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := l.ClientIP(r); got != c.want {
+				t.Errorf("unexpected ip, want = %s, given = %s", c.want, got)
+			}
+		})
+	}
+}
+
+// fakeClock is a clock moving only when told to.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// serveFrom serves a request from the client at ip to h.
+func serveFrom(h http.Handler, ip string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/todos", nil)
+	r.RemoteAddr = net.JoinHostPort(ip, "1234")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := middleware.NewRateLimiter(middleware.WithNow(clock.Now))
+	h := l.Limit("todos", middleware.Limit{Burst: 2, Per: 2 * time.Second})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// 2 秒で 2 件、つまり 1 秒に 1 件ずつ補充される
+	steps := []struct {
+		after      time.Duration
+		status     int
+		remaining  string
+		reset      string
+		retryAfter string
+	}{
+		{status: http.StatusOK, remaining: "1", reset: "1"},
+		{status: http.StatusOK, remaining: "0", reset: "2"},
+		{status: http.StatusTooManyRequests, remaining: "0", reset: "2", retryAfter: "1"},
+		{after: 500 * time.Millisecond, status: http.StatusTooManyRequests, remaining: "0", reset: "2", retryAfter: "1"},
+		{after: 500 * time.Millisecond, status: http.StatusOK, remaining: "0", reset: "2"},
+		{after: time.Hour, status: http.StatusOK, remaining: "1", reset: "1"},
+	}
+	for i, s := range steps {
+		clock.now = clock.now.Add(s.after)
+		rec := serveFrom(h, "203.0.113.1")
+		header := rec.Header()
+		if rec.Code != s.status {
+			t.Errorf("step %d: unexpected status, want = %d, given = %d", i, s.status, rec.Code)
+		}
+		for name, want := range map[string]string{
+			"RateLimit-Policy":    "2;w=2",
+			"RateLimit-Limit":     "2",
+			"RateLimit-Remaining": s.remaining,
+			"RateLimit-Reset":     s.reset,
+			"Retry-After":         s.retryAfter,
+		} {
+			if got := header.Get(name); got != want {
+				t.Errorf("step %d: unexpected %s, want = %q, given = %q", i, name, want, got)
+			}
+		}
+	}
+
+	// クライアントごと、グループごとにバケツを分ける
+	if rec := serveFrom(h, "203.0.113.2"); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Remaining") != "1" {
+		t.Errorf("unexpected response to another client, status = %d", rec.Code)
+	}
+	other := l.Limit("auth", middleware.Limit{Burst: 2, Per: 2 * time.Second})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if rec := serveFrom(other, "203.0.113.1"); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Remaining") != "1" {
+		t.Errorf("unexpected response of another group, status = %d", rec.Code)
+	}
+}
+
+func TestRateLimitWithoutLimit(t *testing.T) {
+	t.Parallel()
+
+	h := middleware.NewRateLimiter().Limit("todos", middleware.Limit{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for i := 0; i < 3; i++ {
+		if rec := serveFrom(h, "203.0.113.1"); rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "" {
+			t.Errorf("unexpected response, status = %d, RateLimit-Limit = %s", rec.Code, rec.Header().Get("RateLimit-Limit"))
+		}
+	}
+}
+
+func TestRateLimitEviction(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := middleware.NewRateLimiter(middleware.WithNow(clock.Now), middleware.WithMaxBuckets(10))
+	h := l.Limit("todos", middleware.Limit{Burst: 1, Per: time.Hour})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// 使い切ったバケツは、ほかのクライアントに押し出されるまで残る
+	if rec := serveFrom(h, "203.0.113.1"); rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status, given = %d", rec.Code)
+	}
+	for i := 0; i < 9; i++ {
+		serveFrom(h, fmt.Sprintf("198.51.100.%d", i))
+	}
+	if rec := serveFrom(h, "203.0.113.1"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("unexpected status of a limited client, given = %d", rec.Code)
+	}
+
+	// アドレスを変えながら送られても、バケツの数は上限を超えない
+	for i := 0; i < 100; i++ {
+		serveFrom(h, fmt.Sprintf("192.0.2.%d", i))
+		if n := l.Buckets(); n > 10 {
+			t.Fatalf("unexpected number of buckets, want <= 10, given = %d", n)
+		}
+	}
+}
+
+func TestRateLimiterSweepEvery(t *testing.T) {
+	t.Parallel()
+
+	clock := &fakeClock{now: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := middleware.NewRateLimiter(middleware.WithNow(clock.Now))
+	h := l.Limit("todos", middleware.Limit{Burst: 10, Per: 10 * time.Second})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serveFrom(h, "203.0.113.1")
+	clock.now = clock.now.Add(time.Second)
+	serveFrom(h, "203.0.113.2")
+
+	// 満杯に戻ったバケツだけを捨てる
+	clock.now = clock.now.Add(9 * time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l.SweepEvery(ctx, time.Minute)
+	if n := l.Buckets(); n != 1 {
+		t.Errorf("unexpected number of buckets, want = 1, given = %d", n)
+	}
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// A RecordingWriter is an http.ResponseWriter recording the status and the
+// size of the response written through it, for middlewares to report them.
+//
+// It implements http.Flusher and http.Hijacker, whether the writer it wraps
+// does or not, so that wrapping does not hide them from handlers streaming
+// responses or taking over connections. Flush does nothing and Hijack fails
+// when the wrapped writer does not support them.
+type RecordingWriter struct {
+	http.ResponseWriter
+	status   int
+	bytes    int64
+	hijacked bool
+}
+
+// Record returns w as a RecordingWriter, wrapping it unless it is one
+// already, so that nested middlewares share a single record.
+func Record(w http.ResponseWriter) *RecordingWriter {
+	if rw, ok := w.(*RecordingWriter); ok {
+		return rw
+	}
+	return &RecordingWriter{ResponseWriter: w}
+}
+
+// WriteHeader implements http.ResponseWriter interface.
+// Informational statuses but 101 are not recorded, since the final status
+// follows them.
+func (w *RecordingWriter) WriteHeader(status int) {
+	if w.status == 0 && (status >= 200 || status == http.StatusSwitchingProtocols) {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter interface.
+func (w *RecordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher interface.
+func (w *RecordingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker interface.
+func (w *RecordingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the response writer does not support hijacking")
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the wrapped writer, for code looking for the interfaces of
+// the writers under a RecordingWriter.
+func (w *RecordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// WroteHeader reports whether the header of the response has been written,
+// after which the status can no longer change.
+func (w *RecordingWriter) WroteHeader() bool {
+	return w.status != 0 || w.hijacked
+}
+
+// Status returns the status of the response. It is 200 when the handler
+// wrote nothing, as net/http responds then, and 0 when the connection was
+// hijacked.
+func (w *RecordingWriter) Status() int {
+	switch {
+	case w.status != 0:
+		return w.status
+	case w.hijacked:
+		return 0
+	}
+	return http.StatusOK
+}
+
+// Bytes returns the size of the body written so far.
+func (w *RecordingWriter) Bytes() int64 {
+	return w.bytes
+}
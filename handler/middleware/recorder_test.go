@@ -0,0 +1,158 @@
+package middleware_test
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/handler/middleware"
+)
+
+// plainWriter is an http.ResponseWriter which is neither an http.Flusher
+// nor an http.Hijacker.
+type plainWriter struct {
+	header http.Header
+}
+
+func (w *plainWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *plainWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *plainWriter) WriteHeader(status int) {}
+
+// hijackableWriter is an http.ResponseWriter whose connection can be hijacked.
+type hijackableWriter struct {
+	plainWriter
+}
+
+func (w *hijackableWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	server, client := net.Pipe()
+	client.Close()
+	return server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)), nil
+}
+
+func TestRecordingWriter(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		writer      func() http.ResponseWriter
+		serve       func(rw *middleware.RecordingWriter)
+		status      int
+		wroteHeader bool
+		bytes       int64
+	}{
+		"Nothing written": {
+			serve:  func(rw *middleware.RecordingWriter) {},
+			status: http.StatusOK,
+		},
+		"Status": {
+			serve:       func(rw *middleware.RecordingWriter) { rw.WriteHeader(http.StatusCreated) },
+			status:      http.StatusCreated,
+			wroteHeader: true,
+		},
+		"Body": {
+			serve:       func(rw *middleware.RecordingWriter) { rw.Write([]byte("hello")) },
+			status:      http.StatusOK,
+			wroteHeader: true,
+			bytes:       5,
+		},
+		"Status written twice": {
+			serve: func(rw *middleware.RecordingWriter) {
+				rw.WriteHeader(http.StatusNotFound)
+				rw.WriteHeader(http.StatusInternalServerError)
+				rw.Write([]byte("a"))
+				rw.Write([]byte("bc"))
+			},
+			status:      http.StatusNotFound,
+			wroteHeader: true,
+			bytes:       3,
+		},
+		// 1xx の後には本当のステータスが続く
+		"Informational status": {
+			writer: func() http.ResponseWriter { return &plainWriter{header: http.Header{}} },
+			serve:  func(rw *middleware.RecordingWriter) { rw.WriteHeader(http.StatusEarlyHints) },
+			status: http.StatusOK,
+		},
+		"Informational then final status": {
+			writer: func() http.ResponseWriter { return &plainWriter{header: http.Header{}} },
+			serve: func(rw *middleware.RecordingWriter) {
+				rw.WriteHeader(http.StatusContinue)
+				rw.WriteHeader(http.StatusAccepted)
+			},
+			status:      http.StatusAccepted,
+			wroteHeader: true,
+		},
+		"Switching protocols": {
+			serve:       func(rw *middleware.RecordingWriter) { rw.WriteHeader(http.StatusSwitchingProtocols) },
+			status:      http.StatusSwitchingProtocols,
+			wroteHeader: true,
+		},
+		"Flush without write": {
+			serve:       func(rw *middleware.RecordingWriter) { rw.Flush() },
+			status:      http.StatusOK,
+			wroteHeader: true,
+		},
+		"Flush unsupported": {
+			writer: func() http.ResponseWriter { return &plainWriter{header: http.Header{}} },
+			serve:  func(rw *middleware.RecordingWriter) { rw.Flush() },
+			status: http.StatusOK,
+		},
+		"Hijacked": {
+			writer: func() http.ResponseWriter { return &hijackableWriter{plainWriter{header: http.Header{}}} },
+			serve: func(rw *middleware.RecordingWriter) {
+				conn, _, err := rw.Hijack()
+				if err != nil {
+					t.Error("failed to hijack, err =", err)
+					return
+				}
+				conn.Close()
+			},
+			status:      0,
+			wroteHeader: true,
+		},
+		"Hijack unsupported": {
+			serve: func(rw *middleware.RecordingWriter) {
+				if _, _, err := rw.Hijack(); err == nil {
+					t.Error("expected an error hijacking a recorder")
+				}
+			},
+			status: http.StatusOK,
+		},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var w http.ResponseWriter = httptest.NewRecorder()
+			if c.writer != nil {
+				w = c.writer()
+			}
+			rw := middleware.Record(w)
+			c.serve(rw)
+			if rw.Status() != c.status || rw.WroteHeader() != c.wroteHeader || rw.Bytes() != c.bytes {
+				t.Errorf("unexpected record, status = %d, wrote header = %t, bytes = %d", rw.Status(), rw.WroteHeader(), rw.Bytes())
+			}
+		})
+	}
+}
+
+func TestRecord(t *testing.T) {
+	t.Parallel()
+
+	w := httptest.NewRecorder()
+	rw := middleware.Record(w)
+	// 入れ子のミドルウェアは同じ記録を共有する
+	if middleware.Record(rw) != rw {
+		t.Error("a RecordingWriter is wrapped again")
+	}
+	if rw.Unwrap() != w {
+		t.Error("unexpected writer unwrapped")
+	}
+}
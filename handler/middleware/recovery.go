@@ -1,26 +1,95 @@
 package middleware
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/handler/response"
+	"github.com/TechBowl-japan/go-stations/logger"
+	"github.com/TechBowl-japan/go-stations/tracing"
 )
 
-func Recovery(h http.Handler) http.Handler {
-	fn := func(w http.ResponseWriter, r *http.Request) {
-		// TODO: ここに実装をする
-		defer func() {
-			//nilが返ってきた場合はパニックが起こっていない
-			if err := recover(); err != nil {
-				jsonBody, _ := json.Marshal(map[string]string{
-					"error": fmt.Sprintf("%v", err),
-				})
-				w.Header().Set("Content-type", "application/json")
-				w.WriteHeader(http.StatusInternalServerError)
-				w.Write(jsonBody)
-			}
-		}()
-		h.ServeHTTP(w, r)
+// A Panic expresses a panic recovered while serving a request, as reported
+// by Recovery.
+type Panic struct {
+	// Value is the value the handler panicked with.
+	Value     interface{}
+	Stack     []byte
+	Time      time.Time
+	RequestID string
+	Method    string
+	URI       string
+	// WroteHeader is true when the handler had started the response before
+	// it panicked, so that the client got a broken response rather than the
+	// error envelope.
+	WroteHeader bool
+}
+
+func (p *Panic) Error() string {
+	return fmt.Sprintf("panic: %v", p.Value)
+}
+
+// A PanicReporter reports the panics recovered by Recovery somewhere, e.g.
+// to a directory of crash reports or an error tracker. Report is called
+// from the goroutines of concurrent requests.
+type PanicReporter interface {
+	Report(p *Panic) error
+}
+
+// Recovery returns a middleware recovering from panics of handlers, logging
+// them with their stack and reporting them to each of reporters. Clients
+// get the envelope of an internal error, without anything of the panic.
+// When the response had already been started, the connection is aborted
+// instead, so that the client cannot take the response for a whole one.
+//
+// http.ErrAbortHandler is panicked on as it is, since it aborts responses on
+// purpose. It must be used inside SetRequestID for the logs and reports to
+// have the request ID, and inside AccessLogger and Metrics for them to count
+// the panics as 500, and the aborted responses by the status written.
+func Recovery(reporters ...PanicReporter) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			rw := Record(w)
+			defer func() {
+				//nilが返ってきた場合はパニックが起こっていない
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				requestID, _ := GetRequestID(r.Context())
+				p := &Panic{
+					Value:       v,
+					Stack:       debug.Stack(),
+					Time:        time.Now(),
+					RequestID:   requestID,
+					Method:      r.Method,
+					URI:         r.RequestURI,
+					WroteHeader: rw.WroteHeader(),
+				}
+				logger.Printf(r.Context(), "%v serving %s %s\n%s", p, p.Method, p.URI, p.Stack)
+				tracing.SpanFromContext(r.Context()).SetError(p)
+				for _, reporter := range reporters {
+					if err := reporter.Report(p); err != nil {
+						logger.Println(r.Context(), "failed to report panic, err =", err)
+					}
+				}
+				if p.WroteHeader {
+					panic(http.ErrAbortHandler)
+				}
+				// the headers describing the body the handler meant to write
+				// do not describe the envelope
+				for _, key := range []string{"Content-Length", "Content-Encoding", "Content-Disposition", "ETag", "Last-Modified"} {
+					rw.Header().Del(key)
+				}
+				response.Internal(rw)
+			}()
+			h.ServeHTTP(rw, r)
+		}
+		return http.HandlerFunc(fn)
 	}
-	return http.HandlerFunc(fn)
 }
@@ -0,0 +1,156 @@
+package middleware_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/handler/middleware"
+	"github.com/TechBowl-japan/go-stations/metrics"
+)
+
+// panicRecorder is a PanicReporter keeping the panics it is given, and
+// failing with err if any.
+type panicRecorder struct {
+	err    error
+	mu     sync.Mutex
+	panics []*middleware.Panic
+}
+
+func (r *panicRecorder) Report(p *middleware.Panic) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.panics = append(r.panics, p)
+	return r.err
+}
+
+// serveRecovered serves a request to h behind SetRequestID and Recovery
+// with reporters, and returns the response with the value the server would
+// have recovered from, if any.
+func serveRecovered(h http.Handler, reporters ...middleware.PanicReporter) (rec *httptest.ResponseRecorder, recovered interface{}) {
+	req := httptest.NewRequest(http.MethodGet, "/do-panic?x=1", nil)
+	req.Header.Set(middleware.RequestIDHeader, "abc")
+	rec = httptest.NewRecorder()
+	defer func() {
+		recovered = recover()
+	}()
+	middleware.SetRequestID(middleware.Recovery(reporters...)(h)).ServeHTTP(rec, req)
+	return rec, nil
+}
+
+func TestRecovery(t *testing.T) {
+	t.Parallel()
+
+	// 報告に失敗した報告先があっても、残りの報告先には報告する
+	failing := &panicRecorder{err: errors.New("disk full")}
+	reporter := &panicRecorder{}
+	rec, recovered := serveRecovered(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Set("Content-Length", "100")
+		header.Set("ETag", `"etag"`)
+		header.Set("Last-Modified", "Fri, 01 Jan 2021 00:00:00 GMT")
+		header.Set("Cache-Control", "no-store")
+		panic("boom")
+	}), failing, reporter)
+
+	if recovered != nil {
+		t.Fatalf("unexpected panic, given = %v", recovered)
+	}
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("unexpected status, given = %d", rec.Code)
+	}
+	if want := `{"error":{"code":"internal","message":"Internal Server Error"}}`; strings.TrimSpace(rec.Body.String()) != want {
+		t.Errorf("unexpected body, want = %s, given = %s", want, rec.Body)
+	}
+	header := rec.Header()
+	for _, key := range []string{"Content-Length", "ETag", "Last-Modified"} {
+		if v := header.Get(key); v != "" {
+			t.Errorf("%s of the body meant to be written is kept, given = %s", key, v)
+		}
+	}
+	if header.Get("Cache-Control") != "no-store" || header.Get(middleware.RequestIDHeader) != "abc" {
+		t.Errorf("unexpected headers, given = %v", header)
+	}
+
+	for _, r := range []*panicRecorder{failing, reporter} {
+		if len(r.panics) != 1 {
+			t.Fatalf("unexpected number of reports, given = %d", len(r.panics))
+		}
+		p := r.panics[0]
+		if p.Value != "boom" || p.RequestID != "abc" || p.Method != http.MethodGet || p.URI != "/do-panic?x=1" ||
+			p.WroteHeader || p.Time.IsZero() || !strings.Contains(string(p.Stack), "recovery_test.go") {
+			t.Errorf("unexpected report, given = %+v", p)
+		}
+	}
+}
+
+func TestRecoveryAfterWriteHeader(t *testing.T) {
+	t.Parallel()
+
+	reporter := &panicRecorder{}
+	rec, recovered := serveRecovered(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("partial"))
+		panic("boom")
+	}), reporter)
+
+	// 書きかけのレスポンスは完結させず、接続を切らせる
+	if recovered != http.ErrAbortHandler {
+		t.Errorf("unexpected panic, given = %v", recovered)
+	}
+	if rec.Body.String() != "partial" {
+		t.Errorf("unexpected body, given = %s", rec.Body)
+	}
+	if len(reporter.panics) != 1 || !reporter.panics[0].WroteHeader {
+		t.Errorf("unexpected reports, given = %+v", reporter.panics)
+	}
+}
+
+func TestRecoveryErrAbortHandler(t *testing.T) {
+	t.Parallel()
+
+	reporter := &panicRecorder{}
+	_, recovered := serveRecovered(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}), reporter)
+	if recovered != http.ErrAbortHandler {
+		t.Errorf("unexpected panic, given = %v", recovered)
+	}
+	if len(reporter.panics) != 0 {
+		t.Errorf("reported an intended abort, given = %+v", reporter.panics)
+	}
+}
+
+func TestRecoveryAfterWriteHeaderIsRecorded(t *testing.T) {
+	t.Parallel()
+
+	reg := metrics.NewRegistry()
+	sink := &accessLogRecorder{}
+	h := middleware.Recovery()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		panic("boom")
+	}))
+	h = middleware.Metrics(reg, func(r *http.Request) string { return r.URL.Path })(h)
+	h = middleware.AccessLogger(sink)(h)
+	_, recovered := serveRecovered(h)
+	if recovered != http.ErrAbortHandler {
+		t.Fatalf("unexpected panic, given = %v", recovered)
+	}
+
+	// 接続を切らせたリクエストも、アクセスログとメトリクスに残す
+	if len(sink.logs) != 1 || sink.logs[0].Status != http.StatusOK || sink.logs[0].Path != "/do-panic" {
+		t.Errorf("unexpected access logs, given = %+v", sink.logs)
+	}
+	var b bytes.Buffer
+	reg.WriteText(&b)
+	if want := `http_requests_total{method="GET",route="/do-panic",status="200"} 1`; !strings.Contains(b.String(), want) {
+		t.Errorf("request is not counted, want = %s, given = %s", want, b.String())
+	}
+	if want := "http_requests_in_flight 0"; !strings.Contains(b.String(), want) {
+		t.Errorf("request is left in flight, given = %s", b.String())
+	}
+}
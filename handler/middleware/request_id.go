@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/logger"
+)
+
+// RequestIDHeader is the header carrying the ID of a request, both in the
+// request, from clients or proxies, and in the response.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the length of the longest request ID taken from clients.
+const maxRequestIDLength = 128
+
+// SetRequestID takes the request ID from the X-Request-ID header, or makes
+// a new one when there is none, and puts it into the request context, see
+// GetRequestID. Logs written with logger in the context carry it, and it is
+// echoed in the X-Request-ID header of the response.
+//
+// Request IDs from clients are only taken when they are at most 128
+// printable ASCII characters without spaces, so that they cannot forge lines
+// of the logs they are written into.
+func SetRequestID(h http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		h.ServeHTTP(w, r.WithContext(logger.ContextWithRequestID(r.Context(), id)))
+	}
+	return http.HandlerFunc(fn)
+}
+
+// GetRequestID returns the request ID of ctx set by SetRequestID.
+func GetRequestID(ctx context.Context) (string, error) {
+	id, ok := logger.RequestID(ctx)
+	if !ok {
+		return "", fmt.Errorf("request id not found")
+	}
+	return id, nil
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns 128 random bits in hex.
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand does not fail in practice, and the time still tells
+		// most requests apart
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
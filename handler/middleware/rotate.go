@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+// A RotatingFile is a file rotated once it would grow past a size, e.g. to
+// write access logs into with an AccessLogSink. Rotating renames the file to
+// path.1, shifting older ones to path.2 and so on, and only keeps a number
+// of them. It is safe for concurrent use.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// OpenRotatingFile opens the file at path for appending, creating it if
+// needed, to be rotated when it would grow past maxSize bytes, keeping
+// maxBackups rotated files. A maxSize of 0 never rotates.
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.f, f.size = file, info.Size()
+	return nil
+}
+
+// Write implements io.Writer interface. p is never split between files.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return 0, os.ErrClosed
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			// p is still written to the file, when it could be opened again,
+			// rather than lost
+			log.Println("failed to rotate", f.path, "err =", err)
+			if f.f == nil {
+				return 0, err
+			}
+		}
+	}
+	n, err := f.f.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate closes the file, shifts the rotated files and opens a new file.
+// The file is opened again even when shifting fails.
+func (f *RotatingFile) rotate() error {
+	err := f.f.Close()
+	f.f = nil
+	if err == nil {
+		err = f.shift()
+	}
+	if oerr := f.open(); oerr != nil {
+		return oerr
+	}
+	return err
+}
+
+// shift renames the file to path.1, shifting the rotated files and removing
+// the oldest of them.
+func (f *RotatingFile) shift() error {
+	if f.maxBackups <= 0 {
+		return os.Remove(f.path)
+	}
+	for i := f.maxBackups - 1; i > 0; i-- {
+		if err := os.Rename(f.backup(i), f.backup(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(f.path, f.backup(1))
+}
+
+func (f *RotatingFile) backup(i int) string {
+	return fmt.Sprintf("%s.%d", f.path, i)
+}
+
+// Close implements io.Closer interface.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return nil
+	}
+	err := f.f.Close()
+	f.f = nil
+	return err
+}
@@ -0,0 +1,145 @@
+package middleware_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/handler/middleware"
+)
+
+// readFiles returns the contents of the files at paths, with "" for those
+// missing.
+func readFiles(t *testing.T, paths ...string) []string {
+	t.Helper()
+	var contents []string
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal("failed to read file, err =", err)
+		}
+		contents = append(contents, string(b))
+	}
+	return contents
+}
+
+// writeLines writes each of lines to f.
+func writeLines(t *testing.T, f *middleware.RotatingFile, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if n, err := f.Write([]byte(line)); err != nil || n != len(line) {
+			t.Fatalf("failed to write %q, n = %d, err = %v", line, n, err)
+		}
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := middleware.OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal("failed to open file, err =", err)
+	}
+	defer f.Close()
+
+	cases := []struct {
+		line string
+		want []string
+	}{
+		{line: "12345\n", want: []string{"12345\n", "", ""}},
+		{line: "abcde\n", want: []string{"abcde\n", "12345\n", ""}},
+		{line: "ABCDE\n", want: []string{"ABCDE\n", "abcde\n", "12345\n"}},
+		// ちょうど上限までは回さない
+		{line: "xyz\n", want: []string{"ABCDE\nxyz\n", "abcde\n", "12345\n"}},
+		// 一番古いものを消す
+		{line: "0\n", want: []string{"0\n", "ABCDE\nxyz\n", "abcde\n"}},
+		// 上限より長い行も分けずに書く
+		{line: "0123456789ABCDEF\n", want: []string{"0123456789ABCDEF\n", "0\n", "ABCDE\nxyz\n"}},
+	}
+	for i, c := range cases {
+		writeLines(t, f, c.line)
+		got := readFiles(t, path, path+".1", path+".2")
+		for j := range got {
+			if got[j] != c.want[j] {
+				t.Errorf("step %d: unexpected file %d, want = %q, given = %q", i, j, c.want[j], got[j])
+			}
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("kept more rotated files than asked, err =", err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal("failed to close file, err =", err)
+	}
+	if _, err := f.Write([]byte("closed\n")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("unexpected error writing a closed file, given = %v", err)
+	}
+}
+
+func TestRotatingFileReopen(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "access.log")
+	if err := os.WriteFile(path, []byte("12345\n"), 0o644); err != nil {
+		t.Fatal("failed to write file, err =", err)
+	}
+
+	// 既存のファイルには追記し、その大きさも数える
+	f, err := middleware.OpenRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatal("failed to open file, err =", err)
+	}
+	defer f.Close()
+	writeLines(t, f, "abc\n", "def\n")
+	if got := readFiles(t, path, path+".1"); got[0] != "def\n" || got[1] != "12345\nabc\n" {
+		t.Errorf("unexpected files, given = %q", got)
+	}
+
+	// 回せなくても、開き直したファイルに書き続ける
+	if err := os.Rename(path+".1", path+".1.old"); err != nil {
+		t.Fatal("failed to move file, err =", err)
+	}
+	if err := os.Mkdir(path+".1", 0o755); err != nil {
+		t.Fatal("failed to create dir, err =", err)
+	}
+	if err := os.WriteFile(filepath.Join(path+".1", "blocker"), nil, 0o644); err != nil {
+		t.Fatal("failed to write file, err =", err)
+	}
+	writeLines(t, f, "ghijklmn\n", "o\n")
+	if got := readFiles(t, path); got[0] != "def\nghijklmn\no\n" {
+		t.Errorf("unexpected file, given = %q", got[0])
+	}
+}
+
+func TestRotatingFileWithoutBackups(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := middleware.OpenRotatingFile(path, 10, 0)
+	if err != nil {
+		t.Fatal("failed to open file, err =", err)
+	}
+	defer f.Close()
+	writeLines(t, f, "12345\n", "abcde\n")
+	if got := readFiles(t, path, path+".1"); got[0] != "abcde\n" || got[1] != "" {
+		t.Errorf("unexpected files, given = %q", got)
+	}
+}
+
+func TestRotatingFileWithoutLimit(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := middleware.OpenRotatingFile(path, 0, 1)
+	if err != nil {
+		t.Fatal("failed to open file, err =", err)
+	}
+	defer f.Close()
+	writeLines(t, f, "12345\n", "abcde\n", "ABCDE\n")
+	if got := readFiles(t, path, path+".1"); got[0] != "12345\nabcde\nABCDE\n" || got[1] != "" {
+		t.Errorf("unexpected files, given = %q", got)
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/tracing"
+)
+
+// Trace returns a middleware serving every request in a server span of
+// tracer named by the method and the route of the request, e.g.
+// "GET /todos". The span is a child of the one in the traceparent header of
+// the request, if any, and its own traceparent is sent back in the response,
+// so that clients can find the trace. It must be used inside SetRequestID
+// for the spans to have the request ID. A nil tracer traces nothing.
+func Trace(tracer *tracing.Tracer, route func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		if tracer == nil {
+			return h
+		}
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			path := route(r)
+			ctx, span := tracer.Start(tracing.Extract(r.Context(), r.Header), r.Method+" "+path,
+				tracing.WithSpanKind(tracing.SpanKindServer),
+				tracing.WithAttributes(
+					tracing.String("http.request.method", r.Method),
+					tracing.String("http.route", path),
+					tracing.String("url.path", r.URL.Path),
+				))
+			defer span.End()
+			if requestID, err := GetRequestID(ctx); err == nil {
+				span.SetAttributes(tracing.String("request_id", requestID))
+			}
+			w.Header().Set(tracing.TraceparentHeader, span.SpanContext().Traceparent())
+			rw := Record(w)
+
+			h.ServeHTTP(rw, r.WithContext(ctx))
+			span.SetAttributes(tracing.Int("http.response.status_code", int64(rw.Status())))
+			if rw.Status() >= http.StatusInternalServerError {
+				span.SetError(httpError(rw.Status()))
+			}
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// httpError is the error of a span whose response is a server error.
+type httpError int
+
+func (e httpError) Error() string {
+	return http.StatusText(int(e))
+}
+
+// Traced returns mw traced in a span named name, e.g. "middleware
+// Authenticate", which ends once mw passes the request on, so that the span
+// only covers mw itself. The spans of the handlers after mw are siblings of
+// it, rather than children.
+func Traced(name string, mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		inner := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if span := tracing.SpanFromContext(r.Context()); span != nil {
+				span.End()
+				r = r.WithContext(tracing.ContextWithSpan(r.Context(), span.Parent()))
+			}
+			next.ServeHTTP(w, r)
+		}))
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := tracing.Start(r.Context(), name)
+			// ends the span when mw responds without passing the request on
+			defer span.End()
+			inner.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
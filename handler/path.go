@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+)
+
+// matchPath matches path against pattern, e.g. /todos/{id}, and returns the
+// values of the parameters in braces in order. Parameters are positive IDs.
+//
+// http.ServeMux only matches fixed paths and subtrees, so handlers registered
+// for a subtree use matchPath to route the paths below it.
+func matchPath(pattern, path string) ([]int64, bool) {
+	patterns := strings.Split(strings.Trim(pattern, "/"), "/")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patterns) != len(segments) {
+		return nil, false
+	}
+
+	var params []int64
+	for i, p := range patterns {
+		if !strings.HasPrefix(p, "{") || !strings.HasSuffix(p, "}") {
+			if p != segments[i] {
+				return nil, false
+			}
+			continue
+		}
+		v, err := strconv.ParseInt(segments[i], 10, 64)
+		if err != nil || v <= 0 {
+			return nil, false
+		}
+		params = append(params, v)
+	}
+	return params, true
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/handler/response"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A ProjectHandler implements the endpoints of projects under /projects.
+// It must be used inside middleware.Authenticate.
+type ProjectHandler struct {
+	svc     *service.ProjectService
+	todoSvc *service.TODOService
+}
+
+// NewProjectHandler returns ProjectHandler based http.Handler.
+// The TODOs of projects are read with todoSvc.
+func NewProjectHandler(svc *service.ProjectService, todoSvc *service.TODOService) *ProjectHandler {
+	return &ProjectHandler{
+		svc:     svc,
+		todoSvc: todoSvc,
+	}
+}
+
+// Create handles the endpoint that creates a project.
+func (h *ProjectHandler) Create(ctx context.Context, req *model.CreateProjectRequest) (*model.CreateProjectResponse, error) {
+	project, err := h.svc.CreateProject(ctx, req.Name, req.Description)
+	return &model.CreateProjectResponse{Project: *project}, err
+}
+
+// Read handles the endpoint that reads the projects.
+func (h *ProjectHandler) Read(ctx context.Context, req *model.ReadProjectsRequest) (*model.ReadProjectsResponse, error) {
+	projects, err := h.svc.ReadProjects(ctx)
+	return &model.ReadProjectsResponse{Projects: projects}, err
+}
+
+// Find handles the endpoint that reads the project.
+func (h *ProjectHandler) Find(ctx context.Context, req *model.FindProjectRequest) (*model.FindProjectResponse, error) {
+	project, err := h.svc.ReadProject(ctx, req.ID)
+	return &model.FindProjectResponse{Project: *project}, err
+}
+
+// Update handles the endpoint that updates the project.
+func (h *ProjectHandler) Update(ctx context.Context, req *model.UpdateProjectRequest) (*model.UpdateProjectResponse, error) {
+	project, err := h.svc.UpdateProject(ctx, req.ID, req.Name, req.Description)
+	return &model.UpdateProjectResponse{Project: *project}, err
+}
+
+// Delete handles the endpoint that deletes the project.
+func (h *ProjectHandler) Delete(ctx context.Context, req *model.DeleteProjectRequest) (*model.DeleteProjectResponse, error) {
+	return &model.DeleteProjectResponse{}, h.svc.DeleteProject(ctx, req.ID)
+}
+
+// Members handles the endpoint that reads the members of the project.
+func (h *ProjectHandler) Members(ctx context.Context, req *model.ReadProjectMembersRequest) (*model.ReadProjectMembersResponse, error) {
+	members, err := h.svc.ReadMembers(ctx, req.ProjectID)
+	return &model.ReadProjectMembersResponse{Members: members}, err
+}
+
+// AddMember handles the endpoint that adds a member to the project.
+func (h *ProjectHandler) AddMember(ctx context.Context, req *model.AddProjectMemberRequest) (*model.AddProjectMemberResponse, error) {
+	member, err := h.svc.AddMember(ctx, req.ProjectID, req.Username, req.Role)
+	return &model.AddProjectMemberResponse{Member: *member}, err
+}
+
+// RemoveMember handles the endpoint that removes the member from the project.
+func (h *ProjectHandler) RemoveMember(ctx context.Context, req *model.RemoveProjectMemberRequest) (*model.RemoveProjectMemberResponse, error) {
+	return &model.RemoveProjectMemberResponse{}, h.svc.RemoveMember(ctx, req.ProjectID, req.UserID)
+}
+
+// TODOs handles the endpoint that reads the TODOs of the project.
+func (h *ProjectHandler) TODOs(ctx context.Context, req *model.ReadTODORequest) (*model.ReadTODOResponse, error) {
+	todos, err := h.todoSvc.ReadTODO(ctx, req.PrevID, req.Size, req.TODOFilter)
+	return &model.ReadTODOResponse{TODOs: todos}, err
+}
+
+// ServeHTTP implements http.Handler interface.
+// It serves the collection /projects, the items /projects/{id}, their
+// members /projects/{id}/members[/{user_id}] and their TODOs /projects/{id}/todos.
+func (h *ProjectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var (
+		res interface{}
+		err error
+	)
+	if r.URL.Path == "/projects" {
+		res, err = h.serveCollection(w, r)
+	} else if params, ok := matchPath("/projects/{id}", r.URL.Path); ok {
+		res, err = h.serveItem(w, r, params[0])
+	} else if params, ok := matchPath("/projects/{id}/members", r.URL.Path); ok {
+		res, err = h.serveMembers(w, r, params[0])
+	} else if params, ok := matchPath("/projects/{id}/members/{user_id}", r.URL.Path); ok {
+		if r.Method != http.MethodDelete {
+			methodNotAllowed(w, r, http.MethodDelete)
+			return
+		}
+		req := &model.RemoveProjectMemberRequest{ProjectID: params[0], UserID: params[1]}
+		if err = req.Validate(); err == nil {
+			res, err = h.RemoveMember(r.Context(), req)
+		}
+	} else if params, ok := matchPath("/projects/{id}/todos", r.URL.Path); ok {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		req, perr := parseReadTODORequest(r.URL.Query())
+		if perr != nil {
+			response.Error(w, r, perr)
+			return
+		}
+		req.ProjectID = params[0]
+		res, err = h.TODOs(r.Context(), req)
+	} else {
+		err = &model.ErrNotFound{}
+	}
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	if res != nil {
+		response.JSON(w, http.StatusOK, res)
+	}
+}
+
+// serveCollection serves /projects. It returns nil without error once it has
+// responded itself.
+func (h *ProjectHandler) serveCollection(w http.ResponseWriter, r *http.Request) (interface{}, error) {
+	switch r.Method {
+	case http.MethodGet:
+		return h.Read(r.Context(), &model.ReadProjectsRequest{})
+	case http.MethodPost:
+		req := &model.CreateProjectRequest{}
+		if err := decodeJSON(r, req); err != nil {
+			return nil, err
+		}
+		return h.Create(r.Context(), req)
+	}
+	methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+	return nil, nil
+}
+
+// serveItem serves /projects/{id}. It returns nil without error once it has
+// responded itself.
+func (h *ProjectHandler) serveItem(w http.ResponseWriter, r *http.Request, id int64) (interface{}, error) {
+	switch r.Method {
+	case http.MethodGet:
+		return h.Find(r.Context(), &model.FindProjectRequest{ID: id})
+	case http.MethodPatch:
+		req := &model.UpdateProjectRequest{ID: id}
+		if err := decodeJSON(r, req); err != nil {
+			return nil, err
+		}
+		return h.Update(r.Context(), req)
+	case http.MethodDelete:
+		return h.Delete(r.Context(), &model.DeleteProjectRequest{ID: id})
+	}
+	methodNotAllowed(w, r, http.MethodGet, http.MethodPatch, http.MethodDelete)
+	return nil, nil
+}
+
+// serveMembers serves /projects/{id}/members. It returns nil without error
+// once it has responded itself.
+func (h *ProjectHandler) serveMembers(w http.ResponseWriter, r *http.Request, id int64) (interface{}, error) {
+	switch r.Method {
+	case http.MethodGet:
+		return h.Members(r.Context(), &model.ReadProjectMembersRequest{ProjectID: id})
+	case http.MethodPost:
+		req := &model.AddProjectMemberRequest{ProjectID: id}
+		if err := decodeJSON(r, req); err != nil {
+			return nil, err
+		}
+		return h.AddMember(r.Context(), req)
+	}
+	methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+	return nil, nil
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/handler/response"
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// Media types of request bodies.
+const (
+	mediaTypeJSON       = "application/json"
+	mediaTypeMergePatch = "application/merge-patch+json"
+	mediaTypeJSONPatch  = "application/json-patch+json"
+)
+
+// mediaType returns the media type of the body of r without parameters,
+// or "" when r does not tell.
+func mediaType(r *http.Request) string {
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		return ""
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return ct
+	}
+	return mt
+}
+
+// A validatable is implemented by model request types.
+type validatable interface {
+	Validate() error
+}
+
+// decodeJSON decodes the JSON body of r into v and validates it.
+//
+// A malformed body is reported as model.ErrBadRequest. Unknown fields and
+// values of the wrong type are reported per field as model.ErrValidation,
+// the same way as values failing v.Validate.
+func decodeJSON(r *http.Request, v validatable) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		var errType *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &errType):
+			return fieldError(errType.Field, "must be "+errType.Type.String())
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			// encoding/json has no error type for unknown fields
+			field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+			return fieldError(field, "is not allowed")
+		default:
+			return &model.ErrBadRequest{Message: "malformed JSON body: " + err.Error()}
+		}
+	}
+	return v.Validate()
+}
+
+func fieldError(field, message string) error {
+	return &model.ErrValidation{
+		Message: "request has invalid fields",
+		Fields:  []*model.FieldError{{Field: field, Message: message}},
+	}
+}
+
+// methodNotAllowed responds 405 listing the allowed methods.
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	response.Error(w, r, &model.ErrMethodNotAllowed{Method: r.Method})
+}
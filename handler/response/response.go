@@ -0,0 +1,93 @@
+// Package response writes the JSON responses shared by handlers and middlewares.
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/logger"
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// JSON writes v as the body of a response with status.
+func JSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(err)
+	}
+}
+
+// Error writes the error envelope for err.
+//
+// This is the only place errors are mapped to HTTP status codes and error
+// codes. Errors of unknown types are reported as internal errors without
+// their message, so that no internals leak to clients, and logged in the
+// context of r, which carries the request ID set by middleware.SetRequestID.
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	var errTooMany *model.ErrTooManyRequests
+	if errors.As(err, &errTooMany) && errTooMany.RetryAfter > 0 {
+		// Retry-After is in whole seconds, rounded up so that clients do not retry too early
+		w.Header().Set("Retry-After", strconv.FormatInt(int64((errTooMany.RetryAfter+time.Second-1)/time.Second), 10))
+	}
+	status, body := errorBody(err)
+	if status == http.StatusInternalServerError {
+		logger.Println(r.Context(), err)
+	}
+	JSON(w, status, &model.ErrorResponse{Error: *body})
+}
+
+// Internal writes the envelope of an internal error without logging it, for
+// callers which have logged the error with more context already, e.g. the
+// stack of a recovered panic.
+func Internal(w http.ResponseWriter) {
+	status, body := errorBody(nil)
+	JSON(w, status, &model.ErrorResponse{Error: *body})
+}
+
+func errorBody(err error) (int, *model.ErrorBody) {
+	var (
+		errNotFound     *model.ErrNotFound
+		errBadRequest   *model.ErrBadRequest
+		errValidation   *model.ErrValidation
+		errConflict     *model.ErrConflict
+		errPrecondition *model.ErrPreconditionFailed
+		errUnauthorized *model.ErrUnauthorized
+		errForbidden    *model.ErrForbidden
+		errTooMany      *model.ErrTooManyRequests
+		errMethod       *model.ErrMethodNotAllowed
+		errMediaType    *model.ErrUnsupportedMediaType
+	)
+	switch {
+	case errors.As(err, &errNotFound):
+		return http.StatusNotFound, &model.ErrorBody{Code: "not_found", Message: errNotFound.Error()}
+	case errors.As(err, &errBadRequest):
+		return http.StatusBadRequest, &model.ErrorBody{Code: "bad_request", Message: errBadRequest.Error()}
+	case errors.As(err, &errValidation):
+		body := &model.ErrorBody{Code: "validation_failed", Message: errValidation.Error()}
+		if len(errValidation.Fields) > 0 {
+			body.Details = errValidation.Fields
+		}
+		return http.StatusUnprocessableEntity, body
+	case errors.As(err, &errConflict):
+		return http.StatusConflict, &model.ErrorBody{Code: "conflict", Message: errConflict.Error()}
+	case errors.As(err, &errPrecondition):
+		return http.StatusPreconditionFailed, &model.ErrorBody{Code: "precondition_failed", Message: errPrecondition.Error()}
+	case errors.As(err, &errUnauthorized):
+		return http.StatusUnauthorized, &model.ErrorBody{Code: "unauthorized", Message: errUnauthorized.Error()}
+	case errors.As(err, &errForbidden):
+		return http.StatusForbidden, &model.ErrorBody{Code: "forbidden", Message: errForbidden.Error()}
+	case errors.As(err, &errTooMany):
+		return http.StatusTooManyRequests, &model.ErrorBody{Code: "too_many_requests", Message: errTooMany.Error()}
+	case errors.As(err, &errMethod):
+		return http.StatusMethodNotAllowed, &model.ErrorBody{Code: "method_not_allowed", Message: errMethod.Error()}
+	case errors.As(err, &errMediaType):
+		return http.StatusUnsupportedMediaType, &model.ErrorBody{Code: "unsupported_media_type", Message: errMediaType.Error()}
+	default:
+		return http.StatusInternalServerError, &model.ErrorBody{Code: "internal", Message: http.StatusText(http.StatusInternalServerError)}
+	}
+}
@@ -0,0 +1,121 @@
+package response_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/handler/response"
+	"github.com/TechBowl-japan/go-stations/logger"
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+func TestError(t *testing.T) {
+	t.Parallel()
+
+	validation := &model.ErrValidation{Message: "invalid todo", Fields: []*model.FieldError{{Field: "subject", Message: "is required"}}}
+	cases := map[string]struct {
+		err        error
+		status     int
+		code       string
+		message    string
+		retryAfter string
+		details    string
+	}{
+		"Not found":              {err: &model.ErrNotFound{}, status: http.StatusNotFound, code: "not_found", message: "record not found"},
+		"Bad request":            {err: &model.ErrBadRequest{Message: "bad"}, status: http.StatusBadRequest, code: "bad_request", message: "bad"},
+		"Validation":             {err: validation, status: http.StatusUnprocessableEntity, code: "validation_failed", message: "invalid todo", details: `[{"field":"subject","message":"is required"}]`},
+		"Validation of no field": {err: &model.ErrValidation{Message: "invalid"}, status: http.StatusUnprocessableEntity, code: "validation_failed", message: "invalid"},
+		"Conflict":               {err: &model.ErrConflict{Message: "conflict"}, status: http.StatusConflict, code: "conflict", message: "conflict"},
+		"Status transition":      {err: &model.ErrInvalidStatusTransition{From: model.TODOStatusDone, To: model.TODOStatusInProgress}, status: http.StatusConflict, code: "conflict", message: "cannot change status from done to in_progress"},
+		"Precondition failed":    {err: &model.ErrPreconditionFailed{}, status: http.StatusPreconditionFailed, code: "precondition_failed", message: "precondition failed"},
+		"Unauthorized":           {err: &model.ErrUnauthorized{}, status: http.StatusUnauthorized, code: "unauthorized", message: "unauthorized"},
+		"Forbidden":              {err: &model.ErrForbidden{}, status: http.StatusForbidden, code: "forbidden", message: "forbidden"},
+		"Method not allowed":     {err: &model.ErrMethodNotAllowed{Method: http.MethodPut}, status: http.StatusMethodNotAllowed, code: "method_not_allowed", message: "method PUT is not allowed"},
+		"Unsupported media type": {err: &model.ErrUnsupportedMediaType{}, status: http.StatusUnsupportedMediaType, code: "unsupported_media_type", message: "content type is required"},
+		// 待ち時間は秒に切り上げる
+		"Too many requests":             {err: &model.ErrTooManyRequests{RetryAfter: 1500 * time.Millisecond}, status: http.StatusTooManyRequests, code: "too_many_requests", message: "too many requests", retryAfter: "2"},
+		"Too many requests in a second": {err: &model.ErrTooManyRequests{RetryAfter: 2 * time.Second}, status: http.StatusTooManyRequests, code: "too_many_requests", message: "too many requests", retryAfter: "2"},
+		"Too many requests, no wait":    {err: &model.ErrTooManyRequests{}, status: http.StatusTooManyRequests, code: "too_many_requests", message: "too many requests"},
+		"Wrapped":                       {err: fmt.Errorf("find: %w", &model.ErrNotFound{}), status: http.StatusNotFound, code: "not_found", message: "record not found"},
+		// 内部のエラーの内容はクライアントに見せない
+		"Unknown": {err: errors.New("secret"), status: http.StatusInternalServerError, code: "internal", message: "Internal Server Error"},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			response.Error(rec, httptest.NewRequest(http.MethodGet, "/todos", nil), c.err)
+			if rec.Code != c.status {
+				t.Errorf("unexpected status, want = %d, given = %d", c.status, rec.Code)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json; charset=utf-8" {
+				t.Errorf("unexpected Content-Type, given = %s", got)
+			}
+			if got := rec.Header().Get("Retry-After"); got != c.retryAfter {
+				t.Errorf("unexpected Retry-After, want = %q, given = %q", c.retryAfter, got)
+			}
+			var res struct {
+				Error struct {
+					Code    string          `json:"code"`
+					Message string          `json:"message"`
+					Details json.RawMessage `json:"details"`
+				} `json:"error"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+				t.Fatal("failed to decode error, err =", err)
+			}
+			if res.Error.Code != c.code || res.Error.Message != c.message || string(res.Error.Details) != c.details {
+				t.Errorf("unexpected error, given = %+v, details = %s", res.Error, res.Error.Details)
+			}
+		})
+	}
+}
+
+func TestInternal(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	response.Internal(rec)
+	if rec.Code != http.StatusInternalServerError || rec.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Errorf("unexpected response, status = %d, Content-Type = %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if want := `{"error":{"code":"internal","message":"Internal Server Error"}}`; strings.TrimSpace(rec.Body.String()) != want {
+		t.Errorf("unexpected body, want = %s, given = %s", want, rec.Body)
+	}
+}
+
+func TestErrorLogsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	output, flags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(output)
+		log.SetFlags(flags)
+	})
+
+	// リクエスト ID はレスポンスヘッダではなくリクエストのコンテキストから取る
+	req := httptest.NewRequest(http.MethodGet, "/todos", nil)
+	req = req.WithContext(logger.ContextWithRequestID(req.Context(), "abc"))
+	response.Error(httptest.NewRecorder(), req, errors.New("broken database"))
+	if want := "request_id=abc broken database\n"; buf.String() != want {
+		t.Errorf("unexpected logs, want = %q, given = %q", want, buf.String())
+	}
+
+	// クライアントの誤りはログに書かない
+	buf.Reset()
+	response.Error(httptest.NewRecorder(), req, &model.ErrNotFound{})
+	if got := buf.String(); strings.TrimSpace(got) != "" {
+		t.Errorf("unexpected logs, given = %q", got)
+	}
+}
@@ -0,0 +1,153 @@
+package router
+
+import (
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/handler/middleware"
+	"github.com/TechBowl-japan/go-stations/metrics"
+	"github.com/TechBowl-japan/go-stations/service"
+	"github.com/TechBowl-japan/go-stations/tracing"
+)
+
+// An Option configures the router made by NewRouter.
+type Option func(*options)
+
+type options struct {
+	keys      *service.KeySet
+	basicAuth *middleware.Credentials
+	basicOpts []middleware.BasicAuthOption
+	limiter   *middleware.RateLimiter
+	limits    map[string]middleware.Limit
+	accessLog []middleware.AccessLogSink
+	metrics   *metrics.Registry
+	tracer    *tracing.Tracer
+	health    *service.HealthService
+	reporters []middleware.PanicReporter
+
+	// metricsAuth is who /metrics lets in, or nil for anyone.
+	metricsAuth     *middleware.Credentials
+	metricsAuthOpts []middleware.BasicAuthOption
+}
+
+// Route groups limited by the rate limiter, see WithRateLimit.
+const (
+	// RouteGroupAuth is the endpoints logging in and issuing tokens,
+	// limited per client address.
+	RouteGroupAuth = "auth"
+	// RouteGroupAPI is the endpoints of TODOs, projects and API tokens,
+	// limited per user when authenticated.
+	RouteGroupAPI = "api"
+	// RouteGroupAPIClient is the endpoints of RouteGroupAPI limited per
+	// client address before authenticating, so that tokens cannot be
+	// guessed without limit.
+	RouteGroupAPIClient = "api-client"
+)
+
+// Default limits of the route groups.
+var defaultLimits = map[string]middleware.Limit{
+	RouteGroupAuth:      {Burst: 10, Per: time.Minute},
+	RouteGroupAPI:       {Burst: 300, Per: time.Minute},
+	RouteGroupAPIClient: {Burst: 600, Per: time.Minute},
+}
+
+// WithKeySet makes the router sign and verify JWTs with keys.
+//
+// Without it, an Ed25519 key generated by NewRouter signs them, so that
+// the JWTs become invalid when the process restarts.
+func WithKeySet(keys *service.KeySet) Option {
+	return func(o *options) {
+		o.keys = keys
+	}
+}
+
+// WithBasicAuth makes /basicauth let in the users of creds, configured by opts.
+//
+// Without it, /basicauth lets nobody in.
+func WithBasicAuth(creds *middleware.Credentials, opts ...middleware.BasicAuthOption) Option {
+	return func(o *options) {
+		o.basicAuth = creds
+		o.basicOpts = opts
+	}
+}
+
+// WithRateLimiter makes the router limit the requests of clients with l,
+// whose idle buckets are evicted by its SweepEvery, if the caller runs it.
+//
+// Without it, the router uses a RateLimiter of its own trusting no proxy.
+func WithRateLimiter(l *middleware.RateLimiter) Option {
+	return func(o *options) {
+		o.limiter = l
+	}
+}
+
+// WithRateLimit replaces the limit of the route group, e.g. RouteGroupAPI.
+// A zero limit lifts the limit of the group.
+func WithRateLimit(group string, limit middleware.Limit) Option {
+	return func(o *options) {
+		if o.limits == nil {
+			o.limits = map[string]middleware.Limit{}
+		}
+		o.limits[group] = limit
+	}
+}
+
+// WithAccessLog makes the router write the access log of every request to
+// each of sinks.
+//
+// Without it, or without sinks, no access log is written, so that the router
+// does not write to stdout unless asked to, e.g. by main.
+func WithAccessLog(sinks ...middleware.AccessLogSink) Option {
+	return func(o *options) {
+		o.accessLog = append([]middleware.AccessLogSink{}, sinks...)
+	}
+}
+
+// WithMetrics makes the router keep its metrics in reg and serve them at
+// /metrics, e.g. to add metrics of other parts of the server.
+//
+// Without it, the router keeps them in a Registry of its own.
+func WithMetrics(reg *metrics.Registry) Option {
+	return func(o *options) {
+		o.metrics = reg
+	}
+}
+
+// WithMetricsAuth makes /metrics let in only the users of creds, configured
+// by opts, e.g. the Prometheus scraping it with basic_auth.
+//
+// Without it, /metrics is served to anyone, and must be kept out of reach of
+// clients, e.g. by a firewall only letting the scrapers through.
+func WithMetricsAuth(creds *middleware.Credentials, opts ...middleware.BasicAuthOption) Option {
+	return func(o *options) {
+		o.metricsAuth = creds
+		o.metricsAuthOpts = opts
+	}
+}
+
+// WithTracer makes the router trace every request with tracer, continuing
+// the traces of the traceparent headers of requests.
+//
+// Without it, nothing is traced.
+func WithTracer(tracer *tracing.Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// WithHealthService makes /readyz report the readiness of svc, e.g. so that
+// the server can drain it before shutting down.
+//
+// Without it, /readyz checks todoDB with a HealthService of its own.
+func WithHealthService(svc *service.HealthService) Option {
+	return func(o *options) {
+		o.health = svc
+	}
+}
+
+// WithPanicReporters makes the router report the panics of handlers to each
+// of reporters, besides logging them.
+func WithPanicReporters(reporters ...middleware.PanicReporter) Option {
+	return func(o *options) {
+		o.reporters = append([]middleware.PanicReporter{}, reporters...)
+	}
+}
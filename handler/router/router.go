@@ -2,44 +2,132 @@ package router
 
 import (
 	"database/sql"
-	"log"
 	"net/http"
 	"time"
 
 	"github.com/TechBowl-japan/go-stations/handler"
 	"github.com/TechBowl-japan/go-stations/handler/middleware"
+	"github.com/TechBowl-japan/go-stations/handler/response"
+	"github.com/TechBowl-japan/go-stations/logger"
+	"github.com/TechBowl-japan/go-stations/metrics"
+	"github.com/TechBowl-japan/go-stations/model"
 	"github.com/TechBowl-japan/go-stations/service"
 )
 
-func NewRouter(todoDB *sql.DB) *http.ServeMux {
+func NewRouter(todoDB *sql.DB, opts ...Option) *http.ServeMux {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.keys == nil {
+		keys, err := service.GenerateKeySet()
+		if err != nil {
+			// crypto/rand は失敗しないので、失敗したら起動できない
+			panic(err)
+		}
+		o.keys = keys
+	}
+	if o.limiter == nil {
+		o.limiter = middleware.NewRateLimiter()
+	}
+	limit := func(group string) func(http.Handler) http.Handler {
+		l, ok := o.limits[group]
+		if !ok {
+			l = defaultLimits[group]
+		}
+		return o.limiter.Limit(group, l)
+	}
+	if o.metrics == nil {
+		o.metrics = metrics.NewRegistry()
+	}
+	if o.health == nil {
+		o.health = service.NewHealthService(todoDB)
+	}
+	if o.basicAuth == nil {
+		o.basicAuth, _ = middleware.StaticCredentials("", "")
+	}
+
 	// register routes
 	mux := http.NewServeMux()
 
+	// 未登録のパスにもエラーの JSON を返す
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		response.Error(w, r, &model.ErrNotFound{})
+	})
+
 	healthHandler := handler.NewHealthzHandler()
 	mux.HandleFunc("/healthz", healthHandler.ServeHTTP)
+	// ロードバランサーやオーケストレーターのためのプローブ
+	mux.Handle("/livez", handler.NewLivezHandler())
+	mux.Handle("/readyz", handler.NewReadyzHandler(o.health))
+
+	// Prometheus がスクレイプするメトリクス。WithMetricsAuth がなければ誰でも読める
+	o.metrics.DBStats(todoDB)
+	var metricsHandler http.Handler = o.metrics
+	if o.metricsAuth != nil {
+		metricsHandler = middleware.Traced("middleware BasicAuth", middleware.BasicAuth(o.metricsAuth, o.metricsAuthOpts...))(metricsHandler)
+	}
+	mux.Handle("/metrics", metricsHandler)
+
+	userService := service.NewUserService(todoDB)
+	tokenService := service.NewTokenService(userService, o.keys)
+	// JWT のアクセストークンを先に検証し、それ以外のトークンはセッションか API トークンとして扱う
+	// トークンの総当たりも抑えるよう認証の前にクライアントのアドレスごとに制限し、
+	// 認証済みのリクエストはさらにユーザーごとに制限する
+	authenticate := func(h http.Handler) http.Handler {
+		return middleware.Traced("middleware RateLimit client", limit(RouteGroupAPIClient))(
+			middleware.Traced("middleware VerifyJWT", middleware.VerifyJWT(tokenService))(
+				middleware.Traced("middleware Authenticate", middleware.Authenticate(userService))(
+					middleware.Traced("middleware RateLimit", limit(RouteGroupAPI))(h))))
+	}
+	mux.Handle("/auth/", limit(RouteGroupAuth)(handler.NewAuthHandler(userService)))
+	mux.Handle("/auth/token", limit(RouteGroupAuth)(handler.NewTokenHandler(tokenService)))
+	mux.Handle("/.well-known/jwks.json", handler.NewJWKSHandler(tokenService))
+	apiTokenHandler := authenticate(handler.NewAPITokenHandler(userService))
+	mux.Handle("/auth/tokens", apiTokenHandler)
+	mux.Handle("/auth/tokens/", apiTokenHandler)
 
+	// TODO の各エンドポイントはログイン中のユーザーの TODO だけを扱い、
+	// ログインしていないリクエストには 401 を返す。
+	// API トークンは読み取りに todos:read、変更に todos:write のスコープが要る
+	todos := func(h http.Handler) http.Handler {
+		return authenticate(middleware.Traced("middleware RequireScope", middleware.RequireScope(model.ScopeTODOsRead, model.ScopeTODOsWrite))(h))
+	}
 	todoService := service.NewTODOService(todoDB)
-	todoHandler := handler.NewTODOHandler(todoService)
-	mux.HandleFunc("/todos", todoHandler.ServeHTTP)
+	todoService.Instrument(o.metrics)
+	todoHandler := todos(handler.NewTODOHandler(todoService))
+	mux.Handle("/todos", todoHandler)
+	mux.Handle("/todos/", todoHandler)
+	mux.Handle("/todos/status", todos(handler.NewTODOStatusHandler(todoService)))
+	mux.Handle("/todos/search", todos(handler.NewSearchHandler(todoService)))
+	mux.Handle("/todos/trash", todos(handler.NewTrashHandler(todoService)))
+	mux.Handle("/tags", todos(handler.NewTagHandler(todoService)))
 
-	mux.Handle("/do-panic", middleware.Recovery(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	// プロジェクトは TODO と同じスコープで扱い、ロールはサービスで確かめる
+	projectHandler := todos(handler.NewProjectHandler(service.NewProjectService(todoDB), todoService))
+	mux.Handle("/projects", projectHandler)
+	mux.Handle("/projects/", projectHandler)
+
+	// パニックはすべてのルートで回復する
+	mux.Handle("/do-panic", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		panic("intended panic")
-	})))
+	}))
 
 	mux.Handle("/useros", middleware.SetUserOS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		os, err := middleware.GetUserOS(r.Context())
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+			response.Error(w, r, err)
 			return
 		}
-		log.Println(os)
+		logger.Println(r.Context(), os)
 	})))
 
-	mux.Handle("/accesslog", middleware.SetUserOS(middleware.AccessLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	// アクセスログはすべてのルートで書く
+	mux.Handle("/accesslog", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(time.Second * 3)
-	}))))
+	}))
 
-	mux.Handle("/basicauth", middleware.BasicAuth((http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("/basicauth", middleware.Traced("middleware BasicAuth", middleware.BasicAuth(o.basicAuth, o.basicOpts...))((http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Header().Set("Content-Type", "application/text")
 		w.Write([]byte("Authenticated"))
@@ -58,5 +146,24 @@ func NewRouter(todoDB *sql.DB) *http.ServeMux {
 		w.Header().Set("Content-Type", "application/text")
 		w.Write([]byte("Not Graceful Shutdown"))
 	}))
-	return mux
+
+	// すべてのルートに共通のミドルウェアをかける。
+	// メトリクスとトレースのルートはパスではなく、リクエストを処理したパターンで数える
+	route := func(r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		if pattern == "" {
+			return "/"
+		}
+		return pattern
+	}
+	// 内側から順にかけるので、パニックはアクセスログとメトリクスに 500 として残る
+	var h http.Handler = mux
+	h = middleware.Recovery(o.reporters...)(h)
+	h = middleware.Metrics(o.metrics, route)(h)
+	h = middleware.AccessLogger(o.accessLog...)(h)
+	h = middleware.Trace(o.tracer, route)(h)
+	h = middleware.SetRequestID(h)
+	root := http.NewServeMux()
+	root.Handle("/", h)
+	return root
 }
@@ -0,0 +1,95 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/handler/middleware"
+	"github.com/TechBowl-japan/go-stations/handler/router"
+)
+
+func TestMetricsAuth(t *testing.T) {
+	t.Parallel()
+
+	todoDB, err := db.NewDB(filepath.Join(t.TempDir(), "todo.db"))
+	if err != nil {
+		t.Fatal("failed to create database, err =", err)
+	}
+	t.Cleanup(func() {
+		if err := todoDB.Close(); err != nil {
+			t.Error("failed to close database, err =", err)
+		}
+	})
+	creds, err := middleware.StaticCredentials("prometheus", "secret")
+	if err != nil {
+		t.Fatal("failed to create credentials, err =", err)
+	}
+
+	cases := map[string]struct {
+		opts               []router.Option
+		username, password string
+		status             int
+	}{
+		// WithMetricsAuth がなければ誰でも読める
+		"Open":           {status: http.StatusOK},
+		"No credentials": {opts: []router.Option{router.WithMetricsAuth(creds)}, status: http.StatusUnauthorized},
+		"Wrong password": {opts: []router.Option{router.WithMetricsAuth(creds)}, username: "prometheus", password: "wrong", status: http.StatusUnauthorized},
+		"Valid":          {opts: []router.Option{router.WithMetricsAuth(creds)}, username: "prometheus", password: "secret", status: http.StatusOK},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mux := router.NewRouter(todoDB, c.opts...)
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if c.username != "" {
+				req.SetBasicAuth(c.username, c.password)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != c.status {
+				t.Errorf("unexpected status, want = %d, given = %d", c.status, rec.Code)
+			}
+		})
+	}
+}
+
+func TestRateLimitBeforeAuthentication(t *testing.T) {
+	t.Parallel()
+
+	todoDB, err := db.NewDB(filepath.Join(t.TempDir(), "todo.db"))
+	if err != nil {
+		t.Fatal("failed to create database, err =", err)
+	}
+	t.Cleanup(func() {
+		if err := todoDB.Close(); err != nil {
+			t.Error("failed to close database, err =", err)
+		}
+	})
+	mux := router.NewRouter(todoDB,
+		router.WithRateLimit(router.RouteGroupAPIClient, middleware.Limit{Burst: 2, Per: time.Hour}))
+
+	// 無効なトークンで総当たりするクライアントも、アドレスごとに止める
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodGet, "/todos", nil)
+		req.RemoteAddr = "203.0.113.1:1234"
+		req.Header.Set("Authorization", "Bearer guess")
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("request %d: unexpected status, want = %d, given = %d", i, want, rec.Code)
+		}
+	}
+	req := httptest.NewRequest(http.MethodGet, "/todos", nil)
+	req.RemoteAddr = "203.0.113.2:1234"
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("unexpected status of another client, given = %d", rec.Code)
+	}
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/TechBowl-japan/go-stations/handler/response"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A SearchHandler implements the full-text search endpoint of TODOs.
+type SearchHandler struct {
+	svc *service.TODOService
+}
+
+// NewSearchHandler returns SearchHandler based http.Handler.
+func NewSearchHandler(svc *service.TODOService) *SearchHandler {
+	return &SearchHandler{
+		svc: svc,
+	}
+}
+
+// Search handles the endpoint that searches the TODOs.
+func (h *SearchHandler) Search(ctx context.Context, req *model.SearchTODORequest) (*model.SearchTODOResponse, error) {
+	results, err := h.svc.SearchTODO(ctx, req.Query, req.Size)
+	return &model.SearchTODOResponse{Results: results}, err
+}
+
+// ServeHTTP implements http.Handler interface.
+func (h *SearchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	req := &model.SearchTODORequest{Query: r.URL.Query().Get("q"), Size: 20}
+	if size := r.URL.Query().Get("size"); size != "" {
+		var err error
+		req.Size, err = strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			response.Error(w, r, &model.ErrBadRequest{Message: "size must be an integer"})
+			return
+		}
+	}
+
+	if err := req.Validate(); err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	res, err := h.Search(r.Context(), req)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, res)
+}
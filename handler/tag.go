@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/handler/response"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A TagHandler implements the endpoint listing tags.
+type TagHandler struct {
+	svc *service.TODOService
+}
+
+// NewTagHandler returns TagHandler based http.Handler.
+func NewTagHandler(svc *service.TODOService) *TagHandler {
+	return &TagHandler{
+		svc: svc,
+	}
+}
+
+// Read handles the endpoint that reads the tags.
+func (h *TagHandler) Read(ctx context.Context) (*model.ReadTagResponse, error) {
+	tags, err := h.svc.ReadTags(ctx)
+	return &model.ReadTagResponse{Tags: tags}, err
+}
+
+// ServeHTTP implements http.Handler interface.
+func (h *TagHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	res, err := h.Read(r.Context())
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, res)
+}
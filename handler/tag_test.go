@@ -0,0 +1,97 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/handler"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadTODOTagFilter(t *testing.T) {
+	t.Parallel()
+
+	h, svc, ctx := newTODOHandler(t)
+	var ids []int64
+	for _, tags := range [][]string{{"a"}, {"a", "b"}, {"b"}, nil} {
+		todo, err := svc.CreateTODO(ctx, "subject", "", service.WithTags(tags...))
+		if err != nil {
+			t.Fatal("failed to create todo, err =", err)
+		}
+		ids = append(ids, todo.ID)
+	}
+
+	cases := map[string]struct {
+		query  string
+		status int
+		ids    []int64
+	}{
+		"One tag":           {query: "tag=a", status: http.StatusOK, ids: []int64{ids[1], ids[0]}},
+		"Every tag":         {query: "tag=a&tag=b", status: http.StatusOK, ids: []int64{ids[1]}},
+		"Comma separated":   {query: "tag=a,b&tag_mode=all", status: http.StatusOK, ids: []int64{ids[1]}},
+		"Any tag":           {query: "tag=a,b&tag_mode=any", status: http.StatusOK, ids: []int64{ids[2], ids[1], ids[0]}},
+		"Unknown tag":       {query: "tag=c", status: http.StatusOK, ids: []int64{}},
+		"Unknown tag mode":  {query: "tag=a&tag_mode=none", status: http.StatusBadRequest},
+		"Blank tag ignored": {query: "tag=a,,", status: http.StatusOK, ids: []int64{ids[1], ids[0]}},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := serve(h, http.MethodGet, "/todos?"+c.query, "")
+			if rec.Code != c.status {
+				t.Fatalf("unexpected status, want = %d, given = %d, body = %s", c.status, rec.Code, rec.Body)
+			}
+			if c.status != http.StatusOK {
+				return
+			}
+			if diff := cmp.Diff(c.ids, todoIDs(t, rec)); diff != "" {
+				t.Error("unexpected ids, diff =", diff)
+			}
+		})
+	}
+}
+
+func TestTagHandler(t *testing.T) {
+	t.Parallel()
+
+	todos, svc, ctx := newTODOHandler(t)
+	h := withContext(ctx, handler.NewTagHandler(svc))
+
+	// タグは作成時に正規化される
+	rec := serve(todos, http.MethodPost, "/todos", `{"subject": "subject", "tags": [" b", "a", "b"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("failed to create todo, status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var created model.CreateTODOResponse
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatal("failed to decode todo, err =", err)
+	}
+	if diff := cmp.Diff([]string{"a", "b"}, created.Tags); diff != "" {
+		t.Error("unexpected tags of created todo, diff =", diff)
+	}
+	if _, err := svc.CreateTODO(ctx, "subject", "", service.WithTags("a")); err != nil {
+		t.Fatal("failed to create todo, err =", err)
+	}
+
+	rec = serve(h, http.MethodGet, "/tags", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status, given = %d, body = %s", rec.Code, rec.Body)
+	}
+	var res model.ReadTagResponse
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatal("failed to decode tags, err =", err)
+	}
+	if diff := cmp.Diff([]*model.Tag{{Name: "a", Count: 2}, {Name: "b", Count: 1}}, res.Tags); diff != "" {
+		t.Error("unexpected tags, diff =", diff)
+	}
+
+	rec = serve(h, http.MethodPost, "/tags", "")
+	if rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != http.MethodGet {
+		t.Errorf("unexpected response to POST, status = %d, Allow = %s", rec.Code, rec.Header().Get("Allow"))
+	}
+}
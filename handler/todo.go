@@ -2,12 +2,15 @@ package handler
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
-	"log"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/TechBowl-japan/go-stations/handler/response"
 	"github.com/TechBowl-japan/go-stations/model"
 	"github.com/TechBowl-japan/go-stations/service"
 )
@@ -26,140 +29,402 @@ func NewTODOHandler(svc *service.TODOService) *TODOHandler {
 
 // Create handles the endpoint that creates the TODO.
 func (h *TODOHandler) Create(ctx context.Context, req *model.CreateTODORequest) (*model.CreateTODOResponse, error) {
-	todo, err := h.svc.CreateTODO(ctx, req.Subject, req.Description)
+	todo, err := h.svc.CreateTODO(ctx, req.Subject, req.Description,
+		service.WithDueAt(req.DueAt), service.WithRemindAt(req.RemindAt), service.WithTags(req.Tags...),
+		service.WithProject(req.ProjectID))
 	return &model.CreateTODOResponse{TODO: *todo}, err
 }
 
 // Read handles the endpoint that reads the TODOs.
 func (h *TODOHandler) Read(ctx context.Context, req *model.ReadTODORequest) (*model.ReadTODOResponse, error) {
-	todos, err := h.svc.ReadTODO(ctx, req.PrevID, req.Size)
+	todos, err := h.svc.ReadTODO(ctx, req.PrevID, req.Size, req.TODOFilter)
 	return &model.ReadTODOResponse{TODOs: todos}, err
 }
 
 // Update handles the endpoint that updates the TODO.
 func (h *TODOHandler) Update(ctx context.Context, req *model.UpdateTODORequest) (*model.UpdateTODOResponse, error) {
-	todo, err := h.svc.UpdateTODO(ctx, req.ID, req.Subject, req.Description)
+	todo, err := h.svc.UpdateTODO(ctx, req.ID, req.Subject, req.Description,
+		service.WithDueAt(req.DueAt), service.WithRemindAt(req.RemindAt), service.WithTags(req.Tags...),
+		service.WithPrecondition(service.IfMatch(req.IfMatch...)))
 	return &model.UpdateTODOResponse{TODO: *todo}, err
 }
 
+// Find handles the endpoint that reads the TODO.
+func (h *TODOHandler) Find(ctx context.Context, req *model.FindTODORequest) (*model.FindTODOResponse, error) {
+	todo, err := h.svc.ReadTODOByID(ctx, req.ID)
+	return &model.FindTODOResponse{TODO: *todo}, err
+}
+
+// Patch handles the endpoint that partially updates the TODO.
+func (h *TODOHandler) Patch(ctx context.Context, req *model.PatchTODORequest) (*model.PatchTODOResponse, error) {
+	opts := []service.TODOOption{service.WithPrecondition(service.IfMatch(req.IfMatch...))}
+	if req.Subject != nil {
+		opts = append(opts, service.WithSubject(*req.Subject))
+	}
+	if req.Description != nil {
+		opts = append(opts, service.WithDescription(*req.Description))
+	}
+	if req.Status != nil {
+		opts = append(opts, service.WithStatus(*req.Status))
+	}
+	if req.DueAt != nil {
+		opts = append(opts, service.WithDueAt(req.DueAt))
+	}
+	if req.RemindAt != nil {
+		opts = append(opts, service.WithRemindAt(req.RemindAt))
+	}
+	if req.Tags != nil {
+		opts = append(opts, service.WithTags(*req.Tags...))
+	}
+	todo, err := h.svc.PatchTODO(ctx, req.ID, opts...)
+	return &model.PatchTODOResponse{TODO: *todo}, err
+}
+
+// ApplyPatch handles the endpoint that patches the TODO with JSON Merge Patch or JSON Patch.
+func (h *TODOHandler) ApplyPatch(ctx context.Context, req *model.ApplyTODOPatchRequest) (*model.PatchTODOResponse, error) {
+	var (
+		patch service.TODOPatch
+		err   error
+	)
+	switch req.ContentType {
+	case mediaTypeMergePatch:
+		patch, err = service.NewMergePatch(req.Patch)
+	case mediaTypeJSONPatch:
+		patch, err = service.NewJSONPatch(req.Patch)
+	default:
+		err = &model.ErrUnsupportedMediaType{MediaType: req.ContentType}
+	}
+	if err != nil {
+		return &model.PatchTODOResponse{}, err
+	}
+	todo, err := h.svc.ApplyTODOPatch(ctx, req.ID, patch, service.WithPrecondition(service.IfMatch(req.IfMatch...)))
+	return &model.PatchTODOResponse{TODO: *todo}, err
+}
+
 // Delete handles the endpoint that deletes the TODOs.
 func (h *TODOHandler) Delete(ctx context.Context, req *model.DeleteTODORequest) (*model.DeleteTODOResponse, error) {
-	err := h.svc.DeleteTODO(ctx, req.IDs)
+	err := h.svc.DeleteTODO(ctx, req.IDs, service.IfMatch(req.IfMatch...))
 	return &model.DeleteTODOResponse{}, err
 }
 
-func (t *TODOHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+// Restore handles the endpoint that restores the TODO from the trash.
+func (h *TODOHandler) Restore(ctx context.Context, req *model.RestoreTODORequest) (*model.RestoreTODOResponse, error) {
+	todo, err := h.svc.RestoreTODO(ctx, req.ID)
+	return &model.RestoreTODOResponse{TODO: *todo}, err
+}
+
+// History handles the endpoint that reads the history of the TODO.
+func (h *TODOHandler) History(ctx context.Context, req *model.ReadTODOHistoryRequest) (*model.ReadTODOHistoryResponse, error) {
+	events, err := h.svc.ReadTODOHistory(ctx, req.ID)
+	return &model.ReadTODOHistoryResponse{Events: events}, err
+}
+
+// Revert handles the endpoint that reverts the TODO to a revision of its history.
+func (h *TODOHandler) Revert(ctx context.Context, req *model.RevertTODORequest) (*model.RevertTODOResponse, error) {
+	todo, err := h.svc.RevertTODO(ctx, req.ID, req.Revision, service.WithPrecondition(service.IfMatch(req.IfMatch...)))
+	return &model.RevertTODOResponse{TODO: *todo}, err
+}
+
+// ServeHTTP implements http.Handler interface.
+// It serves the collection /todos, the items /todos/{id} and their
+// subresources restore, history and revert.
+func (h *TODOHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/todos" {
+		h.serveCollection(w, r)
+		return
+	}
+	if params, ok := matchPath("/todos/{id}", r.URL.Path); ok {
+		h.serveItem(w, r, params[0])
+		return
+	}
+	if params, ok := matchPath("/todos/{id}/restore", r.URL.Path); ok {
+		h.serveRestore(w, r, params[0])
+		return
+	}
+	if params, ok := matchPath("/todos/{id}/history", r.URL.Path); ok {
+		h.serveHistory(w, r, params[0])
+		return
+	}
+	if params, ok := matchPath("/todos/{id}/revert", r.URL.Path); ok {
+		h.serveRevert(w, r, params[0])
+		return
+	}
+	response.Error(w, r, &model.ErrNotFound{})
+}
+
+func (h *TODOHandler) serveRestore(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	req := &model.RestoreTODORequest{ID: id}
+	if err := req.Validate(); err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	res, err := h.Restore(r.Context(), req)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	setETag(w, &res.TODO)
+	response.JSON(w, http.StatusOK, res)
+}
+
+func (h *TODOHandler) serveItem(w http.ResponseWriter, r *http.Request, id int64) {
+	var (
+		res  interface{}
+		todo *model.TODO
+		err  error
+	)
 	switch r.Method {
-	case "POST":
-		req := &model.CreateTODORequest{}
-		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-			log.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
+	case http.MethodGet:
+		req := &model.FindTODORequest{ID: id}
+		if err := req.Validate(); err != nil {
+			response.Error(w, r, err)
 			return
 		}
-		if req.Subject == "" {
-			w.WriteHeader(http.StatusBadRequest)
+		found, ferr := h.Find(r.Context(), req)
+		if ferr == nil && noneMatch(r, found.TODO.ETag()) {
+			w.Header().Set("ETag", found.TODO.ETag())
+			w.WriteHeader(http.StatusNotModified)
 			return
 		}
-		res, err := t.Create(r.Context(), req)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+		res, todo, err = found, &found.TODO, ferr
+	case http.MethodPatch:
+		patched, perr := h.servePatch(w, r, id)
+		if patched == nil {
 			return
 		}
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		w.WriteHeader(http.StatusOK)
-		if err := json.NewEncoder(w).Encode(res); err != nil {
-			log.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+		res, todo, err = patched, &patched.TODO, perr
+	case http.MethodDelete:
+		res, err = h.Delete(r.Context(), &model.DeleteTODORequest{IDs: []int64{id}, IfMatch: ifMatch(r)})
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPatch, http.MethodDelete)
+		return
+	}
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	setETag(w, todo)
+	response.JSON(w, http.StatusOK, res)
+}
+
+func (h *TODOHandler) serveHistory(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	req := &model.ReadTODOHistoryRequest{ID: id}
+	if err := req.Validate(); err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	res, err := h.History(r.Context(), req)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, res)
+}
+
+func (h *TODOHandler) serveRevert(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	req := &model.RevertTODORequest{ID: id, IfMatch: ifMatch(r)}
+	if err := decodeJSON(r, req); err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	res, err := h.Revert(r.Context(), req)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	setETag(w, &res.TODO)
+	response.JSON(w, http.StatusOK, res)
+}
+
+// acceptPatch lists the media types PATCH /todos/{id} accepts.
+var acceptPatch = strings.Join([]string{mediaTypeJSON, mediaTypeMergePatch, mediaTypeJSONPatch}, ", ")
+
+// servePatch patches the TODO with id, choosing the format of the patch by its
+// Content-Type. application/json sets the fields given, leaving null ones
+// unchanged, whereas null removes a field in JSON Merge Patch. It returns nil
+// when it has responded to a request which cannot be decoded.
+func (h *TODOHandler) servePatch(w http.ResponseWriter, r *http.Request, id int64) (*model.PatchTODOResponse, error) {
+	switch mt := mediaType(r); mt {
+	case "", mediaTypeJSON:
+		req := &model.PatchTODORequest{ID: id, IfMatch: ifMatch(r)}
+		if err := decodeJSON(r, req); err != nil {
+			response.Error(w, r, err)
+			return nil, err
 		}
-	case "PUT":
-		req := &model.UpdateTODORequest{}
-		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-			log.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+		return h.Patch(r.Context(), req)
+	case mediaTypeMergePatch, mediaTypeJSONPatch:
+		req := &model.ApplyTODOPatchRequest{ID: id, ContentType: mt, IfMatch: ifMatch(r)}
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			response.Error(w, r, &model.ErrBadRequest{Message: "failed to read body: " + err.Error()})
+			return nil, err
+		}
+		req.Patch = b
+		if err := req.Validate(); err != nil {
+			response.Error(w, r, err)
+			return nil, err
 		}
-		if req.Subject == "" || req.ID == 0 {
-			w.WriteHeader(http.StatusBadRequest)
+		return h.ApplyPatch(r.Context(), req)
+	default:
+		w.Header().Set("Accept-Patch", acceptPatch)
+		err := &model.ErrUnsupportedMediaType{MediaType: mt}
+		response.Error(w, r, err)
+		return nil, err
+	}
+}
+
+func (h *TODOHandler) serveCollection(w http.ResponseWriter, r *http.Request) {
+	var (
+		res  interface{}
+		todo *model.TODO
+		err  error
+	)
+	switch r.Method {
+	case http.MethodPost:
+		req := &model.CreateTODORequest{}
+		if err := decodeJSON(r, req); err != nil {
+			response.Error(w, r, err)
 			return
 		}
-		res, err := t.Update(r.Context(), req)
-		if errors.Is(err, &model.ErrNotFound{}) {
-			w.WriteHeader(http.StatusNotFound)
+		created, cerr := h.Create(r.Context(), req)
+		res, todo, err = created, &created.TODO, cerr
+	case http.MethodPut:
+		req := &model.UpdateTODORequest{IfMatch: ifMatch(r)}
+		if err := decodeJSON(r, req); err != nil {
+			response.Error(w, r, err)
 			return
 		}
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+		updated, uerr := h.Update(r.Context(), req)
+		res, todo, err = updated, &updated.TODO, uerr
+	case http.MethodGet:
+		req, perr := parseReadTODORequest(r.URL.Query())
+		if perr != nil {
+			response.Error(w, r, perr)
 			return
 		}
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		w.WriteHeader(http.StatusOK)
-		if err := json.NewEncoder(w).Encode(res); err != nil {
-			log.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
+		res, err = h.Read(r.Context(), req)
+	case http.MethodDelete:
+		req := &model.DeleteTODORequest{IfMatch: ifMatch(r)}
+		if err := decodeJSON(r, req); err != nil {
+			response.Error(w, r, err)
 			return
 		}
-	case "GET":
-		req := &model.ReadTODORequest{PrevID: 0, Size: 5}
-		pid := r.URL.Query().Get("prev_id")
-		size := r.URL.Query().Get("size")
-		var err error
-		if pid != "" {
-			req.PrevID, err = strconv.ParseInt(pid, 10, 64)
-			if err != nil {
-				log.Println(err)
-				w.WriteHeader(http.StatusBadRequest)
-				return
-			}
+		res, err = h.Delete(r.Context(), req)
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete)
+		return
+	}
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	setETag(w, todo)
+	response.JSON(w, http.StatusOK, res)
+}
+
+// parseReadTODORequest parses the query of GET /todos.
+func parseReadTODORequest(q url.Values) (*model.ReadTODORequest, error) {
+	req := &model.ReadTODORequest{PrevID: 0, Size: 5}
+	var err error
+	if pid := q.Get("prev_id"); pid != "" {
+		req.PrevID, err = strconv.ParseInt(pid, 10, 64)
+		if err != nil {
+			return nil, &model.ErrBadRequest{Message: "prev_id must be an integer"}
 		}
-		if size != "" {
-			req.Size, err = strconv.ParseInt(size, 10, 64)
-			if err != nil {
-				log.Println(err)
-				w.WriteHeader(http.StatusBadRequest)
-				return
+	}
+	if size := q.Get("size"); size != "" {
+		req.Size, err = strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			return nil, &model.ErrBadRequest{Message: "size must be an integer"}
+		}
+	}
+	req.Statuses, err = parseStatuses(q["status"])
+	if err != nil {
+		return nil, &model.ErrBadRequest{Message: err.Error()}
+	}
+	if err := parseDueFilter(q, &req.TODOFilter); err != nil {
+		return nil, &model.ErrBadRequest{Message: err.Error()}
+	}
+	if err := parseTagFilter(q, &req.TODOFilter); err != nil {
+		return nil, &model.ErrBadRequest{Message: err.Error()}
+	}
+	return req, req.Validate()
+}
+
+// splitQuery splits query values, each of which may list several items
+// separated by commas.
+func splitQuery(values []string) []string {
+	var ret []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item != "" {
+				ret = append(ret, item)
 			}
 		}
+	}
+	return ret
+}
 
-		res, err := t.Read(r.Context(), req)
+// parseStatuses parses status query values.
+func parseStatuses(values []string) ([]model.TODOStatus, error) {
+	var statuses []model.TODOStatus
+	for _, name := range splitQuery(values) {
+		status, err := model.ParseTODOStatus(name)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return nil, err
 		}
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		w.WriteHeader(http.StatusOK)
-		if err := json.NewEncoder(w).Encode(res); err != nil {
-			log.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-	case "DELETE":
-		req := &model.DeleteTODORequest{}
-		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-			log.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		if len(req.IDs) == 0 {
-			w.WriteHeader(http.StatusBadRequest)
-			return
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// parseDueFilter parses the overdue, due_before and due_after query values into f.
+// Times must be RFC 3339 so that the offset of the client is never guessed.
+func parseDueFilter(q url.Values, f *model.TODOFilter) error {
+	if v := q.Get("overdue"); v != "" {
+		overdue, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
 		}
-		res, err := t.Delete(r.Context(), req)
-		if errors.Is(err, &model.ErrNotFound{}) {
-			w.WriteHeader(http.StatusNotFound)
-			return
+		f.Overdue = overdue
+	}
+	for key, dst := range map[string]**time.Time{"due_before": &f.DueBefore, "due_after": &f.DueAfter} {
+		v := q.Get(key)
+		if v == "" {
+			continue
 		}
+		t, err := time.Parse(time.RFC3339, v)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		w.WriteHeader(http.StatusOK)
-		if err := json.NewEncoder(w).Encode(res); err != nil {
-			log.Println(err)
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+			return err
 		}
+		*dst = &t
+	}
+	return nil
+}
+
+// parseTagFilter parses the tag and tag_mode query values into f.
+// tag_mode is either all, the default, or any.
+func parseTagFilter(q url.Values, f *model.TODOFilter) error {
+	f.Tags = splitQuery(q["tag"])
+	switch mode := q.Get("tag_mode"); mode {
+	case "", "all":
+		f.AnyTag = false
+	case "any":
+		f.AnyTag = true
+	default:
+		return fmt.Errorf("unknown tag_mode %q", mode)
 	}
+	return nil
 }
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/TechBowl-japan/go-stations/handler/response"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A TODOStatusHandler implements the endpoint moving TODOs through their workflow.
+type TODOStatusHandler struct {
+	svc *service.TODOService
+}
+
+// NewTODOStatusHandler returns TODOStatusHandler based http.Handler.
+func NewTODOStatusHandler(svc *service.TODOService) *TODOStatusHandler {
+	return &TODOStatusHandler{
+		svc: svc,
+	}
+}
+
+// Update handles the endpoint that changes the status of the TODO.
+func (h *TODOStatusHandler) Update(ctx context.Context, req *model.UpdateTODOStatusRequest) (*model.UpdateTODOStatusResponse, error) {
+	todo, err := h.svc.UpdateTODOStatus(ctx, req.ID, req.Status)
+	return &model.UpdateTODOStatusResponse{TODO: *todo}, err
+}
+
+// ServeHTTP implements http.Handler interface.
+func (h *TODOStatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		methodNotAllowed(w, r, http.MethodPut)
+		return
+	}
+
+	req := &model.UpdateTODOStatusRequest{}
+	if err := decodeJSON(r, req); err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	res, err := h.Update(r.Context(), req)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	setETag(w, &res.TODO)
+	response.JSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,215 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/handler"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/repository"
+	"github.com/TechBowl-japan/go-stations/service"
+	"github.com/google/go-cmp/cmp"
+)
+
+// newTODOHandler returns a TODOHandler on TODOs in memory, serving requests
+// on behalf of a user as middleware.Authenticate does, with the service and a
+// context of the user to prepare TODOs with.
+func newTODOHandler(t *testing.T) (http.Handler, *service.TODOService, context.Context) {
+	t.Helper()
+	ctx := service.ContextWithUser(context.Background(), &model.User{ID: 1, Username: "user"})
+	svc := service.NewTODOServiceWithRepository(repository.NewMemoryTODORepository())
+	return withContext(ctx, handler.NewTODOHandler(svc)), svc, ctx
+}
+
+// withContext returns h serving requests with ctx instead of their own.
+func withContext(ctx context.Context, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// serve serves a request with body to h and returns the response.
+func serve(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, r)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// todoIDs returns the ids of the TODOs listed in the body of rec.
+func todoIDs(t *testing.T, rec *httptest.ResponseRecorder) []int64 {
+	t.Helper()
+	var res model.ReadTODOResponse
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatal("failed to decode todos, err =", err)
+	}
+	ids := []int64{}
+	for _, todo := range res.TODOs {
+		ids = append(ids, todo.ID)
+	}
+	return ids
+}
+
+func TestReadTODODueFilter(t *testing.T) {
+	t.Parallel()
+
+	h, svc, ctx := newTODOHandler(t)
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	overdue, err := svc.CreateTODO(ctx, "overdue", "", service.WithDueAt(&past))
+	if err != nil {
+		t.Fatal("failed to create todo, err =", err)
+	}
+	later, err := svc.CreateTODO(ctx, "due later", "", service.WithDueAt(&future))
+	if err != nil {
+		t.Fatal("failed to create todo, err =", err)
+	}
+	noDue, err := svc.CreateTODO(ctx, "no due", "")
+	if err != nil {
+		t.Fatal("failed to create todo, err =", err)
+	}
+
+	now := url.QueryEscape(time.Now().Format(time.RFC3339))
+	cases := map[string]struct {
+		query  string
+		status int
+		ids    []int64
+	}{
+		"Overdue":               {query: "overdue=true", status: http.StatusOK, ids: []int64{overdue.ID}},
+		"Not overdue":           {query: "overdue=false&size=10", status: http.StatusOK, ids: []int64{noDue.ID, later.ID, overdue.ID}},
+		"Due before":            {query: "due_before=" + now, status: http.StatusOK, ids: []int64{overdue.ID}},
+		"Due after":             {query: "due_after=" + now, status: http.StatusOK, ids: []int64{later.ID}},
+		"Invalid overdue":       {query: "overdue=maybe", status: http.StatusBadRequest},
+		"Time without offset":   {query: "due_before=2030-01-01T00:00:00", status: http.StatusBadRequest},
+		"Date only":             {query: "due_after=2030-01-01", status: http.StatusBadRequest},
+		"Bounds in wrong order": {query: "due_before=2020-01-01T00:00:00Z&due_after=2030-01-01T00:00:00Z", status: http.StatusUnprocessableEntity},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := serve(h, http.MethodGet, "/todos?"+c.query, "")
+			if rec.Code != c.status {
+				t.Fatalf("unexpected status, want = %d, given = %d, body = %s", c.status, rec.Code, rec.Body)
+			}
+			if c.status != http.StatusOK {
+				return
+			}
+			if diff := cmp.Diff(c.ids, todoIDs(t, rec)); diff != "" {
+				t.Error("unexpected ids, diff =", diff)
+			}
+		})
+	}
+}
+
+func TestTODOItem(t *testing.T) {
+	t.Parallel()
+
+	h, svc, ctx := newTODOHandler(t)
+	todo, err := svc.CreateTODO(ctx, "subject", "description")
+	if err != nil {
+		t.Fatal("failed to create todo, err =", err)
+	}
+	item := "/todos/" + strconv.FormatInt(todo.ID, 10)
+
+	// decode returns the TODO in the body of rec.
+	decode := func(t *testing.T, rec *httptest.ResponseRecorder) *model.TODO {
+		t.Helper()
+		var res model.FindTODOResponse
+		if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+			t.Fatal("failed to decode todo, err =", err)
+		}
+		return &res.TODO
+	}
+	// errorCode returns the code of the error envelope in the body of rec.
+	errorCode := func(t *testing.T, rec *httptest.ResponseRecorder) string {
+		t.Helper()
+		var res model.ErrorResponse
+		if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+			t.Fatal("failed to decode error, err =", err)
+		}
+		return res.Error.Code
+	}
+
+	rec := serve(h, http.MethodGet, item, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status of GET, given = %d, body = %s", rec.Code, rec.Body)
+	}
+	if got := decode(t, rec); got.ID != todo.ID || got.Subject != "subject" || rec.Header().Get("ETag") != todo.ETag() {
+		t.Errorf("unexpected todo, given = %+v, ETag = %s", got, rec.Header().Get("ETag"))
+	}
+
+	for _, path := range []string{"/todos/100", "/todos/0", "/todos/-1", "/todos/abc", item + "/unknown"} {
+		rec := serve(h, http.MethodGet, path, "")
+		if rec.Code != http.StatusNotFound || errorCode(t, rec) != "not_found" {
+			t.Errorf("unexpected response to GET %s, status = %d", path, rec.Code)
+		}
+	}
+
+	rec = serve(h, http.MethodPut, item, `{"subject": "changed"}`)
+	if rec.Code != http.StatusMethodNotAllowed || errorCode(t, rec) != "method_not_allowed" {
+		t.Errorf("unexpected response to PUT, status = %d", rec.Code)
+	}
+	if allow := rec.Header().Get("Allow"); allow != "GET, PATCH, DELETE" {
+		t.Errorf("unexpected Allow, given = %s", allow)
+	}
+
+	// 省略した項目は変わらない
+	rec = serve(h, http.MethodPatch, item, `{"subject": "changed", "status": "done"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status of PATCH, given = %d, body = %s", rec.Code, rec.Body)
+	}
+	if got := decode(t, rec); got.Subject != "changed" || got.Description != "description" || got.Status != model.TODOStatusDone {
+		t.Errorf("unexpected patched todo, given = %+v", got)
+	}
+	rec = serve(h, http.MethodPatch, item, `{"status": "in_progress"}`)
+	if rec.Code != http.StatusConflict || errorCode(t, rec) != "conflict" {
+		t.Errorf("unexpected response to a forbidden transition, status = %d", rec.Code)
+	}
+	rec = serve(h, http.MethodPatch, item, `{"subject": ""}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("unexpected response to an empty subject, status = %d", rec.Code)
+	}
+
+	rec = serve(h, http.MethodDelete, item, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status of DELETE, given = %d, body = %s", rec.Code, rec.Body)
+	}
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		if rec := serve(h, method, item, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("unexpected status of %s after DELETE, given = %d", method, rec.Code)
+		}
+	}
+}
+
+func TestTODOItemIfMatchMissing(t *testing.T) {
+	t.Parallel()
+
+	h, _, _ := newTODOHandler(t)
+	for _, method := range []string{http.MethodPatch, http.MethodDelete} {
+		req := httptest.NewRequest(method, "/todos/100", strings.NewReader(`{"subject": "subject"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", "*")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusPreconditionFailed {
+			t.Errorf("unexpected status of %s, want = %d, given = %d", method, http.StatusPreconditionFailed, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/handler/response"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A TokenHandler implements the endpoint /auth/token issuing JWTs, which
+// follows the token endpoint of OAuth 2.0 with JSON bodies.
+type TokenHandler struct {
+	svc *service.TokenService
+}
+
+// NewTokenHandler returns TokenHandler based http.Handler.
+func NewTokenHandler(svc *service.TokenService) *TokenHandler {
+	return &TokenHandler{
+		svc: svc,
+	}
+}
+
+// Issue handles the endpoint that issues an access token and a refresh token.
+func (h *TokenHandler) Issue(ctx context.Context, req *model.IssueTokenRequest) (*model.IssueTokenResponse, error) {
+	var (
+		pair *model.TokenPair
+		err  error
+	)
+	scopes := strings.Fields(req.Scope)
+	if req.GrantType == model.GrantTypeRefreshToken {
+		pair, err = h.svc.RefreshTokens(ctx, req.RefreshToken, scopes)
+	} else {
+		pair, err = h.svc.IssueTokens(ctx, req.Username, req.Password, scopes)
+	}
+	if err != nil {
+		return &model.IssueTokenResponse{}, err
+	}
+	return &model.IssueTokenResponse{
+		AccessToken:  pair.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(time.Until(pair.ExpiresAt).Round(time.Second) / time.Second),
+		RefreshToken: pair.RefreshToken,
+		Scope:        strings.Join(pair.Scopes, " "),
+	}, nil
+}
+
+// ServeHTTP implements http.Handler interface.
+func (h *TokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	req := &model.IssueTokenRequest{}
+	if err := decodeJSON(r, req); err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	res, err := h.Issue(r.Context(), req)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	// tokens must not be cached, as RFC 6749 requires
+	w.Header().Set("Cache-Control", "no-store")
+	response.JSON(w, http.StatusOK, res)
+}
+
+// A JWKSHandler implements the endpoint publishing the keys verifying JWTs.
+type JWKSHandler struct {
+	svc *service.TokenService
+}
+
+// NewJWKSHandler returns JWKSHandler based http.Handler.
+func NewJWKSHandler(svc *service.TokenService) *JWKSHandler {
+	return &JWKSHandler{
+		svc: svc,
+	}
+}
+
+// ServeHTTP implements http.Handler interface.
+func (h *JWKSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	// verifiers may cache the keys for a while
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	response.JSON(w, http.StatusOK, h.svc.JWKS())
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/TechBowl-japan/go-stations/handler/response"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// A TrashHandler implements the endpoint listing deleted TODOs.
+// They are restored by POST /todos/{id}/restore of TODOHandler.
+type TrashHandler struct {
+	svc *service.TODOService
+}
+
+// NewTrashHandler returns TrashHandler based http.Handler.
+func NewTrashHandler(svc *service.TODOService) *TrashHandler {
+	return &TrashHandler{
+		svc: svc,
+	}
+}
+
+// Read handles the endpoint that reads the TODOs in the trash.
+func (h *TrashHandler) Read(ctx context.Context, req *model.ReadTrashRequest) (*model.ReadTrashResponse, error) {
+	todos, err := h.svc.ReadTrash(ctx, req.PrevID, req.Size)
+	return &model.ReadTrashResponse{TODOs: todos}, err
+}
+
+// ServeHTTP implements http.Handler interface.
+func (h *TrashHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	q := r.URL.Query()
+	req := &model.ReadTrashRequest{PrevID: 0, Size: 5}
+	var err error
+	if pid := q.Get("prev_id"); pid != "" {
+		req.PrevID, err = strconv.ParseInt(pid, 10, 64)
+		if err != nil {
+			response.Error(w, r, &model.ErrBadRequest{Message: "prev_id must be an integer"})
+			return
+		}
+	}
+	if size := q.Get("size"); size != "" {
+		req.Size, err = strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			response.Error(w, r, &model.ErrBadRequest{Message: "size must be an integer"})
+			return
+		}
+	}
+	if err := req.Validate(); err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	res, err := h.Read(r.Context(), req)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	response.JSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,42 @@
+// Package logger writes logs with the request ID of the context they are
+// written in, so that every log of a request can be told apart and
+// correlated, from the handler down to the SQL errors of its services.
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a copy of ctx with the request ID id, which
+// every log written with the copy carries.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID of ctx, if any.
+func RequestID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// Println logs v as log.Println does, after the request ID of ctx if any.
+func Println(ctx context.Context, v ...interface{}) {
+	log.Output(2, prefix(ctx)+fmt.Sprintln(v...))
+}
+
+// Printf logs as log.Printf does, after the request ID of ctx if any.
+func Printf(ctx context.Context, format string, v ...interface{}) {
+	log.Output(2, prefix(ctx)+fmt.Sprintf(format, v...))
+}
+
+// prefix returns what logs start with in ctx.
+func prefix(ctx context.Context) string {
+	if id, ok := RequestID(ctx); ok {
+		return "request_id=" + id + " "
+	}
+	return ""
+}
@@ -0,0 +1,31 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/logger"
+)
+
+func TestPrintln(t *testing.T) {
+	var buf bytes.Buffer
+	output, flags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(output)
+		log.SetFlags(flags)
+	})
+
+	ctx := context.Background()
+	logger.Println(ctx, "no id")
+	logger.Println(logger.ContextWithRequestID(ctx, "abc"), "with", "id")
+	logger.Printf(logger.ContextWithRequestID(ctx, "def"), "%d", 42)
+
+	want := "no id\nrequest_id=abc with id\nrequest_id=def 42\n"
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected logs, want = %q, given = %q", want, got)
+	}
+}
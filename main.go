@@ -2,31 +2,51 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/handler/middleware"
 	"github.com/TechBowl-japan/go-stations/handler/router"
+	"github.com/TechBowl-japan/go-stations/service"
+	"github.com/TechBowl-japan/go-stations/tracing"
 )
 
 func main() {
-	err := realMain()
+	var err error
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err = migrateMain(os.Args[2:])
+	} else {
+		err = realMain()
+	}
 	if err != nil {
 		log.Fatalln("main: failed to exit successfully, err =", err)
 	}
 }
 
-func realMain() error {
-	// config values
-	const (
-		defaultPort   = ":8080"
-		defaultDBPath = ".sqlite3/todo.db"
-	)
+// config values
+const (
+	defaultPort   = ":8080"
+	defaultDBPath = ".sqlite3/todo.db"
+	// defaultTrashRetentionDays is how long deleted TODOs stay in the trash.
+	defaultTrashRetentionDays = 30
+	defaultTrashPurgeInterval = time.Hour
+	// defaultCrashReportMax is how many crash reports are kept.
+	defaultCrashReportMax = 100
+)
 
+func realMain() error {
+	var err error
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = defaultPort
@@ -37,8 +57,144 @@ func realMain() error {
 		dbPath = defaultDBPath
 	}
 
+	// TRASH_RETENTION_DAYS=0 keeps deleted TODOs in the trash forever
+	retentionDays := defaultTrashRetentionDays
+	if v := os.Getenv("TRASH_RETENTION_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid TRASH_RETENTION_DAYS %q", v)
+		}
+		retentionDays = n
+	}
+
+	purgeInterval := defaultTrashPurgeInterval
+	if v := os.Getenv("TRASH_PURGE_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid TRASH_PURGE_INTERVAL %q", v)
+		}
+		purgeInterval = d
+	}
+
+	// JWT_KEYS lists the keys signing JWTs, the first of which signs new ones,
+	// e.g. "2024-02:EdDSA:<base64 seed>,2024-01:HS256:<base64 secret>"
+	var routerOpts []router.Option
+	if v := os.Getenv("JWT_KEYS"); v != "" {
+		keys, err := service.ParseKeySet(v)
+		if err != nil {
+			return fmt.Errorf("invalid JWT_KEYS: %w", err)
+		}
+		routerOpts = append(routerOpts, router.WithKeySet(keys))
+	}
+
+	// TRUSTED_PROXIES lists the proxies whose X-Forwarded-For tells the client
+	// address, e.g. "10.0.0.0/8", for rate limits and basic auth lockouts.
+	proxies, err := middleware.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		return fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+
+	// BASIC_AUTH_FILE is an htpasswd file with bcrypt hashes, reloaded on SIGHUP.
+	// BASIC_AUTH_USER_ID and BASIC_AUTH_PASSWORD make a single user without it.
+	var basicAuth *middleware.Credentials
+	if v := os.Getenv("BASIC_AUTH_FILE"); v != "" {
+		basicAuth, err = middleware.LoadCredentials(v)
+	} else {
+		basicAuth, err = middleware.StaticCredentials(os.Getenv("BASIC_AUTH_USER_ID"), os.Getenv("BASIC_AUTH_PASSWORD"))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid basic auth credentials: %w", err)
+	}
+	basicAuthOpts := []middleware.BasicAuthOption{middleware.WithLockoutProxies(proxies...)}
+	if v := os.Getenv("BASIC_AUTH_REALM"); v != "" {
+		basicAuthOpts = append(basicAuthOpts, middleware.WithRealm(v))
+	}
+	routerOpts = append(routerOpts, router.WithBasicAuth(basicAuth, basicAuthOpts...))
+
+	// METRICS_BASIC_AUTH_FILE, or METRICS_BASIC_AUTH_USER_ID and
+	// METRICS_BASIC_AUTH_PASSWORD, put /metrics behind basic auth in the same
+	// way. Without them, /metrics must be firewalled from everyone but the
+	// scrapers.
+	var metricsAuth *middleware.Credentials
+	if v := os.Getenv("METRICS_BASIC_AUTH_FILE"); v != "" {
+		metricsAuth, err = middleware.LoadCredentials(v)
+	} else if v := os.Getenv("METRICS_BASIC_AUTH_USER_ID"); v != "" {
+		metricsAuth, err = middleware.StaticCredentials(v, os.Getenv("METRICS_BASIC_AUTH_PASSWORD"))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid metrics basic auth credentials: %w", err)
+	}
+	if metricsAuth != nil {
+		routerOpts = append(routerOpts, router.WithMetricsAuth(metricsAuth, middleware.WithRealm("metrics"), middleware.WithLockoutProxies(proxies...)))
+	} else {
+		log.Println("/metrics is served without authentication, set METRICS_BASIC_AUTH_FILE to protect it")
+	}
+
+	// RATE_LIMIT_AUTH, RATE_LIMIT_API and RATE_LIMIT_API_CLIENT replace the
+	// limits of the route groups, e.g. "300/1m", or lift them with "off".
+	limiter := middleware.NewRateLimiter(middleware.WithTrustedProxies(proxies...))
+	routerOpts = append(routerOpts, router.WithRateLimiter(limiter))
+	for env, group := range map[string]string{
+		"RATE_LIMIT_AUTH":       router.RouteGroupAuth,
+		"RATE_LIMIT_API":        router.RouteGroupAPI,
+		"RATE_LIMIT_API_CLIENT": router.RouteGroupAPIClient,
+	} {
+		if v := os.Getenv(env); v != "" {
+			limit, err := middleware.ParseLimit(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", env, err)
+			}
+			routerOpts = append(routerOpts, router.WithRateLimit(group, limit))
+		}
+	}
+
+	// ACCESS_LOG_FILE writes the access logs to a file rotated once it grows
+	// past ACCESS_LOG_MAX_SIZE_MB, keeping ACCESS_LOG_MAX_BACKUPS of them,
+	// instead of stdout. ACCESS_LOG_FORMAT is json or combined, the Combined
+	// Log Format of the Apache HTTP Server.
+	accessLog, err := openAccessLog()
+	if err != nil {
+		return err
+	}
+	defer accessLog.Close()
+	routerOpts = append(routerOpts, router.WithAccessLog(accessLog.sink))
+
+	// CRASH_REPORT_DIR writes a report of every panic of handlers into the
+	// directory, keeping the newest CRASH_REPORT_MAX of them.
+	if dir := os.Getenv("CRASH_REPORT_DIR"); dir != "" {
+		maxReports, err := envInt("CRASH_REPORT_MAX", defaultCrashReportMax)
+		if err != nil {
+			return err
+		}
+		reports, err := middleware.NewCrashReportDir(dir, maxReports)
+		if err != nil {
+			return fmt.Errorf("invalid CRASH_REPORT_DIR: %w", err)
+		}
+		routerOpts = append(routerOpts, router.WithPanicReporters(reports))
+	}
+
+	// TRACE_FILE writes the spans of traced requests to a file as JSON lines.
+	// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, or OTEL_EXPORTER_OTLP_ENDPOINT
+	// followed by /v1/traces, exports them to a collector with OTLP/HTTP as
+	// OTEL_SERVICE_NAME instead. Without either, nothing is traced.
+	tracer, err := openTracer()
+	if err != nil {
+		return err
+	}
+	if tracer != nil {
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := tracer.Shutdown(ctx); err != nil {
+				log.Println("main: failed to export the last spans, err =", err)
+			}
+		}()
+		routerOpts = append(routerOpts, router.WithTracer(tracer))
+	}
+
 	// set time zone
-	var err error
+	// NOTE: time.Local only affects how times are presented. The service stores
+	// and compares every timestamp, including due dates, in UTC.
 	time.Local, err = time.LoadLocation("Asia/Tokyo")
 	if err != nil {
 		return err
@@ -51,26 +207,66 @@ func realMain() error {
 	}
 	defer todoDB.Close()
 
+	// /readyz fails as soon as shutdown begins. SHUTDOWN_DRAIN_DELAY is how
+	// long the server keeps serving afterwards, e.g. "10s", so that load
+	// balancers notice and stop sending requests before it stops accepting
+	// them. It must be longer than the interval of their health checks, and
+	// "0" shuts down at once, e.g. without any load balancer.
+	drainDelay := defaultDrainDelay
+	if v := os.Getenv("SHUTDOWN_DRAIN_DELAY"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid SHUTDOWN_DRAIN_DELAY %q", v)
+		}
+		drainDelay = d
+	}
+	health := service.NewHealthService(todoDB)
+	routerOpts = append(routerOpts, router.WithHealthService(health))
+
 	// NOTE: 新しいエンドポイントの登録はrouter.NewRouterの内部で行うようにする
-	mux := router.NewRouter(todoDB)
+	mux := router.NewRouter(todoDB, routerOpts...)
 	s := &http.Server{
 		Addr:    port,
 		Handler: mux,
 	}
 
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, os.Kill)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
 	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		basicAuth.ReloadOn(ctx, syscall.SIGHUP)
+	}()
+	if metricsAuth != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			metricsAuth.ReloadOn(ctx, syscall.SIGHUP)
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		limiter.SweepEvery(ctx, time.Minute)
+	}()
+
+	if retentionDays > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			retention := time.Duration(retentionDays) * 24 * time.Hour
+			service.NewTODOService(todoDB).PurgeTrashEvery(ctx, retention, purgeInterval)
+		}()
+	}
+
 	wg.Add(1)
 
 	go func() {
 		defer wg.Done()
-		<-ctx.Done()
-		//Shutdownで無期限に処理終了を待機しないように有効期限のあるcontextを渡す
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		if err := s.Shutdown(ctx); err != nil {
+		if err := drainAndShutdown(ctx, s, health, drainDelay, shutdownTimeout); err != nil {
 			log.Fatalf("failed to shutdonw err=%+v\n", err)
 		}
 	}()
@@ -83,3 +279,135 @@ func realMain() error {
 	wg.Wait()
 	return nil
 }
+
+const (
+	// defaultDrainDelay is how long the server keeps serving after /readyz
+	// starts failing, unless SHUTDOWN_DRAIN_DELAY says otherwise.
+	defaultDrainDelay = 5 * time.Second
+	// shutdownTimeout is how long shutting down waits for the requests in
+	// flight to be served.
+	shutdownTimeout = 5 * time.Second
+)
+
+// drainAndShutdown shuts s down once ctx is done. health is drained first,
+// and s keeps serving for drainDelay, so that load balancers see /readyz
+// failing and stop sending requests before s stops accepting them. The
+// requests in flight are then waited for up to timeout.
+func drainAndShutdown(ctx context.Context, s interface{ Shutdown(context.Context) error }, health *service.HealthService, drainDelay, timeout time.Duration) error {
+	<-ctx.Done()
+	health.Drain()
+	if drainDelay > 0 {
+		log.Println("main: draining for", drainDelay, "before shutting down")
+		time.Sleep(drainDelay)
+	}
+	//Shutdownで無期限に処理終了を待機しないように有効期限のあるcontextを渡す
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return s.Shutdown(ctx)
+}
+
+// defaults of the access log file
+const (
+	defaultAccessLogMaxSizeMB  = 100
+	defaultAccessLogMaxBackups = 5
+)
+
+// An accessLog is the sink of the access logs and the file it writes to, if any.
+type accessLog struct {
+	sink middleware.AccessLogSink
+	file *middleware.RotatingFile
+}
+
+func (l *accessLog) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// openAccessLog opens the access log configured by the environment variables.
+func openAccessLog() (*accessLog, error) {
+	l := &accessLog{}
+	var w io.Writer = os.Stdout
+	if path := os.Getenv("ACCESS_LOG_FILE"); path != "" {
+		maxSize, err := envInt("ACCESS_LOG_MAX_SIZE_MB", defaultAccessLogMaxSizeMB)
+		if err != nil {
+			return nil, err
+		}
+		maxBackups, err := envInt("ACCESS_LOG_MAX_BACKUPS", defaultAccessLogMaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		if l.file, err = middleware.OpenRotatingFile(path, int64(maxSize)<<20, maxBackups); err != nil {
+			return nil, err
+		}
+		w = l.file
+	}
+
+	switch format := os.Getenv("ACCESS_LOG_FORMAT"); format {
+	case "", "json":
+		l.sink = middleware.NewJSONSink(w)
+	case "combined":
+		l.sink = middleware.NewCombinedSink(w)
+	default:
+		l.Close()
+		return nil, fmt.Errorf("invalid ACCESS_LOG_FORMAT %q", format)
+	}
+	return l, nil
+}
+
+// openTracer returns the tracer configured by the environment variables, or
+// nil when tracing is not configured. The tracer closes the file it writes to
+// on shutdown.
+func openTracer() (*tracing.Tracer, error) {
+	path := os.Getenv("TRACE_FILE")
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint == "" && v != "" {
+		endpoint = strings.TrimSuffix(v, "/") + "/v1/traces"
+	}
+	switch {
+	case path != "" && endpoint != "":
+		return nil, errors.New("TRACE_FILE and OTLP endpoints are exclusive")
+	case path != "":
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		return tracing.NewTracer(&closingExporter{Exporter: tracing.NewJSONLinesExporter(f), c: f}), nil
+	case endpoint != "":
+		var opts []tracing.OTLPOption
+		if v := os.Getenv("OTEL_SERVICE_NAME"); v != "" {
+			opts = append(opts, tracing.WithServiceName(v))
+		}
+		return tracing.NewTracer(tracing.NewOTLPExporter(endpoint, opts...)), nil
+	}
+	return nil, nil
+}
+
+// A closingExporter closes c once the Exporter has shut down.
+type closingExporter struct {
+	tracing.Exporter
+	c io.Closer
+}
+
+func (e *closingExporter) Shutdown(ctx context.Context) error {
+	err := e.Exporter.Shutdown(ctx)
+	if cerr := e.c.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// envInt returns the non-negative integer of the environment variable key,
+// or def when it is not set.
+func envInt(key string, def int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, v)
+	}
+	return n, nil
+}
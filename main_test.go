@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/handler"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestDrainAndShutdown(t *testing.T) {
+	t.Parallel()
+
+	health := service.NewHealthServiceWithChecks(time.Second)
+	s := &http.Server{Handler: handler.NewReadyzHandler(health)}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("failed to listen, err =", err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(ln)
+	}()
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}, Timeout: time.Second}
+	readyz := "http://" + ln.Addr().String() + "/readyz"
+	ready := func() (int, error) {
+		res, err := client.Get(readyz)
+		if err != nil {
+			return 0, err
+		}
+		res.Body.Close()
+		return res.StatusCode, nil
+	}
+	if status, err := ready(); err != nil || status != http.StatusOK {
+		t.Fatalf("unexpected readiness before shutdown, status = %d, err = %v", status, err)
+	}
+
+	const drainDelay = 500 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- drainAndShutdown(ctx, s, health, drainDelay, time.Second)
+	}()
+	start := time.Now()
+	cancel()
+
+	// 止まる前の猶予の間は、準備できていないと答えながらリクエストを受け付ける
+	status, err := 0, error(nil)
+	for time.Since(start) < drainDelay/2 {
+		if status, err = ready(); err != nil || status != http.StatusOK {
+			break
+		}
+	}
+	if err != nil || status != http.StatusServiceUnavailable {
+		t.Errorf("unexpected readiness while draining, status = %d, err = %v", status, err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal("failed to shut down, err =", err)
+	}
+	if elapsed := time.Since(start); elapsed < drainDelay {
+		t.Errorf("shut down before draining, elapsed = %s", elapsed)
+	}
+	if err := <-served; err != http.ErrServerClosed {
+		t.Errorf("unexpected error of serving, given = %v", err)
+	}
+	if _, err := ready(); err == nil {
+		t.Error("served a request after shutdown")
+	}
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import "database/sql"
+
+// DBStats registers the connection pool statistics of db, which are read
+// whenever the metrics are written.
+func (r *Registry) DBStats(db *sql.DB) {
+	stat := func(f func(s sql.DBStats) float64) func() float64 {
+		return func() float64 { return f(db.Stats()) }
+	}
+	r.GaugeFunc("db_max_open_connections", "Maximum number of open connections to the database.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }))
+	r.GaugeFunc("db_open_connections", "Number of established connections to the database, in use or idle.",
+		stat(func(s sql.DBStats) float64 { return float64(s.OpenConnections) }))
+	r.GaugeFunc("db_in_use_connections", "Number of connections to the database currently in use.",
+		stat(func(s sql.DBStats) float64 { return float64(s.InUse) }))
+	r.GaugeFunc("db_idle_connections", "Number of idle connections to the database.",
+		stat(func(s sql.DBStats) float64 { return float64(s.Idle) }))
+	r.CounterFunc("db_wait_count_total", "Total number of connections waited for.",
+		stat(func(s sql.DBStats) float64 { return float64(s.WaitCount) }))
+	r.CounterFunc("db_wait_duration_seconds_total", "Total time blocked waiting for a new connection.",
+		stat(func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }))
+	r.CounterFunc("db_max_idle_closed_total", "Total number of connections closed due to the maximum of idle connections.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }))
+	r.CounterFunc("db_max_lifetime_closed_total", "Total number of connections closed due to their maximum lifetime.",
+		stat(func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }))
+}
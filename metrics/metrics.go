@@ -0,0 +1,371 @@
+// Package metrics keeps counters, gauges and histograms, and exposes them in
+// the text exposition format of Prometheus.
+//
+// It implements the small part of the Prometheus client the server needs,
+// so that the server does not depend on it.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds of histogram buckets for latencies in
+// seconds, the same as those of the Prometheus client.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// A Registry holds metrics by name. Getting a metric registers it on first
+// use and returns the same metric afterwards, so that every user of a metric
+// shares it. It is safe for concurrent use.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+// A metric writes its samples in the text exposition format.
+type metric interface {
+	kind() string
+	help() string
+	labels() []string
+	write(b *bytes.Buffer, name string)
+}
+
+// NewRegistry returns a new empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{metrics: map[string]metric{}}
+}
+
+// get returns the metric with name, registering the one made by newMetric
+// when there is none. It panics when the metric with name is of another kind
+// or has other labels, since that is a programming error.
+func (r *Registry) get(name, kind string, labels []string, newMetric func() metric) metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m, ok := r.metrics[name]; ok {
+		if m.kind() != kind || strings.Join(m.labels(), ",") != strings.Join(labels, ",") {
+			panic(fmt.Sprintf("metrics: %s is registered as a %s with labels %v", name, m.kind(), m.labels()))
+		}
+		return m
+	}
+	m := newMetric()
+	r.metrics[name] = m
+	return m
+}
+
+// Counter returns the counter with name, partitioned by labels.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	return r.get(name, "counter", labels, func() metric {
+		return &Counter{vec: newVec(help, labels)}
+	}).(*Counter)
+}
+
+// Gauge returns the gauge with name, partitioned by labels.
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return r.get(name, "gauge", labels, func() metric {
+		return &Gauge{vec: newVec(help, labels)}
+	}).(*Gauge)
+}
+
+// Histogram returns the histogram with name, partitioned by labels, counting
+// observations into buckets with the upper bounds in buckets, e.g.
+// DefaultBuckets.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return r.get(name, "histogram", labels, func() metric {
+		b := append([]float64{}, buckets...)
+		sort.Float64s(b)
+		return &Histogram{vec: newVec(help, labels), buckets: b}
+	}).(*Histogram)
+}
+
+// GaugeFunc registers a gauge with name whose value is read from f whenever
+// the metrics are written, e.g. for values kept by other packages.
+func (r *Registry) GaugeFunc(name, help string, f func() float64) {
+	r.get(name, "gauge", nil, func() metric { return &funcMetric{k: "gauge", h: help, f: f} })
+}
+
+// CounterFunc registers a counter with name whose value is read from f
+// whenever the metrics are written. f must never decrease.
+func (r *Registry) CounterFunc(name, help string, f func() float64) {
+	r.get(name, "counter", nil, func() metric { return &funcMetric{k: "counter", h: help, f: f} })
+}
+
+// WriteText writes every metric in the text exposition format, sorted by name.
+func (r *Registry) WriteText(b *bytes.Buffer) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	metrics := make([]metric, len(names))
+	sort.Strings(names)
+	for i, name := range names {
+		metrics[i] = r.metrics[name]
+	}
+	r.mu.Unlock()
+
+	for i, m := range metrics {
+		fmt.Fprintf(b, "# HELP %s %s\n", names[i], escapeHelp(m.help()))
+		fmt.Fprintf(b, "# TYPE %s %s\n", names[i], m.kind())
+		m.write(b, names[i])
+	}
+}
+
+// ServeHTTP implements http.Handler interface, serving the metrics to
+// Prometheus.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	var b bytes.Buffer
+	r.WriteText(&b)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(b.Bytes())
+}
+
+// A vec holds the series of a metric, one per combination of label values.
+type vec struct {
+	h          string
+	labelNames []string
+
+	mu     sync.Mutex
+	series map[string]interface{}
+	// values are the label values of every series, by key.
+	values map[string][]string
+}
+
+func newVec(help string, labels []string) vec {
+	return vec{h: help, labelNames: labels, series: map[string]interface{}{}, values: map[string][]string{}}
+}
+
+func (v *vec) help() string     { return v.h }
+func (v *vec) labels() []string { return v.labelNames }
+
+// get returns the series of values, made by newSeries on first use.
+func (v *vec) get(values []string, newSeries func() interface{}) interface{} {
+	if len(values) != len(v.labelNames) {
+		panic(fmt.Sprintf("metrics: got %d label values for labels %v", len(values), v.labelNames))
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = newSeries()
+		v.series[key] = s
+		v.values[key] = append([]string{}, values...)
+	}
+	return s
+}
+
+// each calls f with the label pairs and the series of every series of v,
+// sorted by label values.
+func (v *vec) each(f func(labels string, series interface{})) {
+	v.mu.Lock()
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	type entry struct {
+		labels string
+		series interface{}
+	}
+	entries := make([]entry, len(keys))
+	for i, key := range keys {
+		entries[i] = entry{formatLabels(v.labelNames, v.values[key]), v.series[key]}
+	}
+	v.mu.Unlock()
+
+	for _, e := range entries {
+		f(e.labels, e.series)
+	}
+}
+
+// A value is a float64 updated atomically by its mutex.
+type value struct {
+	mu sync.Mutex
+	v  float64
+}
+
+func (v *value) add(d float64) {
+	v.mu.Lock()
+	v.v += d
+	v.mu.Unlock()
+}
+
+func (v *value) set(x float64) {
+	v.mu.Lock()
+	v.v = x
+	v.mu.Unlock()
+}
+
+func (v *value) get() float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.v
+}
+
+// A Counter is a metric which only goes up, e.g. the number of requests.
+type Counter struct {
+	vec
+}
+
+func (c *Counter) kind() string { return "counter" }
+
+// Add adds d, which must not be negative, to the series of labelValues.
+func (c *Counter) Add(d float64, labelValues ...string) {
+	if d < 0 {
+		panic("metrics: counters cannot decrease")
+	}
+	c.get(labelValues, func() interface{} { return &value{} }).(*value).add(d)
+}
+
+// Inc adds 1 to the series of labelValues.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) write(b *bytes.Buffer, name string) {
+	c.each(func(labels string, s interface{}) {
+		writeSample(b, name, labels, s.(*value).get())
+	})
+}
+
+// A Gauge is a metric which goes up and down, e.g. the requests in flight.
+type Gauge struct {
+	vec
+}
+
+func (g *Gauge) kind() string { return "gauge" }
+
+// Add adds d to the series of labelValues.
+func (g *Gauge) Add(d float64, labelValues ...string) {
+	g.get(labelValues, func() interface{} { return &value{} }).(*value).add(d)
+}
+
+// Set sets the series of labelValues to x.
+func (g *Gauge) Set(x float64, labelValues ...string) {
+	g.get(labelValues, func() interface{} { return &value{} }).(*value).set(x)
+}
+
+func (g *Gauge) write(b *bytes.Buffer, name string) {
+	g.each(func(labels string, s interface{}) {
+		writeSample(b, name, labels, s.(*value).get())
+	})
+}
+
+// A Histogram is a metric counting observations into buckets, e.g. latencies.
+type Histogram struct {
+	vec
+	buckets []float64
+}
+
+type histogramSeries struct {
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (h *Histogram) kind() string { return "histogram" }
+
+// Observe counts x into the series of labelValues.
+func (h *Histogram) Observe(x float64, labelValues ...string) {
+	s := h.get(labelValues, func() interface{} {
+		return &histogramSeries{counts: make([]uint64, len(h.buckets))}
+	}).(*histogramSeries)
+	i := sort.SearchFloat64s(h.buckets, x)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i < len(s.counts) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += x
+}
+
+func (h *Histogram) write(b *bytes.Buffer, name string) {
+	h.each(func(labels string, series interface{}) {
+		s := series.(*histogramSeries)
+		s.mu.Lock()
+		counts := append([]uint64{}, s.counts...)
+		count, sum := s.count, s.sum
+		s.mu.Unlock()
+
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += counts[i]
+			writeSample(b, name+"_bucket", appendLabel(labels, "le", formatFloat(upper)), float64(cumulative))
+		}
+		writeSample(b, name+"_bucket", appendLabel(labels, "le", "+Inf"), float64(count))
+		writeSample(b, name+"_sum", labels, sum)
+		writeSample(b, name+"_count", labels, float64(count))
+	})
+}
+
+// A funcMetric is a metric without labels whose value is read from f.
+type funcMetric struct {
+	k, h string
+	f    func() float64
+}
+
+func (m *funcMetric) kind() string     { return m.k }
+func (m *funcMetric) help() string     { return m.h }
+func (m *funcMetric) labels() []string { return nil }
+
+func (m *funcMetric) write(b *bytes.Buffer, name string) {
+	writeSample(b, name, "", m.f())
+}
+
+func writeSample(b *bytes.Buffer, name, labels string, v float64) {
+	b.WriteString(name)
+	if labels != "" {
+		b.WriteString("{" + labels + "}")
+	}
+	b.WriteString(" " + formatFloat(v) + "\n")
+}
+
+// formatLabels returns the label pairs of names and values, without braces.
+func formatLabels(names, values []string) string {
+	var pairs string
+	for i, name := range names {
+		pairs = appendLabel(pairs, name, values[i])
+	}
+	return pairs
+}
+
+func appendLabel(pairs, name, value string) string {
+	if pairs != "" {
+		pairs += ","
+	}
+	return pairs + name + `="` + labelEscaper.Replace(value) + `"`
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
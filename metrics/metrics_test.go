@@ -0,0 +1,68 @@
+package metrics_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/metrics"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRegistryWriteText(t *testing.T) {
+	t.Parallel()
+
+	r := metrics.NewRegistry()
+	requests := r.Counter("requests_total", "Requests.\nBy route.", "route", "status")
+	requests.Inc("/todos", "200")
+	requests.Add(2, "/todos", "200")
+	requests.Inc(`/a"b\`, "500")
+	r.Gauge("in_flight", "In flight.").Add(3)
+	r.Gauge("in_flight", "In flight.").Add(-1)
+	latency := r.Histogram("latency_seconds", "Latency.", []float64{0.5, 0.1}, "route")
+	latency.Observe(0.1, "/todos")
+	latency.Observe(0.3, "/todos")
+	latency.Observe(7, "/todos")
+	r.GaugeFunc("answer", "Answer.", func() float64 { return 42 })
+
+	// 同じ名前で取り直すと同じメトリクスになる
+	if r.Counter("requests_total", "Requests.", "route", "status") != requests {
+		t.Error("got another counter with the same name")
+	}
+
+	var b bytes.Buffer
+	r.WriteText(&b)
+	want := `# HELP answer Answer.
+# TYPE answer gauge
+answer 42
+# HELP in_flight In flight.
+# TYPE in_flight gauge
+in_flight 2
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/todos",le="0.1"} 1
+latency_seconds_bucket{route="/todos",le="0.5"} 2
+latency_seconds_bucket{route="/todos",le="+Inf"} 3
+latency_seconds_sum{route="/todos"} 7.4
+latency_seconds_count{route="/todos"} 3
+# HELP requests_total Requests.\nBy route.
+# TYPE requests_total counter
+requests_total{route="/a\"b\\",status="500"} 1
+requests_total{route="/todos",status="200"} 3
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("unexpected text, diff = %s", diff)
+	}
+}
+
+func TestRegistryConflict(t *testing.T) {
+	t.Parallel()
+
+	r := metrics.NewRegistry()
+	r.Counter("requests_total", "Requests.", "route")
+	defer func() {
+		if recover() == nil {
+			t.Error("registered a gauge with the name of a counter")
+		}
+	}()
+	r.Gauge("requests_total", "Requests.", "route")
+}
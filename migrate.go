@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/TechBowl-japan/go-stations/db"
+)
+
+const migrateUsage = `usage: go-stations migrate <command>
+
+commands:
+  up        apply every pending migration
+  down [n]  revert the last n applied migrations (default 1)
+  status    show the status of every migration
+
+The database is DB_PATH, or .sqlite3/todo.db by default.`
+
+// migrateMain runs the migrate subcommand with args following "migrate".
+func migrateMain(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	dbPath := os.Getenv("DB_PATH")
+	if dbPath == "" {
+		dbPath = defaultDBPath
+	}
+	todoDB, err := db.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer todoDB.Close()
+
+	m, err := db.NewMigrator(todoDB, db.Migrations())
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		if len(args) != 1 {
+			return errors.New(migrateUsage)
+		}
+		applied, err := m.Up(ctx)
+		if err != nil && len(applied) == 0 {
+			return err
+		}
+		printMigrations(os.Stdout, "applied", applied)
+		return err
+	case "down":
+		steps := 1
+		switch len(args) {
+		case 1:
+		case 2:
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of migrations %q", args[1])
+			}
+		default:
+			return errors.New(migrateUsage)
+		}
+		reverted, err := m.Down(ctx, steps)
+		if err != nil && len(reverted) == 0 {
+			return err
+		}
+		printMigrations(os.Stdout, "reverted", reverted)
+		return err
+	case "status":
+		if len(args) != 1 {
+			return errors.New(migrateUsage)
+		}
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		return printMigrationStatus(os.Stdout, statuses)
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+func printMigrations(w io.Writer, verb string, migrations []*db.Migration) {
+	if len(migrations) == 0 {
+		fmt.Fprintf(w, "no migration %s\n", verb)
+		return
+	}
+	for _, m := range migrations {
+		fmt.Fprintf(w, "%s %04d_%s\n", verb, m.Version, m.Name)
+	}
+}
+
+func printMigrationStatus(w io.Writer, statuses []*db.MigrationStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range statuses {
+		status, appliedAt := "pending", "-"
+		if s.Applied {
+			status = "applied"
+			appliedAt = s.AppliedAt.Local().Format("2006-01-02 15:04:05 MST")
+		}
+		switch {
+		case s.Unknown:
+			status = "unknown"
+		case s.Modified:
+			status = "modified"
+		}
+		fmt.Fprintf(tw, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, status, appliedAt)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,76 @@
+package model
+
+import "time"
+
+// Scopes of API tokens.
+const (
+	ScopeTODOsRead  = "todos:read"
+	ScopeTODOsWrite = "todos:write"
+)
+
+// Scopes lists every scope an API token can be granted.
+var Scopes = []string{ScopeTODOsRead, ScopeTODOsWrite}
+
+type (
+	// An APIToken expresses a personal access token of a user, which
+	// authenticates programs such as CI scripts without the password.
+	APIToken struct {
+		ID     int64  `json:"id"`
+		UserID int64  `json:"-"`
+		Name   string `json:"name"`
+		// Prefix is the public head of the token telling it apart from
+		// others. The token itself is only known when it is created.
+		Prefix string `json:"prefix"`
+		// TokenHash is the SHA-256 of the token.
+		TokenHash  string     `json:"-"`
+		Scopes     []string   `json:"scopes"`
+		LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+		CreatedAt  time.Time  `json:"created_at"`
+	}
+
+	// A Principal expresses who makes a request and what they may do.
+	Principal struct {
+		User *User
+		// Scopes are granted by the API token authenticating the request.
+		// Principals of sessions have every scope.
+		Scopes []string
+		// APITokenID is the ID of the API token authenticating the request,
+		// or 0 for sessions.
+		APITokenID int64
+	}
+
+	// A CreateAPITokenRequest expresses ...
+	CreateAPITokenRequest struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	// A CreateAPITokenResponse expresses ...
+	CreateAPITokenResponse struct {
+		APIToken APIToken `json:"api_token"`
+		Token    string   `json:"token"`
+	}
+
+	// A ReadAPITokensRequest expresses ...
+	ReadAPITokensRequest struct{}
+	// A ReadAPITokensResponse expresses ...
+	ReadAPITokensResponse struct {
+		APITokens []*APIToken `json:"api_tokens"`
+	}
+
+	// A RevokeAPITokenRequest expresses ...
+	RevokeAPITokenRequest struct {
+		ID int64 `json:"-"`
+	}
+	// A RevokeAPITokenResponse expresses ...
+	RevokeAPITokenResponse struct{}
+)
+
+// HasScope reports whether p is granted scope.
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
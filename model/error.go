@@ -1,8 +1,133 @@
 package model
 
+import "time"
+
 type ErrNotFound struct {
 }
 
 func (e *ErrNotFound) Error() string {
 	return "record not found"
 }
+
+// An ErrBadRequest expresses a request which cannot be parsed, e.g. malformed JSON.
+type ErrBadRequest struct {
+	Message string
+}
+
+func (e *ErrBadRequest) Error() string {
+	return e.Message
+}
+
+// An ErrValidation expresses a well-formed request with invalid values.
+// Fields lists every failing field when they are known.
+type ErrValidation struct {
+	Message string
+	Fields  []*FieldError
+}
+
+// A FieldError expresses why the value of a request field is invalid.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *ErrValidation) Error() string {
+	return e.Message
+}
+
+// An ErrConflict expresses a request conflicting with the current state of a resource.
+type ErrConflict struct {
+	Message string
+}
+
+func (e *ErrConflict) Error() string {
+	return e.Message
+}
+
+// An ErrPreconditionFailed expresses a conditional request whose condition
+// does not hold for the current state of a resource, e.g. a stale If-Match.
+type ErrPreconditionFailed struct {
+	Message string
+}
+
+func (e *ErrPreconditionFailed) Error() string {
+	if e.Message == "" {
+		return "precondition failed"
+	}
+	return e.Message
+}
+
+// An ErrUnauthorized expresses a request without valid credentials.
+type ErrUnauthorized struct {
+	Message string
+}
+
+func (e *ErrUnauthorized) Error() string {
+	if e.Message == "" {
+		return "unauthorized"
+	}
+	return e.Message
+}
+
+// An ErrForbidden expresses a request whose credentials do not permit it,
+// e.g. an API token without the required scope.
+type ErrForbidden struct {
+	Message string
+}
+
+func (e *ErrForbidden) Error() string {
+	if e.Message == "" {
+		return "forbidden"
+	}
+	return e.Message
+}
+
+// An ErrTooManyRequests expresses a request refused until RetryAfter has
+// passed, e.g. after too many failed logins.
+type ErrTooManyRequests struct {
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *ErrTooManyRequests) Error() string {
+	if e.Message == "" {
+		return "too many requests"
+	}
+	return e.Message
+}
+
+// An ErrMethodNotAllowed expresses a request with a method the endpoint does not serve.
+type ErrMethodNotAllowed struct {
+	Method string
+}
+
+func (e *ErrMethodNotAllowed) Error() string {
+	return "method " + e.Method + " is not allowed"
+}
+
+// An ErrUnsupportedMediaType expresses a request body in a format the endpoint does not accept.
+type ErrUnsupportedMediaType struct {
+	MediaType string
+}
+
+func (e *ErrUnsupportedMediaType) Error() string {
+	if e.MediaType == "" {
+		return "content type is required"
+	}
+	return "content type " + e.MediaType + " is not supported"
+}
+
+type (
+	// An ErrorResponse expresses the body of every error response.
+	ErrorResponse struct {
+		Error ErrorBody `json:"error"`
+	}
+
+	// An ErrorBody expresses what went wrong.
+	// Code is a stable identifier for programs, Message is for humans.
+	ErrorBody struct {
+		Code    string      `json:"code"`
+		Message string      `json:"message"`
+		Details interface{} `json:"details,omitempty"`
+	}
+)
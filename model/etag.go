@@ -0,0 +1,20 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// ETag returns a strong entity tag of the JSON representation of the TODO,
+// quoted as in the ETag header. It changes whenever the representation does,
+// which includes updated_at, so that any write invalidates it.
+// It returns "" for a TODO which cannot be represented, e.g. with an unknown status.
+func (t *TODO) ETag() string {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(b)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
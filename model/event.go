@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// A TODOEventAction expresses the kind of change a TODOEvent records.
+type TODOEventAction string
+
+const (
+	TODOEventCreate  TODOEventAction = "create"
+	TODOEventUpdate  TODOEventAction = "update"
+	TODOEventDelete  TODOEventAction = "delete"
+	TODOEventRestore TODOEventAction = "restore"
+	TODOEventRevert  TODOEventAction = "revert"
+)
+
+type (
+	// A TODOEvent expresses a change of a TODO in its history.
+	//
+	// Revision numbers the events of each TODO from 1, and After is the TODO
+	// as of the revision. Before is nil for a create or a restore, and After
+	// is nil for a delete.
+	TODOEvent struct {
+		TODOID   int64           `json:"todo_id"`
+		Revision int64           `json:"revision"`
+		Action   TODOEventAction `json:"action"`
+		// Actor is who made the change, empty when it is unknown.
+		Actor string `json:"actor,omitempty"`
+		// RevertedTo is the revision a revert went back to.
+		RevertedTo int64     `json:"reverted_to,omitempty"`
+		Before     *TODO     `json:"before"`
+		After      *TODO     `json:"after"`
+		CreatedAt  time.Time `json:"created_at"`
+	}
+
+	// A ReadTODOHistoryRequest expresses ...
+	ReadTODOHistoryRequest struct {
+		ID int64
+	}
+	// A ReadTODOHistoryResponse expresses ...
+	ReadTODOHistoryResponse struct {
+		Events []*TODOEvent `json:"events"`
+	}
+
+	// A RevertTODORequest expresses ...
+	RevertTODORequest struct {
+		ID       int64    `json:"-"`
+		Revision int64    `json:"revision"`
+		IfMatch  []string `json:"-"`
+	}
+	// A RevertTODOResponse expresses ...
+	RevertTODOResponse struct {
+		TODO `json:"todo"`
+	}
+)
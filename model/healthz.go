@@ -4,3 +4,24 @@ package model
 type HealthzResponse struct {
 	Message string `json:"message"`
 }
+
+// Statuses of probes and their checks.
+const (
+	ProbeStatusOK      = "ok"
+	ProbeStatusFailing = "failing"
+)
+
+// A ProbeResponse expresses the result of a liveness or readiness probe,
+// with the result of each of its checks by name.
+type ProbeResponse struct {
+	Status string                  `json:"status"`
+	Checks map[string]*CheckResult `json:"checks,omitempty"`
+}
+
+// A CheckResult expresses the result of a check of a probe.
+type CheckResult struct {
+	Status string `json:"status"`
+	// Duration is how long the check took, in milliseconds.
+	Duration float64 `json:"duration_ms"`
+	Error    string  `json:"error,omitempty"`
+}
@@ -0,0 +1,109 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Grant types of IssueTokenRequest, as in OAuth 2.0.
+const (
+	GrantTypePassword     = "password"
+	GrantTypeRefreshToken = "refresh_token"
+)
+
+type (
+	// Claims expresses the payload of a JWT issued by the service.
+	// Times are in seconds since the Unix epoch.
+	Claims struct {
+		Issuer    string   `json:"iss,omitempty"`
+		Subject   string   `json:"sub"`
+		Audience  Audience `json:"aud"`
+		ExpiresAt int64    `json:"exp"`
+		NotBefore int64    `json:"nbf,omitempty"`
+		IssuedAt  int64    `json:"iat,omitempty"`
+		ID        string   `json:"jti,omitempty"`
+		Username  string   `json:"username,omitempty"`
+		// Scope lists the granted scopes separated by spaces.
+		Scope string `json:"scope,omitempty"`
+	}
+
+	// Audience expresses the aud claim, which is either a string or an
+	// array of strings in JWTs.
+	Audience []string
+
+	// A JWK expresses a public key in a JSON Web Key Set.
+	JWK struct {
+		KeyType   string `json:"kty"`
+		Curve     string `json:"crv,omitempty"`
+		X         string `json:"x,omitempty"`
+		KeyID     string `json:"kid"`
+		Algorithm string `json:"alg"`
+		Use       string `json:"use"`
+	}
+	// A JWKS expresses a JSON Web Key Set.
+	JWKS struct {
+		Keys []*JWK `json:"keys"`
+	}
+
+	// A TokenPair expresses an access token and the refresh token issuing
+	// the next pair.
+	TokenPair struct {
+		AccessToken      string
+		ExpiresAt        time.Time
+		RefreshToken     string
+		RefreshExpiresAt time.Time
+		Scopes           []string
+	}
+
+	// An IssueTokenRequest expresses ...
+	IssueTokenRequest struct {
+		GrantType    string `json:"grant_type"`
+		Username     string `json:"username,omitempty"`
+		Password     string `json:"password,omitempty"`
+		RefreshToken string `json:"refresh_token,omitempty"`
+		// Scope narrows the scopes of the tokens, separated by spaces.
+		Scope string `json:"scope,omitempty"`
+	}
+	// An IssueTokenResponse expresses ...
+	IssueTokenResponse struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int64  `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+		Scope        string `json:"scope"`
+	}
+)
+
+// Contains reports whether a has aud.
+func (a Audience) Contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalJSON implements json.Marshaler interface.
+// A single audience is encoded as a string.
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface.
+func (a *Audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = Audience{s}
+		return nil
+	}
+	var ss []string
+	if err := json.Unmarshal(b, &ss); err != nil {
+		return err
+	}
+	*a = Audience(ss)
+	return nil
+}
@@ -0,0 +1,127 @@
+package model
+
+import "time"
+
+// A ProjectRole expresses what a member may do in a project.
+type ProjectRole string
+
+// Roles of project members, from the most to the least privileged.
+const (
+	ProjectRoleOwner  ProjectRole = "owner"
+	ProjectRoleEditor ProjectRole = "editor"
+	ProjectRoleViewer ProjectRole = "viewer"
+)
+
+// IsValid reports whether r is one of the defined roles.
+func (r ProjectRole) IsValid() bool {
+	switch r {
+	case ProjectRoleOwner, ProjectRoleEditor, ProjectRoleViewer:
+		return true
+	}
+	return false
+}
+
+// CanEditTODOs reports whether members with r may change the TODOs of the project.
+func (r ProjectRole) CanEditTODOs() bool {
+	return r == ProjectRoleOwner || r == ProjectRoleEditor
+}
+
+// CanManage reports whether members with r may change the project and its members.
+func (r ProjectRole) CanManage() bool {
+	return r == ProjectRoleOwner
+}
+
+type (
+	// A Project expresses a list of TODOs shared by its members.
+	// Role is the role of the user reading the project.
+	Project struct {
+		ID          int64       `json:"id"`
+		Name        string      `json:"name"`
+		Description string      `json:"description"`
+		Role        ProjectRole `json:"role,omitempty"`
+		CreatedAt   time.Time   `json:"created_at"`
+		UpdatedAt   time.Time   `json:"updated_at"`
+	}
+
+	// A ProjectMember expresses a user taking part in a project.
+	ProjectMember struct {
+		UserID    int64       `json:"user_id"`
+		Username  string      `json:"username"`
+		Role      ProjectRole `json:"role"`
+		CreatedAt time.Time   `json:"created_at"`
+	}
+
+	// A CreateProjectRequest expresses ...
+	CreateProjectRequest struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	// A CreateProjectResponse expresses ...
+	CreateProjectResponse struct {
+		Project `json:"project"`
+	}
+
+	// A ReadProjectsRequest expresses ...
+	ReadProjectsRequest struct{}
+	// A ReadProjectsResponse expresses ...
+	ReadProjectsResponse struct {
+		Projects []*Project `json:"projects"`
+	}
+
+	// A FindProjectRequest expresses ...
+	FindProjectRequest struct {
+		ID int64
+	}
+	// A FindProjectResponse expresses ...
+	FindProjectResponse struct {
+		Project `json:"project"`
+	}
+
+	// An UpdateProjectRequest expresses ...
+	// Fields left out of the body are left as they are.
+	UpdateProjectRequest struct {
+		ID          int64   `json:"-"`
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+	}
+	// An UpdateProjectResponse expresses ...
+	UpdateProjectResponse struct {
+		Project `json:"project"`
+	}
+
+	// A DeleteProjectRequest expresses ...
+	DeleteProjectRequest struct {
+		ID int64
+	}
+	// A DeleteProjectResponse expresses ...
+	DeleteProjectResponse struct{}
+
+	// A ReadProjectMembersRequest expresses ...
+	ReadProjectMembersRequest struct {
+		ProjectID int64
+	}
+	// A ReadProjectMembersResponse expresses ...
+	ReadProjectMembersResponse struct {
+		Members []*ProjectMember `json:"members"`
+	}
+
+	// An AddProjectMemberRequest expresses ...
+	// Adding a member again changes their role.
+	AddProjectMemberRequest struct {
+		ProjectID int64       `json:"-"`
+		Username  string      `json:"username"`
+		Role      ProjectRole `json:"role"`
+	}
+	// An AddProjectMemberResponse expresses ...
+	AddProjectMemberResponse struct {
+		Member ProjectMember `json:"member"`
+	}
+
+	// A RemoveProjectMemberRequest expresses ...
+	RemoveProjectMemberRequest struct {
+		ProjectID int64
+		UserID    int64
+	}
+	// A RemoveProjectMemberResponse expresses ...
+	RemoveProjectMemberResponse struct{}
+)
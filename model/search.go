@@ -0,0 +1,24 @@
+package model
+
+type (
+	// A SearchTODORequest expresses ...
+	SearchTODORequest struct {
+		Query string
+		Size  int64
+	}
+	// A SearchTODOResponse expresses ...
+	SearchTODOResponse struct {
+		Results []*SearchResult `json:"results"`
+	}
+
+	// A SearchResult expresses a TODO matching a search query.
+	//
+	// Snippet is an HTML escaped excerpt of the TODO with the matched text
+	// enclosed in <mark> and </mark>, the only tags it contains.
+	// Rank is higher for more relevant TODOs.
+	SearchResult struct {
+		TODO    `json:"todo"`
+		Snippet string  `json:"snippet"`
+		Rank    float64 `json:"rank"`
+	}
+)
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"sort"
+	"strings"
+)
+
+type (
+	// A Tag expresses a label attached to TODOs with the number of TODOs having it.
+	Tag struct {
+		Name  string `json:"name"`
+		Count int64  `json:"count"`
+	}
+
+	// A ReadTagResponse expresses ...
+	ReadTagResponse struct {
+		Tags []*Tag `json:"tags"`
+	}
+)
+
+// NormalizeTags trims, deduplicates and sorts tags, dropping empty ones.
+// It returns nil rather than an empty slice when no tag is left.
+func NormalizeTags(tags []string) []string {
+	var ret []string
+	seen := map[string]bool{}
+	for _, v := range tags {
+		v = strings.TrimSpace(v)
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		ret = append(ret, v)
+	}
+	sort.Strings(ret)
+	return ret
+}
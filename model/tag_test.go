@@ -0,0 +1,34 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		tags []string
+		want []string
+	}{
+		"No tags":     {},
+		"Only blanks": {tags: []string{"", " "}},
+		"Sorted":      {tags: []string{"b", "a", "c"}, want: []string{"a", "b", "c"}},
+		"Trimmed":     {tags: []string{" a ", "\tb"}, want: []string{"a", "b"}},
+		// 大文字と小文字は別のタグとして扱う
+		"Deduplicated": {tags: []string{"a", " a", "A", "a "}, want: []string{"A", "a"}},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(c.want, model.NormalizeTags(c.tags)); diff != "" {
+				t.Error("unexpected tags, diff =", diff)
+			}
+		})
+	}
+}
@@ -4,18 +4,54 @@ import "time"
 
 type (
 	// A TODO expresses ...
+	//
+	// DeletedAt is set only while the TODO is in the trash.
 	TODO struct {
-		ID          int64     `json:"id"`
-		Subject     string    `json:"subject"`
-		Description string    `json:"description"`
-		CreatedAt   time.Time `json:"created_at"`
-		UpdatedAt   time.Time `json:"updated_at"`
+		ID          int64      `json:"id"`
+		Subject     string     `json:"subject"`
+		Description string     `json:"description"`
+		Status      TODOStatus `json:"status"`
+		CompletedAt *time.Time `json:"completed_at,omitempty"`
+		DueAt       *time.Time `json:"due_at,omitempty"`
+		RemindAt    *time.Time `json:"remind_at,omitempty"`
+		Tags        []string   `json:"tags,omitempty"`
+		CreatedAt   time.Time  `json:"created_at"`
+		UpdatedAt   time.Time  `json:"updated_at"`
+		DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+		// ProjectID is the id of the project the TODO belongs to, or 0 when
+		// it is a TODO of its owner alone.
+		ProjectID int64 `json:"project_id,omitempty"`
+		// OwnerID is the id of the user owning the TODO.
+		OwnerID int64 `json:"-"`
+	}
+
+	// A TODOFilter expresses conditions narrowing the TODOs to read.
+	TODOFilter struct {
+		// Statuses matches TODOs in any of the given states.
+		Statuses []TODOStatus
+		// DueBefore and DueAfter bound the due date exclusively.
+		// TODOs without a due date never match either bound.
+		DueBefore *time.Time
+		DueAfter  *time.Time
+		// Overdue matches TODOs past their due date which are not closed yet.
+		Overdue bool
+		// Tags matches TODOs having all of the given tags,
+		// or any of them when AnyTag is set.
+		Tags   []string
+		AnyTag bool
+		// ProjectID matches the TODOs of the project, unless it is 0.
+		ProjectID int64
 	}
 
 	// A CreateTODORequest expresses ...
 	CreateTODORequest struct {
-		Subject     string `json:"subject"`
-		Description string `json:"description"`
+		Subject     string     `json:"subject"`
+		Description string     `json:"description"`
+		DueAt       *time.Time `json:"due_at"`
+		RemindAt    *time.Time `json:"remind_at"`
+		Tags        []string   `json:"tags"`
+		// ProjectID creates the TODO in the project instead of for the user alone.
+		ProjectID int64 `json:"project_id"`
 	}
 	// A CreateTODOResponse expresses ...
 	CreateTODOResponse struct {
@@ -26,27 +62,98 @@ type (
 	ReadTODORequest struct {
 		PrevID int64
 		Size   int64
+		TODOFilter
 	}
 	// A ReadTODOResponse expresses ...
 	ReadTODOResponse struct {
 		TODOs []*TODO `json:"todos"`
 	}
 
+	// A FindTODORequest expresses ...
+	FindTODORequest struct {
+		ID int64
+	}
+	// A FindTODOResponse expresses ...
+	FindTODOResponse struct {
+		TODO `json:"todo"`
+	}
+
 	// A UpdateTODORequest expresses ...
 	UpdateTODORequest struct {
-		ID          int64  `json:"id"`
-		Subject     string `json:"subject"`
-		Description string `json:"description"`
+		ID          int64      `json:"id"`
+		Subject     string     `json:"subject"`
+		Description string     `json:"description"`
+		DueAt       *time.Time `json:"due_at"`
+		RemindAt    *time.Time `json:"remind_at"`
+		Tags        []string   `json:"tags"`
+		// IfMatch lists the ETags the TODO must have to be updated.
+		// It is given by the If-Match header, and no ETag means no condition.
+		IfMatch []string `json:"-"`
 	}
 	// A UpdateTODOResponse expresses ...
 	UpdateTODOResponse struct {
 		TODO `json:"todo"`
 	}
 
+	// A PatchTODORequest expresses ...
+	// Fields left out, or set to null, are left unchanged.
+	PatchTODORequest struct {
+		ID          int64       `json:"-"`
+		Subject     *string     `json:"subject"`
+		Description *string     `json:"description"`
+		Status      *TODOStatus `json:"status"`
+		DueAt       *time.Time  `json:"due_at"`
+		RemindAt    *time.Time  `json:"remind_at"`
+		Tags        *[]string   `json:"tags"`
+		IfMatch     []string    `json:"-"`
+	}
+	// A ApplyTODOPatchRequest expresses a patch document of the TODO with ID,
+	// in the format ContentType tells, either JSON Merge Patch or JSON Patch.
+	ApplyTODOPatchRequest struct {
+		ID          int64
+		ContentType string
+		Patch       []byte
+		IfMatch     []string
+	}
+	// A PatchTODOResponse expresses ...
+	PatchTODOResponse struct {
+		TODO `json:"todo"`
+	}
+
+	// A UpdateTODOStatusRequest expresses ...
+	UpdateTODOStatusRequest struct {
+		ID     int64      `json:"id"`
+		Status TODOStatus `json:"status"`
+	}
+	// A UpdateTODOStatusResponse expresses ...
+	UpdateTODOStatusResponse struct {
+		TODO `json:"todo"`
+	}
+
 	// A DeleteTODORequest expresses ...
 	DeleteTODORequest struct {
-		IDs []int64 `json:"ids"`
+		IDs     []int64  `json:"ids"`
+		IfMatch []string `json:"-"`
 	}
 	// A DeleteTODOResponse expresses ...
 	DeleteTODOResponse struct{}
+
+	// A ReadTrashRequest expresses ...
+	ReadTrashRequest struct {
+		PrevID int64
+		Size   int64
+	}
+	// A ReadTrashResponse expresses ...
+	ReadTrashResponse struct {
+		TODOs []*TODO `json:"todos"`
+	}
+
+	// A RestoreTODORequest expresses ...
+	RestoreTODORequest struct {
+		ID int64
+	}
+	// A RestoreTODOResponse expresses ...
+	RestoreTODOResponse struct {
+		TODO `json:"todo"`
+	}
 )
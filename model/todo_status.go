@@ -0,0 +1,129 @@
+package model
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// A TODOStatus expresses the workflow state of a TODO.
+//
+// The zero value is TODOStatusOpen so that TODOs created without a status,
+// including every row that existed before the column was introduced, are open.
+type TODOStatus int
+
+const (
+	TODOStatusOpen TODOStatus = iota
+	TODOStatusInProgress
+	TODOStatusDone
+	TODOStatusCancelled
+)
+
+var todoStatusNames = [...]string{
+	TODOStatusOpen:       "open",
+	TODOStatusInProgress: "in_progress",
+	TODOStatusDone:       "done",
+	TODOStatusCancelled:  "cancelled",
+}
+
+// todoStatusTransitions lists the states each state may move to.
+var todoStatusTransitions = map[TODOStatus][]TODOStatus{
+	TODOStatusOpen:       {TODOStatusInProgress, TODOStatusDone, TODOStatusCancelled},
+	TODOStatusInProgress: {TODOStatusOpen, TODOStatusDone, TODOStatusCancelled},
+	TODOStatusDone:       {TODOStatusOpen},
+	TODOStatusCancelled:  {TODOStatusOpen},
+}
+
+// ParseTODOStatus returns the TODOStatus named by v.
+func ParseTODOStatus(v string) (TODOStatus, error) {
+	for s, name := range todoStatusNames {
+		if name == v {
+			return TODOStatus(s), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown todo status %q", v)
+}
+
+// String implements fmt.Stringer interface.
+func (s TODOStatus) String() string {
+	if !s.valid() {
+		return fmt.Sprintf("TODOStatus(%d)", int(s))
+	}
+	return todoStatusNames[s]
+}
+
+// IsClosed reports whether no more work is expected on a TODO in the state.
+func (s TODOStatus) IsClosed() bool {
+	return s == TODOStatusDone || s == TODOStatusCancelled
+}
+
+// CanTransitionTo reports whether a TODO may move from s to next.
+// Staying in the same state is always allowed.
+func (s TODOStatus) CanTransitionTo(next TODOStatus) bool {
+	if s == next {
+		return s.valid()
+	}
+	for _, v := range todoStatusTransitions[s] {
+		if v == next {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshalText implements encoding.TextMarshaler interface.
+func (s TODOStatus) MarshalText() ([]byte, error) {
+	if !s.valid() {
+		return nil, fmt.Errorf("unknown todo status %d", int(s))
+	}
+	return []byte(todoStatusNames[s]), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler interface.
+func (s *TODOStatus) UnmarshalText(b []byte) error {
+	v, err := ParseTODOStatus(string(b))
+	if err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
+
+// Scan implements sql.Scanner interface.
+func (s *TODOStatus) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case string:
+		return s.UnmarshalText([]byte(v))
+	case []byte:
+		return s.UnmarshalText(v)
+	default:
+		return fmt.Errorf("cannot scan %T into TODOStatus", src)
+	}
+}
+
+// Value implements driver.Valuer interface.
+func (s TODOStatus) Value() (driver.Value, error) {
+	b, err := s.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (s TODOStatus) valid() bool {
+	return s >= 0 && int(s) < len(todoStatusNames)
+}
+
+// An ErrInvalidStatusTransition expresses a status change the workflow does not allow.
+type ErrInvalidStatusTransition struct {
+	From TODOStatus
+	To   TODOStatus
+}
+
+func (e *ErrInvalidStatusTransition) Error() string {
+	return fmt.Sprintf("cannot change status from %s to %s", e.From, e.To)
+}
+
+// Unwrap classifies the error as an ErrConflict.
+func (e *ErrInvalidStatusTransition) Unwrap() error {
+	return &ErrConflict{Message: e.Error()}
+}
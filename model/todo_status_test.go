@@ -0,0 +1,91 @@
+package model_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+func TestTODOStatusCanTransitionTo(t *testing.T) {
+	t.Parallel()
+
+	const (
+		open       = model.TODOStatusOpen
+		inProgress = model.TODOStatusInProgress
+		done       = model.TODOStatusDone
+		cancelled  = model.TODOStatusCancelled
+	)
+	// 閉じた TODO は開き直すことしかできない
+	allowed := map[model.TODOStatus][]model.TODOStatus{
+		open:       {open, inProgress, done, cancelled},
+		inProgress: {open, inProgress, done, cancelled},
+		done:       {open, done},
+		cancelled:  {open, cancelled},
+	}
+	for _, from := range []model.TODOStatus{open, inProgress, done, cancelled} {
+		for _, to := range []model.TODOStatus{open, inProgress, done, cancelled} {
+			want := false
+			for _, v := range allowed[from] {
+				want = want || v == to
+			}
+			if got := from.CanTransitionTo(to); got != want {
+				t.Errorf("unexpected transition from %s to %s, want = %t, given = %t", from, to, want, got)
+			}
+		}
+	}
+	if unknown := model.TODOStatus(100); unknown.CanTransitionTo(unknown) || open.CanTransitionTo(unknown) {
+		t.Error("transition to an unknown status is allowed")
+	}
+}
+
+func TestTODOStatusText(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"open", "in_progress", "done", "cancelled"} {
+		s, err := model.ParseTODOStatus(name)
+		if err != nil {
+			t.Fatalf("failed to parse %s, err = %v", name, err)
+		}
+		if s.String() != name {
+			t.Errorf("unexpected name, want = %s, given = %s", name, s)
+		}
+		if b, err := json.Marshal(s); err != nil || string(b) != `"`+name+`"` {
+			t.Errorf("unexpected JSON of %s, given = %s, err = %v", name, b, err)
+		}
+	}
+	if _, err := model.ParseTODOStatus("closed"); err == nil {
+		t.Error("expected an error parsing an unknown status")
+	}
+	var s model.TODOStatus
+	if err := json.Unmarshal([]byte(`"Done"`), &s); err == nil {
+		t.Error("expected an error decoding a status in another case")
+	}
+	if _, err := json.Marshal(model.TODOStatus(-1)); err == nil {
+		t.Error("expected an error encoding an unknown status")
+	}
+
+	// 開いている TODO にも status が付き、項目がないのと区別できる
+	b, err := json.Marshal(&model.TODO{ID: 1, Subject: "subject"})
+	if err != nil {
+		t.Fatal("failed to encode todo, err =", err)
+	}
+	if !strings.Contains(string(b), `"status":"open"`) {
+		t.Errorf("status of an open todo is missing, given = %s", b)
+	}
+}
+
+func TestErrInvalidStatusTransition(t *testing.T) {
+	t.Parallel()
+
+	var err error = &model.ErrInvalidStatusTransition{From: model.TODOStatusDone, To: model.TODOStatusInProgress}
+	var errConflict *model.ErrConflict
+	if !errors.As(err, &errConflict) {
+		t.Fatalf("not classified as a conflict, given = %v", err)
+	}
+	if want := "cannot change status from done to in_progress"; errConflict.Message != want {
+		t.Errorf("unexpected message, want = %s, given = %s", want, errConflict.Message)
+	}
+}
@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+type (
+	// A User expresses an account owning TODOs.
+	User struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+		// PasswordHash is the bcrypt hash of the password.
+		PasswordHash string    `json:"-"`
+		CreatedAt    time.Time `json:"created_at"`
+	}
+
+	// A Session expresses a login of a user. Token is only known when the
+	// session is created, since only its hash is stored.
+	Session struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+		User      *User     `json:"user"`
+	}
+
+	// A RegisterRequest expresses ...
+	RegisterRequest struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	// A RegisterResponse expresses ...
+	RegisterResponse struct {
+		User `json:"user"`
+	}
+
+	// A LoginRequest expresses ...
+	LoginRequest struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	// A LoginResponse expresses ...
+	LoginResponse struct {
+		Session
+	}
+
+	// A LogoutRequest expresses ...
+	LogoutRequest struct {
+		Token string
+	}
+	// A LogoutResponse expresses ...
+	LogoutResponse struct{}
+)
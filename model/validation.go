@@ -0,0 +1,339 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits of request values. Lengths are counted in characters.
+const (
+	MaxSubjectLength     = 200
+	MaxDescriptionLength = 10000
+	MaxTags              = 20
+	MaxTagLength         = 50
+	MaxReadSize          = 100
+	MaxDeleteIDs         = 100
+	MaxSearchQueryLength = 200
+	MinPasswordLength    = 8
+	// MaxPasswordLength is in bytes, as bcrypt ignores the bytes after it.
+	MaxPasswordLength    = 72
+	MaxTokenNameLength   = 100
+	MaxProjectNameLength = 100
+)
+
+// usernamePattern is the form of usernames, which are compared ignoring case.
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{3,32}$`)
+
+// A validator collects the failing fields of a request.
+type validator struct {
+	fields []*FieldError
+}
+
+// check records message for field unless ok.
+func (v *validator) check(ok bool, field, message string) {
+	if !ok {
+		v.fields = append(v.fields, &FieldError{Field: field, Message: message})
+	}
+}
+
+func (v *validator) checkSubject(subject string) {
+	v.check(subject != "", "subject", "must not be empty")
+	v.check(utf8.RuneCountInString(subject) <= MaxSubjectLength, "subject",
+		fmt.Sprintf("must be at most %d characters", MaxSubjectLength))
+}
+
+func (v *validator) checkDescription(description string) {
+	v.check(utf8.RuneCountInString(description) <= MaxDescriptionLength, "description",
+		fmt.Sprintf("must be at most %d characters", MaxDescriptionLength))
+}
+
+func (v *validator) checkTags(tags []string) {
+	v.check(len(tags) <= MaxTags, "tags", fmt.Sprintf("must have at most %d items", MaxTags))
+	for i, tag := range tags {
+		field := fmt.Sprintf("tags[%d]", i)
+		v.check(strings.TrimSpace(tag) != "", field, "must not be empty")
+		v.check(utf8.RuneCountInString(tag) <= MaxTagLength, field,
+			fmt.Sprintf("must be at most %d characters", MaxTagLength))
+	}
+}
+
+func (v *validator) checkID(field string, id int64) {
+	v.check(id > 0, field, "must be a positive integer")
+}
+
+// err returns the collected failures as an ErrValidation, or nil if there is none.
+func (v *validator) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &ErrValidation{Message: "request has invalid fields", Fields: v.fields}
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *CreateTODORequest) Validate() error {
+	v := &validator{}
+	v.checkSubject(r.Subject)
+	v.checkDescription(r.Description)
+	v.checkTags(r.Tags)
+	if r.DueAt != nil && r.RemindAt != nil {
+		v.check(!r.RemindAt.After(*r.DueAt), "remind_at", "must not be after due_at")
+	}
+	v.check(r.ProjectID >= 0, "project_id", "must not be negative")
+	return v.err()
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *ReadTODORequest) Validate() error {
+	v := &validator{}
+	v.check(r.PrevID >= 0, "prev_id", "must not be negative")
+	v.check(r.Size >= 0 && r.Size <= MaxReadSize, "size", fmt.Sprintf("must be between 0 and %d", MaxReadSize))
+	v.checkTags(r.Tags)
+	if r.DueBefore != nil && r.DueAfter != nil {
+		v.check(r.DueAfter.Before(*r.DueBefore), "due_after", "must be before due_before")
+	}
+	return v.err()
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *FindTODORequest) Validate() error {
+	v := &validator{}
+	v.checkID("id", r.ID)
+	return v.err()
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *UpdateTODORequest) Validate() error {
+	v := &validator{}
+	v.checkID("id", r.ID)
+	v.checkSubject(r.Subject)
+	v.checkDescription(r.Description)
+	v.checkTags(r.Tags)
+	if r.DueAt != nil && r.RemindAt != nil {
+		v.check(!r.RemindAt.After(*r.DueAt), "remind_at", "must not be after due_at")
+	}
+	return v.err()
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *PatchTODORequest) Validate() error {
+	v := &validator{}
+	v.checkID("id", r.ID)
+	if r.Subject != nil {
+		v.checkSubject(*r.Subject)
+	}
+	if r.Description != nil {
+		v.checkDescription(*r.Description)
+	}
+	if r.Tags != nil {
+		v.checkTags(*r.Tags)
+	}
+	if r.DueAt != nil && r.RemindAt != nil {
+		v.check(!r.RemindAt.After(*r.DueAt), "remind_at", "must not be after due_at")
+	}
+	return v.err()
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *ApplyTODOPatchRequest) Validate() error {
+	v := &validator{}
+	v.checkID("id", r.ID)
+	v.check(len(r.Patch) > 0, "patch", "must not be empty")
+	return v.err()
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *UpdateTODOStatusRequest) Validate() error {
+	v := &validator{}
+	v.checkID("id", r.ID)
+	return v.err()
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *DeleteTODORequest) Validate() error {
+	v := &validator{}
+	v.check(len(r.IDs) > 0, "ids", "must not be empty")
+	v.check(len(r.IDs) <= MaxDeleteIDs, "ids", fmt.Sprintf("must have at most %d items", MaxDeleteIDs))
+	seen := make(map[int64]bool, len(r.IDs))
+	for i, id := range r.IDs {
+		field := fmt.Sprintf("ids[%d]", i)
+		v.checkID(field, id)
+		v.check(!seen[id], field, "must not be duplicated")
+		seen[id] = true
+	}
+	return v.err()
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *ReadTrashRequest) Validate() error {
+	v := &validator{}
+	v.check(r.PrevID >= 0, "prev_id", "must not be negative")
+	v.check(r.Size >= 0 && r.Size <= MaxReadSize, "size", fmt.Sprintf("must be between 0 and %d", MaxReadSize))
+	return v.err()
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *RestoreTODORequest) Validate() error {
+	v := &validator{}
+	v.checkID("id", r.ID)
+	return v.err()
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *ReadTODOHistoryRequest) Validate() error {
+	v := &validator{}
+	v.checkID("id", r.ID)
+	return v.err()
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *RevertTODORequest) Validate() error {
+	v := &validator{}
+	v.checkID("id", r.ID)
+	v.check(r.Revision > 0, "revision", "must be a positive integer")
+	return v.err()
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *SearchTODORequest) Validate() error {
+	v := &validator{}
+	v.check(strings.TrimSpace(r.Query) != "", "q", "must not be empty")
+	v.check(utf8.RuneCountInString(r.Query) <= MaxSearchQueryLength, "q",
+		fmt.Sprintf("must be at most %d characters", MaxSearchQueryLength))
+	v.check(r.Size > 0 && r.Size <= MaxReadSize, "size", fmt.Sprintf("must be between 1 and %d", MaxReadSize))
+	return v.err()
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *RegisterRequest) Validate() error {
+	v := &validator{}
+	v.check(usernamePattern.MatchString(r.Username), "username",
+		"must be 3 to 32 letters, digits, underscores, dots or hyphens")
+	v.check(utf8.RuneCountInString(r.Password) >= MinPasswordLength, "password",
+		fmt.Sprintf("must be at least %d characters", MinPasswordLength))
+	v.check(len(r.Password) <= MaxPasswordLength, "password",
+		fmt.Sprintf("must be at most %d bytes", MaxPasswordLength))
+	return v.err()
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *LoginRequest) Validate() error {
+	v := &validator{}
+	v.check(r.Username != "", "username", "must not be empty")
+	v.check(r.Password != "", "password", "must not be empty")
+	return v.err()
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *CreateAPITokenRequest) Validate() error {
+	v := &validator{}
+	v.check(strings.TrimSpace(r.Name) != "", "name", "must not be empty")
+	v.check(utf8.RuneCountInString(r.Name) <= MaxTokenNameLength, "name",
+		fmt.Sprintf("must be at most %d characters", MaxTokenNameLength))
+	v.check(len(r.Scopes) > 0, "scopes", "must not be empty")
+	for i, scope := range r.Scopes {
+		v.check(isScope(scope), fmt.Sprintf("scopes[%d]", i),
+			"must be one of "+strings.Join(Scopes, ", "))
+	}
+	return v.err()
+}
+
+func isScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *RevokeAPITokenRequest) Validate() error {
+	v := &validator{}
+	v.checkID("id", r.ID)
+	return v.err()
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *IssueTokenRequest) Validate() error {
+	v := &validator{}
+	switch r.GrantType {
+	case GrantTypePassword:
+		v.check(r.Username != "", "username", "must not be empty")
+		v.check(r.Password != "", "password", "must not be empty")
+	case GrantTypeRefreshToken:
+		v.check(r.RefreshToken != "", "refresh_token", "must not be empty")
+	default:
+		v.check(false, "grant_type", "must be "+GrantTypePassword+" or "+GrantTypeRefreshToken)
+	}
+	for _, scope := range strings.Fields(r.Scope) {
+		v.check(isScope(scope), "scope", "must be some of "+strings.Join(Scopes, ", "))
+	}
+	return v.err()
+}
+
+func (v *validator) checkProjectName(name string) {
+	v.check(strings.TrimSpace(name) != "", "name", "must not be empty")
+	v.check(utf8.RuneCountInString(name) <= MaxProjectNameLength, "name",
+		fmt.Sprintf("must be at most %d characters", MaxProjectNameLength))
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *CreateProjectRequest) Validate() error {
+	v := &validator{}
+	v.checkProjectName(r.Name)
+	v.checkDescription(r.Description)
+	return v.err()
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *FindProjectRequest) Validate() error {
+	v := &validator{}
+	v.checkID("id", r.ID)
+	return v.err()
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *UpdateProjectRequest) Validate() error {
+	v := &validator{}
+	v.checkID("id", r.ID)
+	if r.Name != nil {
+		v.checkProjectName(*r.Name)
+	}
+	if r.Description != nil {
+		v.checkDescription(*r.Description)
+	}
+	return v.err()
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *DeleteProjectRequest) Validate() error {
+	v := &validator{}
+	v.checkID("id", r.ID)
+	return v.err()
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *ReadProjectMembersRequest) Validate() error {
+	v := &validator{}
+	v.checkID("project_id", r.ProjectID)
+	return v.err()
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *AddProjectMemberRequest) Validate() error {
+	v := &validator{}
+	v.checkID("project_id", r.ProjectID)
+	v.check(r.Username != "", "username", "must not be empty")
+	v.check(r.Role.IsValid(), "role", "must be owner, editor or viewer")
+	return v.err()
+}
+
+// Validate reports the invalid fields of r as an ErrValidation.
+func (r *RemoveProjectMemberRequest) Validate() error {
+	v := &validator{}
+	v.checkID("project_id", r.ProjectID)
+	v.checkID("user_id", r.UserID)
+	return v.err()
+}
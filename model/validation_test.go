@@ -0,0 +1,95 @@
+package model_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	due := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	remind := due.Add(time.Hour)
+
+	cases := map[string]struct {
+		req    interface{ Validate() error }
+		fields []string
+	}{
+		"Valid create":          {req: &model.CreateTODORequest{Subject: "subject"}},
+		"Empty subject":         {req: &model.CreateTODORequest{}, fields: []string{"subject"}},
+		"Too long subject":      {req: &model.CreateTODORequest{Subject: strings.Repeat("あ", model.MaxSubjectLength+1)}, fields: []string{"subject"}},
+		"Subject at max length": {req: &model.CreateTODORequest{Subject: strings.Repeat("あ", model.MaxSubjectLength)}},
+		"Remind after due": {
+			req:    &model.CreateTODORequest{Subject: "subject", DueAt: &due, RemindAt: &remind},
+			fields: []string{"remind_at"},
+		},
+		"Every failing field": {
+			req:    &model.UpdateTODORequest{Tags: []string{" "}},
+			fields: []string{"id", "subject", "tags[0]"},
+		},
+		"Zero size read":     {req: &model.ReadTODORequest{}},
+		"Too large size":     {req: &model.ReadTODORequest{Size: model.MaxReadSize + 1}, fields: []string{"size"}},
+		"Negative prev id":   {req: &model.ReadTODORequest{PrevID: -1}, fields: []string{"prev_id"}},
+		"Empty ids":          {req: &model.DeleteTODORequest{}, fields: []string{"ids"}},
+		"Duplicated ids":     {req: &model.DeleteTODORequest{IDs: []int64{1, 2, 1}}, fields: []string{"ids[2]"}},
+		"Negative id":        {req: &model.DeleteTODORequest{IDs: []int64{-1}}, fields: []string{"ids[0]"}},
+		"Empty patch":        {req: &model.PatchTODORequest{ID: 1}},
+		"Blank search query": {req: &model.SearchTODORequest{Query: " ", Size: 1}, fields: []string{"q"}},
+		"Zero revision":      {req: &model.RevertTODORequest{ID: 1}, fields: []string{"revision"}},
+		"Valid register":     {req: &model.RegisterRequest{Username: "alice.b-c_1", Password: "12345678"}},
+		"Invalid username":   {req: &model.RegisterRequest{Username: "al ice", Password: "12345678"}, fields: []string{"username"}},
+		"Short password":     {req: &model.RegisterRequest{Username: "alice", Password: "1234567"}, fields: []string{"password"}},
+		"Too long password": {
+			req:    &model.RegisterRequest{Username: "alice", Password: strings.Repeat("a", model.MaxPasswordLength+1)},
+			fields: []string{"password"},
+		},
+		"Empty login": {req: &model.LoginRequest{}, fields: []string{"username", "password"}},
+		"Valid token": {req: &model.CreateAPITokenRequest{Name: "ci", Scopes: []string{"todos:read", "todos:write"}}},
+		"Invalid token": {
+			req:    &model.CreateAPITokenRequest{Name: " ", Scopes: []string{"todos:read", "admin"}},
+			fields: []string{"name", "scopes[1]"},
+		},
+		"Token without scopes": {req: &model.CreateAPITokenRequest{Name: "ci"}, fields: []string{"scopes"}},
+		"Zero token ID":        {req: &model.RevokeAPITokenRequest{}, fields: []string{"id"}},
+		"Negative project":     {req: &model.CreateTODORequest{Subject: "x", ProjectID: -1}, fields: []string{"project_id"}},
+		"Blank project":        {req: &model.CreateProjectRequest{Name: " "}, fields: []string{"name"}},
+		"Valid member":         {req: &model.AddProjectMemberRequest{ProjectID: 1, Username: "bob", Role: "viewer"}},
+		"Unknown role":         {req: &model.AddProjectMemberRequest{ProjectID: 1, Username: "bob", Role: "admin"}, fields: []string{"role"}},
+		"Zero member":          {req: &model.RemoveProjectMemberRequest{ProjectID: 1}, fields: []string{"user_id"}},
+		"Password grant": {
+			req: &model.IssueTokenRequest{GrantType: "password", Username: "alice", Password: "x", Scope: "todos:read"},
+		},
+		"Refresh grant without token": {req: &model.IssueTokenRequest{GrantType: "refresh_token"}, fields: []string{"refresh_token"}},
+		"Unknown grant":               {req: &model.IssueTokenRequest{GrantType: "client_credentials"}, fields: []string{"grant_type"}},
+		"Unknown token scope": {
+			req:    &model.IssueTokenRequest{GrantType: "refresh_token", RefreshToken: "x", Scope: "todos:read admin"},
+			fields: []string{"scope"},
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := c.req.Validate()
+			var got []string
+			var errValidation *model.ErrValidation
+			if errors.As(err, &errValidation) {
+				for _, f := range errValidation.Fields {
+					got = append(got, f.Field)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error type, given = %T", err)
+			}
+			if diff := cmp.Diff(c.fields, got); diff != "" {
+				t.Errorf("unexpected failing fields (-expected +given):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/mattn/go-sqlite3"
+)
+
+const apiTokenColumns = `api_tokens.id, api_tokens.user_id, api_tokens.name, api_tokens.prefix,
+	api_tokens.token_hash, api_tokens.scopes, api_tokens.last_used_at, api_tokens.created_at`
+
+func scanAPIToken(row rowScanner) (*model.APIToken, error) {
+	var (
+		token  model.APIToken
+		scopes string
+	)
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.Prefix,
+		&token.TokenHash, &scopes, &token.LastUsedAt, &token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	token.Scopes = strings.Fields(scopes)
+	return &token, nil
+}
+
+// CreateAPIToken implements UserRepository interface.
+func (r *SQLiteUserRepository) CreateAPIToken(ctx context.Context, token *model.APIToken) (*model.APIToken, error) {
+	const (
+		insert = `INSERT INTO api_tokens(user_id, name, prefix, token_hash, scopes) VALUES(?, ?, ?, ?, ?)`
+		read   = `SELECT ` + apiTokenColumns + ` FROM api_tokens WHERE id = ?`
+	)
+	result, err := r.db.ExecContext(ctx, insert,
+		token.UserID, token.Name, token.Prefix, token.TokenHash, strings.Join(token.Scopes, " "))
+	var errSQLite sqlite3.Error
+	if errors.As(err, &errSQLite) && errSQLite.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return nil, &model.ErrConflict{Message: "prefix is already taken"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return scanAPIToken(r.db.QueryRowContext(ctx, read, id))
+}
+
+// ListAPITokens implements UserRepository interface.
+func (r *SQLiteUserRepository) ListAPITokens(ctx context.Context, userID int64) ([]*model.APIToken, error) {
+	const read = `SELECT ` + apiTokenColumns + ` FROM api_tokens WHERE user_id = ? ORDER BY id`
+	rows, err := r.db.QueryContext(ctx, read, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*model.APIToken{}
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// FindAPIToken implements UserRepository interface.
+func (r *SQLiteUserRepository) FindAPIToken(ctx context.Context, prefix string) (*model.APIToken, error) {
+	const read = `SELECT ` + apiTokenColumns + ` FROM api_tokens WHERE prefix = ?`
+	token, err := scanAPIToken(r.db.QueryRowContext(ctx, read, prefix))
+	if err == sql.ErrNoRows {
+		return nil, &model.ErrNotFound{}
+	}
+	return token, err
+}
+
+// TouchAPIToken implements UserRepository interface.
+func (r *SQLiteUserRepository) TouchAPIToken(ctx context.Context, id int64, usedAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, sqlTime(&usedAt), id)
+	return err
+}
+
+// DeleteAPIToken implements UserRepository interface.
+func (r *SQLiteUserRepository) DeleteAPIToken(ctx context.Context, userID, id int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM api_tokens WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return &model.ErrNotFound{}
+	}
+	return nil
+}
@@ -0,0 +1,758 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/repository"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestSQLiteTODORepository(t *testing.T) {
+	t.Parallel()
+
+	testTODORepository(t, func(t *testing.T) repository.TODORepository {
+		todoDB, err := db.NewDB(filepath.Join(t.TempDir(), "todo.db"))
+		if err != nil {
+			t.Fatal("failed to create database, err =", err)
+		}
+		t.Cleanup(func() {
+			if err := todoDB.Close(); err != nil {
+				t.Error("failed to close database, err =", err)
+			}
+		})
+		return repository.NewSQLiteTODORepository(todoDB)
+	})
+}
+
+func TestMemoryTODORepository(t *testing.T) {
+	t.Parallel()
+
+	testTODORepository(t, func(t *testing.T) repository.TODORepository {
+		return repository.NewMemoryTODORepository()
+	})
+}
+
+// testTODORepository checks that the repository made by newRepo satisfies
+// the contract of repository.TODORepository. Every case gets an empty repository.
+func testTODORepository(t *testing.T, newRepo func(t *testing.T) repository.TODORepository) {
+	ctx := context.Background()
+	past := time.Now().Add(-24 * time.Hour).UTC().Truncate(time.Second)
+	future := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+
+	// create stores todos in order and fails the test on errors.
+	create := func(t *testing.T, repo repository.TODORepository, todos ...*model.TODO) []*model.TODO {
+		t.Helper()
+		var ret []*model.TODO
+		for _, todo := range todos {
+			created, err := repo.Create(ctx, todo)
+			if err != nil {
+				t.Fatal("failed to create todo, err =", err)
+			}
+			ret = append(ret, created)
+		}
+		return ret
+	}
+	ids := func(todos []*model.TODO) []int64 {
+		ret := []int64{}
+		for _, todo := range todos {
+			ret = append(ret, todo.ID)
+		}
+		return ret
+	}
+
+	t.Run("Create and find", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+
+		created := create(t, repo, &model.TODO{
+			Subject:     "subject",
+			Description: "description",
+			Status:      model.TODOStatusDone,
+			DueAt:       &future,
+			Tags:        []string{"b", "a", "a"},
+		})[0]
+		if created.ID == 0 || created.CreatedAt.IsZero() || created.UpdatedAt.IsZero() {
+			t.Errorf("missing generated fields, given = %+v", created)
+		}
+		want := &model.TODO{
+			ID:          created.ID,
+			Subject:     "subject",
+			Description: "description",
+			DueAt:       &future,
+			Tags:        []string{"a", "b"},
+			CreatedAt:   created.CreatedAt,
+			UpdatedAt:   created.UpdatedAt,
+		}
+		if diff := cmp.Diff(want, created); diff != "" {
+			t.Error("unexpected created todo, diff =", diff)
+		}
+
+		found, err := repo.Find(ctx, created.ID)
+		if err != nil {
+			t.Fatal("failed to find todo, err =", err)
+		}
+		if diff := cmp.Diff(created, found); diff != "" {
+			t.Error("unexpected found todo, diff =", diff)
+		}
+	})
+
+	t.Run("Empty subject", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+
+		if _, err := repo.Create(ctx, &model.TODO{}); err == nil {
+			t.Error("expected an error creating a todo without subject")
+		}
+		todo := create(t, repo, &model.TODO{Subject: "subject"})[0]
+		todo.Subject = ""
+		if _, err := repo.Update(ctx, todo); err == nil {
+			t.Error("expected an error updating a todo without subject")
+		}
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+
+		var errNotFound *model.ErrNotFound
+		if _, err := repo.Find(ctx, 1); !errors.As(err, &errNotFound) {
+			t.Errorf("unexpected find error, given = %v", err)
+		}
+		if _, err := repo.Update(ctx, &model.TODO{ID: 1, Subject: "subject"}); !errors.As(err, &errNotFound) {
+			t.Errorf("unexpected update error, given = %v", err)
+		}
+		if err := repo.Delete(ctx, []int64{1}); !errors.As(err, &errNotFound) {
+			t.Errorf("unexpected delete error, given = %v", err)
+		}
+	})
+
+	t.Run("Returned todos are not shared", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+
+		in := &model.TODO{Subject: "subject", Tags: []string{"a"}}
+		created := create(t, repo, in)[0]
+		in.Tags[0] = "changed"
+		created.Tags[0] = "changed"
+		found, err := repo.Find(ctx, created.ID)
+		if err != nil {
+			t.Fatal("failed to find todo, err =", err)
+		}
+		if diff := cmp.Diff([]string{"a"}, found.Tags); diff != "" {
+			t.Error("stored todo was modified, diff =", diff)
+		}
+	})
+
+	t.Run("List pages newest first", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+
+		todos := create(t, repo, &model.TODO{Subject: "1"}, &model.TODO{Subject: "2"}, &model.TODO{Subject: "3"})
+		cases := map[string]struct {
+			prevID int64
+			size   int64
+			want   []int64
+		}{
+			"First page":  {prevID: 0, size: 2, want: []int64{todos[2].ID, todos[1].ID}},
+			"Second page": {prevID: todos[1].ID, size: 2, want: []int64{todos[0].ID}},
+			"Past end":    {prevID: todos[0].ID, size: 2, want: []int64{}},
+			"Zero size":   {prevID: 0, size: 0, want: []int64{}},
+		}
+		for name, c := range cases {
+			got, err := repo.List(ctx, c.prevID, c.size)
+			if err != nil {
+				t.Fatal("failed to list todos, err =", err)
+			}
+			if diff := cmp.Diff(c.want, ids(got)); diff != "" {
+				t.Errorf("%s: unexpected ids, diff = %s", name, diff)
+			}
+		}
+	})
+
+	t.Run("List filters", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+
+		todos := create(t, repo,
+			&model.TODO{Subject: "overdue", DueAt: &past, Tags: []string{"a"}},
+			&model.TODO{Subject: "due later", DueAt: &future, Tags: []string{"a", "b"}},
+			&model.TODO{Subject: "no due", Tags: []string{"b"}},
+			&model.TODO{Subject: "done", DueAt: &past},
+		)
+		todos[3].Status = model.TODOStatusDone
+		if _, err := repo.Update(ctx, todos[3]); err != nil {
+			t.Fatal("failed to update todo, err =", err)
+		}
+
+		now := time.Now()
+		cases := map[string]struct {
+			filters []model.TODOFilter
+			want    []*model.TODO
+		}{
+			"No filter":        {want: []*model.TODO{todos[3], todos[2], todos[1], todos[0]}},
+			"Status":           {filters: []model.TODOFilter{{Statuses: []model.TODOStatus{model.TODOStatusDone}}}, want: []*model.TODO{todos[3]}},
+			"Due before":       {filters: []model.TODOFilter{{DueBefore: &now}}, want: []*model.TODO{todos[3], todos[0]}},
+			"Due after":        {filters: []model.TODOFilter{{DueAfter: &now}}, want: []*model.TODO{todos[1]}},
+			"Overdue":          {filters: []model.TODOFilter{{Overdue: true}}, want: []*model.TODO{todos[0]}},
+			"All tags":         {filters: []model.TODOFilter{{Tags: []string{"a", "b"}}}, want: []*model.TODO{todos[1]}},
+			"Any tag":          {filters: []model.TODOFilter{{Tags: []string{"a", "b"}, AnyTag: true}}, want: []*model.TODO{todos[2], todos[1], todos[0]}},
+			"Every filter":     {filters: []model.TODOFilter{{Tags: []string{"a"}}, {Overdue: true}}, want: []*model.TODO{todos[0]}},
+			"Nothing matching": {filters: []model.TODOFilter{{Tags: []string{"c"}}}, want: []*model.TODO{}},
+		}
+		for name, c := range cases {
+			got, err := repo.List(ctx, 0, 10, c.filters...)
+			if err != nil {
+				t.Fatal("failed to list todos, err =", err)
+			}
+			if diff := cmp.Diff(ids(c.want), ids(got)); diff != "" {
+				t.Errorf("%s: unexpected ids, diff = %s", name, diff)
+			}
+		}
+	})
+
+	t.Run("Due date bounds", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+
+		todos := create(t, repo,
+			&model.TODO{Subject: "at the bound", DueAt: &past},
+			&model.TODO{Subject: "in progress", DueAt: &past},
+			&model.TODO{Subject: "cancelled", DueAt: &past},
+			&model.TODO{Subject: "due soon", DueAt: &future},
+		)
+		for i, status := range map[int]model.TODOStatus{1: model.TODOStatusInProgress, 2: model.TODOStatusCancelled} {
+			todos[i].Status = status
+			if _, err := repo.Update(ctx, todos[i]); err != nil {
+				t.Fatal("failed to update todo, err =", err)
+			}
+		}
+
+		// 期限の境界はどちらも含まず、中止した TODO は期限切れにならない
+		before, after := past.Add(time.Second), past.Add(-time.Second)
+		cases := map[string]struct {
+			filter model.TODOFilter
+			want   []*model.TODO
+		}{
+			"Due before the due date": {filter: model.TODOFilter{DueBefore: &past}, want: []*model.TODO{}},
+			"Due before a second later": {
+				filter: model.TODOFilter{DueBefore: &before},
+				want:   []*model.TODO{todos[2], todos[1], todos[0]},
+			},
+			"Due after the due date": {filter: model.TODOFilter{DueAfter: &past}, want: []*model.TODO{todos[3]}},
+			"Due after a second earlier": {
+				filter: model.TODOFilter{DueAfter: &after},
+				want:   []*model.TODO{todos[3], todos[2], todos[1], todos[0]},
+			},
+			"Overdue": {filter: model.TODOFilter{Overdue: true}, want: []*model.TODO{todos[1], todos[0]}},
+		}
+		for name, c := range cases {
+			got, err := repo.List(ctx, 0, 10, c.filter)
+			if err != nil {
+				t.Fatal("failed to list todos, err =", err)
+			}
+			if diff := cmp.Diff(ids(c.want), ids(got)); diff != "" {
+				t.Errorf("%s: unexpected ids, diff = %s", name, diff)
+			}
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+
+		todo := create(t, repo, &model.TODO{Subject: "subject", DueAt: &future, Tags: []string{"a"}})[0]
+		todo.Subject = "changed"
+		todo.Status = model.TODOStatusDone
+		todo.CompletedAt = &past
+		todo.DueAt = nil
+		todo.Tags = []string{"b"}
+		updated, err := repo.Update(ctx, todo)
+		if err != nil {
+			t.Fatal("failed to update todo, err =", err)
+		}
+		if updated.UpdatedAt.Before(todo.UpdatedAt) {
+			t.Errorf("updated_at went back, given = %s", updated.UpdatedAt)
+		}
+		todo.UpdatedAt = updated.UpdatedAt
+		if diff := cmp.Diff(todo, updated); diff != "" {
+			t.Error("unexpected updated todo, diff =", diff)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+
+		todos := create(t, repo, &model.TODO{Subject: "1"}, &model.TODO{Subject: "2"})
+		if err := repo.Delete(ctx, nil); err != nil {
+			t.Error("failed to delete no todo, err =", err)
+		}
+		if err := repo.Delete(ctx, []int64{todos[0].ID, todos[1].ID + 1}); err != nil {
+			t.Error("failed to delete todos partially existing, err =", err)
+		}
+		got, err := repo.List(ctx, 0, 10)
+		if err != nil {
+			t.Fatal("failed to list todos, err =", err)
+		}
+		if diff := cmp.Diff([]int64{todos[1].ID}, ids(got)); diff != "" {
+			t.Error("unexpected remaining ids, diff =", diff)
+		}
+	})
+
+	t.Run("Trash", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+
+		todos := create(t, repo,
+			&model.TODO{Subject: "trashed 1", Tags: []string{"a"}},
+			&model.TODO{Subject: "trashed 2"},
+			&model.TODO{Subject: "kept"},
+		)
+		if err := repo.Delete(ctx, []int64{todos[0].ID, todos[1].ID}); err != nil {
+			t.Fatal("failed to delete todos, err =", err)
+		}
+
+		var errNotFound *model.ErrNotFound
+		if _, err := repo.Find(ctx, todos[0].ID); !errors.As(err, &errNotFound) {
+			t.Errorf("unexpected find error of a trashed todo, given = %v", err)
+		}
+		if _, err := repo.Update(ctx, todos[0]); !errors.As(err, &errNotFound) {
+			t.Errorf("unexpected update error of a trashed todo, given = %v", err)
+		}
+		if err := repo.Delete(ctx, []int64{todos[0].ID}); !errors.As(err, &errNotFound) {
+			t.Errorf("unexpected delete error of a trashed todo, given = %v", err)
+		}
+		if got, err := repo.Search(ctx, []string{"trashed"}, 10); err != nil || len(got) != 0 {
+			t.Errorf("trashed todos are searched, given = %d results, err = %v", len(got), err)
+		}
+		if got, err := repo.Tags(ctx); err != nil || len(got) != 0 {
+			t.Errorf("tags of trashed todos are counted, given = %d tags, err = %v", len(got), err)
+		}
+
+		trash, err := repo.ListTrash(ctx, 0, 10)
+		if err != nil {
+			t.Fatal("failed to list trash, err =", err)
+		}
+		if diff := cmp.Diff([]int64{todos[1].ID, todos[0].ID}, ids(trash)); diff != "" {
+			t.Error("unexpected trashed ids, diff =", diff)
+		}
+		if trash[1].DeletedAt == nil || !cmp.Equal([]string{"a"}, trash[1].Tags) {
+			t.Errorf("unexpected trashed todo, given = %+v", trash[1])
+		}
+		if page, err := repo.ListTrash(ctx, todos[1].ID, 10); err != nil || !cmp.Equal([]int64{todos[0].ID}, ids(page)) {
+			t.Errorf("unexpected next page of trash, given = %v, err = %v", ids(page), err)
+		}
+
+		restored, err := repo.Restore(ctx, todos[0].ID)
+		if err != nil {
+			t.Fatal("failed to restore todo, err =", err)
+		}
+		if restored.DeletedAt != nil || restored.Subject != "trashed 1" || !cmp.Equal([]string{"a"}, restored.Tags) {
+			t.Errorf("unexpected restored todo, given = %+v", restored)
+		}
+		if _, err := repo.Restore(ctx, todos[0].ID); !errors.As(err, &errNotFound) {
+			t.Errorf("unexpected restore error of a live todo, given = %v", err)
+		}
+		if _, err := repo.Find(ctx, todos[0].ID); err != nil {
+			t.Error("failed to find restored todo, err =", err)
+		}
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+
+		todos := create(t, repo, &model.TODO{Subject: "trashed"}, &model.TODO{Subject: "kept"})
+		if err := repo.Delete(ctx, []int64{todos[0].ID}); err != nil {
+			t.Fatal("failed to delete todo, err =", err)
+		}
+
+		if n, err := repo.Purge(ctx, past); err != nil || n != 0 {
+			t.Errorf("unexpected purge of recently trashed todos, given = %d, err = %v", n, err)
+		}
+		if n, err := repo.Purge(ctx, future); err != nil || n != 1 {
+			t.Errorf("unexpected purge, given = %d, err = %v", n, err)
+		}
+		var errNotFound *model.ErrNotFound
+		if _, err := repo.Restore(ctx, todos[0].ID); !errors.As(err, &errNotFound) {
+			t.Errorf("unexpected restore error of a purged todo, given = %v", err)
+		}
+		if got, err := repo.List(ctx, 0, 10); err != nil || !cmp.Equal([]int64{todos[1].ID}, ids(got)) {
+			t.Errorf("unexpected remaining ids, given = %v, err = %v", ids(got), err)
+		}
+	})
+
+	t.Run("Events", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+
+		todo := create(t, repo, &model.TODO{Subject: "before", Tags: []string{"a"}})[0]
+		changed := *todo
+		changed.Subject = "after"
+		changed.DueAt = &future
+		given := []*model.TODOEvent{
+			{TODOID: todo.ID, Action: model.TODOEventCreate, After: todo},
+			{TODOID: todo.ID, Action: model.TODOEventUpdate, Actor: "alice", Before: todo, After: &changed},
+			{TODOID: todo.ID, Action: model.TODOEventRevert, RevertedTo: 1, Before: &changed, After: todo},
+		}
+		for i, event := range given {
+			added, err := repo.AddEvent(ctx, event)
+			if err != nil {
+				t.Fatal("failed to add event, err =", err)
+			}
+			if added.Revision != int64(i+1) || added.CreatedAt.IsZero() {
+				t.Errorf("unexpected added event, given = %+v", added)
+			}
+		}
+
+		// 削除済みの TODO の履歴も読める
+		if err := repo.Delete(ctx, []int64{todo.ID}); err != nil {
+			t.Fatal("failed to delete todo, err =", err)
+		}
+		got, err := repo.Events(ctx, todo.ID)
+		if err != nil {
+			t.Fatal("failed to read events, err =", err)
+		}
+		opt := cmpopts.IgnoreFields(model.TODOEvent{}, "Revision", "CreatedAt")
+		if diff := cmp.Diff(given, got, opt); diff != "" {
+			t.Error("unexpected events, diff =", diff)
+		}
+		event, err := repo.Event(ctx, todo.ID, 2)
+		if err != nil {
+			t.Fatal("failed to read event, err =", err)
+		}
+		if diff := cmp.Diff(given[1], event, opt); diff != "" {
+			t.Error("unexpected event, diff =", diff)
+		}
+
+		var errNotFound *model.ErrNotFound
+		if _, err := repo.Event(ctx, todo.ID, 4); !errors.As(err, &errNotFound) {
+			t.Errorf("unexpected error of a missing revision, given = %v", err)
+		}
+		if _, err := repo.Events(ctx, todo.ID+1); !errors.As(err, &errNotFound) {
+			t.Errorf("unexpected error of a missing todo, given = %v", err)
+		}
+		if _, err := repo.Purge(ctx, future); err != nil {
+			t.Fatal("failed to purge, err =", err)
+		}
+		if _, err := repo.Events(ctx, todo.ID); !errors.As(err, &errNotFound) {
+			t.Errorf("unexpected error of a purged todo, given = %v", err)
+		}
+	})
+
+	t.Run("Owners", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+		alice, bob := repo.ForOwner(1), repo.ForOwner(2)
+
+		mine := create(t, alice, &model.TODO{Subject: "mine", Tags: []string{"a"}})[0]
+		if mine.OwnerID != 1 {
+			t.Errorf("unexpected owner, given = %d", mine.OwnerID)
+		}
+		trashed := create(t, alice, &model.TODO{Subject: "trashed mine"})[0]
+		if err := alice.Delete(ctx, []int64{trashed.ID}); err != nil {
+			t.Fatal("failed to delete todo, err =", err)
+		}
+		shared := create(t, repo, &model.TODO{Subject: "shared"})[0]
+
+		for name, other := range map[string]repository.TODORepository{"Other owner": bob, "No owner": repo} {
+			var errNotFound *model.ErrNotFound
+			if _, err := other.Find(ctx, mine.ID); !errors.As(err, &errNotFound) {
+				t.Errorf("%s: unexpected find error, given = %v", name, err)
+			}
+			if _, err := other.Update(ctx, mine); !errors.As(err, &errNotFound) {
+				t.Errorf("%s: unexpected update error, given = %v", name, err)
+			}
+			if err := other.Delete(ctx, []int64{mine.ID}); !errors.As(err, &errNotFound) {
+				t.Errorf("%s: unexpected delete error, given = %v", name, err)
+			}
+			if _, err := other.Restore(ctx, trashed.ID); !errors.As(err, &errNotFound) {
+				t.Errorf("%s: unexpected restore error, given = %v", name, err)
+			}
+			if _, err := other.Events(ctx, mine.ID); !errors.As(err, &errNotFound) {
+				t.Errorf("%s: unexpected events error, given = %v", name, err)
+			}
+			if got, err := other.Search(ctx, []string{"mine"}, 10); err != nil || len(got) != 0 {
+				t.Errorf("%s: searched todos of another owner, given = %d results, err = %v", name, len(got), err)
+			}
+			if got, err := other.Tags(ctx); err != nil || len(got) != 0 {
+				t.Errorf("%s: counted tags of another owner, given = %d tags, err = %v", name, len(got), err)
+			}
+			if got, err := other.ListTrash(ctx, 0, 10); err != nil || len(got) != 0 {
+				t.Errorf("%s: listed trash of another owner, given = %v, err = %v", name, ids(got), err)
+			}
+		}
+
+		for name, c := range map[string]struct {
+			repo repository.TODORepository
+			want []int64
+		}{
+			"Owner":       {repo: alice, want: []int64{mine.ID}},
+			"Other owner": {repo: bob, want: []int64{}},
+			"No owner":    {repo: repo, want: []int64{shared.ID}},
+		} {
+			got, err := c.repo.List(ctx, 0, 10)
+			if err != nil {
+				t.Fatal("failed to list todos, err =", err)
+			}
+			if diff := cmp.Diff(c.want, ids(got)); diff != "" {
+				t.Errorf("%s: unexpected ids, diff = %s", name, diff)
+			}
+		}
+
+		err := alice.WithTx(ctx, func(tx repository.TODORepository) error {
+			_, err := tx.Find(ctx, mine.ID)
+			return err
+		})
+		if err != nil {
+			t.Error("failed to find todo of the owner in a transaction, err =", err)
+		}
+	})
+
+	t.Run("Projects", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+		alice, bob, outsider := repo.ForOwner(1, 10), repo.ForOwner(2, 10, 20), repo.ForOwner(3)
+
+		var errNotFound *model.ErrNotFound
+		if _, err := alice.Create(ctx, &model.TODO{Subject: "elsewhere", ProjectID: 20}); !errors.As(err, &errNotFound) {
+			t.Errorf("unexpected error creating a todo of a project out of scope, given = %v", err)
+		}
+		shared := create(t, alice, &model.TODO{Subject: "shared", ProjectID: 10})[0]
+		if shared.ProjectID != 10 {
+			t.Errorf("unexpected project, given = %d", shared.ProjectID)
+		}
+		mine := create(t, alice, &model.TODO{Subject: "mine"})[0]
+		other := create(t, bob, &model.TODO{Subject: "other", ProjectID: 20})[0]
+
+		// プロジェクトの TODO は作成者に関わらずメンバー全員が扱える
+		moved := *shared
+		moved.Subject, moved.ProjectID = "changed by bob", 20
+		updated, err := bob.Update(ctx, &moved)
+		if err != nil {
+			t.Fatal("failed to update todo of a project, err =", err)
+		}
+		if updated.ProjectID != 10 {
+			t.Errorf("update moved todo to another project, given = %d", updated.ProjectID)
+		}
+		if _, err := outsider.Find(ctx, shared.ID); !errors.As(err, &errNotFound) {
+			t.Errorf("unexpected find error of a non-member, given = %v", err)
+		}
+
+		for name, c := range map[string]struct {
+			repo    repository.TODORepository
+			filters []model.TODOFilter
+			want    []int64
+		}{
+			"Owner and project": {repo: alice, want: []int64{mine.ID, shared.ID}},
+			"Every project":     {repo: bob, want: []int64{other.ID, shared.ID}},
+			"Project filter":    {repo: bob, filters: []model.TODOFilter{{ProjectID: 10}}, want: []int64{shared.ID}},
+			"No project":        {repo: outsider, want: []int64{}},
+		} {
+			got, err := c.repo.List(ctx, 0, 10, c.filters...)
+			if err != nil {
+				t.Fatal("failed to list todos, err =", err)
+			}
+			if diff := cmp.Diff(c.want, ids(got)); diff != "" {
+				t.Errorf("%s: unexpected ids, diff = %s", name, diff)
+			}
+		}
+	})
+
+	t.Run("Tags", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+
+		todos := create(t, repo,
+			&model.TODO{Subject: "1", Tags: []string{"b", "a"}},
+			&model.TODO{Subject: "2", Tags: []string{"a"}},
+			&model.TODO{Subject: "3", Tags: []string{"c"}},
+		)
+		if err := repo.Delete(ctx, []int64{todos[2].ID}); err != nil {
+			t.Fatal("failed to delete todo, err =", err)
+		}
+		got, err := repo.Tags(ctx)
+		if err != nil {
+			t.Fatal("failed to read tags, err =", err)
+		}
+		want := []*model.Tag{{Name: "a", Count: 2}, {Name: "b", Count: 1}}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Error("unexpected tags, diff =", diff)
+		}
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+
+		todos := create(t, repo,
+			&model.TODO{Subject: "buy milk", Description: "at the store"},
+			&model.TODO{Subject: "store", Description: "milk and MILK"},
+			&model.TODO{Subject: "walk the dog"},
+		)
+		// the order of results depends on how each implementation ranks them
+		cases := map[string]struct {
+			terms []string
+			size  int64
+			want  []int64
+		}{
+			"Every term":   {terms: []string{"milk", "store"}, size: 10, want: []int64{todos[0].ID, todos[1].ID}},
+			"Ignore case":  {terms: []string{"DOG"}, size: 10, want: []int64{todos[2].ID}},
+			"No match":     {terms: []string{"cat"}, size: 10, want: []int64{}},
+			"No term":      {size: 10, want: []int64{}},
+			"Zero size":    {terms: []string{"milk"}, size: 0, want: []int64{}},
+			"Wildcard":     {terms: []string{"%"}, size: 10, want: []int64{}},
+			"Partial word": {terms: []string{"alk"}, size: 10, want: []int64{todos[2].ID}},
+		}
+		for name, c := range cases {
+			got, err := repo.Search(ctx, c.terms, c.size)
+			if err != nil {
+				t.Fatal("failed to search todos, err =", err)
+			}
+			gotIDs := []int64{}
+			for _, r := range got {
+				gotIDs = append(gotIDs, r.ID)
+				if r.Snippet == "" {
+					t.Errorf("%s: missing snippet of %d", name, r.ID)
+				}
+			}
+			sort.Slice(gotIDs, func(i, j int) bool { return gotIDs[i] < gotIDs[j] })
+			if diff := cmp.Diff(c.want, gotIDs); diff != "" {
+				t.Errorf("%s: unexpected ids, diff = %s", name, diff)
+			}
+		}
+
+		got, err := repo.Search(ctx, []string{"milk"}, 1)
+		if err != nil {
+			t.Fatal("failed to search todos, err =", err)
+		}
+		if len(got) != 1 {
+			t.Errorf("unexpected number of results, given = %d, expected = 1", len(got))
+		}
+
+		// 索引を使わない検索では、新しい方から size の ScanFactor 倍だけを順位付けする
+		create(t, repo, &model.TODO{Subject: "zz zz zz"})
+		for i := 0; i < repository.ScanFactor; i++ {
+			create(t, repo, &model.TODO{Subject: "subject", Description: "zz"})
+		}
+		got, err = repo.Search(ctx, []string{"zz"}, 1)
+		if err != nil {
+			t.Fatal("failed to search todos, err =", err)
+		}
+		if len(got) != 1 || got[0].Subject != "subject" {
+			t.Errorf("ranked a todo beyond the scan, given = %+v", got)
+		}
+
+		// 抜粋は HTML としてエスケープされ、<mark> だけがタグになる
+		create(t, repo, &model.TODO{Subject: "markup", Description: `<script>alert("x&y")</script>`})
+		got, err = repo.Search(ctx, []string{"script"}, 10)
+		if err != nil {
+			t.Fatal("failed to search todos, err =", err)
+		}
+		want := `&lt;<mark>script</mark>&gt;alert(&#34;x&amp;y&#34;)&lt;/<mark>script</mark>&gt;`
+		if len(got) != 1 || got[0].Snippet != want {
+			t.Errorf("unexpected snippet, want = %s, given = %+v", want, got)
+		}
+	})
+
+	t.Run("WithTx rolls back", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+
+		todo := create(t, repo, &model.TODO{Subject: "subject"})[0]
+		errAbort := errors.New("abort")
+		err := repo.WithTx(ctx, func(tx repository.TODORepository) error {
+			if _, err := tx.Create(ctx, &model.TODO{Subject: "created"}); err != nil {
+				return err
+			}
+			changed := *todo
+			changed.Subject = "changed"
+			if _, err := tx.Update(ctx, &changed); err != nil {
+				return err
+			}
+			// nested calls join the transaction
+			if err := tx.WithTx(ctx, func(tx repository.TODORepository) error {
+				return tx.Delete(ctx, []int64{todo.ID})
+			}); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			t.Fatalf("unexpected error, given = %v", err)
+		}
+
+		got, err := repo.List(ctx, 0, 10)
+		if err != nil {
+			t.Fatal("failed to list todos, err =", err)
+		}
+		if diff := cmp.Diff([]*model.TODO{todo}, got); diff != "" {
+			t.Error("transaction was not rolled back, diff =", diff)
+		}
+	})
+
+	t.Run("WithTx commits", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+
+		err := repo.WithTx(ctx, func(tx repository.TODORepository) error {
+			_, err := tx.Create(ctx, &model.TODO{Subject: "subject"})
+			return err
+		})
+		if err != nil {
+			t.Fatal("failed to commit, err =", err)
+		}
+		got, err := repo.List(ctx, 0, 10)
+		if err != nil {
+			t.Fatal("failed to list todos, err =", err)
+		}
+		if len(got) != 1 {
+			t.Errorf("unexpected number of todos, given = %d", len(got))
+		}
+	})
+
+	t.Run("Concurrent creates", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+
+		const n = 20
+		var wg sync.WaitGroup
+		errs := make(chan error, n)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := repo.Create(ctx, &model.TODO{Subject: "subject", Tags: []string{"a"}})
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Error("failed to create todo concurrently, err =", err)
+			}
+		}
+
+		got, err := repo.List(ctx, 0, n+1)
+		if err != nil {
+			t.Fatal("failed to list todos, err =", err)
+		}
+		seen := map[int64]bool{}
+		for _, todo := range got {
+			seen[todo.ID] = true
+		}
+		if len(seen) != n {
+			t.Errorf("unexpected number of distinct todos, given = %d, expected = %d", len(seen), n)
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+const eventColumns = `todo_id, revision, action, actor, COALESCE(reverted_to, 0), before, after, created_at`
+
+// snapshot encodes todo to be stored in todo_events, keeping nil as NULL.
+func snapshot(todo *model.TODO) (interface{}, error) {
+	if todo == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(todo)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// scanEvent scans the eventColumns of row.
+func scanEvent(row rowScanner) (*model.TODOEvent, error) {
+	event := model.TODOEvent{}
+	var before, after sql.NullString
+	if err := row.Scan(&event.TODOID, &event.Revision, &event.Action, &event.Actor, &event.RevertedTo, &before, &after, &event.CreatedAt); err != nil {
+		return nil, err
+	}
+	for _, s := range []struct {
+		src  sql.NullString
+		dest **model.TODO
+	}{{before, &event.Before}, {after, &event.After}} {
+		if !s.src.Valid {
+			continue
+		}
+		*s.dest = &model.TODO{}
+		if err := json.Unmarshal([]byte(s.src.String), *s.dest); err != nil {
+			return nil, err
+		}
+	}
+	return &event, nil
+}
+
+// AddEvent implements TODORepository interface.
+func (r *SQLiteTODORepository) AddEvent(ctx context.Context, event *model.TODOEvent) (*model.TODOEvent, error) {
+	const (
+		insert = `INSERT INTO todo_events(todo_id, revision, action, actor, reverted_to, before, after)
+		SELECT ?, COALESCE(MAX(revision), 0) + 1, ?, ?, NULLIF(?, 0), ?, ? FROM todo_events WHERE todo_id = ?`
+		read = `SELECT ` + eventColumns + ` FROM todo_events WHERE id = ?`
+	)
+	before, err := snapshot(event.Before)
+	if err != nil {
+		return nil, err
+	}
+	after, err := snapshot(event.After)
+	if err != nil {
+		return nil, err
+	}
+
+	var added *model.TODOEvent
+	err = r.WithTx(ctx, func(repo TODORepository) error {
+		tx := repo.(*SQLiteTODORepository)
+		result, err := tx.q.ExecContext(ctx, insert, event.TODOID, event.Action, event.Actor, event.RevertedTo, before, after, event.TODOID)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		added, err = scanEvent(tx.q.QueryRowContext(ctx, read, id))
+		return err
+	})
+	return added, err
+}
+
+// Events implements TODORepository interface.
+func (r *SQLiteTODORepository) Events(ctx context.Context, id int64) ([]*model.TODOEvent, error) {
+	const read = `SELECT ` + eventColumns + ` FROM todo_events WHERE todo_id = ? ORDER BY revision`
+	cond, args := r.scope(id)
+	exists := `SELECT COUNT(*) > 0 FROM todos WHERE id = ? AND ` + cond
+	var ok bool
+	if err := r.q.QueryRowContext(ctx, exists, args...).Scan(&ok); err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, &model.ErrNotFound{}
+	}
+
+	rows, err := r.q.QueryContext(ctx, read, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*model.TODOEvent{}
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// Event implements TODORepository interface.
+func (r *SQLiteTODORepository) Event(ctx context.Context, id, revision int64) (*model.TODOEvent, error) {
+	cond, args := r.scope(id, revision)
+	read := `SELECT ` + eventColumns + ` FROM todo_events
+		WHERE todo_id = ? AND revision = ? AND todo_id IN (SELECT id FROM todos WHERE ` + cond + `)`
+	event, err := scanEvent(r.q.QueryRowContext(ctx, read, args...))
+	if err == sql.ErrNoRows {
+		return nil, &model.ErrNotFound{}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
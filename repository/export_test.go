@@ -0,0 +1,5 @@
+package repository
+
+// ScanFactor bounds the TODOs ranked without the index to ScanFactor times
+// the size of the results.
+const ScanFactor = scanFactor
@@ -0,0 +1,442 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// errEmptySubject is returned by MemoryTODORepository in place of the CHECK
+// constraint of the todos table.
+var errEmptySubject = errors.New("repository: subject must not be empty")
+
+// A MemoryTODORepository implements TODORepository in memory.
+// It is meant for tests and for running without a database.
+type MemoryTODORepository struct {
+	s *memoryStore
+	// locked is true while in WithTx, whose caller already holds s.mu.
+	locked bool
+	// owner is the OwnerID of the TODOs in the scope.
+	owner int64
+	// projects are the ProjectID of the TODOs in the scope.
+	projects []int64
+}
+
+type memoryStore struct {
+	mu     sync.Mutex
+	todos  map[int64]*model.TODO
+	events map[int64][]*model.TODOEvent
+	nextID int64
+}
+
+// NewMemoryTODORepository returns new MemoryTODORepository.
+func NewMemoryTODORepository() *MemoryTODORepository {
+	return &MemoryTODORepository{
+		s: &memoryStore{
+			todos:  map[int64]*model.TODO{},
+			events: map[int64][]*model.TODOEvent{},
+			nextID: 1,
+		},
+	}
+}
+
+func (r *MemoryTODORepository) lock() func() {
+	if r.locked {
+		return func() {}
+	}
+	r.s.mu.Lock()
+	return r.s.mu.Unlock
+}
+
+// ForOwner implements TODORepository interface.
+func (r *MemoryTODORepository) ForOwner(ownerID int64, projectIDs ...int64) TODORepository {
+	c := *r
+	c.owner = ownerID
+	c.projects = append([]int64{}, projectIDs...)
+	return &c
+}
+
+// owns reports whether todo is in the scope of r.
+func (r *MemoryTODORepository) owns(todo *model.TODO) bool {
+	if todo.ProjectID == 0 {
+		return todo.OwnerID == r.owner
+	}
+	return r.inScope(todo.ProjectID)
+}
+
+// inScope reports whether the project with projectID is in the scope of r.
+func (r *MemoryTODORepository) inScope(projectID int64) bool {
+	for _, id := range r.projects {
+		if id == projectID {
+			return true
+		}
+	}
+	return false
+}
+
+// now returns the current time as precise as the SQLite implementation stores it.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// storedTime returns a copy of t as precise as the SQLite implementation stores it.
+func storedTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	v := t.UTC().Truncate(time.Second)
+	return &v
+}
+
+// cloneTODO returns a deep copy of todo.
+func cloneTODO(todo *model.TODO) *model.TODO {
+	c := *todo
+	c.CompletedAt = storedTime(todo.CompletedAt)
+	c.DueAt = storedTime(todo.DueAt)
+	c.RemindAt = storedTime(todo.RemindAt)
+	c.DeletedAt = storedTime(todo.DeletedAt)
+	c.Tags = model.NormalizeTags(todo.Tags)
+	return &c
+}
+
+// cloneEvent returns a deep copy of event.
+func cloneEvent(event *model.TODOEvent) *model.TODOEvent {
+	c := *event
+	if event.Before != nil {
+		c.Before = cloneTODO(event.Before)
+	}
+	if event.After != nil {
+		c.After = cloneTODO(event.After)
+	}
+	return &c
+}
+
+// Create implements TODORepository interface.
+func (r *MemoryTODORepository) Create(ctx context.Context, todo *model.TODO) (*model.TODO, error) {
+	if todo.Subject == "" {
+		return nil, errEmptySubject
+	}
+	if todo.ProjectID != 0 && !r.inScope(todo.ProjectID) {
+		return nil, &model.ErrNotFound{}
+	}
+	defer r.lock()()
+
+	stored := cloneTODO(todo)
+	stored.ID = r.s.nextID
+	stored.Status = model.TODOStatusOpen
+	stored.CompletedAt = nil
+	stored.DeletedAt = nil
+	stored.OwnerID = r.owner
+	stored.CreatedAt = now()
+	stored.UpdatedAt = stored.CreatedAt
+	r.s.todos[stored.ID] = stored
+	r.s.nextID++
+	return cloneTODO(stored), nil
+}
+
+// Find implements TODORepository interface.
+func (r *MemoryTODORepository) Find(ctx context.Context, id int64) (*model.TODO, error) {
+	defer r.lock()()
+
+	todo, ok := r.s.todos[id]
+	if !ok || todo.DeletedAt != nil || !r.owns(todo) {
+		return nil, &model.ErrNotFound{}
+	}
+	return cloneTODO(todo), nil
+}
+
+// List implements TODORepository interface.
+func (r *MemoryTODORepository) List(ctx context.Context, prevID, size int64, filters ...model.TODOFilter) ([]*model.TODO, error) {
+	defer r.lock()()
+
+	todos := []*model.TODO{}
+	for _, todo := range r.sorted() {
+		if int64(len(todos)) >= size {
+			break
+		}
+		if prevID != 0 && todo.ID >= prevID {
+			continue
+		}
+		if todo.DeletedAt != nil || !matchFilters(todo, filters) {
+			continue
+		}
+		todos = append(todos, cloneTODO(todo))
+	}
+	return todos, nil
+}
+
+// sorted returns the stored TODOs of the owner newest first.
+func (r *MemoryTODORepository) sorted() []*model.TODO {
+	todos := make([]*model.TODO, 0, len(r.s.todos))
+	for _, todo := range r.s.todos {
+		if r.owns(todo) {
+			todos = append(todos, todo)
+		}
+	}
+	sort.Slice(todos, func(i, j int) bool {
+		return todos[i].ID > todos[j].ID
+	})
+	return todos
+}
+
+// matchFilters reports whether todo matches every filter, as filterConditions does in SQL.
+func matchFilters(todo *model.TODO, filters []model.TODOFilter) bool {
+	for _, f := range filters {
+		if len(f.Statuses) > 0 && !containsStatus(f.Statuses, todo.Status) {
+			return false
+		}
+		if f.DueBefore != nil && (todo.DueAt == nil || !todo.DueAt.Before(*storedTime(f.DueBefore))) {
+			return false
+		}
+		if f.DueAfter != nil && (todo.DueAt == nil || !todo.DueAt.After(*storedTime(f.DueAfter))) {
+			return false
+		}
+		if f.Overdue && (todo.DueAt == nil || !todo.DueAt.Before(now()) || todo.Status.IsClosed()) {
+			return false
+		}
+		if f.ProjectID != 0 && todo.ProjectID != f.ProjectID {
+			return false
+		}
+		if tags := model.NormalizeTags(f.Tags); len(tags) > 0 {
+			hits := 0
+			for _, tag := range tags {
+				if containsTag(todo.Tags, tag) {
+					hits++
+				}
+			}
+			if hits == 0 || (!f.AnyTag && hits < len(tags)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func containsStatus(statuses []model.TODOStatus, status model.TODOStatus) bool {
+	for _, v := range statuses {
+		if v == status {
+			return true
+		}
+	}
+	return false
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, v := range tags {
+		if v == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Update implements TODORepository interface.
+func (r *MemoryTODORepository) Update(ctx context.Context, todo *model.TODO) (*model.TODO, error) {
+	if todo.Subject == "" {
+		return nil, errEmptySubject
+	}
+	defer r.lock()()
+
+	old, ok := r.s.todos[todo.ID]
+	if !ok || old.DeletedAt != nil || !r.owns(old) {
+		return nil, &model.ErrNotFound{}
+	}
+	stored := cloneTODO(todo)
+	stored.DeletedAt = nil
+	stored.ProjectID = old.ProjectID
+	stored.OwnerID = old.OwnerID
+	stored.CreatedAt = old.CreatedAt
+	stored.UpdatedAt = now()
+	r.s.todos[stored.ID] = stored
+	return cloneTODO(stored), nil
+}
+
+// Delete implements TODORepository interface.
+func (r *MemoryTODORepository) Delete(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	defer r.lock()()
+
+	deleted := false
+	for _, id := range ids {
+		if todo, ok := r.s.todos[id]; ok && todo.DeletedAt == nil && r.owns(todo) {
+			// 保存済みの TODO は共有されているため、書き換えずに置き換える
+			stored := cloneTODO(todo)
+			t := now()
+			stored.DeletedAt = &t
+			stored.UpdatedAt = t
+			r.s.todos[id] = stored
+			deleted = true
+		}
+	}
+	if !deleted {
+		return &model.ErrNotFound{}
+	}
+	return nil
+}
+
+// ListTrash implements TODORepository interface.
+func (r *MemoryTODORepository) ListTrash(ctx context.Context, prevID, size int64) ([]*model.TODO, error) {
+	defer r.lock()()
+
+	todos := []*model.TODO{}
+	for _, todo := range r.sorted() {
+		if int64(len(todos)) >= size {
+			break
+		}
+		if todo.DeletedAt == nil || (prevID != 0 && todo.ID >= prevID) {
+			continue
+		}
+		todos = append(todos, cloneTODO(todo))
+	}
+	return todos, nil
+}
+
+// Restore implements TODORepository interface.
+func (r *MemoryTODORepository) Restore(ctx context.Context, id int64) (*model.TODO, error) {
+	defer r.lock()()
+
+	todo, ok := r.s.todos[id]
+	if !ok || todo.DeletedAt == nil || !r.owns(todo) {
+		return nil, &model.ErrNotFound{}
+	}
+	stored := cloneTODO(todo)
+	stored.DeletedAt = nil
+	stored.UpdatedAt = now()
+	r.s.todos[id] = stored
+	return cloneTODO(stored), nil
+}
+
+// Purge implements TODORepository interface.
+func (r *MemoryTODORepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	defer r.lock()()
+
+	var purged int64
+	for id, todo := range r.s.todos {
+		if todo.DeletedAt != nil && todo.DeletedAt.Before(*storedTime(&before)) {
+			delete(r.s.todos, id)
+			delete(r.s.events, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// AddEvent implements TODORepository interface.
+func (r *MemoryTODORepository) AddEvent(ctx context.Context, event *model.TODOEvent) (*model.TODOEvent, error) {
+	defer r.lock()()
+
+	if _, ok := r.s.todos[event.TODOID]; !ok {
+		// todo_events の外部キー制約の代わり
+		return nil, errors.New("repository: no todo for the event")
+	}
+	stored := cloneEvent(event)
+	stored.Revision = int64(len(r.s.events[event.TODOID])) + 1
+	stored.CreatedAt = now()
+	r.s.events[event.TODOID] = append(r.s.events[event.TODOID], stored)
+	return cloneEvent(stored), nil
+}
+
+// Events implements TODORepository interface.
+func (r *MemoryTODORepository) Events(ctx context.Context, id int64) ([]*model.TODOEvent, error) {
+	defer r.lock()()
+
+	if todo, ok := r.s.todos[id]; !ok || !r.owns(todo) {
+		return nil, &model.ErrNotFound{}
+	}
+	events := make([]*model.TODOEvent, 0, len(r.s.events[id]))
+	for _, event := range r.s.events[id] {
+		events = append(events, cloneEvent(event))
+	}
+	return events, nil
+}
+
+// Event implements TODORepository interface.
+func (r *MemoryTODORepository) Event(ctx context.Context, id, revision int64) (*model.TODOEvent, error) {
+	defer r.lock()()
+
+	if todo, ok := r.s.todos[id]; !ok || !r.owns(todo) {
+		return nil, &model.ErrNotFound{}
+	}
+	events := r.s.events[id]
+	if revision < 1 || revision > int64(len(events)) {
+		return nil, &model.ErrNotFound{}
+	}
+	return cloneEvent(events[revision-1]), nil
+}
+
+// Tags implements TODORepository interface.
+func (r *MemoryTODORepository) Tags(ctx context.Context) ([]*model.Tag, error) {
+	defer r.lock()()
+
+	counts := map[string]int64{}
+	for _, todo := range r.s.todos {
+		if todo.DeletedAt != nil || !r.owns(todo) {
+			continue
+		}
+		for _, name := range todo.Tags {
+			counts[name]++
+		}
+	}
+	tags := make([]*model.Tag, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, &model.Tag{Name: name, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
+
+// Search implements TODORepository interface.
+// It ranks and cuts snippets the same way as the LIKE fallback of SQLiteTODORepository.
+func (r *MemoryTODORepository) Search(ctx context.Context, terms []string, size int64) ([]*model.SearchResult, error) {
+	if len(terms) == 0 || size <= 0 {
+		return []*model.SearchResult{}, nil
+	}
+	defer r.lock()()
+
+	m := newMatcher(terms)
+	results := []*model.SearchResult{}
+	for _, todo := range r.sorted() {
+		if int64(len(results)) == scanFactor*size {
+			break
+		}
+		if todo.DeletedAt == nil && m.match(todo) {
+			results = append(results, m.result(cloneTODO(todo)))
+		}
+	}
+	return rankResults(results, size), nil
+}
+
+// WithTx implements TODORepository interface.
+// The store is locked until fn returns, and restored when fn fails.
+func (r *MemoryTODORepository) WithTx(ctx context.Context, fn func(TODORepository) error) error {
+	if r.locked {
+		return fn(r)
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	todos := make(map[int64]*model.TODO, len(r.s.todos))
+	for id, todo := range r.s.todos {
+		todos[id] = todo
+	}
+	// 追記は元のスライスの長さを変えないため、スライスの複製は不要
+	events := make(map[int64][]*model.TODOEvent, len(r.s.events))
+	for id, e := range r.s.events {
+		events[id] = e
+	}
+	nextID := r.s.nextID
+
+	if err := fn(&MemoryTODORepository{s: r.s, locked: true, owner: r.owner, projects: r.projects}); err != nil {
+		r.s.todos, r.s.events, r.s.nextID = todos, events, nextID
+		return err
+	}
+	return nil
+}
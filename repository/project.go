@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// A ProjectRepository stores projects and their members.
+//
+// Projects are read on behalf of a user, whose role is set to Role of the
+// projects returned, and are only found by their members. Rules such as who
+// may change a project belong to the service package.
+type ProjectRepository interface {
+	// CreateProject stores project as a new project with owner as its owner
+	// and returns it as stored. ID, Role, CreatedAt and UpdatedAt of project
+	// are ignored.
+	CreateProject(ctx context.Context, project *model.Project, owner *model.User) (*model.Project, error)
+	// FindProject returns the project with id which the user with userID is a member of.
+	FindProject(ctx context.Context, id, userID int64) (*model.Project, error)
+	// ListProjects returns the projects the user with userID is a member of by id.
+	ListProjects(ctx context.Context, userID int64) ([]*model.Project, error)
+	// UpdateProject overwrites Name and Description of the stored project
+	// having the id of project.
+	UpdateProject(ctx context.Context, project *model.Project) error
+	// DeleteProject deletes the project with id and its members.
+	DeleteProject(ctx context.Context, id int64) error
+	// ProjectIDs returns the ids of the projects the user with userID is a member of.
+	ProjectIDs(ctx context.Context, userID int64) ([]int64, error)
+	// Members returns the members of the project with id by user id.
+	Members(ctx context.Context, id int64) ([]*model.ProjectMember, error)
+	// SetMember adds member to the project with id, or changes their role
+	// when they are a member already, and returns them as stored.
+	// Username and CreatedAt of member are ignored when they are a member already.
+	SetMember(ctx context.Context, id int64, member *model.ProjectMember) (*model.ProjectMember, error)
+	// RemoveMember removes the user with userID from the project with id.
+	RemoveMember(ctx context.Context, id, userID int64) error
+	// WithTx calls fn with a repository whose operations are applied
+	// atomically, and only if fn returns nil.
+	WithTx(ctx context.Context, fn func(ProjectRepository) error) error
+}
+
+// A SQLiteProjectRepository implements ProjectRepository on the go-sqlite3 database made by db.NewDB.
+type SQLiteProjectRepository struct {
+	db *sql.DB
+	// q is db, or the transaction while in WithTx.
+	q  queryer
+	tx *sql.Tx
+}
+
+// NewSQLiteProjectRepository returns new SQLiteProjectRepository.
+func NewSQLiteProjectRepository(db *sql.DB) *SQLiteProjectRepository {
+	return &SQLiteProjectRepository{
+		db: db,
+		q:  db,
+	}
+}
+
+const projectColumns = `projects.id, projects.name, projects.description, project_members.role, projects.created_at, projects.updated_at`
+
+func scanProject(row rowScanner) (*model.Project, error) {
+	project := model.Project{}
+	err := row.Scan(&project.ID, &project.Name, &project.Description, &project.Role, &project.CreatedAt, &project.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+const memberColumns = `project_members.user_id, users.username, project_members.role, project_members.created_at`
+
+func scanMember(row rowScanner) (*model.ProjectMember, error) {
+	member := model.ProjectMember{}
+	if err := row.Scan(&member.UserID, &member.Username, &member.Role, &member.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// CreateProject implements ProjectRepository interface.
+func (r *SQLiteProjectRepository) CreateProject(ctx context.Context, project *model.Project, owner *model.User) (*model.Project, error) {
+	const (
+		insert = `INSERT INTO projects(name, description) VALUES(?, ?)`
+		member = `INSERT INTO project_members(project_id, user_id, role) VALUES(?, ?, ?)`
+	)
+	var created *model.Project
+	err := r.WithTx(ctx, func(repo ProjectRepository) error {
+		tx := repo.(*SQLiteProjectRepository)
+		result, err := tx.q.ExecContext(ctx, insert, project.Name, project.Description)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.q.ExecContext(ctx, member, id, owner.ID, model.ProjectRoleOwner); err != nil {
+			return err
+		}
+		created, err = tx.FindProject(ctx, id, owner.ID)
+		return err
+	})
+	return created, err
+}
+
+// FindProject implements ProjectRepository interface.
+func (r *SQLiteProjectRepository) FindProject(ctx context.Context, id, userID int64) (*model.Project, error) {
+	const read = `SELECT ` + projectColumns + ` FROM projects
+		JOIN project_members ON project_members.project_id = projects.id
+		WHERE projects.id = ? AND project_members.user_id = ?`
+	project, err := scanProject(r.q.QueryRowContext(ctx, read, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, &model.ErrNotFound{}
+	}
+	return project, err
+}
+
+// ListProjects implements ProjectRepository interface.
+func (r *SQLiteProjectRepository) ListProjects(ctx context.Context, userID int64) ([]*model.Project, error) {
+	const read = `SELECT ` + projectColumns + ` FROM projects
+		JOIN project_members ON project_members.project_id = projects.id
+		WHERE project_members.user_id = ? ORDER BY projects.id`
+	rows, err := r.q.QueryContext(ctx, read, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := []*model.Project{}
+	for rows.Next() {
+		project, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, project)
+	}
+	return projects, rows.Err()
+}
+
+// UpdateProject implements ProjectRepository interface.
+func (r *SQLiteProjectRepository) UpdateProject(ctx context.Context, project *model.Project) error {
+	const update = `UPDATE projects SET name = ?, description = ? WHERE id = ?`
+	return r.execOne(ctx, update, project.Name, project.Description, project.ID)
+}
+
+// DeleteProject implements ProjectRepository interface.
+// Members are deleted by the foreign keys of project_members.
+func (r *SQLiteProjectRepository) DeleteProject(ctx context.Context, id int64) error {
+	return r.execOne(ctx, `DELETE FROM projects WHERE id = ?`, id)
+}
+
+// execOne executes query, failing with *model.ErrNotFound when no row is affected.
+func (r *SQLiteProjectRepository) execOne(ctx context.Context, query string, args ...interface{}) error {
+	result, err := r.q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return &model.ErrNotFound{}
+	}
+	return nil
+}
+
+// ProjectIDs implements ProjectRepository interface.
+func (r *SQLiteProjectRepository) ProjectIDs(ctx context.Context, userID int64) ([]int64, error) {
+	const read = `SELECT project_id FROM project_members WHERE user_id = ? ORDER BY project_id`
+	rows, err := r.q.QueryContext(ctx, read, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Members implements ProjectRepository interface.
+func (r *SQLiteProjectRepository) Members(ctx context.Context, id int64) ([]*model.ProjectMember, error) {
+	const read = `SELECT ` + memberColumns + ` FROM project_members JOIN users ON users.id = project_members.user_id
+		WHERE project_members.project_id = ? ORDER BY project_members.user_id`
+	rows, err := r.q.QueryContext(ctx, read, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*model.ProjectMember{}
+	for rows.Next() {
+		member, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// SetMember implements ProjectRepository interface.
+func (r *SQLiteProjectRepository) SetMember(ctx context.Context, id int64, member *model.ProjectMember) (*model.ProjectMember, error) {
+	const (
+		upsert = `INSERT INTO project_members(project_id, user_id, role) VALUES(?, ?, ?)
+			ON CONFLICT(project_id, user_id) DO UPDATE SET role = excluded.role`
+		read = `SELECT ` + memberColumns + ` FROM project_members JOIN users ON users.id = project_members.user_id
+			WHERE project_members.project_id = ? AND project_members.user_id = ?`
+	)
+	if _, err := r.q.ExecContext(ctx, upsert, id, member.UserID, member.Role); err != nil {
+		return nil, err
+	}
+	return scanMember(r.q.QueryRowContext(ctx, read, id, member.UserID))
+}
+
+// RemoveMember implements ProjectRepository interface.
+func (r *SQLiteProjectRepository) RemoveMember(ctx context.Context, id, userID int64) error {
+	return r.execOne(ctx, `DELETE FROM project_members WHERE project_id = ? AND user_id = ?`, id, userID)
+}
+
+// WithTx implements ProjectRepository interface.
+// Calls nested in a transaction join it.
+func (r *SQLiteProjectRepository) WithTx(ctx context.Context, fn func(ProjectRepository) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&SQLiteProjectRepository{db: r.db, q: tx, tx: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,174 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/repository"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSQLiteProjectRepository(t *testing.T) {
+	t.Parallel()
+
+	testProjectRepository(t, func(t *testing.T) (repository.ProjectRepository, repository.UserRepository) {
+		todoDB, err := db.NewDB(filepath.Join(t.TempDir(), "todo.db"))
+		if err != nil {
+			t.Fatal("failed to create database, err =", err)
+		}
+		t.Cleanup(func() {
+			if err := todoDB.Close(); err != nil {
+				t.Error("failed to close database, err =", err)
+			}
+		})
+		return repository.NewSQLiteProjectRepository(todoDB), repository.NewSQLiteUserRepository(todoDB)
+	})
+}
+
+func TestMemoryProjectRepository(t *testing.T) {
+	t.Parallel()
+
+	testProjectRepository(t, func(t *testing.T) (repository.ProjectRepository, repository.UserRepository) {
+		return repository.NewMemoryProjectRepository(), repository.NewMemoryUserRepository()
+	})
+}
+
+// testProjectRepository checks that the repository made by newRepos satisfies
+// the contract of repository.ProjectRepository. Every case gets empty
+// repositories, the second of which stores the users of the first.
+func testProjectRepository(t *testing.T, newRepos func(t *testing.T) (repository.ProjectRepository, repository.UserRepository)) {
+	ctx := context.Background()
+
+	// users creates the users with usernames.
+	users := func(t *testing.T, repo repository.UserRepository, usernames ...string) []*model.User {
+		t.Helper()
+		var created []*model.User
+		for _, name := range usernames {
+			user, err := repo.CreateUser(ctx, &model.User{Username: name, PasswordHash: "hash"})
+			if err != nil {
+				t.Fatal("failed to create user, err =", err)
+			}
+			created = append(created, user)
+		}
+		return created
+	}
+
+	t.Run("Projects", func(t *testing.T) {
+		t.Parallel()
+		repo, userRepo := newRepos(t)
+		u := users(t, userRepo, "alice", "bob")
+		alice, bob := u[0], u[1]
+
+		created, err := repo.CreateProject(ctx, &model.Project{Name: "sprint", Description: "desc"}, alice)
+		if err != nil {
+			t.Fatal("failed to create project, err =", err)
+		}
+		if created.ID == 0 || created.Name != "sprint" || created.Role != model.ProjectRoleOwner || created.CreatedAt.IsZero() {
+			t.Errorf("unexpected created project, given = %+v", created)
+		}
+		other, err := repo.CreateProject(ctx, &model.Project{Name: "other"}, bob)
+		if err != nil {
+			t.Fatal("failed to create project, err =", err)
+		}
+
+		var errNotFound *model.ErrNotFound
+		if _, err := repo.FindProject(ctx, created.ID, bob.ID); !errors.As(err, &errNotFound) {
+			t.Errorf("unexpected error finding a project of a non-member, given = %v", err)
+		}
+		created.Name, created.Description = "renamed", ""
+		if err := repo.UpdateProject(ctx, created); err != nil {
+			t.Fatal("failed to update project, err =", err)
+		}
+		found, err := repo.FindProject(ctx, created.ID, alice.ID)
+		if err != nil || found.Name != "renamed" || found.Description != "" {
+			t.Errorf("unexpected updated project, given = %+v, err = %v", found, err)
+		}
+
+		projects, err := repo.ListProjects(ctx, alice.ID)
+		if err != nil || len(projects) != 1 || projects[0].ID != created.ID {
+			t.Errorf("unexpected projects of alice, given = %v, err = %v", projects, err)
+		}
+		ids, err := repo.ProjectIDs(ctx, bob.ID)
+		if diff := cmp.Diff([]int64{other.ID}, ids); err != nil || diff != "" {
+			t.Errorf("unexpected project ids of bob, diff = %s, err = %v", diff, err)
+		}
+
+		if err := repo.DeleteProject(ctx, other.ID); err != nil {
+			t.Fatal("failed to delete project, err =", err)
+		}
+		if _, err := repo.FindProject(ctx, other.ID, bob.ID); !errors.As(err, &errNotFound) {
+			t.Errorf("unexpected error finding a deleted project, given = %v", err)
+		}
+		if err := repo.DeleteProject(ctx, other.ID); !errors.As(err, &errNotFound) {
+			t.Errorf("unexpected error deleting a deleted project, given = %v", err)
+		}
+	})
+
+	t.Run("Members", func(t *testing.T) {
+		t.Parallel()
+		repo, userRepo := newRepos(t)
+		u := users(t, userRepo, "alice", "bob")
+		alice, bob := u[0], u[1]
+
+		project, err := repo.CreateProject(ctx, &model.Project{Name: "sprint"}, alice)
+		if err != nil {
+			t.Fatal("failed to create project, err =", err)
+		}
+		member, err := repo.SetMember(ctx, project.ID, &model.ProjectMember{UserID: bob.ID, Username: bob.Username, Role: model.ProjectRoleViewer})
+		if err != nil {
+			t.Fatal("failed to add member, err =", err)
+		}
+		if member.Username != "bob" || member.Role != model.ProjectRoleViewer || member.CreatedAt.IsZero() {
+			t.Errorf("unexpected member, given = %+v", member)
+		}
+		if _, err := repo.SetMember(ctx, project.ID, &model.ProjectMember{UserID: bob.ID, Role: model.ProjectRoleEditor}); err != nil {
+			t.Fatal("failed to change role, err =", err)
+		}
+		found, err := repo.FindProject(ctx, project.ID, bob.ID)
+		if err != nil || found.Role != model.ProjectRoleEditor {
+			t.Errorf("unexpected project of bob, given = %+v, err = %v", found, err)
+		}
+
+		members, err := repo.Members(ctx, project.ID)
+		if err != nil {
+			t.Fatal("failed to read members, err =", err)
+		}
+		var got []string
+		for _, m := range members {
+			got = append(got, m.Username+":"+string(m.Role))
+		}
+		if diff := cmp.Diff([]string{"alice:owner", "bob:editor"}, got); diff != "" {
+			t.Errorf("unexpected members, diff = %s", diff)
+		}
+
+		// トランザクションが失敗したら、その中での変更は残らない
+		errRollback := errors.New("rollback")
+		err = repo.WithTx(ctx, func(tx repository.ProjectRepository) error {
+			if err := tx.RemoveMember(ctx, project.ID, bob.ID); err != nil {
+				return err
+			}
+			return errRollback
+		})
+		if !errors.Is(err, errRollback) {
+			t.Errorf("unexpected transaction error, given = %v", err)
+		}
+		if _, err := repo.FindProject(ctx, project.ID, bob.ID); err != nil {
+			t.Error("member removed in a failed transaction, err =", err)
+		}
+
+		if err := repo.RemoveMember(ctx, project.ID, bob.ID); err != nil {
+			t.Fatal("failed to remove member, err =", err)
+		}
+		var errNotFound *model.ErrNotFound
+		if err := repo.RemoveMember(ctx, project.ID, bob.ID); !errors.As(err, &errNotFound) {
+			t.Errorf("unexpected error removing a non-member, given = %v", err)
+		}
+		if ids, err := repo.ProjectIDs(ctx, bob.ID); err != nil || len(ids) != 0 {
+			t.Errorf("unexpected project ids of a removed member, given = %v, err = %v", ids, err)
+		}
+	})
+}
@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// A MemoryProjectRepository implements ProjectRepository in memory.
+// It is meant for tests and for running without a database.
+type MemoryProjectRepository struct {
+	s *memoryProjectStore
+	// locked is true while in WithTx, whose caller already holds s.mu.
+	locked bool
+}
+
+type memoryProjectStore struct {
+	mu       sync.Mutex
+	projects map[int64]*model.Project
+	// members are the members of each project by user id.
+	members map[int64]map[int64]*model.ProjectMember
+	nextID  int64
+}
+
+// NewMemoryProjectRepository returns new MemoryProjectRepository.
+func NewMemoryProjectRepository() *MemoryProjectRepository {
+	return &MemoryProjectRepository{
+		s: &memoryProjectStore{
+			projects: map[int64]*model.Project{},
+			members:  map[int64]map[int64]*model.ProjectMember{},
+			nextID:   1,
+		},
+	}
+}
+
+func (r *MemoryProjectRepository) lock() func() {
+	if r.locked {
+		return func() {}
+	}
+	r.s.mu.Lock()
+	return r.s.mu.Unlock
+}
+
+// project returns a copy of the project with id as seen by the user with
+// userID, or nil when they are not a member.
+func (r *MemoryProjectRepository) project(id, userID int64) *model.Project {
+	project, ok := r.s.projects[id]
+	member, isMember := r.s.members[id][userID]
+	if !ok || !isMember {
+		return nil
+	}
+	ret := *project
+	ret.Role = member.Role
+	return &ret
+}
+
+// CreateProject implements ProjectRepository interface.
+func (r *MemoryProjectRepository) CreateProject(ctx context.Context, project *model.Project, owner *model.User) (*model.Project, error) {
+	defer r.lock()()
+
+	stored := *project
+	stored.ID = r.s.nextID
+	stored.Role = ""
+	stored.CreatedAt = now()
+	stored.UpdatedAt = stored.CreatedAt
+	r.s.projects[stored.ID] = &stored
+	r.s.members[stored.ID] = map[int64]*model.ProjectMember{
+		owner.ID: {UserID: owner.ID, Username: owner.Username, Role: model.ProjectRoleOwner, CreatedAt: stored.CreatedAt},
+	}
+	r.s.nextID++
+	return r.project(stored.ID, owner.ID), nil
+}
+
+// FindProject implements ProjectRepository interface.
+func (r *MemoryProjectRepository) FindProject(ctx context.Context, id, userID int64) (*model.Project, error) {
+	defer r.lock()()
+
+	project := r.project(id, userID)
+	if project == nil {
+		return nil, &model.ErrNotFound{}
+	}
+	return project, nil
+}
+
+// ListProjects implements ProjectRepository interface.
+func (r *MemoryProjectRepository) ListProjects(ctx context.Context, userID int64) ([]*model.Project, error) {
+	defer r.lock()()
+
+	projects := []*model.Project{}
+	for id := range r.s.projects {
+		if project := r.project(id, userID); project != nil {
+			projects = append(projects, project)
+		}
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].ID < projects[j].ID })
+	return projects, nil
+}
+
+// UpdateProject implements ProjectRepository interface.
+func (r *MemoryProjectRepository) UpdateProject(ctx context.Context, project *model.Project) error {
+	defer r.lock()()
+
+	old, ok := r.s.projects[project.ID]
+	if !ok {
+		return &model.ErrNotFound{}
+	}
+	stored := *old
+	stored.Name = project.Name
+	stored.Description = project.Description
+	stored.UpdatedAt = now()
+	r.s.projects[project.ID] = &stored
+	return nil
+}
+
+// DeleteProject implements ProjectRepository interface.
+func (r *MemoryProjectRepository) DeleteProject(ctx context.Context, id int64) error {
+	defer r.lock()()
+
+	if _, ok := r.s.projects[id]; !ok {
+		return &model.ErrNotFound{}
+	}
+	delete(r.s.projects, id)
+	delete(r.s.members, id)
+	return nil
+}
+
+// ProjectIDs implements ProjectRepository interface.
+func (r *MemoryProjectRepository) ProjectIDs(ctx context.Context, userID int64) ([]int64, error) {
+	defer r.lock()()
+
+	ids := []int64{}
+	for id, members := range r.s.members {
+		if _, ok := members[userID]; ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// Members implements ProjectRepository interface.
+func (r *MemoryProjectRepository) Members(ctx context.Context, id int64) ([]*model.ProjectMember, error) {
+	defer r.lock()()
+
+	members := []*model.ProjectMember{}
+	for _, member := range r.s.members[id] {
+		ret := *member
+		members = append(members, &ret)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].UserID < members[j].UserID })
+	return members, nil
+}
+
+// SetMember implements ProjectRepository interface.
+func (r *MemoryProjectRepository) SetMember(ctx context.Context, id int64, member *model.ProjectMember) (*model.ProjectMember, error) {
+	defer r.lock()()
+
+	members, ok := r.s.members[id]
+	if !ok {
+		return nil, &model.ErrNotFound{}
+	}
+	stored := *member
+	if old, ok := members[member.UserID]; ok {
+		stored = *old
+		stored.Role = member.Role
+	} else {
+		stored.CreatedAt = now()
+	}
+	members[member.UserID] = &stored
+	ret := stored
+	return &ret, nil
+}
+
+// RemoveMember implements ProjectRepository interface.
+func (r *MemoryProjectRepository) RemoveMember(ctx context.Context, id, userID int64) error {
+	defer r.lock()()
+
+	if _, ok := r.s.members[id][userID]; !ok {
+		return &model.ErrNotFound{}
+	}
+	delete(r.s.members[id], userID)
+	return nil
+}
+
+// WithTx implements ProjectRepository interface.
+// The store is locked until fn returns, and restored when fn fails.
+func (r *MemoryProjectRepository) WithTx(ctx context.Context, fn func(ProjectRepository) error) error {
+	if r.locked {
+		return fn(r)
+	}
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	// 値は置き換えるだけで書き換えないため、マップの複製で足りる
+	projects := make(map[int64]*model.Project, len(r.s.projects))
+	for id, project := range r.s.projects {
+		projects[id] = project
+	}
+	members := make(map[int64]map[int64]*model.ProjectMember, len(r.s.members))
+	for id, m := range r.s.members {
+		c := make(map[int64]*model.ProjectMember, len(m))
+		for userID, member := range m {
+			c[userID] = member
+		}
+		members[id] = c
+	}
+	nextID := r.s.nextID
+
+	if err := fn(&MemoryProjectRepository{s: r.s, locked: true}); err != nil {
+		r.s.projects, r.s.members, r.s.nextID = projects, members, nextID
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Package repository stores TODO entities.
+//
+// TODORepository only persists TODOs. Rules such as the status workflow
+// belong to the service package, so that every implementation behaves the
+// same way and can be checked by the same conformance tests.
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// A TODORepository stores TODOs and their tags.
+//
+// A repository is scoped to the TODOs of an owner and of some projects, see
+// ForOwner, and the repositories returned by the constructors are scoped to
+// TODOs without owner or project, which only migrations and tests make.
+//
+// Deleted TODOs are kept in the trash until they are restored or purged.
+// Except for the methods on the trash, TODOs in the trash are treated as if
+// they did not exist.
+//
+// Methods looking up TODOs by id return *model.ErrNotFound when there is none.
+// TODOs passed in are never retained, and TODOs returned are never shared, so
+// both sides may modify them freely.
+type TODORepository interface {
+	// ForOwner returns the repository of the TODOs without project owned by
+	// the user with ownerID, or without owner when ownerID is 0, and of the
+	// TODOs of the projects with projectIDs whoever owns them.
+	ForOwner(ownerID int64, projectIDs ...int64) TODORepository
+	// Create stores todo as a new TODO of the owner and returns it as stored.
+	// The TODO belongs to the project with ProjectID of todo unless it is 0,
+	// which must be in the scope. ID, Status, CompletedAt, CreatedAt,
+	// UpdatedAt, DeletedAt and OwnerID of todo are ignored.
+	Create(ctx context.Context, todo *model.TODO) (*model.TODO, error)
+	// Find returns the TODO with id.
+	Find(ctx context.Context, id int64) (*model.TODO, error)
+	// List returns at most size TODOs with an id less than prevID, or any id
+	// when prevID is 0, matching every filter, newest first.
+	List(ctx context.Context, prevID, size int64, filters ...model.TODOFilter) ([]*model.TODO, error)
+	// Update overwrites the stored TODO having the id of todo and returns it as stored.
+	// CreatedAt, UpdatedAt, DeletedAt, ProjectID and OwnerID of todo are ignored.
+	Update(ctx context.Context, todo *model.TODO) (*model.TODO, error)
+	// Delete moves the TODOs with ids to the trash. It fails only when none
+	// of them exists, and does nothing for empty ids.
+	Delete(ctx context.Context, ids []int64) error
+	// ListTrash returns at most size TODOs in the trash with an id less than
+	// prevID, or any id when prevID is 0, newest first.
+	ListTrash(ctx context.Context, prevID, size int64) ([]*model.TODO, error)
+	// Restore moves the TODO with id back from the trash and returns it.
+	Restore(ctx context.Context, id int64) (*model.TODO, error)
+	// Purge permanently deletes the TODOs of every owner moved to the trash
+	// before the given time, together with their history, and returns how
+	// many were deleted.
+	Purge(ctx context.Context, before time.Time) (int64, error)
+	// AddEvent appends event to the history of the TODO with event.TODOID and
+	// returns it as stored. Revision and CreatedAt of event are ignored.
+	AddEvent(ctx context.Context, event *model.TODOEvent) (*model.TODOEvent, error)
+	// Events returns the history of the TODO with id, oldest first. Unlike the
+	// other methods, it also finds TODOs in the trash.
+	Events(ctx context.Context, id int64) ([]*model.TODOEvent, error)
+	// Event returns the event of the TODO with id at revision.
+	Event(ctx context.Context, id, revision int64) (*model.TODOEvent, error)
+	// Tags returns the tags in use with the number of TODOs having them, by name.
+	Tags(ctx context.Context) ([]*model.Tag, error)
+	// Search returns at most size TODOs containing every term in their subject
+	// or description, most relevant first.
+	Search(ctx context.Context, terms []string, size int64) ([]*model.SearchResult, error)
+	// WithTx calls fn with a repository whose operations are applied
+	// atomically, and only if fn returns nil.
+	WithTx(ctx context.Context, fn func(TODORepository) error) error
+}
@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"context"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+const (
+	markOpen  = "<mark>"
+	markClose = "</mark>"
+	// ftsMinTermLength is the shortest term the trigram tokenizer can match.
+	ftsMinTermLength = 3
+	// snippetRunes is the length of excerpts in characters.
+	snippetRunes = 64
+	// scanFactor bounds the TODOs ranked without the index to scanFactor
+	// times the size of the results, so that a term matching most TODOs does
+	// not load all of them.
+	scanFactor = 10
+)
+
+// Search implements TODORepository interface.
+//
+// The FTS5 index is used when the database has one. Otherwise, or when a term
+// is too short for the index, TODOs are scanned with LIKE instead, and only
+// the newest scanFactor*size matches are ranked.
+// Snippets are cut in Go either way, since those of FTS5 are not escaped.
+func (r *SQLiteTODORepository) Search(ctx context.Context, terms []string, size int64) ([]*model.SearchResult, error) {
+	if len(terms) == 0 || size <= 0 {
+		return []*model.SearchResult{}, nil
+	}
+
+	useFTS, err := r.hasFTS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) < ftsMinTermLength {
+			useFTS = false
+		}
+	}
+
+	var results []*model.SearchResult
+	if useFTS {
+		results, err = r.searchFTS(ctx, terms, size)
+	} else {
+		results, err = r.searchLike(ctx, terms, size)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	todos := make([]*model.TODO, 0, len(results))
+	for _, res := range results {
+		todos = append(todos, &res.TODO)
+	}
+	if err := r.loadTags(ctx, todos); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// hasFTS reports whether the full-text index exists.
+func (r *SQLiteTODORepository) hasFTS(ctx context.Context) (bool, error) {
+	const exists = `SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'todos_fts'`
+	var ok bool
+	if err := r.q.QueryRowContext(ctx, exists).Scan(&ok); err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+func (r *SQLiteTODORepository) searchFTS(ctx context.Context, terms []string, size int64) ([]*model.SearchResult, error) {
+	// 利用者の入力を FTS5 のクエリ構文として解釈させないよう、各語をフレーズとして扱う
+	phrases := make([]string, 0, len(terms))
+	for _, term := range terms {
+		phrases = append(phrases, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+	}
+
+	cond, args := r.scope(strings.Join(phrases, " "))
+	search := `SELECT ` + todoColumns + `, -bm25(todos_fts)
+		FROM todos_fts JOIN todos ON todos.id = todos_fts.rowid
+		WHERE todos_fts MATCH ? AND todos.deleted_at IS NULL AND ` + cond + ` ORDER BY bm25(todos_fts) LIMIT ?`
+	rows, err := r.q.QueryContext(ctx, search, append(args, size)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	m := newMatcher(terms)
+	results := []*model.SearchResult{}
+	for rows.Next() {
+		var rank float64
+		todo, err := scanTODO(rows, &rank)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, &model.SearchResult{TODO: *todo, Snippet: m.snippet(todo), Rank: rank})
+	}
+	return results, rows.Err()
+}
+
+func (r *SQLiteTODORepository) searchLike(ctx context.Context, terms []string, size int64) ([]*model.SearchResult, error) {
+	cond, args := r.scope()
+	where := []string{`deleted_at IS NULL`, cond}
+	for _, term := range terms {
+		pattern := "%" + likeEscaper.Replace(term) + "%"
+		where = append(where, `(subject LIKE ? ESCAPE '\' OR description LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+	search := `SELECT ` + todoColumns + ` FROM todos WHERE ` + strings.Join(where, ` AND `) + ` ORDER BY id DESC LIMIT ?`
+	rows, err := r.q.QueryContext(ctx, search, append(args, scanFactor*size)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	m := newMatcher(terms)
+	results := []*model.SearchResult{}
+	for rows.Next() {
+		todo, err := scanTODO(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, m.result(todo))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rankResults(results, size), nil
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// A matcher finds search terms in TODOs without an index, ignoring case like LIKE.
+type matcher struct {
+	terms []*regexp.Regexp
+	any   *regexp.Regexp
+}
+
+func newMatcher(terms []string) *matcher {
+	m := &matcher{}
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		q := regexp.QuoteMeta(term)
+		m.terms = append(m.terms, regexp.MustCompile(`(?i)`+q))
+		quoted = append(quoted, q)
+	}
+	m.any = regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+	return m
+}
+
+// match reports whether todo contains every term.
+func (m *matcher) match(todo *model.TODO) bool {
+	for _, re := range m.terms {
+		if !re.MatchString(todo.Subject) && !re.MatchString(todo.Description) {
+			return false
+		}
+	}
+	return true
+}
+
+// result ranks todo and cuts its snippet.
+func (m *matcher) result(todo *model.TODO) *model.SearchResult {
+	subjectHits := len(m.any.FindAllStringIndex(todo.Subject, -1))
+	descriptionHits := len(m.any.FindAllStringIndex(todo.Description, -1))
+	return &model.SearchResult{
+		TODO:    *todo,
+		Snippet: m.snippet(todo),
+		// 件名での一致を説明での一致より重く扱う
+		Rank: float64(2*subjectHits + descriptionHits),
+	}
+}
+
+// snippet cuts the snippet of todo from its description, or from its subject
+// when only the subject matches.
+func (m *matcher) snippet(todo *model.TODO) string {
+	if m.any.MatchString(todo.Description) {
+		return snippet(m.any, todo.Description)
+	}
+	return snippet(m.any, todo.Subject)
+}
+
+// rankResults sorts results by rank, keeping the given order for ties, and keeps the top size.
+func rankResults(results []*model.SearchResult, size int64) []*model.SearchResult {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Rank > results[j].Rank
+	})
+	if int64(len(results)) > size {
+		results = results[:size]
+	}
+	return results
+}
+
+// snippet cuts an excerpt of text around the first match of re and marks every
+// match in it. The text is HTML escaped, so that the marks are the only tags.
+func snippet(re *regexp.Regexp, text string) string {
+	loc := re.FindStringIndex(text)
+	if loc == nil {
+		return ""
+	}
+
+	runes := []rune(text)
+	start := utf8.RuneCountInString(text[:loc[0]]) - snippetRunes/4
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetRunes
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	// 一致を探してからエスケープし、エスケープで生じた文字列に一致させない
+	excerpt := string(runes[start:end])
+	var b strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringIndex(excerpt, -1) {
+		b.WriteString(html.EscapeString(excerpt[last:loc[0]]))
+		b.WriteString(markOpen + html.EscapeString(excerpt[loc[0]:loc[1]]) + markClose)
+		last = loc[1]
+	}
+	b.WriteString(html.EscapeString(excerpt[last:]))
+	excerpt = b.String()
+	if start > 0 {
+		excerpt = "…" + excerpt
+	}
+	if end < len(runes) {
+		excerpt += "…"
+	}
+	return excerpt
+}
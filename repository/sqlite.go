@@ -0,0 +1,446 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// A SQLiteTODORepository implements TODORepository on the go-sqlite3 database made by db.NewDB.
+type SQLiteTODORepository struct {
+	db *sql.DB
+	// q is db, or the transaction while in WithTx.
+	q  queryer
+	tx *sql.Tx
+	// owner is the owner_id of the TODOs in the scope, or 0 for NULL.
+	owner int64
+	// projects are the project_id of the TODOs in the scope.
+	projects []int64
+}
+
+// NewSQLiteTODORepository returns new SQLiteTODORepository.
+func NewSQLiteTODORepository(db *sql.DB) *SQLiteTODORepository {
+	return &SQLiteTODORepository{
+		db: db,
+		q:  db,
+	}
+}
+
+// todoColumns is the column list scanned by scanTODO.
+// Columns are qualified so that the list also works in joins.
+const todoColumns = `todos.id, todos.subject, todos.description, todos.status, todos.completed_at, todos.due_at, todos.remind_at, todos.created_at, todos.updated_at, todos.deleted_at, COALESCE(todos.project_id, 0), COALESCE(todos.owner_id, 0)`
+
+// sqliteTimeLayout is the layout DATETIME('now') produces.
+//
+// Every timestamp is stored in UTC with this layout, whatever time.Local is,
+// so that due dates written by the service compare correctly as text against
+// created_at, updated_at and DATETIME('now') inside SQL.
+const sqliteTimeLayout = "2006-01-02 15:04:05"
+
+// sqlTime converts t to the stored representation, keeping nil as NULL.
+func sqlTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+// A queryer is implemented by both *sql.DB and *sql.Tx.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// A rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// ownerArg is the owner_id of the TODOs in the scope.
+func (r *SQLiteTODORepository) ownerArg() interface{} {
+	if r.owner == 0 {
+		return nil
+	}
+	return r.owner
+}
+
+// scope returns the condition limiting queries to the TODOs in the scope,
+// followed by args and the arguments of the condition.
+func (r *SQLiteTODORepository) scope(args ...interface{}) (string, []interface{}) {
+	// IS matches NULL as well as values, unlike =
+	cond := `todos.project_id IS NULL AND todos.owner_id IS ?`
+	args = append(args, r.ownerArg())
+	if len(r.projects) > 0 {
+		cond = fmt.Sprintf(`%s OR todos.project_id IN (?%s)`, cond, strings.Repeat(", ?", len(r.projects)-1))
+		for _, id := range r.projects {
+			args = append(args, id)
+		}
+	}
+	return `(` + cond + `)`, args
+}
+
+// inScope reports whether the project with projectID is in the scope.
+func (r *SQLiteTODORepository) inScope(projectID int64) bool {
+	for _, id := range r.projects {
+		if id == projectID {
+			return true
+		}
+	}
+	return false
+}
+
+// ForOwner implements TODORepository interface.
+func (r *SQLiteTODORepository) ForOwner(ownerID int64, projectIDs ...int64) TODORepository {
+	c := *r
+	c.owner = ownerID
+	c.projects = append([]int64{}, projectIDs...)
+	return &c
+}
+
+// scanTODO scans the todoColumns of row, followed by extra columns if any.
+func scanTODO(row rowScanner, extra ...interface{}) (*model.TODO, error) {
+	todo := model.TODO{}
+	dest := []interface{}{&todo.ID, &todo.Subject, &todo.Description, &todo.Status, &todo.CompletedAt, &todo.DueAt, &todo.RemindAt, &todo.CreatedAt, &todo.UpdatedAt, &todo.DeletedAt, &todo.ProjectID, &todo.OwnerID}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	return &todo, nil
+}
+
+// Create implements TODORepository interface.
+func (r *SQLiteTODORepository) Create(ctx context.Context, todo *model.TODO) (*model.TODO, error) {
+	const insert = `INSERT INTO todos(subject, description, due_at, remind_at, owner_id, project_id) VALUES(?, ?, ?, ?, ?, ?)`
+	if todo.ProjectID != 0 && !r.inScope(todo.ProjectID) {
+		return nil, &model.ErrNotFound{}
+	}
+	var projectID interface{}
+	if todo.ProjectID != 0 {
+		projectID = todo.ProjectID
+	}
+	var created *model.TODO
+	err := r.WithTx(ctx, func(repo TODORepository) error {
+		tx := repo.(*SQLiteTODORepository)
+		result, err := tx.q.ExecContext(ctx, insert, todo.Subject, todo.Description, sqlTime(todo.DueAt), sqlTime(todo.RemindAt), r.ownerArg(), projectID)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if err := tx.setTags(ctx, id, todo.Tags); err != nil {
+			return err
+		}
+		created, err = tx.Find(ctx, id)
+		return err
+	})
+	return created, err
+}
+
+// Find implements TODORepository interface.
+func (r *SQLiteTODORepository) Find(ctx context.Context, id int64) (*model.TODO, error) {
+	cond, args := r.scope(id)
+	read := `SELECT ` + todoColumns + ` FROM todos WHERE id = ? AND deleted_at IS NULL AND ` + cond
+	todo, err := scanTODO(r.q.QueryRowContext(ctx, read, args...))
+	if err == sql.ErrNoRows {
+		return nil, &model.ErrNotFound{}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := r.loadTags(ctx, []*model.TODO{todo}); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// List implements TODORepository interface.
+func (r *SQLiteTODORepository) List(ctx context.Context, prevID, size int64, filters ...model.TODOFilter) ([]*model.TODO, error) {
+	cond, args := r.scope()
+	where := []string{`deleted_at IS NULL`, cond}
+	if prevID != 0 {
+		where = append(where, `id < ?`)
+		args = append(args, prevID)
+	}
+	for _, f := range filters {
+		w, a := filterConditions(f)
+		where = append(where, w...)
+		args = append(args, a...)
+	}
+
+	return r.list(ctx, where, args, size)
+}
+
+// list returns at most size TODOs matching every condition of where, newest first.
+func (r *SQLiteTODORepository) list(ctx context.Context, where []string, args []interface{}, size int64) ([]*model.TODO, error) {
+	read := `SELECT ` + todoColumns + ` FROM todos WHERE ` + strings.Join(where, ` AND `) + ` ORDER BY id DESC LIMIT ?`
+	rows, err := r.q.QueryContext(ctx, read, append(args, size)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	todos := []*model.TODO{}
+	for rows.Next() {
+		todo, err := scanTODO(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := r.loadTags(ctx, todos); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// filterConditions translates f into SQL conditions and their arguments.
+func filterConditions(f model.TODOFilter) ([]string, []interface{}) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if len(f.Statuses) > 0 {
+		where = append(where, fmt.Sprintf(`status IN (?%s)`, strings.Repeat(", ?", len(f.Statuses)-1)))
+		for _, v := range f.Statuses {
+			args = append(args, v)
+		}
+	}
+	if f.DueBefore != nil {
+		where = append(where, `due_at < ?`)
+		args = append(args, sqlTime(f.DueBefore))
+	}
+	if f.DueAfter != nil {
+		where = append(where, `due_at > ?`)
+		args = append(args, sqlTime(f.DueAfter))
+	}
+	if f.Overdue {
+		where = append(where, `due_at < DATETIME('now') AND status NOT IN (?, ?)`)
+		args = append(args, model.TODOStatusDone, model.TODOStatusCancelled)
+	}
+	if f.ProjectID != 0 {
+		where = append(where, `project_id = ?`)
+		args = append(args, f.ProjectID)
+	}
+	if tags := model.NormalizeTags(f.Tags); len(tags) > 0 {
+		match := fmt.Sprintf(`id IN (SELECT tt.todo_id FROM todo_tags tt JOIN tags t ON t.id = tt.tag_id WHERE t.name IN (?%s) GROUP BY tt.todo_id`,
+			strings.Repeat(", ?", len(tags)-1))
+		for _, v := range tags {
+			args = append(args, v)
+		}
+		if f.AnyTag {
+			match += `)`
+		} else {
+			match += ` HAVING COUNT(*) = ?)`
+			args = append(args, len(tags))
+		}
+		where = append(where, match)
+	}
+	return where, args
+}
+
+// Update implements TODORepository interface.
+func (r *SQLiteTODORepository) Update(ctx context.Context, todo *model.TODO) (*model.TODO, error) {
+	cond, args := r.scope(todo.Subject, todo.Description, todo.Status, sqlTime(todo.CompletedAt),
+		sqlTime(todo.DueAt), sqlTime(todo.RemindAt), todo.ID)
+	update := `UPDATE todos SET subject = ?, description = ?, status = ?, completed_at = ?, due_at = ?, remind_at = ?
+		WHERE id = ? AND deleted_at IS NULL AND ` + cond
+	var updated *model.TODO
+	err := r.WithTx(ctx, func(repo TODORepository) error {
+		tx := repo.(*SQLiteTODORepository)
+		result, err := tx.q.ExecContext(ctx, update, args...)
+		if err != nil {
+			return err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return &model.ErrNotFound{}
+		}
+		if err := tx.setTags(ctx, todo.ID, todo.Tags); err != nil {
+			return err
+		}
+		updated, err = tx.Find(ctx, todo.ID)
+		return err
+	})
+	return updated, err
+}
+
+// Delete implements TODORepository interface.
+func (r *SQLiteTODORepository) Delete(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var arg []interface{}
+	for _, v := range ids {
+		arg = append(arg, v)
+	}
+	cond, arg := r.scope(arg...)
+	deleteFmt := fmt.Sprintf(`UPDATE todos SET deleted_at = DATETIME('now') WHERE id IN (?%s) AND deleted_at IS NULL AND %s`,
+		strings.Repeat(", ?", len(ids)-1), cond)
+	result, err := r.q.ExecContext(ctx, deleteFmt, arg...)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return &model.ErrNotFound{}
+	}
+	return nil
+}
+
+// ListTrash implements TODORepository interface.
+func (r *SQLiteTODORepository) ListTrash(ctx context.Context, prevID, size int64) ([]*model.TODO, error) {
+	cond, args := r.scope()
+	where := []string{`deleted_at IS NOT NULL`, cond}
+	if prevID != 0 {
+		where = append(where, `id < ?`)
+		args = append(args, prevID)
+	}
+	return r.list(ctx, where, args, size)
+}
+
+// Restore implements TODORepository interface.
+func (r *SQLiteTODORepository) Restore(ctx context.Context, id int64) (*model.TODO, error) {
+	cond, args := r.scope(id)
+	restore := `UPDATE todos SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL AND ` + cond
+	var restored *model.TODO
+	err := r.WithTx(ctx, func(repo TODORepository) error {
+		tx := repo.(*SQLiteTODORepository)
+		result, err := tx.q.ExecContext(ctx, restore, args...)
+		if err != nil {
+			return err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return &model.ErrNotFound{}
+		}
+		restored, err = tx.Find(ctx, id)
+		return err
+	})
+	return restored, err
+}
+
+// Purge implements TODORepository interface.
+// Tags of the purged TODOs are detached by the foreign keys of todo_tags.
+func (r *SQLiteTODORepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	const purge = `DELETE FROM todos WHERE deleted_at IS NOT NULL AND deleted_at < ?`
+	result, err := r.q.ExecContext(ctx, purge, sqlTime(&before))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Tags implements TODORepository interface.
+func (r *SQLiteTODORepository) Tags(ctx context.Context) ([]*model.Tag, error) {
+	cond, args := r.scope()
+	read := `SELECT t.name, COUNT(*) FROM tags t JOIN todo_tags tt ON tt.tag_id = t.id JOIN todos ON todos.id = tt.todo_id
+		WHERE todos.deleted_at IS NULL AND ` + cond + ` GROUP BY t.id ORDER BY t.name`
+	rows, err := r.q.QueryContext(ctx, read, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*model.Tag{}
+	for rows.Next() {
+		tag := model.Tag{}
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, &tag)
+	}
+	return tags, rows.Err()
+}
+
+// WithTx implements TODORepository interface.
+// Calls nested in a transaction join it.
+func (r *SQLiteTODORepository) WithTx(ctx context.Context, fn func(TODORepository) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&SQLiteTODORepository{db: r.db, q: tx, tx: tx, owner: r.owner, projects: r.projects}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// loadTags fills Tags of todos.
+func (r *SQLiteTODORepository) loadTags(ctx context.Context, todos []*model.TODO) error {
+	if len(todos) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*model.TODO, len(todos))
+	args := make([]interface{}, 0, len(todos))
+	for _, todo := range todos {
+		byID[todo.ID] = todo
+		args = append(args, todo.ID)
+	}
+	read := fmt.Sprintf(`SELECT tt.todo_id, t.name FROM todo_tags tt JOIN tags t ON t.id = tt.tag_id WHERE tt.todo_id IN (?%s) ORDER BY t.name`,
+		strings.Repeat(", ?", len(todos)-1))
+	rows, err := r.q.QueryContext(ctx, read, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id   int64
+			name string
+		)
+		if err := rows.Scan(&id, &name); err != nil {
+			return err
+		}
+		todo := byID[id]
+		todo.Tags = append(todo.Tags, name)
+	}
+	return rows.Err()
+}
+
+// setTags replaces the tags of the TODO with id, creating missing tags.
+func (r *SQLiteTODORepository) setTags(ctx context.Context, id int64, tags []string) error {
+	const (
+		clear  = `DELETE FROM todo_tags WHERE todo_id = ?`
+		insert = `INSERT INTO tags(name) VALUES(?) ON CONFLICT(name) DO NOTHING`
+		attach = `INSERT INTO todo_tags(todo_id, tag_id) SELECT ?, id FROM tags WHERE name = ?`
+	)
+	if _, err := r.q.ExecContext(ctx, clear, id); err != nil {
+		return err
+	}
+	for _, name := range model.NormalizeTags(tags) {
+		if _, err := r.q.ExecContext(ctx, insert, name); err != nil {
+			return err
+		}
+		if _, err := r.q.ExecContext(ctx, attach, id, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
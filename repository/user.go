@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/mattn/go-sqlite3"
+)
+
+// A UserRepository stores users, their login sessions and API tokens.
+//
+// Usernames are compared ignoring case. Sessions are looked up by the hash of
+// their token, and API tokens by their prefix, so that tokens are never stored.
+type UserRepository interface {
+	// CreateUser stores user as a new user and returns it as stored.
+	// It fails with *model.ErrConflict when the username is taken.
+	// ID and CreatedAt of user are ignored.
+	CreateUser(ctx context.Context, user *model.User) (*model.User, error)
+	// FindUserByName returns the user with username.
+	FindUserByName(ctx context.Context, username string) (*model.User, error)
+	// FindUserByID returns the user with id.
+	FindUserByID(ctx context.Context, id int64) (*model.User, error)
+	// CreateSession stores a session of the user with userID valid until expiresAt.
+	CreateSession(ctx context.Context, tokenHash string, userID int64, expiresAt time.Time) error
+	// FindSession returns the user of the session with tokenHash unless it
+	// has expired by now.
+	FindSession(ctx context.Context, tokenHash string, now time.Time) (*model.User, error)
+	// DeleteSession deletes the session with tokenHash, if any.
+	DeleteSession(ctx context.Context, tokenHash string) error
+	// DeleteExpiredSessions deletes the sessions which have expired by now.
+	DeleteExpiredSessions(ctx context.Context, now time.Time) error
+
+	// CreateAPIToken stores token as a new API token and returns it as stored.
+	// It fails with *model.ErrConflict when the prefix is taken.
+	// ID, LastUsedAt and CreatedAt of token are ignored.
+	CreateAPIToken(ctx context.Context, token *model.APIToken) (*model.APIToken, error)
+	// ListAPITokens returns the API tokens of the user with userID in order of ID.
+	ListAPITokens(ctx context.Context, userID int64) ([]*model.APIToken, error)
+	// FindAPIToken returns the API token with prefix.
+	FindAPIToken(ctx context.Context, prefix string) (*model.APIToken, error)
+	// TouchAPIToken sets LastUsedAt of the API token with id to usedAt.
+	TouchAPIToken(ctx context.Context, id int64, usedAt time.Time) error
+	// DeleteAPIToken deletes the API token with id of the user with userID.
+	// It fails with *model.ErrNotFound when the user has no such token.
+	DeleteAPIToken(ctx context.Context, userID, id int64) error
+}
+
+// A SQLiteUserRepository implements UserRepository on the go-sqlite3 database made by db.NewDB.
+type SQLiteUserRepository struct {
+	db *sql.DB
+}
+
+// NewSQLiteUserRepository returns new SQLiteUserRepository.
+func NewSQLiteUserRepository(db *sql.DB) *SQLiteUserRepository {
+	return &SQLiteUserRepository{
+		db: db,
+	}
+}
+
+const userColumns = `users.id, users.username, users.password_hash, users.created_at`
+
+func scanUser(row rowScanner) (*model.User, error) {
+	user := model.User{}
+	if err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateUser implements UserRepository interface.
+func (r *SQLiteUserRepository) CreateUser(ctx context.Context, user *model.User) (*model.User, error) {
+	const (
+		insert = `INSERT INTO users(username, password_hash) VALUES(?, ?)`
+		read   = `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+	)
+	result, err := r.db.ExecContext(ctx, insert, user.Username, user.PasswordHash)
+	var errSQLite sqlite3.Error
+	if errors.As(err, &errSQLite) && errSQLite.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return nil, &model.ErrConflict{Message: "username is already taken"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return scanUser(r.db.QueryRowContext(ctx, read, id))
+}
+
+// FindUserByName implements UserRepository interface.
+func (r *SQLiteUserRepository) FindUserByName(ctx context.Context, username string) (*model.User, error) {
+	const read = `SELECT ` + userColumns + ` FROM users WHERE username = ?`
+	user, err := scanUser(r.db.QueryRowContext(ctx, read, username))
+	if err == sql.ErrNoRows {
+		return nil, &model.ErrNotFound{}
+	}
+	return user, err
+}
+
+// FindUserByID implements UserRepository interface.
+func (r *SQLiteUserRepository) FindUserByID(ctx context.Context, id int64) (*model.User, error) {
+	const read = `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+	user, err := scanUser(r.db.QueryRowContext(ctx, read, id))
+	if err == sql.ErrNoRows {
+		return nil, &model.ErrNotFound{}
+	}
+	return user, err
+}
+
+// CreateSession implements UserRepository interface.
+func (r *SQLiteUserRepository) CreateSession(ctx context.Context, tokenHash string, userID int64, expiresAt time.Time) error {
+	const insert = `INSERT INTO sessions(token_hash, user_id, expires_at) VALUES(?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, insert, tokenHash, userID, sqlTime(&expiresAt))
+	return err
+}
+
+// FindSession implements UserRepository interface.
+func (r *SQLiteUserRepository) FindSession(ctx context.Context, tokenHash string, now time.Time) (*model.User, error) {
+	const read = `SELECT ` + userColumns + ` FROM sessions JOIN users ON users.id = sessions.user_id
+		WHERE sessions.token_hash = ? AND sessions.expires_at > ?`
+	user, err := scanUser(r.db.QueryRowContext(ctx, read, tokenHash, sqlTime(&now)))
+	if err == sql.ErrNoRows {
+		return nil, &model.ErrNotFound{}
+	}
+	return user, err
+}
+
+// DeleteSession implements UserRepository interface.
+func (r *SQLiteUserRepository) DeleteSession(ctx context.Context, tokenHash string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE token_hash = ?`, tokenHash)
+	return err
+}
+
+// DeleteExpiredSessions implements UserRepository interface.
+func (r *SQLiteUserRepository) DeleteExpiredSessions(ctx context.Context, now time.Time) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at <= ?`, sqlTime(&now))
+	return err
+}
@@ -0,0 +1,183 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/repository"
+)
+
+func TestSQLiteUserRepository(t *testing.T) {
+	t.Parallel()
+
+	testUserRepository(t, func(t *testing.T) repository.UserRepository {
+		todoDB, err := db.NewDB(filepath.Join(t.TempDir(), "todo.db"))
+		if err != nil {
+			t.Fatal("failed to create database, err =", err)
+		}
+		t.Cleanup(func() {
+			if err := todoDB.Close(); err != nil {
+				t.Error("failed to close database, err =", err)
+			}
+		})
+		return repository.NewSQLiteUserRepository(todoDB)
+	})
+}
+
+func TestMemoryUserRepository(t *testing.T) {
+	t.Parallel()
+
+	testUserRepository(t, func(t *testing.T) repository.UserRepository {
+		return repository.NewMemoryUserRepository()
+	})
+}
+
+// testUserRepository checks that the repository made by newRepo satisfies
+// the contract of repository.UserRepository. Every case gets an empty repository.
+func testUserRepository(t *testing.T, newRepo func(t *testing.T) repository.UserRepository) {
+	ctx := context.Background()
+
+	t.Run("Users", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+
+		created, err := repo.CreateUser(ctx, &model.User{Username: "Alice", PasswordHash: "hash"})
+		if err != nil {
+			t.Fatal("failed to create user, err =", err)
+		}
+		if created.ID == 0 || created.CreatedAt.IsZero() || created.PasswordHash != "hash" {
+			t.Errorf("unexpected created user, given = %+v", created)
+		}
+		var errConflict *model.ErrConflict
+		if _, err := repo.CreateUser(ctx, &model.User{Username: "alice", PasswordHash: "hash"}); !errors.As(err, &errConflict) {
+			t.Errorf("unexpected error of a taken username, given = %v", err)
+		}
+
+		found, err := repo.FindUserByName(ctx, "ALICE")
+		if err != nil {
+			t.Fatal("failed to find user, err =", err)
+		}
+		if found.ID != created.ID || found.Username != "Alice" {
+			t.Errorf("unexpected found user, given = %+v", found)
+		}
+		var errNotFound *model.ErrNotFound
+		if _, err := repo.FindUserByName(ctx, "bob"); !errors.As(err, &errNotFound) {
+			t.Errorf("unexpected error of a missing user, given = %v", err)
+		}
+	})
+
+	t.Run("Sessions", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+
+		user, err := repo.CreateUser(ctx, &model.User{Username: "alice", PasswordHash: "hash"})
+		if err != nil {
+			t.Fatal("failed to create user, err =", err)
+		}
+		now := time.Now()
+		if err := repo.CreateSession(ctx, "valid", user.ID, now.Add(time.Hour)); err != nil {
+			t.Fatal("failed to create session, err =", err)
+		}
+		if err := repo.CreateSession(ctx, "expired", user.ID, now.Add(-time.Hour)); err != nil {
+			t.Fatal("failed to create session, err =", err)
+		}
+
+		found, err := repo.FindSession(ctx, "valid", now)
+		if err != nil {
+			t.Fatal("failed to find session, err =", err)
+		}
+		if found.ID != user.ID {
+			t.Errorf("unexpected user of session, given = %+v", found)
+		}
+		var errNotFound *model.ErrNotFound
+		for _, hash := range []string{"expired", "missing"} {
+			if _, err := repo.FindSession(ctx, hash, now); !errors.As(err, &errNotFound) {
+				t.Errorf("unexpected error of session %s, given = %v", hash, err)
+			}
+		}
+
+		if err := repo.DeleteExpiredSessions(ctx, now); err != nil {
+			t.Fatal("failed to delete expired sessions, err =", err)
+		}
+		if _, err := repo.FindSession(ctx, "valid", now); err != nil {
+			t.Error("valid session was deleted, err =", err)
+		}
+		if err := repo.DeleteSession(ctx, "valid"); err != nil {
+			t.Fatal("failed to delete session, err =", err)
+		}
+		if _, err := repo.FindSession(ctx, "valid", now); !errors.As(err, &errNotFound) {
+			t.Errorf("unexpected error of a deleted session, given = %v", err)
+		}
+	})
+	t.Run("APITokens", func(t *testing.T) {
+		t.Parallel()
+		repo := newRepo(t)
+
+		alice, err := repo.CreateUser(ctx, &model.User{Username: "alice", PasswordHash: "hash"})
+		if err != nil {
+			t.Fatal("failed to create user, err =", err)
+		}
+		bob, err := repo.CreateUser(ctx, &model.User{Username: "bob", PasswordHash: "hash"})
+		if err != nil {
+			t.Fatal("failed to create user, err =", err)
+		}
+		if found, err := repo.FindUserByID(ctx, bob.ID); err != nil || found.Username != "bob" {
+			t.Errorf("unexpected user by id, given = %+v, err = %v", found, err)
+		}
+
+		created, err := repo.CreateAPIToken(ctx, &model.APIToken{
+			UserID: alice.ID, Name: "ci", Prefix: "abcd1234", TokenHash: "hash",
+			Scopes: []string{model.ScopeTODOsRead, model.ScopeTODOsWrite},
+		})
+		if err != nil {
+			t.Fatal("failed to create api token, err =", err)
+		}
+		if created.ID == 0 || created.CreatedAt.IsZero() || created.LastUsedAt != nil || len(created.Scopes) != 2 {
+			t.Errorf("unexpected created api token, given = %+v", created)
+		}
+		var errConflict *model.ErrConflict
+		if _, err := repo.CreateAPIToken(ctx, &model.APIToken{
+			UserID: bob.ID, Name: "dup", Prefix: "abcd1234", TokenHash: "other", Scopes: []string{model.ScopeTODOsRead},
+		}); !errors.As(err, &errConflict) {
+			t.Errorf("unexpected error of a taken prefix, given = %v", err)
+		}
+
+		usedAt := time.Now()
+		if err := repo.TouchAPIToken(ctx, created.ID, usedAt); err != nil {
+			t.Fatal("failed to touch api token, err =", err)
+		}
+		found, err := repo.FindAPIToken(ctx, "abcd1234")
+		if err != nil {
+			t.Fatal("failed to find api token, err =", err)
+		}
+		if found.ID != created.ID || found.UserID != alice.ID || found.TokenHash != "hash" ||
+			found.LastUsedAt == nil || !found.LastUsedAt.Equal(usedAt.UTC().Truncate(time.Second)) {
+			t.Errorf("unexpected found api token, given = %+v", found)
+		}
+
+		tokens, err := repo.ListAPITokens(ctx, alice.ID)
+		if err != nil || len(tokens) != 1 || tokens[0].ID != created.ID {
+			t.Errorf("unexpected api tokens of alice, given = %v, err = %v", tokens, err)
+		}
+		tokens, err = repo.ListAPITokens(ctx, bob.ID)
+		if err != nil || len(tokens) != 0 {
+			t.Errorf("unexpected api tokens of bob, given = %v, err = %v", tokens, err)
+		}
+
+		var errNotFound *model.ErrNotFound
+		if err := repo.DeleteAPIToken(ctx, bob.ID, created.ID); !errors.As(err, &errNotFound) {
+			t.Errorf("unexpected error deleting a token of another user, given = %v", err)
+		}
+		if err := repo.DeleteAPIToken(ctx, alice.ID, created.ID); err != nil {
+			t.Fatal("failed to delete api token, err =", err)
+		}
+		if _, err := repo.FindAPIToken(ctx, "abcd1234"); !errors.As(err, &errNotFound) {
+			t.Errorf("unexpected error of a deleted api token, given = %v", err)
+		}
+	})
+}
@@ -0,0 +1,217 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// A MemoryUserRepository implements UserRepository in memory.
+// It is meant for tests and for running without a database.
+type MemoryUserRepository struct {
+	mu          sync.Mutex
+	users       map[int64]*model.User
+	sessions    map[string]*memorySession
+	apiTokens   map[int64]*model.APIToken
+	nextID      int64
+	nextTokenID int64
+}
+
+type memorySession struct {
+	userID    int64
+	expiresAt time.Time
+}
+
+// NewMemoryUserRepository returns new MemoryUserRepository.
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{
+		users:       map[int64]*model.User{},
+		sessions:    map[string]*memorySession{},
+		apiTokens:   map[int64]*model.APIToken{},
+		nextID:      1,
+		nextTokenID: 1,
+	}
+}
+
+// CreateUser implements UserRepository interface.
+func (r *MemoryUserRepository) CreateUser(ctx context.Context, user *model.User) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.findByName(user.Username) != nil {
+		return nil, &model.ErrConflict{Message: "username is already taken"}
+	}
+	stored := *user
+	stored.ID = r.nextID
+	stored.CreatedAt = now()
+	r.users[stored.ID] = &stored
+	r.nextID++
+	ret := stored
+	return &ret, nil
+}
+
+func (r *MemoryUserRepository) findByName(username string) *model.User {
+	for _, user := range r.users {
+		if strings.EqualFold(user.Username, username) {
+			return user
+		}
+	}
+	return nil
+}
+
+// FindUserByName implements UserRepository interface.
+func (r *MemoryUserRepository) FindUserByName(ctx context.Context, username string) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user := r.findByName(username)
+	if user == nil {
+		return nil, &model.ErrNotFound{}
+	}
+	ret := *user
+	return &ret, nil
+}
+
+// FindUserByID implements UserRepository interface.
+func (r *MemoryUserRepository) FindUserByID(ctx context.Context, id int64) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, &model.ErrNotFound{}
+	}
+	ret := *user
+	return &ret, nil
+}
+
+// CreateSession implements UserRepository interface.
+func (r *MemoryUserRepository) CreateSession(ctx context.Context, tokenHash string, userID int64, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.sessions[tokenHash] = &memorySession{userID: userID, expiresAt: *storedTime(&expiresAt)}
+	return nil
+}
+
+// FindSession implements UserRepository interface.
+func (r *MemoryUserRepository) FindSession(ctx context.Context, tokenHash string, now time.Time) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.sessions[tokenHash]
+	if !ok || !s.expiresAt.After(*storedTime(&now)) {
+		return nil, &model.ErrNotFound{}
+	}
+	user, ok := r.users[s.userID]
+	if !ok {
+		return nil, &model.ErrNotFound{}
+	}
+	ret := *user
+	return &ret, nil
+}
+
+// DeleteSession implements UserRepository interface.
+func (r *MemoryUserRepository) DeleteSession(ctx context.Context, tokenHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.sessions, tokenHash)
+	return nil
+}
+
+// DeleteExpiredSessions implements UserRepository interface.
+func (r *MemoryUserRepository) DeleteExpiredSessions(ctx context.Context, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for hash, s := range r.sessions {
+		if !s.expiresAt.After(*storedTime(&now)) {
+			delete(r.sessions, hash)
+		}
+	}
+	return nil
+}
+
+// cloneAPIToken returns a deep copy of token.
+func cloneAPIToken(token *model.APIToken) *model.APIToken {
+	ret := *token
+	ret.Scopes = append([]string{}, token.Scopes...)
+	ret.LastUsedAt = storedTime(token.LastUsedAt)
+	return &ret
+}
+
+// CreateAPIToken implements UserRepository interface.
+func (r *MemoryUserRepository) CreateAPIToken(ctx context.Context, token *model.APIToken) (*model.APIToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, t := range r.apiTokens {
+		if t.Prefix == token.Prefix {
+			return nil, &model.ErrConflict{Message: "prefix is already taken"}
+		}
+	}
+	stored := cloneAPIToken(token)
+	stored.ID = r.nextTokenID
+	stored.LastUsedAt = nil
+	stored.CreatedAt = now()
+	r.apiTokens[stored.ID] = stored
+	r.nextTokenID++
+	return cloneAPIToken(stored), nil
+}
+
+// ListAPITokens implements UserRepository interface.
+func (r *MemoryUserRepository) ListAPITokens(ctx context.Context, userID int64) ([]*model.APIToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tokens := []*model.APIToken{}
+	for _, token := range r.apiTokens {
+		if token.UserID == userID {
+			tokens = append(tokens, cloneAPIToken(token))
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID < tokens[j].ID })
+	return tokens, nil
+}
+
+// FindAPIToken implements UserRepository interface.
+func (r *MemoryUserRepository) FindAPIToken(ctx context.Context, prefix string) (*model.APIToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.apiTokens {
+		if token.Prefix == prefix {
+			return cloneAPIToken(token), nil
+		}
+	}
+	return nil, &model.ErrNotFound{}
+}
+
+// TouchAPIToken implements UserRepository interface.
+func (r *MemoryUserRepository) TouchAPIToken(ctx context.Context, id int64, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if token, ok := r.apiTokens[id]; ok {
+		token.LastUsedAt = storedTime(&usedAt)
+	}
+	return nil
+}
+
+// DeleteAPIToken implements UserRepository interface.
+func (r *MemoryUserRepository) DeleteAPIToken(ctx context.Context, userID, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.apiTokens[id]
+	if !ok || token.UserID != userID {
+		return &model.ErrNotFound{}
+	}
+	delete(r.apiTokens, id)
+	return nil
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/logger"
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// APITokenPrefix starts every API token, which tells them apart from
+// session tokens and makes them easy to find in leaked logs or code.
+const APITokenPrefix = "tdt_"
+
+// apiTokenAttempts is how many times CreateAPIToken generates a token when
+// its prefix, which is only 8 hex digits, is taken already.
+const apiTokenAttempts = 5
+
+// apiTokenLastUsedPrecision is how often LastUsedAt of an API token is
+// updated at most, so that every request does not write to the database.
+const apiTokenLastUsedPrecision = time.Minute
+
+// IsAPIToken reports whether token has the form of an API token.
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+// splitAPIToken returns the prefix stored in plain of an API token, which is
+// formatted as APITokenPrefix, 8 hex digits, "_" and the secret.
+func splitAPIToken(token string) (string, bool) {
+	rest := strings.TrimPrefix(token, APITokenPrefix)
+	if rest == token || len(rest) < 10 || rest[8] != '_' {
+		return "", false
+	}
+	if _, err := hex.DecodeString(rest[:8]); err != nil {
+		return "", false
+	}
+	return rest[:8], true
+}
+
+// CreateAPIToken creates an API token with scopes for the user of ctx and
+// returns it with the token, which is only known now. The token is generated
+// again when its prefix collides with that of another token.
+func (s *UserService) CreateAPIToken(ctx context.Context, name string, scopes []string) (*model.APIToken, string, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return &model.APIToken{}, "", &model.ErrUnauthorized{Message: "login is required"}
+	}
+
+	var errConflict *model.ErrConflict
+	for attempt := 1; ; attempt++ {
+		apiToken, token, err := newAPIToken(user.ID, name, scopes)
+		if err != nil {
+			logger.Println(ctx, err)
+			return &model.APIToken{}, "", err
+		}
+		created, err := s.repo.CreateAPIToken(ctx, apiToken)
+		if errors.As(err, &errConflict) && attempt < apiTokenAttempts {
+			continue
+		}
+		if err != nil {
+			logger.Println(ctx, err)
+			return &model.APIToken{}, "", err
+		}
+		return created, token, nil
+	}
+}
+
+// newAPIToken generates an API token of the user with userID and returns it
+// with the token.
+func newAPIToken(userID int64, name string, scopes []string) (*model.APIToken, string, error) {
+	prefix := make([]byte, 4)
+	secret := make([]byte, 32)
+	for _, b := range [][]byte{prefix, secret} {
+		if _, err := rand.Read(b); err != nil {
+			return nil, "", err
+		}
+	}
+	apiToken := &model.APIToken{
+		UserID: userID,
+		Name:   name,
+		Prefix: hex.EncodeToString(prefix),
+		Scopes: uniqueStrings(scopes),
+	}
+	token := APITokenPrefix + apiToken.Prefix + "_" + base64.RawURLEncoding.EncodeToString(secret)
+	apiToken.TokenHash = hashToken(token)
+	return apiToken, token, nil
+}
+
+// ReadAPITokens returns the API tokens of the user of ctx.
+func (s *UserService) ReadAPITokens(ctx context.Context) ([]*model.APIToken, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, &model.ErrUnauthorized{Message: "login is required"}
+	}
+	tokens, err := s.repo.ListAPITokens(ctx, user.ID)
+	if err != nil {
+		logger.Println(ctx, err)
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// RevokeAPIToken deletes the API token with id of the user of ctx.
+func (s *UserService) RevokeAPIToken(ctx context.Context, id int64) error {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return &model.ErrUnauthorized{Message: "login is required"}
+	}
+	if err := s.repo.DeleteAPIToken(ctx, user.ID, id); err != nil {
+		logger.Println(ctx, err)
+		return err
+	}
+	return nil
+}
+
+// Principal returns the principal authenticated by token, which is either
+// an API token or a session token. Principals of sessions have every scope.
+// It fails with *model.ErrUnauthorized when token is unknown or expired.
+func (s *UserService) Principal(ctx context.Context, token string) (*model.Principal, error) {
+	if !IsAPIToken(token) {
+		user, err := s.Authenticate(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		return &model.Principal{User: user, Scopes: append([]string{}, model.Scopes...)}, nil
+	}
+
+	errInvalid := &model.ErrUnauthorized{Message: "invalid or revoked token"}
+	prefix, ok := splitAPIToken(token)
+	if !ok {
+		return nil, errInvalid
+	}
+	apiToken, err := s.repo.FindAPIToken(ctx, prefix)
+	var errNotFound *model.ErrNotFound
+	if errors.As(err, &errNotFound) {
+		return nil, errInvalid
+	}
+	if err != nil {
+		logger.Println(ctx, err)
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(apiToken.TokenHash)) != 1 {
+		return nil, errInvalid
+	}
+	user, err := s.repo.FindUserByID(ctx, apiToken.UserID)
+	if err != nil {
+		logger.Println(ctx, err)
+		return nil, err
+	}
+
+	now := time.Now()
+	if apiToken.LastUsedAt == nil || now.Sub(*apiToken.LastUsedAt) >= apiTokenLastUsedPrecision {
+		if err := s.repo.TouchAPIToken(ctx, apiToken.ID, now); err != nil {
+			logger.Println(ctx, err)
+			return nil, err
+		}
+	}
+	return &model.Principal{User: user, Scopes: apiToken.Scopes, APITokenID: apiToken.ID}, nil
+}
+
+// uniqueStrings returns ss without duplicates in order of appearance.
+func uniqueStrings(ss []string) []string {
+	ret := make([]string, 0, len(ss))
+	seen := map[string]bool{}
+	for _, s := range ss {
+		if !seen[s] {
+			seen[s] = true
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
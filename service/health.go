@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/logger"
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// DefaultHealthCheckTimeout is how long a check of readiness may take before
+// it fails.
+const DefaultHealthCheckTimeout = 2 * time.Second
+
+// A HealthCheck checks that a dependency of the server works, e.g. that the
+// database answers.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// A HealthService tells whether the server is ready to serve requests, by
+// running its checks, and stops being ready once the server starts draining
+// before shutdown. It is safe for concurrent use.
+type HealthService struct {
+	timeout  time.Duration
+	checks   []HealthCheck
+	draining int32
+}
+
+// NewHealthService returns new HealthService checking that todoDB answers a
+// ping and is migrated to the schema this binary knows.
+func NewHealthService(todoDB *sql.DB) *HealthService {
+	return NewHealthServiceWithChecks(DefaultHealthCheckTimeout,
+		HealthCheck{Name: "database", Check: todoDB.PingContext},
+		HealthCheck{Name: "schema", Check: func(ctx context.Context) error {
+			m, err := db.NewMigrator(todoDB, db.Migrations())
+			if err != nil {
+				return err
+			}
+			return m.CheckVersion(ctx)
+		}},
+	)
+}
+
+// NewHealthServiceWithChecks returns new HealthService running checks,
+// each of which fails after timeout.
+func NewHealthServiceWithChecks(timeout time.Duration, checks ...HealthCheck) *HealthService {
+	return &HealthService{
+		timeout: timeout,
+		checks:  checks,
+	}
+}
+
+// Drain makes s report that the server is not ready from now on, so that
+// load balancers stop sending it requests before it shuts down.
+func (s *HealthService) Drain() {
+	atomic.StoreInt32(&s.draining, 1)
+}
+
+// Draining reports whether Drain has been called.
+func (s *HealthService) Draining() bool {
+	return atomic.LoadInt32(&s.draining) == 1
+}
+
+// Ready runs every check concurrently and reports whether all of them pass
+// and the server is not draining, with the result of each of them. Draining
+// is reported as the failing check "shutdown".
+func (s *HealthService) Ready(ctx context.Context) *model.ProbeResponse {
+	res := &model.ProbeResponse{Status: model.ProbeStatusOK, Checks: make(map[string]*model.CheckResult, len(s.checks)+1)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range s.checks {
+		c := c
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := s.run(ctx, c)
+			mu.Lock()
+			defer mu.Unlock()
+			res.Checks[c.Name] = result
+		}()
+	}
+	wg.Wait()
+
+	if s.Draining() {
+		res.Checks["shutdown"] = &model.CheckResult{Status: model.ProbeStatusFailing, Error: "shutting down"}
+	}
+	for _, result := range res.Checks {
+		if result.Status != model.ProbeStatusOK {
+			res.Status = model.ProbeStatusFailing
+		}
+	}
+	return res
+}
+
+// run runs c within the timeout of s, failing when c outlasts it even if c
+// does not give up.
+func (s *HealthService) run(ctx context.Context, c HealthCheck) *model.CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	result := &model.CheckResult{
+		Status:   model.ProbeStatusOK,
+		Duration: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", s.timeout)
+		}
+		logger.Println(ctx, "health check", c.Name, "failed, err =", err)
+		result.Status = model.ProbeStatusFailing
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,90 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/db"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestHealthServiceReady(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	block := make(chan struct{})
+	t.Cleanup(func() { close(block) })
+	svc := service.NewHealthServiceWithChecks(50*time.Millisecond,
+		service.HealthCheck{Name: "ok", Check: func(ctx context.Context) error { return nil }},
+		service.HealthCheck{Name: "failing", Check: func(ctx context.Context) error { return errors.New("down") }},
+		// コンテキストを無視するチェックもタイムアウトで失敗させる
+		service.HealthCheck{Name: "hanging", Check: func(ctx context.Context) error {
+			<-block
+			return nil
+		}},
+	)
+
+	res := svc.Ready(ctx)
+	if res.Status != model.ProbeStatusFailing {
+		t.Errorf("unexpected status, given = %s", res.Status)
+	}
+	want := map[string]string{
+		"ok":      model.ProbeStatusOK,
+		"failing": model.ProbeStatusFailing,
+		"hanging": model.ProbeStatusFailing,
+	}
+	if len(res.Checks) != len(want) {
+		t.Errorf("unexpected checks, given = %+v", res.Checks)
+	}
+	for name, status := range want {
+		if c := res.Checks[name]; c == nil || c.Status != status {
+			t.Errorf("unexpected result of %s, want = %s, given = %+v", name, status, c)
+		}
+	}
+	if c := res.Checks["hanging"]; c != nil && c.Error != "timed out after 50ms" {
+		t.Errorf("unexpected error of hanging check, given = %s", c.Error)
+	}
+}
+
+func TestHealthServiceDrain(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	todoDB, err := db.NewDB(filepath.Join(t.TempDir(), "health_test.db"))
+	if err != nil {
+		t.Fatal("failed to open database, err =", err)
+	}
+	t.Cleanup(func() { todoDB.Close() })
+	svc := service.NewHealthService(todoDB)
+
+	res := svc.Ready(ctx)
+	if res.Status != model.ProbeStatusOK {
+		t.Fatalf("unexpected status before draining, given = %+v", res.Checks)
+	}
+	for _, name := range []string{"database", "schema"} {
+		if c := res.Checks[name]; c == nil || c.Status != model.ProbeStatusOK {
+			t.Errorf("unexpected result of %s, given = %+v", name, c)
+		}
+	}
+
+	svc.Drain()
+	res = svc.Ready(ctx)
+	if res.Status != model.ProbeStatusFailing || res.Checks["shutdown"] == nil {
+		t.Errorf("still ready after draining, given = %+v", res)
+	}
+	if c := res.Checks["database"]; c == nil || c.Status != model.ProbeStatusOK {
+		t.Errorf("unexpected result of database while draining, given = %+v", c)
+	}
+
+	// 知らないバージョンまで移行されたデータベースでは準備できていない
+	if _, err := todoDB.Exec(`INSERT INTO schema_migrations (version, name, checksum) VALUES (99999, 'future', '')`); err != nil {
+		t.Fatal("failed to record a future migration, err =", err)
+	}
+	if c := service.NewHealthService(todoDB).Ready(ctx).Checks["schema"]; c == nil || c.Status != model.ProbeStatusFailing {
+		t.Errorf("unexpected result of schema migrated past this binary, given = %+v", c)
+	}
+}
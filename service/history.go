@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/TechBowl-japan/go-stations/logger"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/repository"
+)
+
+// actorFromContext returns who makes the changes in ctx, which is empty when
+// no user does.
+func actorFromContext(ctx context.Context) string {
+	if user, ok := UserFromContext(ctx); ok {
+		return user.Username
+	}
+	return ""
+}
+
+// record appends event to the history of its TODO, made by the actor in ctx.
+// It is called with the repository of the transaction making the change.
+func record(ctx context.Context, repo repository.TODORepository, event *model.TODOEvent) error {
+	event.Actor = actorFromContext(ctx)
+	_, err := repo.AddEvent(ctx, event)
+	return err
+}
+
+// ReadTODOHistory reads the history of the TODO with id on DB, oldest first.
+// The history of TODOs in the trash can be read as well.
+func (s *TODOService) ReadTODOHistory(ctx context.Context, id int64) (_ []*model.TODOEvent, err error) {
+	ctx, done := s.observe(ctx, "ReadTODOHistory")
+	defer func() { done(err) }()
+	scope, err := s.scoped(ctx)
+	var events []*model.TODOEvent
+	if err == nil {
+		events, err = scope.repo.Events(ctx, id)
+	}
+	if err != nil {
+		logger.Println(ctx, err)
+		return nil, err
+	}
+	return events, nil
+}
+
+// RevertTODO puts the TODO with id on DB back to how it was as of revision.
+//
+// Every field but the id and the timestamps is restored as it was, including
+// the status even where the workflow would not allow moving to it. The revert
+// is recorded as a new revision, so that it can be reverted in turn.
+func (s *TODOService) RevertTODO(ctx context.Context, id, revision int64, opts ...TODOOption) (_ *model.TODO, err error) {
+	ctx, done := s.observe(ctx, "RevertTODO")
+	defer func() { done(err) }()
+	scope, err := s.scoped(ctx)
+	var event *model.TODOEvent
+	if err == nil {
+		event, err = scope.repo.Event(ctx, id, revision)
+	}
+	if err != nil {
+		logger.Println(ctx, err)
+		return &model.TODO{}, err
+	}
+	if event.After == nil {
+		err := &model.ErrConflict{Message: fmt.Sprintf("revision %d deleted the todo", revision)}
+		logger.Println(ctx, err)
+		return &model.TODO{}, err
+	}
+	return s.changeTODO(ctx, id, append(opts, withRevision(event)), nil)
+}
+
+// withRevision sets every field of the TODO as of event.
+func withRevision(event *model.TODOEvent) TODOOption {
+	return func(c *todoChange) {
+		c.todo.Subject = event.After.Subject
+		c.todo.Description = event.After.Description
+		c.todo.Status = event.After.Status
+		c.todo.CompletedAt = event.After.CompletedAt
+		c.todo.DueAt = event.After.DueAt
+		c.todo.RemindAt = event.After.RemindAt
+		c.todo.Tags = event.After.Tags
+		c.revert = event
+	}
+}
@@ -0,0 +1,94 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/repository"
+	"github.com/TechBowl-japan/go-stations/service"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestTODOHistory(t *testing.T) {
+	t.Parallel()
+
+	ctx := service.ContextWithUser(context.Background(), &model.User{ID: 1, Username: "alice"})
+	svc := service.NewTODOServiceWithRepository(repository.NewMemoryTODORepository())
+	todo, err := svc.CreateTODO(ctx, "subject", "", service.WithTags("a"))
+	if err != nil {
+		t.Fatal("failed to create todo, err =", err)
+	}
+	if _, err := svc.UpdateTODOStatus(ctx, todo.ID, model.TODOStatusInProgress); err != nil {
+		t.Fatal("failed to update status, err =", err)
+	}
+	if _, err := svc.UpdateTODOStatus(ctx, todo.ID, model.TODOStatusDone); err != nil {
+		t.Fatal("failed to update status, err =", err)
+	}
+	// 失敗した変更と変化のない変更は履歴に残らない
+	var errPrecondition *model.ErrPreconditionFailed
+	if _, err := svc.UpdateTODO(ctx, todo.ID, "stale", "", service.WithPrecondition(service.IfMatch(todo.ETag()))); !errors.As(err, &errPrecondition) {
+		t.Fatalf("unexpected error of a stale update, given = %v", err)
+	}
+	if _, err := svc.UpdateTODOStatus(ctx, todo.ID, model.TODOStatusDone); err != nil {
+		t.Fatal("failed to update status, err =", err)
+	}
+
+	// done から in_progress へはワークフロー上移れないが、revert では戻せる
+	reverted, err := svc.RevertTODO(ctx, todo.ID, 2)
+	if err != nil {
+		t.Fatal("failed to revert todo, err =", err)
+	}
+	if reverted.Status != model.TODOStatusInProgress || reverted.CompletedAt != nil || !cmp.Equal([]string{"a"}, reverted.Tags) {
+		t.Errorf("unexpected reverted todo, given = %+v", reverted)
+	}
+
+	if err := svc.DeleteTODO(ctx, []int64{todo.ID, todo.ID}); err != nil {
+		t.Fatal("failed to delete todo, err =", err)
+	}
+	var errNotFound *model.ErrNotFound
+	if _, err := svc.RevertTODO(ctx, todo.ID, 1); !errors.As(err, &errNotFound) {
+		t.Errorf("unexpected error reverting a trashed todo, given = %v", err)
+	}
+	if _, err := svc.RestoreTODO(ctx, todo.ID); err != nil {
+		t.Fatal("failed to restore todo, err =", err)
+	}
+	var errConflict *model.ErrConflict
+	if _, err := svc.RevertTODO(ctx, todo.ID, 5); !errors.As(err, &errConflict) {
+		t.Errorf("unexpected error reverting to a delete, given = %v", err)
+	}
+	if _, err := svc.RevertTODO(ctx, todo.ID, 7); !errors.As(err, &errNotFound) {
+		t.Errorf("unexpected error reverting to a missing revision, given = %v", err)
+	}
+
+	events, err := svc.ReadTODOHistory(ctx, todo.ID)
+	if err != nil {
+		t.Fatal("failed to read history, err =", err)
+	}
+	type summary struct {
+		Action     model.TODOEventAction
+		Actor      string
+		RevertedTo int64
+		Before     bool
+		After      bool
+	}
+	var got []summary
+	for _, e := range events {
+		got = append(got, summary{e.Action, e.Actor, e.RevertedTo, e.Before != nil, e.After != nil})
+	}
+	want := []summary{
+		{model.TODOEventCreate, "alice", 0, false, true},
+		{model.TODOEventUpdate, "alice", 0, true, true},
+		{model.TODOEventUpdate, "alice", 0, true, true},
+		{model.TODOEventRevert, "alice", 2, true, true},
+		{model.TODOEventDelete, "alice", 0, true, false},
+		{model.TODOEventRestore, "alice", 0, false, true},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error("unexpected history, diff =", diff)
+	}
+	if events[2].After.Status != model.TODOStatusDone || events[2].Before.Status != model.TODOStatusInProgress {
+		t.Errorf("unexpected snapshots, given = %+v, %+v", events[2].Before, events[2].After)
+	}
+}
@@ -0,0 +1,380 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// This file implements JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902)
+// on generic JSON values, i.e. what encoding/json decodes into interface{}
+// with numbers kept as json.Number.
+//
+// Patches that cannot be parsed are reported as model.ErrBadRequest, and
+// patches that cannot be applied to the document as model.ErrConflict.
+
+// decodeValue decodes b into a generic JSON value.
+func decodeValue(b []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after the JSON value")
+	}
+	return v, nil
+}
+
+// mergePatch applies the merge patch to target as RFC 7396 defines.
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+// A jsonPatchOp is an operation of JSON Patch.
+type jsonPatchOp struct {
+	Op    string
+	Path  []string
+	From  []string
+	Value interface{}
+}
+
+// parseJSONPatch parses a JSON Patch document.
+func parseJSONPatch(b []byte) ([]*jsonPatchOp, error) {
+	var raws []map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raws); err != nil {
+		return nil, &model.ErrBadRequest{Message: "JSON Patch must be an array of operation objects"}
+	}
+
+	ops := make([]*jsonPatchOp, 0, len(raws))
+	for i, raw := range raws {
+		op, err := parseJSONPatchOp(raw)
+		if err != nil {
+			return nil, &model.ErrBadRequest{Message: fmt.Sprintf("operation %d: %s", i, err)}
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+func parseJSONPatchOp(raw map[string]json.RawMessage) (*jsonPatchOp, error) {
+	op := &jsonPatchOp{}
+	var err error
+	if err := json.Unmarshal(raw["op"], &op.Op); err != nil {
+		return nil, fmt.Errorf("op must be a string")
+	}
+	if op.Path, err = parseMember(raw, "path"); err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		v, ok := raw["value"]
+		if !ok {
+			return nil, fmt.Errorf("%s needs value", op.Op)
+		}
+		if op.Value, err = decodeValue(v); err != nil {
+			return nil, err
+		}
+	case "move", "copy":
+		if op.From, err = parseMember(raw, "from"); err != nil {
+			return nil, err
+		}
+	case "remove":
+	default:
+		return nil, fmt.Errorf("unknown op %q", op.Op)
+	}
+	return op, nil
+}
+
+// parseMember parses the JSON Pointer in the member name of raw.
+func parseMember(raw map[string]json.RawMessage, name string) ([]string, error) {
+	var s string
+	if err := json.Unmarshal(raw[name], &s); err != nil {
+		return nil, fmt.Errorf("%s must be a string", name)
+	}
+	return parsePointer(s)
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its reference tokens.
+func parsePointer(s string) ([]string, error) {
+	if s == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(s, "/") {
+		return nil, fmt.Errorf("JSON Pointer %q must start with /", s)
+	}
+	tokens := strings.Split(s[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+// pointerString formats reference tokens back into a JSON Pointer.
+func pointerString(tokens []string) string {
+	var b strings.Builder
+	for _, t := range tokens {
+		b.WriteString("/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(t))
+	}
+	return b.String()
+}
+
+// applyJSONPatch applies ops to doc in order. Either every operation is
+// applied or the first failure is returned.
+func applyJSONPatch(doc interface{}, ops []*jsonPatchOp) (interface{}, error) {
+	var err error
+	for i, op := range ops {
+		doc, err = op.apply(doc)
+		if err != nil {
+			return nil, &model.ErrConflict{Message: fmt.Sprintf("operation %d: %s", i, err)}
+		}
+	}
+	return doc, nil
+}
+
+func (op *jsonPatchOp) apply(doc interface{}) (interface{}, error) {
+	switch op.Op {
+	case "add":
+		return addValue(doc, op.Path, op.Value)
+	case "remove":
+		doc, _, err := removeValue(doc, op.Path)
+		return doc, err
+	case "replace":
+		doc, _, err := removeValue(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, op.Path, op.Value)
+	case "move":
+		if len(op.From) < len(op.Path) && reflect.DeepEqual(op.From, op.Path[:len(op.From)]) {
+			return nil, fmt.Errorf("cannot move %s into itself", pointerString(op.From))
+		}
+		doc, v, err := removeValue(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, op.Path, v)
+	case "copy":
+		v, err := getValue(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, op.Path, deepCopy(v))
+	case "test":
+		v, err := getValue(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(v, op.Value) {
+			return nil, fmt.Errorf("test of %s failed", pointerString(op.Path))
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown op %q", op.Op)
+}
+
+// getValue returns the value path refers to in doc.
+func getValue(doc interface{}, path []string) (interface{}, error) {
+	for i, t := range path {
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			child, ok := v[t]
+			if !ok {
+				return nil, fmt.Errorf("%s does not exist", pointerString(path[:i+1]))
+			}
+			doc = child
+		case []interface{}:
+			idx, err := arrayIndex(t, len(v)-1)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", pointerString(path[:i+1]), err)
+			}
+			doc = v[idx]
+		default:
+			return nil, fmt.Errorf("%s does not exist", pointerString(path[:i+1]))
+		}
+	}
+	return doc, nil
+}
+
+// addValue adds value at path of doc and returns the new document.
+// Arrays are extended by inserting, and members of objects are replaced.
+func addValue(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := getValue(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[last] = value
+		return doc, nil
+	case []interface{}:
+		idx := len(p)
+		if last != "-" {
+			if idx, err = arrayIndex(last, len(p)); err != nil {
+				return nil, fmt.Errorf("%s: %s", pointerString(path), err)
+			}
+		}
+		p = append(p, nil)
+		copy(p[idx+1:], p[idx:])
+		p[idx] = value
+		return setValue(doc, path[:len(path)-1], p)
+	default:
+		return nil, fmt.Errorf("%s is not a container", pointerString(path[:len(path)-1]))
+	}
+}
+
+// removeValue removes the value at path of doc, returning the new document and the removed value.
+func removeValue(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+	parent, err := getValue(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		v, ok := p[last]
+		if !ok {
+			return nil, nil, fmt.Errorf("%s does not exist", pointerString(path))
+		}
+		delete(p, last)
+		return doc, v, nil
+	case []interface{}:
+		idx, err := arrayIndex(last, len(p)-1)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %s", pointerString(path), err)
+		}
+		v := p[idx]
+		p = append(p[:idx:idx], p[idx+1:]...)
+		doc, err = setValue(doc, path[:len(path)-1], p)
+		return doc, v, err
+	default:
+		return nil, nil, fmt.Errorf("%s does not exist", pointerString(path))
+	}
+}
+
+// setValue replaces the existing value at path of doc, which is needed
+// after arrays change their length.
+func setValue(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := getValue(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[last] = value
+	case []interface{}:
+		idx, err := arrayIndex(last, len(p)-1)
+		if err != nil {
+			return nil, err
+		}
+		p[idx] = value
+	}
+	return doc, nil
+}
+
+// arrayIndex parses the array index t, which must not exceed max.
+func arrayIndex(t string, max int) (int, error) {
+	if t == "" || (len(t) > 1 && t[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", t)
+	}
+	idx, err := strconv.Atoi(t)
+	if err != nil || idx < 0 {
+		return 0, fmt.Errorf("invalid array index %q", t)
+	}
+	if idx > max {
+		return 0, fmt.Errorf("array index %d is out of range", idx)
+	}
+	return idx, nil
+}
+
+// deepCopy copies a generic JSON value so that it shares nothing with v.
+func deepCopy(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			c[k] = deepCopy(e)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, e := range v {
+			c[i] = deepCopy(e)
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// jsonEqual reports whether generic JSON values are equal, comparing numbers by value.
+func jsonEqual(a, b interface{}) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, errA := a.Float64()
+		y, errB := b.Float64()
+		return errA == nil && errB == nil && x == y
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			w, ok := b[k]
+			if !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
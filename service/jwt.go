@@ -0,0 +1,245 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// Algorithms of signing keys, as in the alg header of JWTs.
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// jwtLeeway is the clock skew allowed when checking exp and nbf.
+const jwtLeeway = 30 * time.Second
+
+// A SigningKey signs and verifies JWTs with one algorithm.
+type SigningKey struct {
+	id        string
+	algorithm string
+	secret    []byte
+	private   ed25519.PrivateKey
+	public    ed25519.PublicKey
+}
+
+// NewHS256Key returns a key signing with HMAC-SHA256 by secret, which should
+// be at least 32 random bytes. HS256 keys are not published in the JWKS.
+func NewHS256Key(id string, secret []byte) (*SigningKey, error) {
+	if len(secret) < 32 {
+		return nil, fmt.Errorf("secret of key %q must be at least 32 bytes", id)
+	}
+	return &SigningKey{id: id, algorithm: AlgorithmHS256, secret: secret}, nil
+}
+
+// NewEdDSAKey returns a key signing with Ed25519 by the private key of seed.
+func NewEdDSAKey(id string, seed []byte) (*SigningKey, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("seed of key %q must be %d bytes", id, ed25519.SeedSize)
+	}
+	private := ed25519.NewKeyFromSeed(seed)
+	return &SigningKey{
+		id:        id,
+		algorithm: AlgorithmEdDSA,
+		private:   private,
+		public:    private.Public().(ed25519.PublicKey),
+	}, nil
+}
+
+// ID returns the key ID, the kid header of the JWTs the key signs.
+func (k *SigningKey) ID() string {
+	return k.id
+}
+
+func (k *SigningKey) sign(input []byte) []byte {
+	if k.algorithm == AlgorithmHS256 {
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(input)
+		return mac.Sum(nil)
+	}
+	return ed25519.Sign(k.private, input)
+}
+
+func (k *SigningKey) verify(input, sig []byte) bool {
+	if k.algorithm == AlgorithmHS256 {
+		return hmac.Equal(k.sign(input), sig)
+	}
+	return ed25519.Verify(k.public, input, sig)
+}
+
+// A KeySet holds the active signing keys. The first key signs new JWTs and
+// every key verifies them, so that keys are rotated by putting a new key
+// first and dropping the old one once the JWTs it signed have expired.
+type KeySet struct {
+	keys []*SigningKey
+}
+
+// NewKeySet returns new KeySet signing with the first of keys.
+func NewKeySet(keys ...*SigningKey) (*KeySet, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("key set must have a key")
+	}
+	seen := map[string]bool{}
+	for _, k := range keys {
+		if seen[k.id] {
+			return nil, fmt.Errorf("key ID %q is duplicated", k.id)
+		}
+		seen[k.id] = true
+	}
+	return &KeySet{keys: keys}, nil
+}
+
+// GenerateKeySet returns a KeySet of an Ed25519 key made of a random seed.
+func GenerateKeySet() (*KeySet, error) {
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	key, err := NewEdDSAKey("generated", seed)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet(key)
+}
+
+// ParseKeySet returns the KeySet of s, a comma separated list of keys
+// formatted as ID:ALGORITHM:BASE64, e.g. "2024-02:EdDSA:...,2024-01:HS256:...".
+// BASE64 is the standard base64 of the Ed25519 seed or the HMAC secret.
+func ParseKeySet(s string) (*KeySet, error) {
+	var keys []*SigningKey
+	for _, entry := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+		if len(parts) != 3 || parts[0] == "" {
+			return nil, fmt.Errorf("key %q must be formatted as ID:ALGORITHM:BASE64", entry)
+		}
+		material, err := base64.StdEncoding.DecodeString(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("key %q is not base64: %w", parts[0], err)
+		}
+		var key *SigningKey
+		switch parts[1] {
+		case AlgorithmHS256:
+			key, err = NewHS256Key(parts[0], material)
+		case AlgorithmEdDSA:
+			key, err = NewEdDSAKey(parts[0], material)
+		default:
+			err = fmt.Errorf("algorithm of key %q must be %s or %s", parts[0], AlgorithmHS256, AlgorithmEdDSA)
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return NewKeySet(keys...)
+}
+
+// JWKS returns the public keys of ks. HS256 keys are secret and left out.
+func (ks *KeySet) JWKS() *model.JWKS {
+	jwks := &model.JWKS{Keys: []*model.JWK{}}
+	for _, k := range ks.keys {
+		if k.algorithm != AlgorithmEdDSA {
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, &model.JWK{
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			X:         base64.RawURLEncoding.EncodeToString(k.public),
+			KeyID:     k.id,
+			Algorithm: AlgorithmEdDSA,
+			Use:       "sig",
+		})
+	}
+	return jwks
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+	KeyID     string `json:"kid"`
+}
+
+// Sign returns claims as a JWT signed by the first key of ks.
+func (ks *KeySet) Sign(claims *model.Claims) (string, error) {
+	key := ks.keys[0]
+	header, err := json.Marshal(&jwtHeader{Algorithm: key.algorithm, Type: "JWT", KeyID: key.id})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return input + "." + base64.RawURLEncoding.EncodeToString(key.sign([]byte(input))), nil
+}
+
+// Verify returns the claims of token if a key of ks signed it, it is valid
+// at now and audience is one of its audiences.
+// It fails with *model.ErrUnauthorized otherwise.
+func (ks *KeySet) Verify(token, audience string, now time.Time) (*model.Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, &model.ErrUnauthorized{Message: "malformed token"}
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, &model.ErrUnauthorized{Message: "malformed token"}
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, &model.ErrUnauthorized{Message: "malformed token"}
+	}
+	// alg must match the key, or tokens signed with "none" or with a
+	// public key as HMAC secret would be accepted
+	key := ks.find(header.KeyID)
+	if key == nil || key.algorithm != header.Algorithm || !key.verify([]byte(parts[0]+"."+parts[1]), sig) {
+		return nil, &model.ErrUnauthorized{Message: "invalid token signature"}
+	}
+
+	claims := &model.Claims{}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, &model.ErrUnauthorized{Message: "malformed token"}
+	}
+	switch {
+	case claims.ExpiresAt == 0 || now.Add(-jwtLeeway).Unix() >= claims.ExpiresAt:
+		return nil, &model.ErrUnauthorized{Message: "token is expired"}
+	case claims.NotBefore != 0 && now.Add(jwtLeeway).Unix() < claims.NotBefore:
+		return nil, &model.ErrUnauthorized{Message: "token is not valid yet"}
+	case !claims.Audience.Contains(audience):
+		return nil, &model.ErrUnauthorized{Message: "token is not meant for " + audience}
+	}
+	return claims, nil
+}
+
+func (ks *KeySet) find(id string) *SigningKey {
+	for _, k := range ks.keys {
+		if k.id == id {
+			return k
+		}
+	}
+	return nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// IsJWT reports whether token has the form of a JWT, unlike session tokens
+// and API tokens which have no dots.
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
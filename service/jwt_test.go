@@ -0,0 +1,164 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/repository"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func mustKeySet(t *testing.T, keys ...*service.SigningKey) *service.KeySet {
+	t.Helper()
+	ks, err := service.NewKeySet(keys...)
+	if err != nil {
+		t.Fatal("failed to create key set, err =", err)
+	}
+	return ks
+}
+
+func TestKeySet(t *testing.T) {
+	t.Parallel()
+
+	hs, err := service.NewHS256Key("hs", bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal("failed to create key, err =", err)
+	}
+	ed, err := service.NewEdDSAKey("ed", bytes.Repeat([]byte{2}, 32))
+	if err != nil {
+		t.Fatal("failed to create key, err =", err)
+	}
+	now := time.Now()
+	claims := &model.Claims{
+		Subject:   "1",
+		Audience:  model.Audience{"api"},
+		ExpiresAt: now.Add(time.Hour).Unix(),
+		NotBefore: now.Unix(),
+	}
+
+	// 古い鍵で署名したトークンも、新しい鍵を先頭にした鍵セットで検証できる
+	old := mustKeySet(t, hs)
+	rotated := mustKeySet(t, ed, hs)
+	for name, ks := range map[string]*service.KeySet{"HS256": old, "EdDSA": rotated} {
+		token, err := ks.Sign(claims)
+		if err != nil {
+			t.Fatalf("%s: failed to sign, err = %v", name, err)
+		}
+		got, err := rotated.Verify(token, "api", now)
+		if err != nil || got.Subject != "1" {
+			t.Errorf("%s: unexpected claims, given = %+v, err = %v", name, got, err)
+		}
+	}
+	if token, _ := rotated.Sign(claims); !strings.HasPrefix(token, base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"EdDSA"`))) {
+		t.Errorf("rotated key set does not sign with the first key, given = %s", token)
+	}
+
+	jwks := rotated.JWKS()
+	if len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != "ed" || jwks.Keys[0].Curve != "Ed25519" {
+		t.Errorf("unexpected jwks, given = %+v", jwks.Keys)
+	}
+
+	token, err := old.Sign(claims)
+	if err != nil {
+		t.Fatal("failed to sign, err =", err)
+	}
+	parts := strings.Split(token, ".")
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"hs"}`)) + "." + parts[1] + "."
+	cases := map[string]struct {
+		ks       *service.KeySet
+		token    string
+		audience string
+		at       time.Time
+	}{
+		"Expired":         {ks: old, token: token, audience: "api", at: now.Add(2 * time.Hour)},
+		"Not yet valid":   {ks: old, token: token, audience: "api", at: now.Add(-time.Hour)},
+		"Other audience":  {ks: old, token: token, audience: "other", at: now},
+		"Unknown key":     {ks: mustKeySet(t, ed), token: token, audience: "api", at: now},
+		"Algorithm none":  {ks: old, token: none, audience: "api", at: now},
+		"Tampered claims": {ks: old, token: parts[0] + "." + parts[1] + "x." + parts[2], audience: "api", at: now},
+		"Malformed":       {ks: old, token: "a.b", audience: "api", at: now},
+	}
+	for name, c := range cases {
+		var errUnauthorized *model.ErrUnauthorized
+		if _, err := c.ks.Verify(c.token, c.audience, c.at); !errors.As(err, &errUnauthorized) {
+			t.Errorf("%s: unexpected error, given = %v", name, err)
+		}
+	}
+}
+
+func TestParseKeySet(t *testing.T) {
+	t.Parallel()
+
+	secret := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	if _, err := service.ParseKeySet("new:EdDSA:" + secret + ", old:HS256:" + secret); err != nil {
+		t.Error("failed to parse key set, err =", err)
+	}
+	for _, s := range []string{
+		"",
+		"new:EdDSA",
+		"new:RS256:" + secret,
+		"new:HS256:c2hvcnQ=",
+		"new:EdDSA:" + secret + ",new:HS256:" + secret,
+	} {
+		if _, err := service.ParseKeySet(s); err == nil {
+			t.Errorf("parsed invalid key set %q", s)
+		}
+	}
+}
+
+func TestTokenService(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	users := service.NewUserServiceWithRepository(repository.NewMemoryUserRepository())
+	user, err := users.Register(ctx, "alice", "correct horse")
+	if err != nil {
+		t.Fatal("failed to register, err =", err)
+	}
+	keys, err := service.GenerateKeySet()
+	if err != nil {
+		t.Fatal("failed to generate key set, err =", err)
+	}
+	svc := service.NewTokenService(users, keys)
+
+	var errUnauthorized *model.ErrUnauthorized
+	if _, err := svc.IssueTokens(ctx, "alice", "wrong password", nil); !errors.As(err, &errUnauthorized) {
+		t.Errorf("unexpected error of a wrong password, given = %v", err)
+	}
+	pair, err := svc.IssueTokens(ctx, "alice", "correct horse", nil)
+	if err != nil {
+		t.Fatal("failed to issue tokens, err =", err)
+	}
+	principal, err := svc.Principal(ctx, pair.AccessToken)
+	if err != nil {
+		t.Fatal("failed to verify access token, err =", err)
+	}
+	if principal.User.ID != user.ID || principal.User.Username != "alice" || !principal.HasScope(model.ScopeTODOsWrite) {
+		t.Errorf("unexpected principal, given = %+v", principal)
+	}
+	if _, err := svc.Principal(ctx, pair.RefreshToken); !errors.As(err, &errUnauthorized) {
+		t.Errorf("refresh token is accepted as access token, err = %v", err)
+	}
+	if _, err := svc.RefreshTokens(ctx, pair.AccessToken, nil); !errors.As(err, &errUnauthorized) {
+		t.Errorf("access token is accepted as refresh token, err = %v", err)
+	}
+
+	narrowed, err := svc.RefreshTokens(ctx, pair.RefreshToken, []string{model.ScopeTODOsRead})
+	if err != nil {
+		t.Fatal("failed to refresh tokens, err =", err)
+	}
+	principal, err = svc.Principal(ctx, narrowed.AccessToken)
+	if err != nil || !principal.HasScope(model.ScopeTODOsRead) || principal.HasScope(model.ScopeTODOsWrite) {
+		t.Errorf("unexpected principal of narrowed token, given = %+v, err = %v", principal, err)
+	}
+	var errForbidden *model.ErrForbidden
+	if _, err := svc.RefreshTokens(ctx, narrowed.RefreshToken, []string{model.ScopeTODOsWrite}); !errors.As(err, &errForbidden) {
+		t.Errorf("unexpected error widening scopes, given = %v", err)
+	}
+}
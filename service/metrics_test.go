@@ -0,0 +1,50 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/metrics"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/repository"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestTODOServiceInstrument(t *testing.T) {
+	t.Parallel()
+
+	ctx := asUser(context.Background())
+	reg := metrics.NewRegistry()
+	svc := service.NewTODOServiceWithRepository(repository.NewMemoryTODORepository())
+	svc.Instrument(reg)
+
+	todo, err := svc.CreateTODO(ctx, "subject", "")
+	if err != nil {
+		t.Fatal("failed to create todo, err =", err)
+	}
+	if _, err := svc.UpdateTODOStatus(ctx, todo.ID, model.TODOStatusDone); err != nil {
+		t.Fatal("failed to update todo status, err =", err)
+	}
+	if _, err := svc.ReadTODOByID(ctx, todo.ID+1); err == nil {
+		t.Fatal("expected an error reading a missing todo")
+	}
+
+	var b bytes.Buffer
+	reg.WriteText(&b)
+	got := b.String()
+	for _, want := range []string{
+		`todo_service_operations_total{operation="CreateTODO",result="ok"} 1`,
+		`todo_service_operations_total{operation="UpdateTODOStatus",result="ok"} 1`,
+		`todo_service_operations_total{operation="ReadTODOByID",result="error"} 1`,
+	} {
+		if !strings.Contains(got, want+"\n") {
+			t.Errorf("metrics lack %s, given =\n%s", want, got)
+		}
+	}
+	// UpdateTODOStatus は PatchTODO としては数えない
+	if strings.Contains(got, `operation="PatchTODO"`) {
+		t.Errorf("UpdateTODOStatus was counted twice, given =\n%s", got)
+	}
+}
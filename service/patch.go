@@ -0,0 +1,201 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// A TODOPatch is a patch document changing the representation of a TODO.
+type TODOPatch interface {
+	apply(doc interface{}) (interface{}, error)
+}
+
+type mergePatchDocument struct {
+	patch interface{}
+}
+
+// NewMergePatch parses a JSON Merge Patch (RFC 7396), where null removes a member.
+func NewMergePatch(b []byte) (TODOPatch, error) {
+	patch, err := decodeValue(b)
+	if err != nil {
+		return nil, &model.ErrBadRequest{Message: "malformed JSON Merge Patch: " + err.Error()}
+	}
+	return &mergePatchDocument{patch: patch}, nil
+}
+
+func (p *mergePatchDocument) apply(doc interface{}) (interface{}, error) {
+	return mergePatch(doc, p.patch), nil
+}
+
+type jsonPatchDocument []*jsonPatchOp
+
+// NewJSONPatch parses a JSON Patch (RFC 6902).
+func NewJSONPatch(b []byte) (TODOPatch, error) {
+	ops, err := parseJSONPatch(b)
+	if err != nil {
+		return nil, err
+	}
+	return jsonPatchDocument(ops), nil
+}
+
+func (p jsonPatchDocument) apply(doc interface{}) (interface{}, error) {
+	return applyJSONPatch(doc, p)
+}
+
+// A todoDocument is the representation of a TODO patches are applied to.
+// Unlike the JSON of model.TODO every member is present, even when it is
+// empty, so that JSON Patch can refer to it.
+type todoDocument struct {
+	ID          int64            `json:"id"`
+	Subject     string           `json:"subject"`
+	Description string           `json:"description"`
+	Status      model.TODOStatus `json:"status"`
+	CompletedAt *time.Time       `json:"completed_at"`
+	DueAt       *time.Time       `json:"due_at"`
+	RemindAt    *time.Time       `json:"remind_at"`
+	Tags        []string         `json:"tags"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+func newTODODocument(todo *model.TODO) *todoDocument {
+	tags := todo.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return &todoDocument{
+		ID:          todo.ID,
+		Subject:     todo.Subject,
+		Description: todo.Description,
+		Status:      todo.Status,
+		CompletedAt: todo.CompletedAt,
+		DueAt:       todo.DueAt,
+		RemindAt:    todo.RemindAt,
+		Tags:        tags,
+		CreatedAt:   todo.CreatedAt,
+		UpdatedAt:   todo.UpdatedAt,
+	}
+}
+
+// ApplyTODOPatch applies patch to the representation of the TODO with id on DB.
+//
+// The patch is applied to the stored TODO in the transaction writing it, once
+// the preconditions among opts hold. id, completed_at, created_at and
+// updated_at are read-only, and the patched TODO is validated like PUT /todos.
+func (s *TODOService) ApplyTODOPatch(ctx context.Context, id int64, patch TODOPatch, opts ...TODOOption) (_ *model.TODO, err error) {
+	ctx, done := s.observe(ctx, "ApplyTODOPatch")
+	defer func() { done(err) }()
+	return s.changeTODO(ctx, id, opts, func(current *model.TODO) ([]TODOOption, error) {
+		before := newTODODocument(current)
+		b, err := json.Marshal(before)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := decodeValue(b)
+		if err != nil {
+			return nil, err
+		}
+		if doc, err = patch.apply(doc); err != nil {
+			return nil, err
+		}
+
+		after, err := decodeTODODocument(doc)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkPatchedTODO(before, after); err != nil {
+			return nil, err
+		}
+		return []TODOOption{
+			WithSubject(after.Subject),
+			WithDescription(after.Description),
+			WithStatus(after.Status),
+			WithDueAt(after.DueAt),
+			WithRemindAt(after.RemindAt),
+			WithTags(after.Tags...),
+		}, nil
+	})
+}
+
+// decodeTODODocument decodes a patched document, reporting values which
+// do not fit a TODO as model.ErrValidation.
+func decodeTODODocument(doc interface{}) (*todoDocument, error) {
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, &model.ErrValidation{Message: "the patched todo must be an object"}
+	}
+	if _, ok := m["status"]; !ok {
+		return nil, patchFieldError("status", "must not be removed")
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	after := &todoDocument{}
+	if err := dec.Decode(after); err != nil {
+		var errType *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &errType):
+			return nil, patchFieldError(errType.Field, "must be "+errType.Type.String())
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+			return nil, patchFieldError(field, "is not allowed")
+		default:
+			return nil, &model.ErrValidation{Message: "the patched todo is invalid: " + err.Error()}
+		}
+	}
+	return after, nil
+}
+
+// checkPatchedTODO reports read-only members changed by the patch, or else
+// the invalid members of after.
+func checkPatchedTODO(before, after *todoDocument) error {
+	readOnly := map[string]bool{
+		"id":           after.ID != before.ID,
+		"completed_at": !equalTime(after.CompletedAt, before.CompletedAt),
+		"created_at":   !after.CreatedAt.Equal(before.CreatedAt),
+		"updated_at":   !after.UpdatedAt.Equal(before.UpdatedAt),
+	}
+	var fields []*model.FieldError
+	for _, field := range []string{"id", "completed_at", "created_at", "updated_at"} {
+		if readOnly[field] {
+			fields = append(fields, &model.FieldError{Field: field, Message: "is read-only"})
+		}
+	}
+	if len(fields) > 0 {
+		return &model.ErrValidation{Message: "request has invalid fields", Fields: fields}
+	}
+
+	req := &model.UpdateTODORequest{
+		ID:          before.ID,
+		Subject:     after.Subject,
+		Description: after.Description,
+		DueAt:       after.DueAt,
+		RemindAt:    after.RemindAt,
+		Tags:        after.Tags,
+	}
+	return req.Validate()
+}
+
+func equalTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func patchFieldError(field, message string) error {
+	return &model.ErrValidation{
+		Message: "request has invalid fields",
+		Fields:  []*model.FieldError{{Field: field, Message: message}},
+	}
+}
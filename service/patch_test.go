@@ -0,0 +1,152 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/repository"
+	"github.com/TechBowl-japan/go-stations/service"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestApplyTODOPatch(t *testing.T) {
+	t.Parallel()
+
+	due := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	mergePatch := func(s string) (service.TODOPatch, error) { return service.NewMergePatch([]byte(s)) }
+	jsonPatch := func(s string) (service.TODOPatch, error) { return service.NewJSONPatch([]byte(s)) }
+
+	cases := map[string]struct {
+		patch func() (service.TODOPatch, error)
+		want  *model.TODO
+		err   interface{}
+	}{
+		"Merge patch": {
+			patch: func() (service.TODOPatch, error) {
+				return mergePatch(`{"subject": "changed", "description": null, "due_at": null, "tags": ["b"]}`)
+			},
+			want: &model.TODO{Subject: "changed", Tags: []string{"b"}},
+		},
+		"Merge patch leaving members": {
+			patch: func() (service.TODOPatch, error) { return mergePatch(`{"status": "in_progress"}`) },
+			want: &model.TODO{
+				Subject: "subject", Description: "description", Status: model.TODOStatusInProgress,
+				DueAt: &due, Tags: []string{"a"},
+			},
+		},
+		"Merge patch removing subject": {
+			patch: func() (service.TODOPatch, error) { return mergePatch(`{"subject": null}`) },
+			err:   &model.ErrValidation{},
+		},
+		"Merge patch removing status": {
+			patch: func() (service.TODOPatch, error) { return mergePatch(`{"status": null}`) },
+			err:   &model.ErrValidation{},
+		},
+		"Merge patch with unknown member": {
+			patch: func() (service.TODOPatch, error) { return mergePatch(`{"owner": "me"}`) },
+			err:   &model.ErrValidation{},
+		},
+		"Merge patch replacing the todo": {
+			patch: func() (service.TODOPatch, error) { return mergePatch(`[]`) },
+			err:   &model.ErrValidation{},
+		},
+		"Malformed merge patch": {
+			patch: func() (service.TODOPatch, error) { return mergePatch(`{`) },
+			err:   &model.ErrBadRequest{},
+		},
+		"JSON patch": {
+			patch: func() (service.TODOPatch, error) {
+				return jsonPatch(`[
+					{"op": "test", "path": "/subject", "value": "subject"},
+					{"op": "add", "path": "/tags/0", "value": "0"},
+					{"op": "add", "path": "/tags/-", "value": "z"},
+					{"op": "copy", "from": "/subject", "path": "/description"},
+					{"op": "replace", "path": "/subject", "value": "changed"},
+					{"op": "remove", "path": "/due_at"}
+				]`)
+			},
+			want: &model.TODO{Subject: "changed", Description: "subject", Tags: []string{"0", "a", "z"}},
+		},
+		"JSON patch moving a member": {
+			patch: func() (service.TODOPatch, error) {
+				return jsonPatch(`[{"op": "move", "from": "/description", "path": "/subject"}, {"op": "add", "path": "/description", "value": ""}]`)
+			},
+			want: &model.TODO{Subject: "description", DueAt: &due, Tags: []string{"a"}},
+		},
+		"JSON patch failing test": {
+			patch: func() (service.TODOPatch, error) {
+				return jsonPatch(`[{"op": "replace", "path": "/subject", "value": "changed"}, {"op": "test", "path": "/subject", "value": "subject"}]`)
+			},
+			err: &model.ErrConflict{},
+		},
+		"JSON patch to missing path": {
+			patch: func() (service.TODOPatch, error) {
+				return jsonPatch(`[{"op": "replace", "path": "/tags/5", "value": "x"}]`)
+			},
+			err: &model.ErrConflict{},
+		},
+		"JSON patch to read-only member": {
+			patch: func() (service.TODOPatch, error) {
+				return jsonPatch(`[{"op": "replace", "path": "/id", "value": 100}]`)
+			},
+			err: &model.ErrValidation{},
+		},
+		"JSON patch without value": {
+			patch: func() (service.TODOPatch, error) { return jsonPatch(`[{"op": "add", "path": "/subject"}]`) },
+			err:   &model.ErrBadRequest{},
+		},
+		"JSON patch with unknown op": {
+			patch: func() (service.TODOPatch, error) { return jsonPatch(`[{"op": "increment", "path": "/id"}]`) },
+			err:   &model.ErrBadRequest{},
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := asUser(context.Background())
+			svc := service.NewTODOServiceWithRepository(repository.NewMemoryTODORepository())
+			todo, err := svc.CreateTODO(ctx, "subject", "description", service.WithDueAt(&due), service.WithTags("a"))
+			if err != nil {
+				t.Fatal("failed to create todo, err =", err)
+			}
+
+			patch, err := c.patch()
+			if err == nil {
+				var got *model.TODO
+				got, err = svc.ApplyTODOPatch(ctx, todo.ID, patch)
+				if err == nil {
+					c.want.ID = todo.ID
+					opt := cmpopts.IgnoreFields(model.TODO{}, "CreatedAt", "UpdatedAt", "OwnerID")
+					if diff := cmp.Diff(c.want, got, opt); diff != "" {
+						t.Error("unexpected todo, diff =", diff)
+					}
+				}
+			}
+			switch want := c.err.(type) {
+			case nil:
+				if err != nil {
+					t.Error("unexpected error, err =", err)
+				}
+			case *model.ErrValidation:
+				if !errors.As(err, &want) {
+					t.Errorf("unexpected error, given = %v, expected = %T", err, c.err)
+				}
+			case *model.ErrConflict:
+				if !errors.As(err, &want) {
+					t.Errorf("unexpected error, given = %v, expected = %T", err, c.err)
+				}
+			case *model.ErrBadRequest:
+				if !errors.As(err, &want) {
+					t.Errorf("unexpected error, given = %v, expected = %T", err, c.err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/TechBowl-japan/go-stations/logger"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/repository"
+)
+
+// A ProjectService implements projects and their members.
+// Every method works on behalf of the user in the context, see ContextWithUser,
+// and fails with *model.ErrUnauthorized for anonymous requests. Projects the
+// user is not a member of are not found.
+type ProjectService struct {
+	projects repository.ProjectRepository
+	users    repository.UserRepository
+	todos    repository.TODORepository
+}
+
+// NewProjectService returns new ProjectService storing projects on db.
+func NewProjectService(db *sql.DB) *ProjectService {
+	return NewProjectServiceWithRepositories(
+		repository.NewSQLiteProjectRepository(db),
+		repository.NewSQLiteUserRepository(db),
+		repository.NewSQLiteTODORepository(db),
+	)
+}
+
+// NewProjectServiceWithRepositories returns new ProjectService storing projects
+// on projects, looking members up in users and the TODOs of projects up in todos.
+func NewProjectServiceWithRepositories(projects repository.ProjectRepository, users repository.UserRepository, todos repository.TODORepository) *ProjectService {
+	return &ProjectService{
+		projects: projects,
+		users:    users,
+		todos:    todos,
+	}
+}
+
+// loggedIn returns the user of ctx.
+func loggedIn(ctx context.Context) (*model.User, error) {
+	user, ok := UserFromContext(ctx)
+	if !ok {
+		return nil, &model.ErrUnauthorized{Message: "login is required"}
+	}
+	return user, nil
+}
+
+// find returns the project with id as read by the user of ctx, failing with
+// *model.ErrForbidden unless the user may manage it when manage is set.
+func (s *ProjectService) find(ctx context.Context, repo repository.ProjectRepository, id int64, manage bool) (*model.Project, error) {
+	user, err := loggedIn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	project, err := repo.FindProject(ctx, id, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if manage && !project.Role.CanManage() {
+		return nil, &model.ErrForbidden{Message: "only owners can manage the project"}
+	}
+	return project, nil
+}
+
+// CreateProject creates a project owned by the user of ctx.
+func (s *ProjectService) CreateProject(ctx context.Context, name, description string) (*model.Project, error) {
+	user, err := loggedIn(ctx)
+	if err != nil {
+		return &model.Project{}, err
+	}
+	project, err := s.projects.CreateProject(ctx, &model.Project{Name: name, Description: description}, user)
+	if err != nil {
+		logger.Println(ctx, err)
+		return &model.Project{}, err
+	}
+	return project, nil
+}
+
+// ReadProjects reads the projects the user of ctx is a member of.
+func (s *ProjectService) ReadProjects(ctx context.Context) ([]*model.Project, error) {
+	user, err := loggedIn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	projects, err := s.projects.ListProjects(ctx, user.ID)
+	if err != nil {
+		logger.Println(ctx, err)
+		return nil, err
+	}
+	return projects, nil
+}
+
+// ReadProject reads the project with id.
+func (s *ProjectService) ReadProject(ctx context.Context, id int64) (*model.Project, error) {
+	project, err := s.find(ctx, s.projects, id, false)
+	if err != nil {
+		logger.Println(ctx, err)
+		return &model.Project{}, err
+	}
+	return project, nil
+}
+
+// UpdateProject changes the name and the description of the project with id
+// where they are not nil. Only owners may update a project.
+func (s *ProjectService) UpdateProject(ctx context.Context, id int64, name, description *string) (*model.Project, error) {
+	var project *model.Project
+	err := s.projects.WithTx(ctx, func(repo repository.ProjectRepository) error {
+		var err error
+		if project, err = s.find(ctx, repo, id, true); err != nil {
+			return err
+		}
+		if name != nil {
+			project.Name = *name
+		}
+		if description != nil {
+			project.Description = *description
+		}
+		if err := repo.UpdateProject(ctx, project); err != nil {
+			return err
+		}
+		project, err = s.find(ctx, repo, id, false)
+		return err
+	})
+	if err != nil {
+		logger.Println(ctx, err)
+		return &model.Project{}, err
+	}
+	return project, nil
+}
+
+// DeleteProject deletes the project with id. Only owners may delete a project,
+// and only once it has no TODOs left but those in the trash, which are purged
+// with the rest of the trash.
+func (s *ProjectService) DeleteProject(ctx context.Context, id int64) error {
+	user, err := loggedIn(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := s.find(ctx, s.projects, id, true); err != nil {
+		logger.Println(ctx, err)
+		return err
+	}
+	todos, err := s.todos.ForOwner(user.ID, id).List(ctx, 0, 1, model.TODOFilter{ProjectID: id})
+	if err == nil && len(todos) > 0 {
+		err = &model.ErrConflict{Message: "the project has todos"}
+	}
+	if err == nil {
+		err = s.projects.DeleteProject(ctx, id)
+	}
+	if err != nil {
+		logger.Println(ctx, err)
+		return err
+	}
+	return nil
+}
+
+// ReadMembers reads the members of the project with id.
+func (s *ProjectService) ReadMembers(ctx context.Context, id int64) ([]*model.ProjectMember, error) {
+	if _, err := s.find(ctx, s.projects, id, false); err != nil {
+		logger.Println(ctx, err)
+		return nil, err
+	}
+	members, err := s.projects.Members(ctx, id)
+	if err != nil {
+		logger.Println(ctx, err)
+		return nil, err
+	}
+	return members, nil
+}
+
+// AddMember makes the user with username a member of the project with id with
+// role, or changes their role when they are a member already. Only owners may
+// add members, and the last owner cannot give up being one.
+func (s *ProjectService) AddMember(ctx context.Context, id int64, username string, role model.ProjectRole) (*model.ProjectMember, error) {
+	// The project is checked first, so that only owners learn whether
+	// username exists.
+	if _, err := s.find(ctx, s.projects, id, true); err != nil {
+		logger.Println(ctx, err)
+		return &model.ProjectMember{}, err
+	}
+	user, err := s.users.FindUserByName(ctx, username)
+	if err != nil {
+		logger.Println(ctx, err)
+		return &model.ProjectMember{}, err
+	}
+	var member *model.ProjectMember
+	err = s.projects.WithTx(ctx, func(repo repository.ProjectRepository) error {
+		if _, err := s.find(ctx, repo, id, true); err != nil {
+			return err
+		}
+		if role != model.ProjectRoleOwner {
+			if err := checkOwnerLeft(ctx, repo, id, user.ID); err != nil {
+				return err
+			}
+		}
+		var err error
+		member, err = repo.SetMember(ctx, id, &model.ProjectMember{UserID: user.ID, Username: user.Username, Role: role})
+		return err
+	})
+	if err != nil {
+		logger.Println(ctx, err)
+		return &model.ProjectMember{}, err
+	}
+	return member, nil
+}
+
+// RemoveMember removes the user with userID from the project with id.
+// Owners may remove any member and every member may leave, but the last
+// owner cannot.
+func (s *ProjectService) RemoveMember(ctx context.Context, id, userID int64) error {
+	user, err := loggedIn(ctx)
+	if err != nil {
+		return err
+	}
+	err = s.projects.WithTx(ctx, func(repo repository.ProjectRepository) error {
+		if _, err := s.find(ctx, repo, id, userID != user.ID); err != nil {
+			return err
+		}
+		if err := checkOwnerLeft(ctx, repo, id, userID); err != nil {
+			return err
+		}
+		return repo.RemoveMember(ctx, id, userID)
+	})
+	if err != nil {
+		logger.Println(ctx, err)
+		return err
+	}
+	return nil
+}
+
+// checkOwnerLeft checks that the project with id keeps an owner other than
+// the user with userID.
+func checkOwnerLeft(ctx context.Context, repo repository.ProjectRepository, id, userID int64) error {
+	members, err := repo.Members(ctx, id)
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		if m.Role == model.ProjectRoleOwner && m.UserID != userID {
+			return nil
+		}
+	}
+	return &model.ErrConflict{Message: "the project must keep an owner"}
+}
@@ -0,0 +1,122 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/repository"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestProjectService(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	projects := repository.NewMemoryProjectRepository()
+	users := repository.NewMemoryUserRepository()
+	todos := repository.NewMemoryTODORepository()
+	svc := service.NewProjectServiceWithRepositories(projects, users, todos)
+	todoSvc := service.NewTODOServiceWithRepositories(todos, projects)
+
+	contexts := map[string]context.Context{}
+	for _, name := range []string{"alice", "bob", "carol"} {
+		user, err := users.CreateUser(ctx, &model.User{Username: name, PasswordHash: "hash"})
+		if err != nil {
+			t.Fatal("failed to create user, err =", err)
+		}
+		contexts[name] = service.ContextWithUser(ctx, user)
+	}
+	alice, bob, carol := contexts["alice"], contexts["bob"], contexts["carol"]
+
+	var (
+		errUnauthorized *model.ErrUnauthorized
+		errForbidden    *model.ErrForbidden
+		errNotFound     *model.ErrNotFound
+		errConflict     *model.ErrConflict
+	)
+	if _, err := svc.CreateProject(ctx, "anonymous", ""); !errors.As(err, &errUnauthorized) {
+		t.Errorf("unexpected error creating a project anonymously, given = %v", err)
+	}
+	project, err := svc.CreateProject(alice, "sprint", "")
+	if err != nil {
+		t.Fatal("failed to create project, err =", err)
+	}
+	if _, err := svc.ReadProject(bob, project.ID); !errors.As(err, &errNotFound) {
+		t.Errorf("unexpected error reading the project of others, given = %v", err)
+	}
+	if _, err := svc.AddMember(bob, project.ID, "bob", model.ProjectRoleOwner); !errors.As(err, &errNotFound) {
+		t.Errorf("unexpected error joining the project of others, given = %v", err)
+	}
+	if _, err := svc.AddMember(alice, project.ID, "bob", model.ProjectRoleEditor); err != nil {
+		t.Fatal("failed to add editor, err =", err)
+	}
+	if _, err := svc.AddMember(alice, project.ID, "carol", model.ProjectRoleViewer); err != nil {
+		t.Fatal("failed to add viewer, err =", err)
+	}
+
+	// 編集者は作成・更新でき、閲覧者は読むだけ
+	todo, err := todoSvc.CreateTODO(bob, "shared", "", service.WithProject(project.ID))
+	if err != nil {
+		t.Fatal("failed to create todo as editor, err =", err)
+	}
+	if todo.ProjectID != project.ID {
+		t.Errorf("unexpected project of todo, given = %d", todo.ProjectID)
+	}
+	if _, err := todoSvc.CreateTODO(carol, "viewer's", "", service.WithProject(project.ID)); !errors.As(err, &errForbidden) {
+		t.Errorf("unexpected error creating todo as viewer, given = %v", err)
+	}
+	if _, err := todoSvc.UpdateTODOStatus(carol, todo.ID, model.TODOStatusInProgress); !errors.As(err, &errForbidden) {
+		t.Errorf("unexpected error updating todo as viewer, given = %v", err)
+	}
+	if err := todoSvc.DeleteTODO(carol, []int64{todo.ID}); !errors.As(err, &errForbidden) {
+		t.Errorf("unexpected error deleting todo as viewer, given = %v", err)
+	}
+	if _, err := todoSvc.UpdateTODOStatus(alice, todo.ID, model.TODOStatusInProgress); err != nil {
+		t.Error("failed to update todo as owner, err =", err)
+	}
+	read, err := todoSvc.ReadTODO(carol, 0, 10, model.TODOFilter{ProjectID: project.ID})
+	if err != nil || len(read) != 1 {
+		t.Errorf("unexpected todos of viewer, given = %d todos, err = %v", len(read), err)
+	}
+	if _, err := todoSvc.ReadTODO(ctx, 0, 10, model.TODOFilter{ProjectID: project.ID}); !errors.As(err, &errUnauthorized) {
+		t.Errorf("unexpected error reading todos of the project anonymously, given = %v", err)
+	}
+
+	if _, err := svc.UpdateProject(bob, project.ID, nil, nil); !errors.As(err, &errForbidden) {
+		t.Errorf("unexpected error updating project as editor, given = %v", err)
+	}
+	if err := svc.DeleteProject(alice, project.ID); !errors.As(err, &errConflict) {
+		t.Errorf("unexpected error deleting project with todos, given = %v", err)
+	}
+	if _, err := svc.AddMember(alice, project.ID, "alice", model.ProjectRoleEditor); !errors.As(err, &errConflict) {
+		t.Errorf("unexpected error demoting the last owner, given = %v", err)
+	}
+	owner, _ := service.UserFromContext(alice)
+	if err := svc.RemoveMember(alice, project.ID, owner.ID); !errors.As(err, &errConflict) {
+		t.Errorf("unexpected error removing the last owner, given = %v", err)
+	}
+
+	// 閲覧者は自分で抜けられ、抜けたら読めなくなる
+	viewer, _ := service.UserFromContext(carol)
+	if err := svc.RemoveMember(carol, project.ID, viewer.ID); err != nil {
+		t.Fatal("failed to leave project, err =", err)
+	}
+	if _, err := todoSvc.ReadTODOByID(carol, todo.ID); !errors.As(err, &errNotFound) {
+		t.Errorf("unexpected error reading todo after leaving, given = %v", err)
+	}
+
+	if err := todoSvc.DeleteTODO(bob, []int64{todo.ID}); err != nil {
+		t.Fatal("failed to delete todo as editor, err =", err)
+	}
+	if err := svc.DeleteProject(bob, project.ID); !errors.As(err, &errForbidden) {
+		t.Errorf("unexpected error deleting project as editor, given = %v", err)
+	}
+	if err := svc.DeleteProject(alice, project.ID); err != nil {
+		t.Fatal("failed to delete project, err =", err)
+	}
+	if got, err := svc.ReadProjects(bob); err != nil || len(got) != 0 {
+		t.Errorf("unexpected projects after delete, given = %v, err = %v", got, err)
+	}
+}
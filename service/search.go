@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/TechBowl-japan/go-stations/logger"
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// SearchTODO searches TODOs whose subject or description contain every term
+// of query, most relevant first.
+func (s *TODOService) SearchTODO(ctx context.Context, query string, size int64) (_ []*model.SearchResult, err error) {
+	ctx, done := s.observe(ctx, "SearchTODO")
+	defer func() { done(err) }()
+	terms := strings.Fields(query)
+	if len(terms) == 0 || size <= 0 {
+		return []*model.SearchResult{}, nil
+	}
+
+	scope, err := s.scoped(ctx)
+	var results []*model.SearchResult
+	if err == nil {
+		results, err = scope.repo.Search(ctx, terms, size)
+	}
+	if err != nil {
+		logger.Println(ctx, err)
+		return nil, err
+	}
+	return results, nil
+}
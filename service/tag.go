@@ -0,0 +1,24 @@
+package service
+
+import (
+	"context"
+
+	"github.com/TechBowl-japan/go-stations/logger"
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// ReadTags reads the tags in use with the number of TODOs having each of them.
+func (s *TODOService) ReadTags(ctx context.Context) (_ []*model.Tag, err error) {
+	ctx, done := s.observe(ctx, "ReadTags")
+	defer func() { done(err) }()
+	scope, err := s.scoped(ctx)
+	var tags []*model.Tag
+	if err == nil {
+		tags, err = scope.repo.Tags(ctx)
+	}
+	if err != nil {
+		logger.Println(ctx, err)
+		return nil, err
+	}
+	return tags, nil
+}
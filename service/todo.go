@@ -3,138 +3,465 @@ package service
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
-	"strings"
+	"reflect"
+	"time"
 
+	"github.com/TechBowl-japan/go-stations/logger"
+	"github.com/TechBowl-japan/go-stations/metrics"
 	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/repository"
+	"github.com/TechBowl-japan/go-stations/tracing"
 )
 
 // A TODOService implements CRUD of TODO entities.
+// Every method works on the TODOs of the user in the context, see ContextWithUser,
+// together with the TODOs of the projects the user is a member of.
+// Viewers of a project may read its TODOs but not change them.
 type TODOService struct {
-	db *sql.DB
+	repo     repository.TODORepository
+	projects repository.ProjectRepository
+	// ops counts the operations by their result, once instrumented.
+	ops *metrics.Counter
 }
 
-// NewTODOService returns new TODOService.
+// NewTODOService returns new TODOService storing TODOs on db.
 func NewTODOService(db *sql.DB) *TODOService {
+	return NewTODOServiceWithRepositories(repository.NewSQLiteTODORepository(db), repository.NewSQLiteProjectRepository(db))
+}
+
+// NewTODOServiceWithRepository returns new TODOService storing TODOs on repo,
+// without any project.
+func NewTODOServiceWithRepository(repo repository.TODORepository) *TODOService {
+	return NewTODOServiceWithRepositories(repo, repository.NewMemoryProjectRepository())
+}
+
+// NewTODOServiceWithRepositories returns new TODOService storing TODOs on repo
+// and reading the projects they belong to from projects.
+func NewTODOServiceWithRepositories(repo repository.TODORepository, projects repository.ProjectRepository) *TODOService {
 	return &TODOService{
-		db: db,
+		repo:     repo,
+		projects: projects,
 	}
 }
 
-// CreateTODO creates a TODO on DB.
-//エラーの場合に*model.TODOにnilを設定するとpanicが発生する。UpdateTODOResponseのTODOにメモリを格納しており、ぬるぽが起きる
-func (s *TODOService) CreateTODO(ctx context.Context, subject, description string) (*model.TODO, error) {
-	const (
-		insert  = `INSERT INTO todos(subject, description) VALUES(?, ?)`
-		confirm = `SELECT id, subject, description, created_at, updated_at FROM todos WHERE id = ?`
-	)
-	result, err := s.db.ExecContext(ctx, insert, subject, description)
+// Instrument makes s count its operations into reg, as
+// todo_service_operations_total by operation and result, which is "ok" or
+// "error". It must be called before s is used.
+func (s *TODOService) Instrument(reg *metrics.Registry) {
+	s.ops = reg.Counter("todo_service_operations_total", "Operations of the TODO service by result.", "operation", "result")
+}
+
+// observe starts the operation op in a span, see tracing.Start, returning
+// ctx with the span and a function to call with the error op ended with,
+// which ends the span and counts op by its result.
+func (s *TODOService) observe(ctx context.Context, op string) (context.Context, func(err error)) {
+	ctx, span := tracing.Start(ctx, "TODOService."+op)
+	return ctx, func(err error) {
+		span.SetError(err)
+		span.End()
+		if s.ops == nil {
+			return
+		}
+		result := "ok"
+		if err != nil {
+			result = "error"
+		}
+		s.ops.Inc(op, result)
+	}
+}
+
+// A todoScope is the repository of the TODOs a request may read, with the
+// role of the user in every project whose TODOs are among them.
+type todoScope struct {
+	repo  repository.TODORepository
+	roles map[int64]model.ProjectRole
+}
+
+// scoped returns the scope of the user in ctx, failing with
+// *model.ErrUnauthorized when there is none. The roles are read before any
+// transaction on the TODOs starts, so that the transaction is the only use of
+// DB while it lasts.
+func (s *TODOService) scoped(ctx context.Context) (*todoScope, error) {
+	user, err := loggedIn(ctx)
 	if err != nil {
-		log.Println(err)
-		return &model.TODO{}, err
+		return nil, err
 	}
-	id, err := result.LastInsertId()
+	projects, err := s.projects.ListProjects(ctx, user.ID)
 	if err != nil {
-		log.Println(err)
-		return &model.TODO{}, err
+		return nil, err
+	}
+	scope := &todoScope{roles: make(map[int64]model.ProjectRole, len(projects))}
+	ids := make([]int64, 0, len(projects))
+	for _, p := range projects {
+		scope.roles[p.ID] = p.Role
+		ids = append(ids, p.ID)
 	}
-	todo := model.TODO{}
+	scope.repo = s.repo.ForOwner(user.ID, ids...)
+	return scope, nil
+}
 
-	if err = s.db.QueryRowContext(ctx, confirm, id).Scan(&todo.ID, &todo.Subject, &todo.Description, &todo.CreatedAt, &todo.UpdatedAt); err != nil {
-		log.Println(err)
-		return &model.TODO{}, err
+// canRead checks that the TODOs of the project with id may be read.
+// Every TODO without project in the scope may be read.
+func (sc *todoScope) canRead(projectID int64) error {
+	if _, ok := sc.roles[projectID]; projectID != 0 && !ok {
+		return &model.ErrNotFound{}
 	}
+	return nil
+}
 
-	return &todo, nil
+// canEdit checks that todo may be created, changed or deleted.
+func (sc *todoScope) canEdit(todo *model.TODO) error {
+	if err := sc.canRead(todo.ProjectID); err != nil || todo.ProjectID == 0 {
+		return err
+	}
+	if role := sc.roles[todo.ProjectID]; !role.CanEditTODOs() {
+		return &model.ErrForbidden{Message: fmt.Sprintf("%ss of the project cannot change its todos", role)}
+	}
+	return nil
 }
 
-// ReadTODO reads TODOs on DB.
-func (s *TODOService) ReadTODO(ctx context.Context, prevID, size int64) ([]*model.TODO, error) {
-	const (
-		read       = `SELECT id, subject, description, created_at, updated_at FROM todos ORDER BY id DESC LIMIT ?`
-		readWithID = `SELECT id, subject, description, created_at, updated_at FROM todos WHERE id < ? ORDER BY id DESC LIMIT ?`
-	)
-	todos := []*model.TODO{}
-	var rows *sql.Rows
-	var err error
-	if prevID == 0 {
-		rows, err = s.db.QueryContext(ctx, read, size)
-	} else {
-		rows, err = s.db.QueryContext(ctx, readWithID, prevID, size)
+// A TODOOption sets optional fields of the TODO written by CreateTODO and UpdateTODO,
+// or makes the write conditional. Fields without an option are left as they are.
+type TODOOption func(*todoChange)
+
+// A todoChange is the TODO to write and the conditions to write it on.
+type todoChange struct {
+	todo          model.TODO
+	preconditions []Precondition
+	// revert is the event the TODO is reverted to, if the change is a revert.
+	revert *model.TODOEvent
+	// project is the project the TODO is created in.
+	project int64
+}
+
+// A Precondition checks the current TODO before it is changed, failing the change
+// with its error. current is nil when there is no TODO yet.
+type Precondition func(current *model.TODO) error
+
+// IfMatch returns the Precondition of the If-Match header. It holds when the ETag
+// of the current TODO is one of etags, or etags contains "*". Weak ETags never
+// match, since If-Match uses the strong comparison. No etags means no condition.
+func IfMatch(etags ...string) Precondition {
+	return func(current *model.TODO) error {
+		if len(etags) == 0 {
+			return nil
+		}
+		if current != nil {
+			etag := current.ETag()
+			for _, v := range etags {
+				if v == "*" || (v == etag && etag != "") {
+					return nil
+				}
+			}
+		}
+		return &model.ErrPreconditionFailed{Message: "the todo does not match If-Match"}
 	}
-	if err != nil {
-		log.Println(err)
-		return nil, err
+}
+
+// WithPrecondition makes the write conditional on p.
+func WithPrecondition(p Precondition) TODOOption {
+	return func(c *todoChange) {
+		c.preconditions = append(c.preconditions, p)
 	}
-	defer rows.Close()
+}
 
-	for rows.Next() {
-		todo := model.TODO{}
-		if err := rows.Scan(&todo.ID, &todo.Subject, &todo.Description, &todo.CreatedAt, &todo.UpdatedAt); err != nil {
-			log.Fatalf("getRows rows.Scan error err:%v", err)
+// check checks every precondition of c against current.
+func (c *todoChange) check(current *model.TODO) error {
+	for _, p := range c.preconditions {
+		if err := p(current); err != nil {
+			return err
 		}
-		todos = append(todos, &todo)
 	}
-	if err = rows.Err(); err != nil {
-		log.Println(err)
-		return nil, err
+	return nil
+}
+
+// WithSubject sets the subject of the TODO.
+func WithSubject(subject string) TODOOption {
+	return func(c *todoChange) {
+		c.todo.Subject = subject
 	}
-	return todos, nil
 }
 
-// UpdateTODO updates the TODO on DB.
-//エラーの場合に*model.TODOにnilを設定するとpanicが発生する。UpdateTODOResponseのTODOにメモリを格納しており、ぬるぽが起きる
-func (s *TODOService) UpdateTODO(ctx context.Context, id int64, subject, description string) (*model.TODO, error) {
-	const (
-		update  = `UPDATE todos SET subject = ?, description = ? WHERE id = ?`
-		confirm = `SELECT id, subject, description, created_at, updated_at FROM todos WHERE id = ?`
-	)
-	result, err := s.db.ExecContext(ctx, update, subject, description, id)
-	if err != nil {
-		log.Println(err)
+// WithDescription sets the description of the TODO.
+func WithDescription(description string) TODOOption {
+	return func(c *todoChange) {
+		c.todo.Description = description
+	}
+}
+
+// WithStatus moves the TODO to status. The change is checked against the
+// workflow when it is written.
+func WithStatus(status model.TODOStatus) TODOOption {
+	return func(c *todoChange) {
+		c.todo.Status = status
+	}
+}
+
+// WithDueAt sets the due date of the TODO. A nil t removes the due date.
+func WithDueAt(t *time.Time) TODOOption {
+	return func(c *todoChange) {
+		c.todo.DueAt = t
+	}
+}
+
+// WithRemindAt sets the reminder time of the TODO. A nil t removes the reminder.
+func WithRemindAt(t *time.Time) TODOOption {
+	return func(c *todoChange) {
+		c.todo.RemindAt = t
+	}
+}
+
+// WithTags replaces the tags of the TODO.
+func WithTags(tags ...string) TODOOption {
+	return func(c *todoChange) {
+		c.todo.Tags = model.NormalizeTags(tags)
+	}
+}
+
+// WithProject creates the TODO in the project with id, which needs the role
+// of an owner or an editor. It is ignored when the TODO is changed, since
+// TODOs never move between projects.
+func WithProject(id int64) TODOOption {
+	return func(c *todoChange) {
+		c.project = id
+	}
+}
+
+// CreateTODO creates a TODO on DB.
+// エラーの場合に*model.TODOにnilを設定するとpanicが発生する。UpdateTODOResponseのTODOにメモリを格納しており、ぬるぽが起きる
+func (s *TODOService) CreateTODO(ctx context.Context, subject, description string, opts ...TODOOption) (_ *model.TODO, err error) {
+	ctx, done := s.observe(ctx, "CreateTODO")
+	defer func() { done(err) }()
+	draft := todoChange{todo: model.TODO{Subject: subject, Description: description}}
+	for _, opt := range opts {
+		opt(&draft)
+	}
+	draft.todo.ProjectID = draft.project
+	if err := draft.check(nil); err != nil {
+		logger.Println(ctx, err)
 		return &model.TODO{}, err
 	}
-	rows, err := result.RowsAffected()
+	scope, err := s.scoped(ctx)
+	if err == nil {
+		err = scope.canEdit(&draft.todo)
+	}
 	if err != nil {
+		logger.Println(ctx, err)
 		return &model.TODO{}, err
 	}
-	if rows == 0 {
-		return &model.TODO{}, &model.ErrNotFound{}
+
+	var todo *model.TODO
+	err = scope.repo.WithTx(ctx, func(repo repository.TODORepository) error {
+		var err error
+		if todo, err = repo.Create(ctx, &draft.todo); err != nil {
+			return err
+		}
+		return record(ctx, repo, &model.TODOEvent{TODOID: todo.ID, Action: model.TODOEventCreate, After: todo})
+	})
+	if err != nil {
+		logger.Println(ctx, err)
+		return &model.TODO{}, err
 	}
+	return todo, nil
+}
 
-	todo := model.TODO{}
+// ReadTODO reads TODOs on DB.
+// Only TODOs matching every given filter are returned. Filtering on a project
+// the user is not a member of fails with model.ErrNotFound.
+func (s *TODOService) ReadTODO(ctx context.Context, prevID, size int64, filters ...model.TODOFilter) (_ []*model.TODO, err error) {
+	ctx, done := s.observe(ctx, "ReadTODO")
+	defer func() { done(err) }()
+	scope, err := s.scoped(ctx)
+	for _, f := range filters {
+		if err == nil {
+			err = scope.canRead(f.ProjectID)
+		}
+	}
+	var todos []*model.TODO
+	if err == nil {
+		todos, err = scope.repo.List(ctx, prevID, size, filters...)
+	}
+	if err != nil {
+		logger.Println(ctx, err)
+		return nil, err
+	}
+	return todos, nil
+}
 
-	if err = s.db.QueryRowContext(ctx, confirm, id).Scan(&todo.ID, &todo.Subject, &todo.Description, &todo.CreatedAt, &todo.UpdatedAt); err != nil {
-		log.Println(err)
+// ReadTODOByID reads the TODO with id on DB.
+func (s *TODOService) ReadTODOByID(ctx context.Context, id int64) (_ *model.TODO, err error) {
+	ctx, done := s.observe(ctx, "ReadTODOByID")
+	defer func() { done(err) }()
+	scope, err := s.scoped(ctx)
+	var todo *model.TODO
+	if err == nil {
+		todo, err = scope.repo.Find(ctx, id)
+	}
+	if err != nil {
+		logger.Println(ctx, err)
 		return &model.TODO{}, err
 	}
+	return todo, nil
+}
 
-	return &todo, nil
+// UpdateTODO updates the TODO on DB.
+// エラーの場合に*model.TODOにnilを設定するとpanicが発生する。UpdateTODOResponseのTODOにメモリを格納しており、ぬるぽが起きる
+func (s *TODOService) UpdateTODO(ctx context.Context, id int64, subject, description string, opts ...TODOOption) (_ *model.TODO, err error) {
+	ctx, done := s.observe(ctx, "UpdateTODO")
+	defer func() { done(err) }()
+	return s.changeTODO(ctx, id, append([]TODOOption{WithSubject(subject), WithDescription(description)}, opts...), nil)
 }
 
-// DeleteTODO deletes TODOs on DB by ids.
-func (s *TODOService) DeleteTODO(ctx context.Context, ids []int64) error {
-	if len(ids) == 0 {
-		return nil
+// UpdateTODOStatus moves the TODO to status on DB.
+func (s *TODOService) UpdateTODOStatus(ctx context.Context, id int64, status model.TODOStatus) (_ *model.TODO, err error) {
+	ctx, done := s.observe(ctx, "UpdateTODOStatus")
+	defer func() { done(err) }()
+	return s.changeTODO(ctx, id, []TODOOption{WithStatus(status)}, nil)
+}
+
+// PatchTODO changes the fields of the TODO set by opts on DB.
+// CompletedAt is set when the TODO becomes done and cleared when it leaves done.
+// Preconditions are checked in the transaction writing the TODO, so that the
+// write is conditional on the TODO they were checked against.
+func (s *TODOService) PatchTODO(ctx context.Context, id int64, opts ...TODOOption) (_ *model.TODO, err error) {
+	ctx, done := s.observe(ctx, "PatchTODO")
+	defer func() { done(err) }()
+	return s.changeTODO(ctx, id, opts, nil)
+}
+
+// changeTODO writes the TODO with id changed by opts, followed by the options
+// derive returns for the current TODO if derive is given. derive is only called
+// once every precondition holds. When there is no TODO with id, preconditions
+// are checked against nil, so that a failing one is reported over not found.
+func (s *TODOService) changeTODO(ctx context.Context, id int64, opts []TODOOption, derive func(current *model.TODO) ([]TODOOption, error)) (*model.TODO, error) {
+	scope, err := s.scoped(ctx)
+	if err != nil {
+		logger.Println(ctx, err)
+		return &model.TODO{}, err
 	}
+	var after *model.TODO
+	err = scope.repo.WithTx(ctx, func(repo repository.TODORepository) error {
+		before, err := repo.Find(ctx, id)
+		var errNotFound *model.ErrNotFound
+		if errors.As(err, &errNotFound) {
+			// If-Match: * のような条件は、TODO がないことを 404 より先に伝える
+			change := todoChange{}
+			for _, opt := range opts {
+				opt(&change)
+			}
+			if err := change.check(nil); err != nil {
+				return err
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if err := scope.canEdit(before); err != nil {
+			return err
+		}
+		change := todoChange{todo: *before}
+		for _, opt := range opts {
+			opt(&change)
+		}
+		if err := change.check(before); err != nil {
+			return err
+		}
+		if derive != nil {
+			derived, err := derive(before)
+			if err != nil {
+				return err
+			}
+			for _, opt := range derived {
+				opt(&change)
+			}
+		}
+		todo := change.todo
+		if reflect.DeepEqual(&todo, before) {
+			after = before
+			return nil
+		}
+		if todo.Status != before.Status && change.revert == nil {
+			if !before.Status.CanTransitionTo(todo.Status) {
+				return &model.ErrInvalidStatusTransition{From: before.Status, To: todo.Status}
+			}
+			todo.CompletedAt = nil
+			if todo.Status == model.TODOStatusDone {
+				now := time.Now()
+				todo.CompletedAt = &now
+			}
+		}
 
-	deleteFmt := fmt.Sprintf(`DELETE FROM todos WHERE id IN (?%s)`, strings.Repeat(", ?", len(ids)-1))
-	var arg []interface{}
-	for _, v := range ids {
-		arg = append(arg, v)
+		if after, err = repo.Update(ctx, &todo); err != nil {
+			return err
+		}
+		event := &model.TODOEvent{TODOID: id, Action: model.TODOEventUpdate, Before: before, After: after}
+		if change.revert != nil {
+			event.Action = model.TODOEventRevert
+			event.RevertedTo = change.revert.Revision
+		}
+		return record(ctx, repo, event)
+	})
+	if err != nil {
+		logger.Println(ctx, err)
+		return &model.TODO{}, err
 	}
-	result, err := s.db.ExecContext(ctx, deleteFmt, arg...)
+	return after, nil
+}
+
+// DeleteTODO moves TODOs on DB to the trash by ids.
+// Every TODO must satisfy preconditions for any of them to be deleted, where
+// those missing are checked as nil, e.g. If-Match: * fails for them.
+func (s *TODOService) DeleteTODO(ctx context.Context, ids []int64, preconditions ...Precondition) (err error) {
+	ctx, done := s.observe(ctx, "DeleteTODO")
+	defer func() { done(err) }()
+	scope, err := s.scoped(ctx)
 	if err != nil {
-		log.Println(err)
+		logger.Println(ctx, err)
 		return err
 	}
-	rows, err := result.RowsAffected()
+	err = scope.repo.WithTx(ctx, func(repo repository.TODORepository) error {
+		change := todoChange{preconditions: preconditions}
+		var events []*model.TODOEvent
+		seen := map[int64]bool{}
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			current, err := repo.Find(ctx, id)
+			var errNotFound *model.ErrNotFound
+			if errors.As(err, &errNotFound) {
+				if err := change.check(nil); err != nil {
+					return err
+				}
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := scope.canEdit(current); err != nil {
+				return err
+			}
+			if err := change.check(current); err != nil {
+				return err
+			}
+			events = append(events, &model.TODOEvent{TODOID: id, Action: model.TODOEventDelete, Before: current})
+		}
+		if err := repo.Delete(ctx, ids); err != nil {
+			return err
+		}
+		for _, event := range events {
+			if err := record(ctx, repo, event); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
+		logger.Println(ctx, err)
 		return err
 	}
-	if rows == 0 {
-		return &model.ErrNotFound{}
-	}
 	return nil
 }
@@ -0,0 +1,167 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/repository"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+// asUser returns a copy of ctx on behalf of a user, without which TODOService
+// refuses to work.
+func asUser(ctx context.Context) context.Context {
+	return service.ContextWithUser(ctx, &model.User{ID: 1, Username: "user"})
+}
+
+func TestIfMatch(t *testing.T) {
+	t.Parallel()
+
+	ctx := asUser(context.Background())
+	svc := service.NewTODOServiceWithRepository(repository.NewMemoryTODORepository())
+	todo, err := svc.CreateTODO(ctx, "subject", "")
+	if err != nil {
+		t.Fatal("failed to create todo, err =", err)
+	}
+	etag := todo.ETag()
+
+	cases := map[string]struct {
+		etags []string
+		fail  bool
+	}{
+		"No condition":    {},
+		"Current ETag":    {etags: []string{`"stale"`, etag}},
+		"Any ETag":        {etags: []string{"*"}},
+		"Stale ETag":      {etags: []string{`"stale"`}, fail: true},
+		"Weak comparison": {etags: []string{"W/" + etag}, fail: true},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := service.IfMatch(c.etags...)(todo)
+			var errPrecondition *model.ErrPreconditionFailed
+			if got := errors.As(err, &errPrecondition); got != c.fail {
+				t.Errorf("unexpected result, given = %v, expected failure = %t", err, c.fail)
+			}
+		})
+	}
+}
+
+func TestUpdateTODOIfMatch(t *testing.T) {
+	t.Parallel()
+
+	ctx := asUser(context.Background())
+	svc := service.NewTODOServiceWithRepository(repository.NewMemoryTODORepository())
+	todo, err := svc.CreateTODO(ctx, "subject", "")
+	if err != nil {
+		t.Fatal("failed to create todo, err =", err)
+	}
+	etag := todo.ETag()
+
+	// 最初の更新で ETag が変わるため、同じ ETag での 2 回目の更新は失敗する
+	updated, err := svc.UpdateTODO(ctx, todo.ID, "first", "", service.WithPrecondition(service.IfMatch(etag)))
+	if err != nil {
+		t.Fatal("failed to update todo, err =", err)
+	}
+	if updated.ETag() == etag {
+		t.Error("ETag did not change on update")
+	}
+	var errPrecondition *model.ErrPreconditionFailed
+	if _, err := svc.UpdateTODO(ctx, todo.ID, "second", "", service.WithPrecondition(service.IfMatch(etag))); !errors.As(err, &errPrecondition) {
+		t.Errorf("unexpected error of a stale update, given = %v", err)
+	}
+	if err := svc.DeleteTODO(ctx, []int64{todo.ID}, service.IfMatch(etag)); !errors.As(err, &errPrecondition) {
+		t.Errorf("unexpected error of a stale delete, given = %v", err)
+	}
+
+	current, err := svc.ReadTODOByID(ctx, todo.ID)
+	if err != nil {
+		t.Fatal("failed to read todo, err =", err)
+	}
+	if current.Subject != "first" {
+		t.Errorf("stale update was applied, subject = %s", current.Subject)
+	}
+	if err := svc.DeleteTODO(ctx, []int64{todo.ID}, service.IfMatch(current.ETag())); err != nil {
+		t.Error("failed to delete todo, err =", err)
+	}
+}
+
+func TestIfMatchMissingTODO(t *testing.T) {
+	t.Parallel()
+
+	ctx := asUser(context.Background())
+	svc := service.NewTODOServiceWithRepository(repository.NewMemoryTODORepository())
+	const missing = 100
+
+	// 条件付きの書き込みは、TODO がなければ 404 ではなく 412 で失敗する
+	var errPrecondition *model.ErrPreconditionFailed
+	if _, err := svc.UpdateTODO(ctx, missing, "subject", "", service.WithPrecondition(service.IfMatch("*"))); !errors.As(err, &errPrecondition) {
+		t.Errorf("unexpected error of a conditional update, given = %v", err)
+	}
+	if _, err := svc.PatchTODO(ctx, missing, service.WithPrecondition(service.IfMatch(`"etag"`))); !errors.As(err, &errPrecondition) {
+		t.Errorf("unexpected error of a conditional patch, given = %v", err)
+	}
+	if err := svc.DeleteTODO(ctx, []int64{missing}, service.IfMatch("*")); !errors.As(err, &errPrecondition) {
+		t.Errorf("unexpected error of a conditional delete, given = %v", err)
+	}
+
+	var errNotFound *model.ErrNotFound
+	if _, err := svc.UpdateTODO(ctx, missing, "subject", ""); !errors.As(err, &errNotFound) {
+		t.Errorf("unexpected error of an update, given = %v", err)
+	}
+	if err := svc.DeleteTODO(ctx, []int64{missing}); !errors.As(err, &errNotFound) {
+		t.Errorf("unexpected error of a delete, given = %v", err)
+	}
+}
+
+func TestUpdateTODOStatus(t *testing.T) {
+	t.Parallel()
+
+	ctx := asUser(context.Background())
+	svc := service.NewTODOServiceWithRepository(repository.NewMemoryTODORepository())
+	todo, err := svc.CreateTODO(ctx, "subject", "")
+	if err != nil {
+		t.Fatal("failed to create todo, err =", err)
+	}
+	if todo.Status != model.TODOStatusOpen || todo.CompletedAt != nil {
+		t.Fatalf("unexpected new todo, given = %+v", todo)
+	}
+
+	done, err := svc.UpdateTODOStatus(ctx, todo.ID, model.TODOStatusDone)
+	if err != nil {
+		t.Fatal("failed to complete todo, err =", err)
+	}
+	if done.Status != model.TODOStatusDone || done.CompletedAt == nil {
+		t.Errorf("unexpected done todo, given = %+v", done)
+	}
+
+	// 完了した TODO は作業中に戻せず、開き直すと完了時刻が消える
+	var errTransition *model.ErrInvalidStatusTransition
+	if _, err := svc.UpdateTODOStatus(ctx, todo.ID, model.TODOStatusInProgress); !errors.As(err, &errTransition) {
+		t.Errorf("unexpected error moving a done todo to in_progress, given = %v", err)
+	}
+	reopened, err := svc.UpdateTODOStatus(ctx, todo.ID, model.TODOStatusOpen)
+	if err != nil {
+		t.Fatal("failed to reopen todo, err =", err)
+	}
+	if reopened.Status != model.TODOStatusOpen || reopened.CompletedAt != nil {
+		t.Errorf("unexpected reopened todo, given = %+v", reopened)
+	}
+
+	cancelled, err := svc.UpdateTODOStatus(ctx, todo.ID, model.TODOStatusCancelled)
+	if err != nil {
+		t.Fatal("failed to cancel todo, err =", err)
+	}
+	if cancelled.CompletedAt != nil {
+		t.Errorf("cancelled todo has a completion time, given = %+v", cancelled)
+	}
+	var errNotFound *model.ErrNotFound
+	if _, err := svc.UpdateTODOStatus(ctx, todo.ID+1, model.TODOStatusDone); !errors.As(err, &errNotFound) {
+		t.Errorf("unexpected error of a missing todo, given = %v", err)
+	}
+}
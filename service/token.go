@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/logger"
+	"github.com/TechBowl-japan/go-stations/model"
+)
+
+// Lifetimes of the JWTs issued by TokenService. Access tokens cannot be
+// revoked, so they are short-lived and renewed with refresh tokens.
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// Audiences of the JWTs issued by TokenService, which keep refresh tokens
+// from being accepted as access tokens and vice versa.
+const (
+	AccessTokenAudience  = "todo-api"
+	RefreshTokenAudience = "todo-api/auth/token"
+)
+
+// jwtIssuer is the iss claim of the JWTs issued by TokenService.
+const jwtIssuer = "go-stations"
+
+// A TokenService issues and verifies JWTs for stateless authentication.
+type TokenService struct {
+	users *UserService
+	keys  *KeySet
+}
+
+// NewTokenService returns new TokenService authenticating the users of
+// users and signing with keys.
+func NewTokenService(users *UserService, keys *KeySet) *TokenService {
+	return &TokenService{
+		users: users,
+		keys:  keys,
+	}
+}
+
+// JWKS returns the public keys verifying the JWTs.
+func (s *TokenService) JWKS() *model.JWKS {
+	return s.keys.JWKS()
+}
+
+// IssueTokens returns new tokens of the user with username and password.
+// They are granted scopes, or every scope when scopes is empty.
+func (s *TokenService) IssueTokens(ctx context.Context, username, password string, scopes []string) (*model.TokenPair, error) {
+	user, err := s.users.checkPassword(ctx, username, password)
+	if err != nil {
+		return &model.TokenPair{}, err
+	}
+	if len(scopes) == 0 {
+		scopes = model.Scopes
+	}
+	return s.issue(ctx, user, uniqueStrings(scopes))
+}
+
+// RefreshTokens returns new tokens in exchange for refreshToken. They are
+// granted scopes, which must be some of those of refreshToken, or the same
+// scopes as refreshToken when scopes is empty.
+func (s *TokenService) RefreshTokens(ctx context.Context, refreshToken string, scopes []string) (*model.TokenPair, error) {
+	claims, err := s.verify(refreshToken, RefreshTokenAudience)
+	if err != nil {
+		return &model.TokenPair{}, err
+	}
+	id, _ := strconv.ParseInt(claims.Subject, 10, 64)
+	user, err := s.users.repo.FindUserByID(ctx, id)
+	var errNotFound *model.ErrNotFound
+	if errors.As(err, &errNotFound) {
+		return &model.TokenPair{}, &model.ErrUnauthorized{Message: "user of token does not exist"}
+	}
+	if err != nil {
+		logger.Println(ctx, err)
+		return &model.TokenPair{}, err
+	}
+
+	granted := &model.Principal{Scopes: strings.Fields(claims.Scope)}
+	if len(scopes) == 0 {
+		scopes = granted.Scopes
+	}
+	for _, scope := range scopes {
+		if !granted.HasScope(scope) {
+			return &model.TokenPair{}, &model.ErrForbidden{Message: "refresh token lacks scope " + scope}
+		}
+	}
+	return s.issue(ctx, user, uniqueStrings(scopes))
+}
+
+// Principal returns the principal authenticated by the access token.
+// It fails with *model.ErrUnauthorized when token is invalid or expired.
+func (s *TokenService) Principal(ctx context.Context, token string) (*model.Principal, error) {
+	claims, err := s.verify(token, AccessTokenAudience)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := strconv.ParseInt(claims.Subject, 10, 64)
+	return &model.Principal{
+		User:   &model.User{ID: id, Username: claims.Username},
+		Scopes: strings.Fields(claims.Scope),
+	}, nil
+}
+
+func (s *TokenService) verify(token, audience string) (*model.Claims, error) {
+	claims, err := s.keys.Verify(token, audience, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if id, err := strconv.ParseInt(claims.Subject, 10, 64); claims.Issuer != jwtIssuer || err != nil || id <= 0 {
+		return nil, &model.ErrUnauthorized{Message: "token is not issued by this service"}
+	}
+	return claims, nil
+}
+
+func (s *TokenService) issue(ctx context.Context, user *model.User, scopes []string) (*model.TokenPair, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+	pair := &model.TokenPair{
+		ExpiresAt:        now.Add(AccessTokenTTL),
+		RefreshExpiresAt: now.Add(RefreshTokenTTL),
+		Scopes:           scopes,
+	}
+	for _, t := range []struct {
+		token     *string
+		audience  string
+		expiresAt time.Time
+	}{
+		{&pair.AccessToken, AccessTokenAudience, pair.ExpiresAt},
+		{&pair.RefreshToken, RefreshTokenAudience, pair.RefreshExpiresAt},
+	} {
+		jti := make([]byte, 16)
+		if _, err := rand.Read(jti); err != nil {
+			logger.Println(ctx, err)
+			return &model.TokenPair{}, err
+		}
+		token, err := s.keys.Sign(&model.Claims{
+			Issuer:    jwtIssuer,
+			Subject:   strconv.FormatInt(user.ID, 10),
+			Audience:  model.Audience{t.audience},
+			ExpiresAt: t.expiresAt.Unix(),
+			NotBefore: now.Unix(),
+			IssuedAt:  now.Unix(),
+			ID:        hex.EncodeToString(jti),
+			Username:  user.Username,
+			Scope:     strings.Join(scopes, " "),
+		})
+		if err != nil {
+			logger.Println(ctx, err)
+			return &model.TokenPair{}, err
+		}
+		*t.token = token
+	}
+	return pair, nil
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/logger"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/repository"
+)
+
+// ReadTrash reads TODOs in the trash on DB, newest first.
+func (s *TODOService) ReadTrash(ctx context.Context, prevID, size int64) (_ []*model.TODO, err error) {
+	ctx, done := s.observe(ctx, "ReadTrash")
+	defer func() { done(err) }()
+	scope, err := s.scoped(ctx)
+	var todos []*model.TODO
+	if err == nil {
+		todos, err = scope.repo.ListTrash(ctx, prevID, size)
+	}
+	if err != nil {
+		logger.Println(ctx, err)
+		return nil, err
+	}
+	return todos, nil
+}
+
+// RestoreTODO moves the TODO with id on DB back from the trash.
+func (s *TODOService) RestoreTODO(ctx context.Context, id int64) (_ *model.TODO, err error) {
+	ctx, done := s.observe(ctx, "RestoreTODO")
+	defer func() { done(err) }()
+	scope, err := s.scoped(ctx)
+	if err != nil {
+		logger.Println(ctx, err)
+		return &model.TODO{}, err
+	}
+	var todo *model.TODO
+	err = scope.repo.WithTx(ctx, func(repo repository.TODORepository) error {
+		var err error
+		if todo, err = repo.Restore(ctx, id); err != nil {
+			return err
+		}
+		// The TODO is only known once restored, so a forbidden restore is
+		// rolled back.
+		if err := scope.canEdit(todo); err != nil {
+			return err
+		}
+		return record(ctx, repo, &model.TODOEvent{TODOID: id, Action: model.TODOEventRestore, After: todo})
+	})
+	if err != nil {
+		logger.Println(ctx, err)
+		return &model.TODO{}, err
+	}
+	return todo, nil
+}
+
+// PurgeTrash permanently deletes TODOs of every user on DB which have been
+// in the trash longer than retention, together with their history, and returns how many
+// were deleted.
+func (s *TODOService) PurgeTrash(ctx context.Context, retention time.Duration) (_ int64, err error) {
+	ctx, done := s.observe(ctx, "PurgeTrash")
+	defer func() { done(err) }()
+	n, err := s.repo.Purge(ctx, time.Now().Add(-retention))
+	if err != nil {
+		logger.Println(ctx, err)
+		return 0, err
+	}
+	return n, nil
+}
+
+// PurgeTrashEvery calls PurgeTrash every interval, starting right away,
+// until ctx is done. Failures are logged and retried at the next interval.
+func (s *TODOService) PurgeTrashEvery(ctx context.Context, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := s.PurgeTrash(ctx, retention); err == nil && n > 0 {
+			logger.Printf(ctx, "purged %d todos from the trash", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/repository"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestPurgeTrashEvery(t *testing.T) {
+	t.Parallel()
+
+	ctx := asUser(context.Background())
+	svc := service.NewTODOServiceWithRepository(repository.NewMemoryTODORepository())
+	todo, err := svc.CreateTODO(ctx, "subject", "")
+	if err != nil {
+		t.Fatal("failed to create todo, err =", err)
+	}
+	if err := svc.DeleteTODO(ctx, []int64{todo.ID}); err != nil {
+		t.Fatal("failed to delete todo, err =", err)
+	}
+
+	if n, err := svc.PurgeTrash(ctx, time.Hour); err != nil || n != 0 {
+		t.Errorf("unexpected purge within retention, given = %d, err = %v", n, err)
+	}
+
+	// 負の保持期間では、削除直後の TODO も保持期間を過ぎたものとして扱われる
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		svc.PurgeTrashEvery(ctx, -time.Hour, 10*time.Millisecond)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		trash, err := svc.ReadTrash(ctx, 0, 10)
+		if err != nil {
+			t.Fatal("failed to read trash, err =", err)
+		}
+		if len(trash) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("trash was not purged")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Error("PurgeTrashEvery did not return after ctx was done")
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/TechBowl-japan/go-stations/logger"
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// SessionTTL is how long a session lasts after login.
+const SessionTTL = 24 * time.Hour
+
+// A UserService implements accounts and their login sessions.
+type UserService struct {
+	repo repository.UserRepository
+}
+
+// NewUserService returns new UserService storing users on db.
+func NewUserService(db *sql.DB) *UserService {
+	return NewUserServiceWithRepository(repository.NewSQLiteUserRepository(db))
+}
+
+// NewUserServiceWithRepository returns new UserService storing users on repo.
+func NewUserServiceWithRepository(repo repository.UserRepository) *UserService {
+	return &UserService{
+		repo: repo,
+	}
+}
+
+type userKey struct{}
+
+// ContextWithUser returns a copy of ctx in which TODOService works on behalf
+// of user: only the TODOs of user are read and written, and changes are
+// recorded as made by user.
+func ContextWithUser(ctx context.Context, user *model.User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFromContext returns the user of ctx, if any.
+func UserFromContext(ctx context.Context) (*model.User, bool) {
+	user, ok := ctx.Value(userKey{}).(*model.User)
+	return user, ok && user != nil
+}
+
+// dummyHash is compared against when there is no user to log in, so that
+// the response time does not tell whether the username exists.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// Register creates a user with password, which is stored as its bcrypt hash.
+func (s *UserService) Register(ctx context.Context, username, password string) (*model.User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		logger.Println(ctx, err)
+		return &model.User{}, err
+	}
+	user, err := s.repo.CreateUser(ctx, &model.User{Username: username, PasswordHash: string(hash)})
+	if err != nil {
+		logger.Println(ctx, err)
+		return &model.User{}, err
+	}
+	return user, nil
+}
+
+// Login starts a session of the user with username and password, which lasts
+// for SessionTTL. It fails with *model.ErrUnauthorized alike whether the user
+// does not exist or the password is wrong.
+func (s *UserService) Login(ctx context.Context, username, password string) (*model.Session, error) {
+	user, err := s.checkPassword(ctx, username, password)
+	if err != nil {
+		return &model.Session{}, err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		logger.Println(ctx, err)
+		return &model.Session{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	now := time.Now()
+	expiresAt := now.Add(SessionTTL).UTC().Truncate(time.Second)
+	// ログインのついでに期限切れのセッションを掃除する
+	if err := s.repo.DeleteExpiredSessions(ctx, now); err != nil {
+		logger.Println(ctx, err)
+		return &model.Session{}, err
+	}
+	if err := s.repo.CreateSession(ctx, hashToken(token), user.ID, expiresAt); err != nil {
+		logger.Println(ctx, err)
+		return &model.Session{}, err
+	}
+	return &model.Session{Token: token, ExpiresAt: expiresAt, User: user}, nil
+}
+
+// checkPassword returns the user with username and password. It fails with
+// *model.ErrUnauthorized alike whether the user does not exist or the
+// password is wrong.
+func (s *UserService) checkPassword(ctx context.Context, username, password string) (*model.User, error) {
+	errInvalid := &model.ErrUnauthorized{Message: "invalid username or password"}
+	user, err := s.repo.FindUserByName(ctx, username)
+	var errNotFound *model.ErrNotFound
+	if errors.As(err, &errNotFound) {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, errInvalid
+	}
+	if err != nil {
+		logger.Println(ctx, err)
+		return nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, errInvalid
+	}
+	return user, nil
+}
+
+// Authenticate returns the user of the session with token.
+// It fails with *model.ErrUnauthorized when the session is unknown or expired.
+func (s *UserService) Authenticate(ctx context.Context, token string) (*model.User, error) {
+	user, err := s.repo.FindSession(ctx, hashToken(token), time.Now())
+	var errNotFound *model.ErrNotFound
+	if errors.As(err, &errNotFound) {
+		return nil, &model.ErrUnauthorized{Message: "invalid or expired token"}
+	}
+	if err != nil {
+		logger.Println(ctx, err)
+		return nil, err
+	}
+	return user, nil
+}
+
+// Logout ends the session with token.
+func (s *UserService) Logout(ctx context.Context, token string) error {
+	if err := s.repo.DeleteSession(ctx, hashToken(token)); err != nil {
+		logger.Println(ctx, err)
+		return err
+	}
+	return nil
+}
+
+// hashToken returns the hash a token is stored as. Tokens are random enough
+// that a plain SHA-256 suffices, unlike passwords.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
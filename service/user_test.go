@@ -0,0 +1,219 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/model"
+	"github.com/TechBowl-japan/go-stations/repository"
+	"github.com/TechBowl-japan/go-stations/service"
+)
+
+func TestUserService(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	svc := service.NewUserServiceWithRepository(repository.NewMemoryUserRepository())
+	user, err := svc.Register(ctx, "alice", "correct horse")
+	if err != nil {
+		t.Fatal("failed to register, err =", err)
+	}
+	if user.PasswordHash == "" || user.PasswordHash == "correct horse" {
+		t.Errorf("unexpected password hash, given = %q", user.PasswordHash)
+	}
+	var errConflict *model.ErrConflict
+	if _, err := svc.Register(ctx, "Alice", "another password"); !errors.As(err, &errConflict) {
+		t.Errorf("unexpected error of a taken username, given = %v", err)
+	}
+
+	var errUnauthorized *model.ErrUnauthorized
+	for name, c := range map[string]struct{ username, password string }{
+		"Wrong password": {"alice", "wrong password"},
+		"Unknown user":   {"bob", "correct horse"},
+	} {
+		if _, err := svc.Login(ctx, c.username, c.password); !errors.As(err, &errUnauthorized) {
+			t.Errorf("%s: unexpected login error, given = %v", name, err)
+		}
+	}
+
+	session, err := svc.Login(ctx, "alice", "correct horse")
+	if err != nil {
+		t.Fatal("failed to login, err =", err)
+	}
+	got, err := svc.Authenticate(ctx, session.Token)
+	if err != nil {
+		t.Fatal("failed to authenticate, err =", err)
+	}
+	if got.ID != user.ID {
+		t.Errorf("unexpected user, given = %+v", got)
+	}
+	if _, err := svc.Authenticate(ctx, "unknown"); !errors.As(err, &errUnauthorized) {
+		t.Errorf("unexpected error of an unknown token, given = %v", err)
+	}
+	if err := svc.Logout(ctx, session.Token); err != nil {
+		t.Fatal("failed to logout, err =", err)
+	}
+	if _, err := svc.Authenticate(ctx, session.Token); !errors.As(err, &errUnauthorized) {
+		t.Errorf("unexpected error after logout, given = %v", err)
+	}
+}
+
+func TestTODOServiceScopedToUser(t *testing.T) {
+	t.Parallel()
+
+	svc := service.NewTODOServiceWithRepository(repository.NewMemoryTODORepository())
+	alice := service.ContextWithUser(context.Background(), &model.User{ID: 1, Username: "alice"})
+	bob := service.ContextWithUser(context.Background(), &model.User{ID: 2, Username: "bob"})
+	anonymous := context.Background()
+
+	todo, err := svc.CreateTODO(alice, "alice's", "")
+	if err != nil {
+		t.Fatal("failed to create todo, err =", err)
+	}
+	todos, err := svc.ReadTODO(bob, 0, 10)
+	if err != nil || len(todos) != 0 {
+		t.Errorf("read todos of another user, given = %d todos, err = %v", len(todos), err)
+	}
+	var errNotFound *model.ErrNotFound
+	if _, err := svc.UpdateTODO(bob, todo.ID, "stolen", ""); !errors.As(err, &errNotFound) {
+		t.Errorf("unexpected update error of another user, given = %v", err)
+	}
+	if err := svc.DeleteTODO(bob, []int64{todo.ID}); !errors.As(err, &errNotFound) {
+		t.Errorf("unexpected delete error of another user, given = %v", err)
+	}
+
+	// ユーザーのいないコンテキストでは、どの TODO も扱えない
+	var errUnauthorized *model.ErrUnauthorized
+	if _, err := svc.CreateTODO(anonymous, "anonymous", ""); !errors.As(err, &errUnauthorized) {
+		t.Errorf("unexpected create error without user, given = %v", err)
+	}
+	if _, err := svc.ReadTODO(anonymous, 0, 10); !errors.As(err, &errUnauthorized) {
+		t.Errorf("unexpected read error without user, given = %v", err)
+	}
+	if _, err := svc.UpdateTODO(anonymous, todo.ID, "stolen", ""); !errors.As(err, &errUnauthorized) {
+		t.Errorf("unexpected update error without user, given = %v", err)
+	}
+	if err := svc.DeleteTODO(anonymous, []int64{todo.ID}); !errors.As(err, &errUnauthorized) {
+		t.Errorf("unexpected delete error without user, given = %v", err)
+	}
+	if _, err := svc.RestoreTODO(anonymous, todo.ID); !errors.As(err, &errUnauthorized) {
+		t.Errorf("unexpected restore error without user, given = %v", err)
+	}
+	todos, err = svc.ReadTODO(alice, 0, 10)
+	if err != nil || len(todos) != 1 || todos[0].Subject != "alice's" {
+		t.Errorf("unexpected todos of the owner, given = %v, err = %v", todos, err)
+	}
+}
+
+func TestUserServiceAPITokens(t *testing.T) {
+	t.Parallel()
+
+	svc := service.NewUserServiceWithRepository(repository.NewMemoryUserRepository())
+	user, err := svc.Register(context.Background(), "alice", "correct horse")
+	if err != nil {
+		t.Fatal("failed to register, err =", err)
+	}
+	ctx := service.ContextWithUser(context.Background(), user)
+
+	var errUnauthorized *model.ErrUnauthorized
+	if _, _, err := svc.CreateAPIToken(context.Background(), "ci", []string{model.ScopeTODOsRead}); !errors.As(err, &errUnauthorized) {
+		t.Errorf("unexpected error creating a token anonymously, given = %v", err)
+	}
+	apiToken, token, err := svc.CreateAPIToken(ctx, "ci", []string{model.ScopeTODOsRead, model.ScopeTODOsRead})
+	if err != nil {
+		t.Fatal("failed to create api token, err =", err)
+	}
+	if !service.IsAPIToken(token) || !strings.Contains(token, apiToken.Prefix) || len(apiToken.Scopes) != 1 {
+		t.Errorf("unexpected api token, given = %q, %+v", token, apiToken)
+	}
+
+	principal, err := svc.Principal(ctx, token)
+	if err != nil {
+		t.Fatal("failed to authenticate api token, err =", err)
+	}
+	if principal.User.ID != user.ID || principal.APITokenID != apiToken.ID ||
+		!principal.HasScope(model.ScopeTODOsRead) || principal.HasScope(model.ScopeTODOsWrite) {
+		t.Errorf("unexpected principal, given = %+v", principal)
+	}
+	tokens, err := svc.ReadAPITokens(ctx)
+	if err != nil || len(tokens) != 1 || tokens[0].LastUsedAt == nil {
+		t.Errorf("unexpected api tokens, given = %v, err = %v", tokens, err)
+	}
+
+	for name, wrong := range map[string]string{
+		"Wrong secret": token + "x",
+		"Malformed":    service.APITokenPrefix + "xyz",
+	} {
+		if _, err := svc.Principal(ctx, wrong); !errors.As(err, &errUnauthorized) {
+			t.Errorf("%s: unexpected error, given = %v", name, err)
+		}
+	}
+
+	session, err := svc.Login(ctx, "alice", "correct horse")
+	if err != nil {
+		t.Fatal("failed to login, err =", err)
+	}
+	principal, err = svc.Principal(ctx, session.Token)
+	if err != nil || principal.APITokenID != 0 || !principal.HasScope(model.ScopeTODOsWrite) {
+		t.Errorf("unexpected principal of session, given = %+v, err = %v", principal, err)
+	}
+
+	if err := svc.RevokeAPIToken(ctx, apiToken.ID); err != nil {
+		t.Fatal("failed to revoke api token, err =", err)
+	}
+	if _, err := svc.Principal(ctx, token); !errors.As(err, &errUnauthorized) {
+		t.Errorf("unexpected error of a revoked token, given = %v", err)
+	}
+}
+
+// collidingRepository fails the first conflicts API tokens created as if
+// their prefix was taken.
+type collidingRepository struct {
+	repository.UserRepository
+	conflicts int
+	calls     int
+}
+
+func (r *collidingRepository) CreateAPIToken(ctx context.Context, token *model.APIToken) (*model.APIToken, error) {
+	r.calls++
+	if r.calls <= r.conflicts {
+		return nil, &model.ErrConflict{Message: "prefix is already taken"}
+	}
+	return r.UserRepository.CreateAPIToken(ctx, token)
+}
+
+func TestCreateAPITokenPrefixCollision(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		conflicts int
+		fails     bool
+	}{
+		"No collision":    {},
+		"Some collisions": {conflicts: 2},
+		"Every prefix":    {conflicts: 100, fails: true},
+	}
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			repo := &collidingRepository{UserRepository: repository.NewMemoryUserRepository(), conflicts: c.conflicts}
+			ctx := service.ContextWithUser(context.Background(), &model.User{ID: 1, Username: "alice"})
+			_, token, err := service.NewUserServiceWithRepository(repo).CreateAPIToken(ctx, "ci", nil)
+			var errConflict *model.ErrConflict
+			if c.fails {
+				// 生成をやり直し続けず、いずれ諦める
+				if !errors.As(err, &errConflict) || repo.calls >= c.conflicts {
+					t.Errorf("unexpected error, given = %v after %d attempts", err, repo.calls)
+				}
+				return
+			}
+			if err != nil || !service.IsAPIToken(token) || repo.calls != c.conflicts+1 {
+				t.Errorf("unexpected result, token = %q, err = %v after %d attempts", token, err, repo.calls)
+			}
+		})
+	}
+}
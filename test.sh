@@ -1,5 +1,5 @@
 #!/bin/bash
 go clean -testcache
 #sta5のテストは、テストロジックによって失敗するため実施しない。テストロジックが原因なのかGithubで確認中
-go test -v ./_test/sta1 ./_test/sta2 ./_test/sta3 ./_test/sta4 ./_test/sta5 ./_test/sta6 ./_test/sta7 ./_test/sta8 ./_test/sta9 ./_test/sta10 ./_test/sta11 ./_test/sta12 ./_test/sta13 ./_test/sta14 ./_test/sta15 ./_test/sta16 ./_test/sta17 ./_test/sta18 ./_test/sta19
-#go test -v ./_test/sta1 ./_test/sta2 ./_test/sta3 ./_test/sta4 ./_test/sta6 ./_test/sta7 ./_test/sta8 ./_test/sta9 ./_test/sta10 ./_test/sta11 ./_test/sta12 ./_test/sta13 ./_test/sta14 ./_test/sta15 ./_test/sta16 ./_test/sta17 ./_test/sta18 ./_test/sta19
\ No newline at end of file
+go test -tags sqlite_fts5 -v ./_test/sta1 ./_test/sta2 ./_test/sta3 ./_test/sta4 ./_test/sta5 ./_test/sta6 ./_test/sta7 ./_test/sta8 ./_test/sta9 ./_test/sta10 ./_test/sta11 ./_test/sta12 ./_test/sta13 ./_test/sta14 ./_test/sta15 ./_test/sta16 ./_test/sta17 ./_test/sta18 ./_test/sta19
+#go test -tags sqlite_fts5 -v ./_test/sta1 ./_test/sta2 ./_test/sta3 ./_test/sta4 ./_test/sta6 ./_test/sta7 ./_test/sta8 ./_test/sta9 ./_test/sta10 ./_test/sta11 ./_test/sta12 ./_test/sta13 ./_test/sta14 ./_test/sta15 ./_test/sta16 ./_test/sta17 ./_test/sta18 ./_test/sta19
\ No newline at end of file
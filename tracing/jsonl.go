@@ -0,0 +1,75 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// jsonSpan is a span as written by the JSONLinesExporter.
+type jsonSpan struct {
+	TraceID      string                 `json:"trace_id"`
+	SpanID       string                 `json:"span_id"`
+	ParentSpanID string                 `json:"parent_span_id,omitempty"`
+	Name         string                 `json:"name"`
+	Kind         string                 `json:"kind"`
+	Start        time.Time              `json:"start"`
+	End          time.Time              `json:"end"`
+	Duration     float64                `json:"duration_ms"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+}
+
+// A JSONLinesExporter writes every span to a writer as a line of JSON, e.g.
+// to a file read by jq or loaded into a log viewer:
+//
+//	{"trace_id":"4bf9...","span_id":"00f0...","name":"GET /todos","kind":"server",...,"duration_ms":12.3}
+type JSONLinesExporter struct {
+	w io.Writer
+}
+
+// NewJSONLinesExporter returns a new JSONLinesExporter writing to w. w is
+// not closed on shutdown.
+func NewJSONLinesExporter(w io.Writer) *JSONLinesExporter {
+	return &JSONLinesExporter{w: w}
+}
+
+// ExportSpans implements Exporter interface. The batch is written with a
+// single call, so that lines are not interleaved with other writers.
+func (e *JSONLinesExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, s := range spans {
+		js := jsonSpan{
+			TraceID:  s.SpanContext.TraceID.String(),
+			SpanID:   s.SpanContext.SpanID.String(),
+			Name:     s.Name,
+			Kind:     s.Kind.String(),
+			Start:    s.Start,
+			End:      s.End,
+			Duration: float64(s.End.Sub(s.Start)) / float64(time.Millisecond),
+			Error:    s.Error,
+		}
+		if s.ParentSpanID.IsValid() {
+			js.ParentSpanID = s.ParentSpanID.String()
+		}
+		if len(s.Attributes) > 0 {
+			js.Attributes = make(map[string]interface{}, len(s.Attributes))
+			for _, a := range s.Attributes {
+				js.Attributes[a.Key] = a.Value
+			}
+		}
+		if err := enc.Encode(js); err != nil {
+			return err
+		}
+	}
+	_, err := e.w.Write(buf.Bytes())
+	return err
+}
+
+// Shutdown implements Exporter interface.
+func (e *JSONLinesExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,216 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultServiceName is the service.name of the spans exported by an
+// OTLPExporter.
+const DefaultServiceName = "go-stations"
+
+// instrumentationScope is the name of the instrumentation scope of the
+// spans exported by an OTLPExporter.
+const instrumentationScope = "github.com/TechBowl-japan/go-stations/tracing"
+
+// An OTLPOption configures an OTLPExporter.
+type OTLPOption func(*OTLPExporter)
+
+// WithServiceName replaces DefaultServiceName.
+func WithServiceName(name string) OTLPOption {
+	return func(e *OTLPExporter) {
+		e.serviceName = name
+	}
+}
+
+// WithHeaders sets headers of every export request, e.g. for the
+// credentials of a collector.
+func WithHeaders(h map[string]string) OTLPOption {
+	return func(e *OTLPExporter) {
+		for k, v := range h {
+			e.headers.Set(k, v)
+		}
+	}
+}
+
+// WithHTTPClient replaces the client sending export requests, which times
+// out after 10 seconds by default.
+func WithHTTPClient(c *http.Client) OTLPOption {
+	return func(e *OTLPExporter) {
+		e.client = c
+	}
+}
+
+// An OTLPExporter exports spans to an OpenTelemetry collector with
+// OTLP/HTTP, encoded in JSON.
+type OTLPExporter struct {
+	url         string
+	serviceName string
+	headers     http.Header
+	client      *http.Client
+}
+
+// NewOTLPExporter returns a new OTLPExporter posting spans to url, the
+// traces endpoint of a collector, e.g. "http://localhost:4318/v1/traces".
+func NewOTLPExporter(url string, opts ...OTLPOption) *OTLPExporter {
+	e := &OTLPExporter{
+		url:         url,
+		serviceName: DefaultServiceName,
+		headers:     http.Header{},
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// ExportSpans implements Exporter interface.
+func (e *OTLPExporter) ExportSpans(ctx context.Context, spans []SpanData) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range e.headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// the body is read so that the connection is reused
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector responded %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Shutdown implements Exporter interface.
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// The messages of OTLP/HTTP in the JSON encoding of protobuf, where IDs are
+// hex and 64 bit integers are strings.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		TraceState        string         `json:"traceState,omitempty"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            *otlpStatus    `json:"status,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpAnyValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+	}
+	otlpStatus struct {
+		Message string `json:"message,omitempty"`
+		Code    int    `json:"code"`
+	}
+)
+
+// Span kinds and status codes of OTLP.
+const (
+	otlpSpanKindInternal = 1
+	otlpSpanKindServer   = 2
+	otlpSpanKindClient   = 3
+	otlpStatusCodeError  = 2
+)
+
+func (e *OTLPExporter) request(spans []SpanData) *otlpRequest {
+	out := make([]otlpSpan, len(spans))
+	for i, s := range spans {
+		o := otlpSpan{
+			TraceID:           s.SpanContext.TraceID.String(),
+			SpanID:            s.SpanContext.SpanID.String(),
+			TraceState:        s.SpanContext.TraceState,
+			Name:              s.Name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+		}
+		switch s.Kind {
+		case SpanKindServer:
+			o.Kind = otlpSpanKindServer
+		case SpanKindClient:
+			o.Kind = otlpSpanKindClient
+		}
+		if s.ParentSpanID.IsValid() {
+			o.ParentSpanID = s.ParentSpanID.String()
+		}
+		for _, a := range s.Attributes {
+			o.Attributes = append(o.Attributes, otlpAttribute(a))
+		}
+		if s.Error != "" {
+			o.Status = &otlpStatus{Code: otlpStatusCodeError, Message: s.Error}
+		}
+		out[i] = o
+	}
+	return &otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: []otlpKeyValue{otlpAttribute(String("service.name", e.serviceName))}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: instrumentationScope}, Spans: out}},
+	}}}
+}
+
+func otlpAttribute(a Attribute) otlpKeyValue {
+	kv := otlpKeyValue{Key: a.Key}
+	switch v := a.Value.(type) {
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		kv.Value.IntValue = &s
+	case float64:
+		kv.Value.DoubleValue = &v
+	case bool:
+		kv.Value.BoolValue = &v
+	case string:
+		kv.Value.StringValue = &v
+	default:
+		s := fmt.Sprint(v)
+		kv.Value.StringValue = &s
+	}
+	return kv
+}
@@ -0,0 +1,133 @@
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/tracing"
+)
+
+// collectedSpan is a span as a collector decodes it from OTLP/HTTP in JSON.
+type collectedSpan struct {
+	TraceID           string `json:"traceId"`
+	SpanID            string `json:"spanId"`
+	ParentSpanID      string `json:"parentSpanId"`
+	Name              string `json:"name"`
+	Kind              int    `json:"kind"`
+	StartTimeUnixNano string `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string `json:"endTimeUnixNano"`
+	Attributes        []struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	} `json:"attributes"`
+	Status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+}
+
+type collectedRequest struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []struct {
+				Key   string                 `json:"key"`
+				Value map[string]interface{} `json:"value"`
+			} `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []struct {
+			Spans []collectedSpan `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+func TestOTLPExporter(t *testing.T) {
+	t.Parallel()
+
+	// ローカルのコレクターの代わりに、受け取ったリクエストを記録する
+	requests := make(chan collectedRequest, 10)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		var req collectedRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests <- req
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	}))
+	t.Cleanup(collector.Close)
+
+	exporter := tracing.NewOTLPExporter(collector.URL+"/v1/traces",
+		tracing.WithServiceName("test"), tracing.WithHeaders(map[string]string{"Authorization": "Bearer secret"}))
+	tracer := tracing.NewTracer(exporter)
+	ctx, root := tracer.Start(context.Background(), "GET /todos", tracing.WithSpanKind(tracing.SpanKindServer))
+	_, child := tracing.Start(ctx, "sql.query", tracing.WithSpanKind(tracing.SpanKindClient),
+		tracing.WithAttributes(tracing.String("db.statement", "SELECT 1"), tracing.Int("db.rows", 1)))
+	child.SetError(errors.New("failed"))
+	child.End()
+	root.End()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal("failed to shut down, err =", err)
+	}
+
+	var req collectedRequest
+	select {
+	case req = <-requests:
+	default:
+		t.Fatal("the collector received nothing")
+	}
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected request, given = %+v", req)
+	}
+	if attrs := req.ResourceSpans[0].Resource.Attributes; len(attrs) != 1 || attrs[0].Key != "service.name" || attrs[0].Value["stringValue"] != "test" {
+		t.Errorf("unexpected resource, given = %+v", attrs)
+	}
+	spans := map[string]collectedSpan{}
+	for _, s := range req.ResourceSpans[0].ScopeSpans[0].Spans {
+		spans[s.Name] = s
+	}
+	r, c := spans["GET /todos"], spans["sql.query"]
+	if r.TraceID != root.SpanContext().TraceID.String() || r.SpanID != root.SpanContext().SpanID.String() || r.ParentSpanID != "" || r.Kind != 2 {
+		t.Errorf("unexpected root span, given = %+v", r)
+	}
+	if c.TraceID != r.TraceID || c.ParentSpanID != r.SpanID || c.Kind != 3 || c.Status.Code != 2 || c.Status.Message != "failed" {
+		t.Errorf("unexpected child span, given = %+v", c)
+	}
+	if c.StartTimeUnixNano == "" || c.EndTimeUnixNano < c.StartTimeUnixNano {
+		t.Errorf("unexpected times, given = %s..%s", c.StartTimeUnixNano, c.EndTimeUnixNano)
+	}
+	attrs := map[string]map[string]interface{}{}
+	for _, a := range c.Attributes {
+		attrs[a.Key] = a.Value
+	}
+	if attrs["db.statement"]["stringValue"] != "SELECT 1" || attrs["db.rows"]["intValue"] != "1" {
+		t.Errorf("unexpected attributes, given = %+v", attrs)
+	}
+}
+
+func TestOTLPExporterError(t *testing.T) {
+	t.Parallel()
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(collector.Close)
+
+	exporter := tracing.NewOTLPExporter(collector.URL + "/v1/traces")
+	err := exporter.ExportSpans(context.Background(), []tracing.SpanData{{Name: "span"}})
+	if err == nil {
+		t.Error("expected an error when the collector fails")
+	}
+}
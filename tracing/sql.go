@@ -0,0 +1,226 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+)
+
+// OpenDB returns a *sql.DB of the database at dsn opened with d, tracing
+// every statement, transaction and rows read, when it is run with a context
+// holding a span, in a child span of it. Statements run without span are
+// not traced, e.g. migrations.
+func OpenDB(d driver.Driver, dsn string) *sql.DB {
+	return sql.OpenDB(&connector{d: d, dsn: dsn})
+}
+
+type connector struct {
+	d   driver.Driver
+	dsn string
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.d.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlConn{Conn: conn}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.d
+}
+
+// startSQL starts the span of the operation op of the statement query.
+func startSQL(ctx context.Context, op, query string) (context.Context, *Span) {
+	attrs := []Attribute{String("db.system", "sqlite"), String("db.operation", op)}
+	if query != "" {
+		attrs = append(attrs, String("db.statement", query))
+	}
+	return Start(ctx, "sql."+op, WithSpanKind(SpanKindClient), WithAttributes(attrs...))
+}
+
+// endSQL ends span with err, which is not an error when it only tells
+// database/sql to fall back or that rows ran out.
+func endSQL(span *Span, err error) {
+	if err != driver.ErrSkip && err != io.EOF {
+		span.SetError(err)
+	}
+	span.End()
+}
+
+// A sqlConn traces the statements run on the Conn it wraps.
+type sqlConn struct {
+	driver.Conn
+}
+
+func (c *sqlConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, span := startSQL(ctx, "exec", query)
+	res, err := execer.ExecContext(ctx, query, args)
+	endSQL(span, err)
+	return res, err
+}
+
+func (c *sqlConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, span := startSQL(ctx, "query", query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		endSQL(span, err)
+		return nil, err
+	}
+	return traceRows(rows, span), nil
+}
+
+func (c *sqlConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sqlStmt{Stmt: stmt, query: query}, nil
+}
+
+func (c *sqlConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	// BEGIN IMMEDIATE waits for the write lock, which is worth seeing
+	spanCtx, span := startSQL(ctx, "begin", "")
+	var tx driver.Tx
+	var err error
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err = b.BeginTx(spanCtx, opts)
+	} else {
+		tx, err = c.Conn.Begin()
+	}
+	endSQL(span, err)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{Tx: tx, ctx: ctx}, nil
+}
+
+func (c *sqlConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// A sqlStmt traces the runs of the prepared Stmt it wraps.
+type sqlStmt struct {
+	driver.Stmt
+	query string
+}
+
+func (s *sqlStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	ctx, span := startSQL(ctx, "exec", s.query)
+	var res driver.Result
+	var err error
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = e.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			res, err = s.Stmt.Exec(values)
+		}
+	}
+	endSQL(span, err)
+	return res, err
+}
+
+func (s *sqlStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	ctx, span := startSQL(ctx, "query", s.query)
+	var rows driver.Rows
+	var err error
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValues(args); err == nil {
+			rows, err = s.Stmt.Query(values)
+		}
+	}
+	if err != nil {
+		endSQL(span, err)
+		return nil, err
+	}
+	return traceRows(rows, span), nil
+}
+
+// namedValues returns args as the positional values of drivers predating
+// contexts, which do not support names.
+func namedValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, driver.ErrSkip
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+// A sqlTx traces the end of the transaction it wraps, in the context the
+// transaction began with.
+type sqlTx struct {
+	driver.Tx
+	ctx context.Context
+}
+
+func (tx *sqlTx) Commit() error {
+	_, span := startSQL(tx.ctx, "commit", "")
+	err := tx.Tx.Commit()
+	endSQL(span, err)
+	return err
+}
+
+func (tx *sqlTx) Rollback() error {
+	_, span := startSQL(tx.ctx, "rollback", "")
+	err := tx.Tx.Rollback()
+	endSQL(span, err)
+	return err
+}
+
+// traceRows returns rows, ending span once they are closed so that the span
+// covers reading them, since SQLite runs queries as the rows are read.
+func traceRows(rows driver.Rows, span *Span) driver.Rows {
+	if span == nil {
+		return rows
+	}
+	return &sqlRows{Rows: rows, span: span}
+}
+
+type sqlRows struct {
+	driver.Rows
+	span  *Span
+	count int64
+}
+
+func (r *sqlRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err == nil {
+		r.count++
+	} else if err != io.EOF {
+		r.span.SetError(err)
+	}
+	return err
+}
+
+func (r *sqlRows) Close() error {
+	err := r.Rows.Close()
+	r.span.SetAttributes(Int("db.rows", r.count))
+	endSQL(r.span, err)
+	return err
+}
@@ -0,0 +1,78 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/tracing"
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestOpenDB(t *testing.T) {
+	t.Parallel()
+
+	db := tracing.OpenDB(&sqlite3.SQLiteDriver{}, filepath.Join(t.TempDir(), "tracing_test.db"))
+	t.Cleanup(func() { db.Close() })
+
+	// スパンのないコンテキストの SQL は記録しない
+	if _, err := db.Exec(`CREATE TABLE t (v INTEGER)`); err != nil {
+		t.Fatal("failed to create table, err =", err)
+	}
+
+	var buf bytes.Buffer
+	tracer := tracing.NewTracer(tracing.NewJSONLinesExporter(&buf))
+	ctx, root := tracer.Start(context.Background(), "root")
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal("failed to begin, err =", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO t (v) VALUES (?), (?)`, 1, 2); err != nil {
+		t.Fatal("failed to insert, err =", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal("failed to commit, err =", err)
+	}
+	rows, err := db.QueryContext(ctx, `SELECT v FROM t`)
+	if err != nil {
+		t.Fatal("failed to query, err =", err)
+	}
+	for rows.Next() {
+	}
+	rows.Close()
+	if _, err := db.ExecContext(ctx, `INSERT INTO missing (v) VALUES (1)`); err == nil {
+		t.Fatal("expected an error inserting into a missing table")
+	}
+	root.End()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal("failed to shut down, err =", err)
+	}
+
+	var names []string
+	spans := map[string][]spanLine{}
+	for _, s := range readLines(t, &buf) {
+		names = append(names, s.Name)
+		spans[s.Name] = append(spans[s.Name], s)
+	}
+	for name, n := range map[string]int{"sql.begin": 1, "sql.exec": 2, "sql.commit": 1, "sql.query": 1, "root": 1} {
+		if len(spans[name]) != n {
+			t.Errorf("unexpected number of %s spans, want = %d, given = %v", name, n, names)
+		}
+	}
+	rootID := spans["root"][0].SpanID
+	for name, ss := range spans {
+		for _, s := range ss {
+			if name != "root" && (s.ParentSpanID != rootID || s.Kind != "client") {
+				t.Errorf("unexpected span, given = %+v", s)
+			}
+		}
+	}
+	if q := spans["sql.query"][0]; q.Attributes["db.statement"] != "SELECT v FROM t" || q.Attributes["db.rows"] != float64(2) {
+		t.Errorf("unexpected query span, given = %+v", q)
+	}
+	// 失敗した SQL のスパンにはエラーが記録される
+	if e := spans["sql.exec"]; len(e) != 2 || e[0].Error != "" || e[1].Error == "" {
+		t.Errorf("unexpected exec spans, given = %+v", e)
+	}
+}
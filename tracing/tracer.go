@@ -0,0 +1,186 @@
+package tracing
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// An Exporter sends ended spans somewhere, e.g. to a file or a collector.
+// ExportSpans is only called from one goroutine at a time.
+type Exporter interface {
+	ExportSpans(ctx context.Context, spans []SpanData) error
+	// Shutdown flushes and releases what the Exporter holds. ExportSpans is
+	// not called after it.
+	Shutdown(ctx context.Context) error
+}
+
+// Batching of the spans exported by a Tracer.
+const (
+	// DefaultQueueSize is how many ended spans wait for export before new
+	// ones are dropped.
+	DefaultQueueSize = 2048
+	// DefaultBatchSize is how many spans are exported at once at most.
+	DefaultBatchSize = 512
+	// DefaultBatchTimeout is how long an ended span waits for its batch
+	// to fill at most.
+	DefaultBatchTimeout = 5 * time.Second
+	// exportTimeout is how long exporting a batch may take.
+	exportTimeout = 10 * time.Second
+)
+
+// A TracerOption configures a Tracer.
+type TracerOption func(*Tracer)
+
+// WithBatchTimeout replaces DefaultBatchTimeout, e.g. to export spans as
+// soon as they end in development.
+func WithBatchTimeout(d time.Duration) TracerOption {
+	return func(t *Tracer) {
+		t.batchTimeout = d
+	}
+}
+
+// A Tracer starts the root spans of traces, e.g. for requests served, and
+// exports their spans in batches from a goroutine of its own, so that
+// exporting does not slow requests down. Spans which end while the queue is
+// full are dropped.
+type Tracer struct {
+	exporter     Exporter
+	batchTimeout time.Duration
+
+	mu      sync.Mutex
+	queue   chan SpanData
+	closed  bool
+	dropped int
+	done    chan struct{}
+}
+
+// NewTracer returns a new Tracer exporting spans to exporter, configured by
+// opts. Shutdown must be called to export the last spans.
+func NewTracer(exporter Exporter, opts ...TracerOption) *Tracer {
+	t := &Tracer{
+		exporter:     exporter,
+		batchTimeout: DefaultBatchTimeout,
+		queue:        make(chan SpanData, DefaultQueueSize),
+		done:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	go t.run()
+	return t
+}
+
+// Start starts a span named name as a child of the span in ctx, or of the
+// remote parent in ctx, see Extract, or as the root of a new trace, returning
+// ctx with the new span in it. Children of remote parents are only sampled
+// when their parents are, and new traces always are.
+func (t *Tracer) Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	if parent := SpanFromContext(ctx); parent != nil {
+		return t.start(ctx, name, parent, parent.SpanContext(), opts)
+	}
+	remote, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return t.start(ctx, name, nil, remote, opts)
+}
+
+func (t *Tracer) start(ctx context.Context, name string, parent *Span, psc SpanContext, opts []StartOption) (context.Context, *Span) {
+	traceID, spanID := newIDs()
+	sc := SpanContext{TraceID: traceID, SpanID: spanID, Sampled: true}
+	var parentID SpanID
+	if psc.IsValid() {
+		sc.TraceID, sc.Sampled, sc.TraceState = psc.TraceID, psc.Sampled, psc.TraceState
+		parentID = psc.SpanID
+	}
+	span := &Span{tracer: t, parent: parent, data: SpanData{
+		Name:         name,
+		SpanContext:  sc,
+		ParentSpanID: parentID,
+		Start:        time.Now(),
+	}}
+	for _, opt := range opts {
+		opt(&span.data)
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+// enqueue queues span for export, dropping it when the queue is full or
+// t has been shut down.
+func (t *Tracer) enqueue(span SpanData) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	select {
+	case t.queue <- span:
+	default:
+		t.dropped++
+	}
+}
+
+// run exports the queued spans in batches until the queue is closed.
+func (t *Tracer) run() {
+	defer close(t.done)
+	timer := time.NewTimer(t.batchTimeout)
+	defer timer.Stop()
+	var batch []SpanData
+	for {
+		select {
+		case span, ok := <-t.queue:
+			if !ok {
+				t.export(batch)
+				return
+			}
+			batch = append(batch, span)
+			if len(batch) < DefaultBatchSize {
+				continue
+			}
+		case <-timer.C:
+		}
+		t.export(batch)
+		batch = nil
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(t.batchTimeout)
+	}
+}
+
+func (t *Tracer) export(batch []SpanData) {
+	t.mu.Lock()
+	dropped := t.dropped
+	t.dropped = 0
+	t.mu.Unlock()
+	if dropped > 0 {
+		log.Println("tracing: dropped", dropped, "spans since the export queue was full")
+	}
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+	if err := t.exporter.ExportSpans(ctx, batch); err != nil {
+		log.Println("tracing: failed to export", len(batch), "spans, err =", err)
+	}
+}
+
+// Shutdown exports the spans which have ended and shuts the exporter down.
+// Spans ending afterwards are dropped.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.queue)
+	}
+	t.mu.Unlock()
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return t.exporter.Shutdown(ctx)
+}
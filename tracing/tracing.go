@@ -0,0 +1,323 @@
+// Package tracing traces requests through the server in spans, propagated
+// to and from other services with the W3C Trace Context headers, and exports
+// them to an Exporter, e.g. a file or an OpenTelemetry collector.
+//
+// It implements the small part of OpenTelemetry the server needs, so that
+// the server does not depend on it. Spans are started with Start as children
+// of the span in the context, so that code which is not traced, e.g. with
+// a context without span, pays almost nothing for being instrumented.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A TraceID identifies a trace, every span of which has it.
+type TraceID [16]byte
+
+// String returns t in lowercase hex, as in traceparent.
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+// IsValid reports whether t is not all zeros.
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// A SpanID identifies a span within its trace.
+type SpanID [8]byte
+
+// String returns s in lowercase hex, as in traceparent.
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+// IsValid reports whether s is not all zeros.
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// A SpanContext is what identifies a span across services.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	// Sampled reports whether the span is recorded, as decided at the root
+	// of the trace.
+	Sampled bool
+	// TraceState is the tracestate header of the trace, passed on as it is.
+	TraceState string
+}
+
+// IsValid reports whether sc identifies a span.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent returns sc as the value of a traceparent header, e.g.
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ErrInvalidTraceparent is returned by ParseTraceparent for malformed values.
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// ParseTraceparent parses the value of a traceparent header. Values of later
+// versions are parsed as far as version 00 goes, as the specification asks.
+func ParseTraceparent(v string) (SpanContext, error) {
+	const size = len("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if len(v) < size || v[2] != '-' || v[35] != '-' || v[52] != '-' {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	var version [1]byte
+	if !decodeHex(version[:], v[:2]) || version[0] == 0xff || (version[0] == 0 && len(v) != size) ||
+		(len(v) > size && v[size] != '-') {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	var sc SpanContext
+	var flags [1]byte
+	if !decodeHex(sc.TraceID[:], v[3:35]) || !decodeHex(sc.SpanID[:], v[36:52]) || !decodeHex(flags[:], v[53:55]) ||
+		!sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, nil
+}
+
+// decodeHex decodes s, which must be lowercase hex, into dst.
+func decodeHex(dst []byte, s string) bool {
+	if strings.ToLower(s) != s {
+		return false
+	}
+	n, err := hex.Decode(dst, []byte(s))
+	return err == nil && n == len(dst)
+}
+
+// Header names of the W3C Trace Context.
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// Extract returns ctx with the span context in the traceparent and
+// tracestate headers of h as the remote parent of the spans started in it,
+// or ctx as it is when h has no valid traceparent.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	sc, err := ParseTraceparent(h.Get(TraceparentHeader))
+	if err != nil {
+		return ctx
+	}
+	sc.TraceState = strings.Join(h.Values(TracestateHeader), ",")
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// Inject sets the traceparent and tracestate headers of h to the span in
+// ctx, e.g. for requests to other services, when there is one.
+func Inject(ctx context.Context, h http.Header) {
+	sc := SpanFromContext(ctx).SpanContext()
+	if !sc.IsValid() {
+		return
+	}
+	h.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		h.Set(TracestateHeader, sc.TraceState)
+	} else {
+		h.Del(TracestateHeader)
+	}
+}
+
+type (
+	spanKey   struct{}
+	remoteKey struct{}
+)
+
+// ContextWithSpan returns ctx with span as the parent of the spans started
+// in it.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span in ctx, or nil when there is none.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// A SpanKind tells what a span stands for.
+type SpanKind int
+
+const (
+	// SpanKindInternal is an operation within the server.
+	SpanKindInternal SpanKind = iota
+	// SpanKindServer is a request served by the server.
+	SpanKindServer
+	// SpanKindClient is a request the server makes, e.g. to the database.
+	SpanKindClient
+)
+
+// String returns the name of k, e.g. "server".
+func (k SpanKind) String() string {
+	switch k {
+	case SpanKindServer:
+		return "server"
+	case SpanKindClient:
+		return "client"
+	}
+	return "internal"
+}
+
+// An Attribute is a key and a value describing a span. The value is a
+// string, int64, float64 or bool.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns an attribute with a string value.
+func String(key, value string) Attribute { return Attribute{Key: key, Value: value} }
+
+// Int returns an attribute with an integer value.
+func Int(key string, value int64) Attribute { return Attribute{Key: key, Value: value} }
+
+// Bool returns an attribute with a boolean value.
+func Bool(key string, value bool) Attribute { return Attribute{Key: key, Value: value} }
+
+// SpanData is a span as it is exported once it has ended.
+type SpanData struct {
+	Name         string
+	SpanContext  SpanContext
+	ParentSpanID SpanID
+	Kind         SpanKind
+	Start, End   time.Time
+	Attributes   []Attribute
+	// Error is the error the span ended with, or empty when it succeeded.
+	Error string
+}
+
+// A StartOption configures a span being started.
+type StartOption func(*SpanData)
+
+// WithSpanKind sets the kind of the span, SpanKindInternal by default.
+func WithSpanKind(kind SpanKind) StartOption {
+	return func(d *SpanData) {
+		d.Kind = kind
+	}
+}
+
+// WithAttributes sets attributes of the span.
+func WithAttributes(attrs ...Attribute) StartOption {
+	return func(d *SpanData) {
+		d.Attributes = append(d.Attributes, attrs...)
+	}
+}
+
+// A Span is an operation of a trace, timed from its start until End is
+// called. Spans which are not sampled are not recorded, but still pass their
+// span context on. Every method of a nil *Span does nothing, so that code
+// can be traced whether its context has a span or not. It is safe for
+// concurrent use.
+type Span struct {
+	tracer *Tracer
+	parent *Span
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// Start starts a span named name as a child of the span in ctx, returning
+// ctx with the new span in it. When ctx has no span, nothing is traced and
+// the span is nil.
+func Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	parent := SpanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	return parent.tracer.start(ctx, name, parent, parent.SpanContext(), opts)
+}
+
+// SpanContext returns the span context of s.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.SpanContext
+}
+
+// Parent returns the span s was started in, or nil when its parent is
+// remote or it is a root.
+func (s *Span) Parent() *Span {
+	if s == nil {
+		return nil
+	}
+	return s.parent
+}
+
+// SetName renames s, e.g. once the route of a request is known.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Name = name
+}
+
+// SetAttributes sets attributes of s.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Attributes = append(s.data.Attributes, attrs...)
+}
+
+// SetError marks s as failed with err, unless err is nil.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Error = err.Error()
+}
+
+// End ends s, exporting it when it is sampled. Only the first call has any
+// effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+	if data.SpanContext.Sampled {
+		s.tracer.enqueue(data)
+	}
+}
+
+// newIDs returns new random IDs, the trace ID of which is only used by roots.
+func newIDs() (TraceID, SpanID) {
+	var b [24]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand does not fail on the platforms the server runs on
+		panic(fmt.Sprintf("tracing: failed to generate IDs: %v", err))
+	}
+	var t TraceID
+	var s SpanID
+	copy(t[:], b[:16])
+	copy(s[:], b[16:])
+	return t, s
+}
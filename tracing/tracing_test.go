@@ -0,0 +1,164 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/TechBowl-japan/go-stations/tracing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	t.Parallel()
+
+	const valid = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	testcases := map[string]struct {
+		value   string
+		sampled bool
+		wantErr bool
+	}{
+		"Sampled":            {value: valid, sampled: true},
+		"NotSampled":         {value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+		"LaterVersion":       {value: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", sampled: true},
+		"Empty":              {value: "", wantErr: true},
+		"InvalidVersion":     {value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", wantErr: true},
+		"Version00WithExtra": {value: valid + "-extra", wantErr: true},
+		"Uppercase":          {value: strings.ToUpper(valid), wantErr: true},
+		"ZeroTraceID":        {value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", wantErr: true},
+		"ZeroSpanID":         {value: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", wantErr: true},
+		"NotHex":             {value: "00-4bf92f3577b34da6a3ce929d0e0e473g-00f067aa0ba902b7-01", wantErr: true},
+	}
+	for name, tc := range testcases {
+		name, tc := name, tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			sc, err := tracing.ParseTraceparent(tc.value)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, given = %+v", sc)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("failed to parse traceparent, err =", err)
+			}
+			if sc.Sampled != tc.sampled {
+				t.Errorf("unexpected sampled, want = %t, given = %t", tc.sampled, sc.Sampled)
+			}
+			if got := sc.TraceID.String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+				t.Errorf("unexpected trace id, given = %s", got)
+			}
+			if got := sc.SpanID.String(); got != "00f067aa0ba902b7" {
+				t.Errorf("unexpected span id, given = %s", got)
+			}
+		})
+	}
+
+	sc, _ := tracing.ParseTraceparent(valid)
+	if got := sc.Traceparent(); got != valid {
+		t.Errorf("unexpected traceparent, want = %s, given = %s", valid, got)
+	}
+}
+
+// spanLine is a line written by JSONLinesExporter.
+type spanLine struct {
+	TraceID      string                 `json:"trace_id"`
+	SpanID       string                 `json:"span_id"`
+	ParentSpanID string                 `json:"parent_span_id"`
+	Name         string                 `json:"name"`
+	Kind         string                 `json:"kind"`
+	Attributes   map[string]interface{} `json:"attributes"`
+	Error        string                 `json:"error"`
+}
+
+// readLines decodes the spans written to buf, in order.
+func readLines(t *testing.T, buf *bytes.Buffer) []spanLine {
+	t.Helper()
+	var spans []spanLine
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var s spanLine
+		if err := dec.Decode(&s); err != nil {
+			t.Fatal("failed to decode span, err =", err)
+		}
+		spans = append(spans, s)
+	}
+	return spans
+}
+
+func TestTracer(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	tracer := tracing.NewTracer(tracing.NewJSONLinesExporter(&buf))
+
+	h := http.Header{}
+	h.Set(tracing.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.Set(tracing.TracestateHeader, "vendor=value")
+	ctx, root := tracer.Start(tracing.Extract(context.Background(), h), "root", tracing.WithSpanKind(tracing.SpanKindServer))
+	childCtx, child := tracing.Start(ctx, "child", tracing.WithAttributes(tracing.Int("n", 1)))
+	child.SetError(errors.New("failed"))
+	child.End()
+	child.End()
+
+	out := http.Header{}
+	tracing.Inject(childCtx, out)
+	if want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + child.SpanContext().SpanID.String() + "-01"; out.Get(tracing.TraceparentHeader) != want {
+		t.Errorf("unexpected injected traceparent, want = %s, given = %s", want, out.Get(tracing.TraceparentHeader))
+	}
+	if got := out.Get(tracing.TracestateHeader); got != "vendor=value" {
+		t.Errorf("unexpected injected tracestate, given = %s", got)
+	}
+	root.End()
+
+	// スパンのないコンテキストでは何も記録しない
+	if _, span := tracing.Start(context.Background(), "untraced"); span != nil {
+		t.Error("started a span without parent")
+	}
+
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal("failed to shut down, err =", err)
+	}
+	spans := map[string]spanLine{}
+	for _, s := range readLines(t, &buf) {
+		spans[s.Name] = s
+	}
+	if len(spans) != 2 {
+		t.Fatalf("unexpected spans, given = %+v", spans)
+	}
+	if s := spans["root"]; s.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || s.ParentSpanID != "00f067aa0ba902b7" || s.Kind != "server" {
+		t.Errorf("unexpected root span, given = %+v", s)
+	}
+	if s := spans["child"]; s.ParentSpanID != spans["root"].SpanID || s.Error != "failed" || s.Attributes["n"] != float64(1) {
+		t.Errorf("unexpected child span, given = %+v", s)
+	}
+}
+
+func TestTracerNotSampled(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	tracer := tracing.NewTracer(tracing.NewJSONLinesExporter(&buf))
+
+	h := http.Header{}
+	h.Set(tracing.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	ctx, root := tracer.Start(tracing.Extract(context.Background(), h), "root")
+	_, child := tracing.Start(ctx, "child")
+	child.End()
+	root.End()
+
+	if got := child.SpanContext(); got.Sampled || got.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("unexpected span context of unsampled trace, given = %+v", got)
+	}
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal("failed to shut down, err =", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("exported spans of unsampled trace, given = %s", buf.String())
+	}
+}